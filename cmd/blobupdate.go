@@ -1,10 +1,10 @@
 package cmd
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/andreibanu/pusher/internal/blobrel"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/updates"
 )
 
@@ -35,7 +35,7 @@ func announceBlob(check *updates.BlobCheck, wait time.Duration, leadingBlank boo
 	}
 
 	if leadingBlank {
-		fmt.Println()
+		console.Println()
 	}
 
 	// The branch is named when it is not main, because a build from somebody's
@@ -46,8 +46,8 @@ func announceBlob(check *updates.BlobCheck, wait time.Duration, leadingBlank boo
 		from = " on " + blob.Branch
 	}
 
-	fmt.Printf("[*] blob %s is available%s; this project uses %s\n", blob.Latest, from, blob.Current)
-	fmt.Println("    Update it in `pusher settings` -> blob library.")
+	console.Printf("[*] blob %s is available%s; this project uses %s\n", blob.Latest, from, blob.Current)
+	console.Println("    Update it in `pusher settings` -> blob library.")
 
 	return true
 }
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/andreibanu/pusher/internal/adb"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheSerial  string
+	cacheKeep    int
+	cacheLimitMB int
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Show and trim the chunk cache on the robot",
+	Long: `Delta transfers keep the APK in pieces on the robot, under
+/data/local/tmp/pusher, so a push only sends what changed. These commands show
+what that cache holds and trim it.
+
+The chunks of the last few pushes are kept, and the cache is capped in size;
+set cache_keep and cache_limit_mb in the config file to change either. A robot
+short of space gives up the chunks used longest ago before a push is refused.
+
+  pusher cache status   usage and hit rate for every connected robot
+  pusher cache prune    trim to the policy now rather than after the next push
+  pusher cache clear    remove every chunk; the next push sends everything`,
+}
+
+var cacheStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show what each robot's cache holds and how much it saves",
+	Args:  cobra.NoArgs,
+	RunE:  runCacheStatus,
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Trim the cache to the policy now",
+	Args:  cobra.NoArgs,
+	RunE:  runCachePrune,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached chunk",
+	Args:  cobra.NoArgs,
+	RunE:  runCacheClear,
+}
+
+func init() {
+	cacheCmd.PersistentFlags().StringVar(&cacheSerial, "serial", "",
+		"Robot whose cache to use (default: the connected one)")
+	cachePruneCmd.Flags().IntVar(&cacheKeep, "keep", 0, "Keep the chunks of this many recent pushes (default: cache_keep)")
+	cachePruneCmd.Flags().IntVar(&cacheLimitMB, "max-size", -1, "Cap the cache at this many MB, 0 for no cap (default: cache_limit_mb)")
+
+	cacheCmd.AddCommand(cacheStatusCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
+
+// connectedRobots is who a report covers: the one asked for, or every robot
+// adb can see, since the point is often to compare them.
+func connectedRobots(serial string) ([]adb.Device, error) {
+	if serial != "" {
+		return []adb.Device{{Serial: serial, State: "device"}}, nil
+	}
+
+	devices, err := adb.Devices()
+	if err != nil {
+		return nil, err
+	}
+	var online []adb.Device
+	for _, dev := range devices {
+		if dev.IsOnline() {
+			online = append(online, dev)
+		}
+	}
+	if len(online) == 0 {
+		return nil, fmt.Errorf("no robot connected - plug in USB or run `pusher connect`")
+	}
+	return online, nil
+}
+
+// cacheRobot is the one robot prune and clear act on.
+func cacheRobot() (string, error) {
+	if cacheSerial != "" {
+		return cacheSerial, nil
+	}
+	return adb.Target()
+}
+
+func runCacheStatus(cmd *cobra.Command, args []string) error {
+	robots, err := connectedRobots(cacheSerial)
+	if err != nil {
+		return err
+	}
+
+	policy := adb.ConfiguredCachePolicy()
+	for i, dev := range robots {
+		if i > 0 {
+			console.Println()
+		}
+		console.Println(dev.Label())
+
+		usage, err := adb.CacheStatus(dev.Serial)
+		if err != nil {
+			console.Printf("  [!] %v\n", err)
+			emit("cache", cacheEvent{Serial: dev.Serial, Action: "status", Free: -1, Error: err.Error()})
+			continue
+		}
+
+		console.Printf("  %d chunks, %s, from the last %d of %d pushes kept\n",
+			usage.Chunks, megabytes(usage.Bytes), usage.Manifests, policy.Keep)
+		if policy.MaxBytes > 0 {
+			console.Printf("  capped at %s\n", megabytes(policy.MaxBytes))
+		}
+		if rate, ok := usage.HitRate(); ok {
+			console.Printf("  %.0f%% reused over %d pushes (%s reused, %s sent)\n",
+				rate*100, usage.Pushes, megabytes(usage.ReusedBytes), megabytes(usage.SentBytes))
+		} else {
+			console.Println("  no delta pushes recorded yet")
+		}
+		if usage.Free >= 0 {
+			console.Printf("  %s free on /data\n", megabytes(usage.Free))
+		}
+
+		rate, _ := usage.HitRate()
+		emit("cache", cacheEvent{
+			Serial: dev.Serial, Action: "status",
+			Chunks: usage.Chunks, Bytes: usage.Bytes, Manifests: usage.Manifests,
+			Pushes: usage.Pushes, SentBytes: usage.SentBytes, ReusedBytes: usage.ReusedBytes,
+			HitRate: rate, Free: usage.Free,
+		})
+	}
+	return nil
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) error {
+	serial, err := cacheRobot()
+	if err != nil {
+		return err
+	}
+
+	policy := adb.ConfiguredCachePolicy()
+	if cacheKeep > 0 {
+		policy.Keep = cacheKeep
+	}
+	if cacheLimitMB >= 0 {
+		policy.MaxBytes = int64(cacheLimitMB) << 20
+	}
+
+	result, err := adb.PruneCache(serial, policy)
+	if err != nil {
+		return err
+	}
+
+	if result.Removed == 0 {
+		console.Printf("[OK] Nothing to prune: %d chunks, %s\n", result.Kept, megabytes(result.KeptBytes))
+	} else {
+		console.Printf("[OK] Removed %d chunks (%s), kept %d (%s)\n",
+			result.Removed, megabytes(result.Freed), result.Kept, megabytes(result.KeptBytes))
+	}
+	emit("cache", cacheEvent{
+		Serial: serial, Action: "prune",
+		Chunks: result.Kept, Bytes: result.KeptBytes,
+		Removed: result.Removed, Freed: result.Freed, Free: -1,
+	})
+	return nil
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) error {
+	serial, err := cacheRobot()
+	if err != nil {
+		return err
+	}
+
+	usage, _ := adb.CacheStatus(serial)
+	if err := adb.ClearCache(serial); err != nil {
+		return err
+	}
+
+	console.Printf("[OK] Cleared %d chunks (%s). The next push sends the whole APK.\n",
+		usage.Chunks, megabytes(usage.Bytes))
+	emit("cache", cacheEvent{
+		Serial: serial, Action: "clear",
+		Removed: usage.Chunks, Freed: usage.Bytes, Free: -1,
+	})
+	return nil
+}
+
+// megabytes is a size for a person.
+func megabytes(n int64) string {
+	if n >= 1<<30 {
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
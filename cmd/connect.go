@@ -1,20 +1,52 @@
 package cmd
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/andreibanu/pusher/internal/adb"
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/wifi"
 	"github.com/spf13/cobra"
 )
 
+var (
+	connectPair    bool
+	connectCode    string
+	connectAddr    string
+	connectProfile string
+)
+
 var connectCmd = &cobra.Command{
 	Use:   "connect",
 	Short: "Join the robot's Wi-Fi and connect ADB",
-	Long:  `Joins the robot's Wi-Fi network and establishes an ADB connection, without building or deploying.`,
-	RunE:  runConnect,
+	Long: `Joins the robot's Wi-Fi network and establishes an ADB connection, without building or deploying.
+
+With --pair it pairs with a robot over wireless debugging instead, for a phone
+robot controller or any robot on Android 11 or later that is not at the Control
+Hub's fixed address. On the robot, open Developer options > Wireless debugging >
+Pair device with pairing code, and keep that screen up: pusher finds it on the
+network, asks for the code, and saves where the robot takes connections in a
+profile, so 'pusher' deploys to it from then on.`,
+	RunE: runConnect,
+}
+
+func init() {
+	connectCmd.Flags().BoolVar(&connectPair, "pair", false,
+		"Pair with a robot over wireless debugging")
+	connectCmd.Flags().StringVar(&connectCode, "code", "",
+		"Pairing code shown on the robot (default: ask)")
+	connectCmd.Flags().StringVar(&connectAddr, "addr", "",
+		"Address shown with the pairing code (default: find it over mDNS)")
+	connectCmd.Flags().StringVar(&connectProfile, "profile", "",
+		"Profile to save the paired robot to (default: \"wireless\")")
 }
 
 func runConnect(cmd *cobra.Command, args []string) error {
@@ -22,12 +54,22 @@ func runConnect(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("adb not found - please install Android SDK Platform-Tools")
 	}
 
+	if connectPair {
+		return runPair()
+	}
+
 	if device, ok := adb.FindUSBDevice(); ok {
-		fmt.Printf("[OK] Hub already attached over USB: %s\n", device.Label())
-		fmt.Println("[*] Run 'pusher' to build and deploy.")
+		console.Printf("[OK] Hub already attached over USB: %s\n", device.Label())
+		console.Println("[*] Run 'pusher' to build and deploy.")
 		return nil
 	}
 
+	// A robot paired on the network this computer is on has no Wi-Fi of its own
+	// to join.
+	if profile, err := config.GetDefaultProfile(); err == nil && profile.Paired() && profile.SSID == "" {
+		return connectADB()
+	}
+
 	wifiMgr := wifi.NewManager()
 
 	onRobot, err := wifiMgr.IsOnRobotNetwork()
@@ -36,7 +78,7 @@ func runConnect(cmd *cobra.Command, args []string) error {
 	}
 
 	if onRobot {
-		fmt.Println("[OK] Already on the robot network")
+		console.Println("[OK] Already on the robot network")
 	} else {
 		if err := ensureProfile(); err != nil {
 			return err
@@ -55,28 +97,167 @@ func runConnect(cmd *cobra.Command, args []string) error {
 		ssid, ssidErr := wifiMgr.CurrentSSID()
 		switch {
 		case ssidErr == nil && ssid != "":
-			fmt.Printf("[OK] Currently on: %s\n", ssid)
+			console.Printf("[OK] Currently on: %s\n", ssid)
 		case errors.Is(ssidErr, wifi.ErrSSIDUnavailable):
 			if inferred, err := wifiMgr.MostRecentNetwork(robotSSIDs()...); err == nil && inferred != "" {
-				fmt.Printf("[*] The network name is hidden; assuming you are on %q\n", inferred)
+				console.Printf("[*] The network name is hidden; assuming you are on %q\n", inferred)
 			}
 		}
 
-		fmt.Printf("\n[>] Joining robot Wi-Fi: %s\n", profile.SSID)
+		console.Printf("\n[>] Joining robot Wi-Fi: %s\n", profile.SSID)
 		ip, err := joinRobot(wifiMgr, watcher, profile)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("[OK] On the robot network (%s)\n", ip)
+		console.Printf("[OK] On the robot network (%s)\n", ip)
 	}
 
-	fmt.Println("\n[+] Connecting to robot via ADB...")
+	return connectADB()
+}
+
+func connectADB() error {
+	console.Println("\n[+] Connecting to robot via ADB...")
 	if err := adb.Connect(); err != nil {
 		return fmt.Errorf("failed to connect via ADB: %w", err)
 	}
 
-	fmt.Println("[OK] Connected via ADB")
-	fmt.Println("[*] Run 'pusher' to build and deploy, or 'pusher exit' when you're done.")
+	console.Println("[OK] Connected via ADB")
+	console.Println("[*] Run 'pusher' to build and deploy, or 'pusher exit' when you're done.")
 
 	return nil
 }
+
+// runPair pairs with a robot over wireless debugging, connects to it, and
+// saves it as the default profile.
+func runPair() error {
+	console.Println("[*] On the robot, open Developer options > Wireless debugging >")
+	console.Println("    Pair device with pairing code, and keep that screen up.")
+
+	reader := bufio.NewReader(os.Stdin)
+
+	addr := connectAddr
+	if addr == "" {
+		console.Println("\n[*] Looking for it on this network...")
+		offers, err := adb.Discover(adb.PairingService, 60*time.Second)
+		if err != nil {
+			return err
+		}
+		if len(offers) == 0 {
+			return fmt.Errorf("no robot is offering to pair on this network\n\n" +
+				"[!] Check this computer is on the same network as the robot, or pass the\n" +
+				"    address under the pairing code with --addr")
+		}
+
+		offer, err := chooseService(reader, offers)
+		if err != nil {
+			return err
+		}
+		addr = offer.Addr
+		console.Printf("[OK] Found %s at %s\n", offer.Name, addr)
+	}
+
+	code := connectCode
+	if code == "" {
+		console.Print("Pairing code: ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read the pairing code: %w", err)
+		}
+		code = strings.TrimSpace(line)
+	}
+
+	event := pairEvent{PairAddr: addr}
+	if err := adb.Pair(addr, code); err != nil {
+		event.Error = err.Error()
+		emit("pair", event)
+		return err
+	}
+	console.Println("[OK] Paired")
+
+	// The robot takes connections at another port, advertised separately by
+	// the same address. The wireless debugging screen shows it too.
+	var service adb.Service
+	console.Println("[*] Looking for where it takes connections...")
+	if found, err := adb.Discover(adb.ConnectService, 15*time.Second); err == nil {
+		for _, s := range found {
+			if s.Host() == pairHost(addr) {
+				service = s
+				break
+			}
+		}
+	}
+	if service.Addr == "" {
+		console.Print("IP address & Port shown on the Wireless debugging screen: ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read the address: %w", err)
+		}
+		service.Addr = strings.TrimSpace(line)
+	}
+	event.Addr, event.Service = service.Addr, service.Name
+
+	console.Println("\n[+] Connecting to robot via ADB...")
+	if err := adb.ConnectTo(service.Addr); err != nil {
+		event.Error = err.Error()
+		emit("pair", event)
+		return fmt.Errorf("paired, but failed to connect via ADB: %w", err)
+	}
+	console.Println("[OK] Connected via ADB")
+
+	name := connectProfile
+	if name == "" {
+		name = "wireless"
+	}
+	event.Robot = name
+	if err := config.SetPaired(name, service.Name, service.Addr); err != nil {
+		event.Error = err.Error()
+		emit("pair", event)
+		return fmt.Errorf("failed to save the profile: %w", err)
+	}
+	if err := config.SetDefaultProfile(name); err != nil {
+		event.Error = err.Error()
+		emit("pair", event)
+		return fmt.Errorf("failed to save the profile: %w", err)
+	}
+
+	event.OK = true
+	emit("pair", event)
+
+	console.Printf("[OK] Saved as profile '%s', now the default\n", name)
+	console.Println("[*] Run 'pusher' to build and deploy to it; 'pusher settings' picks another robot.")
+	return nil
+}
+
+// chooseService picks one of several robots offering to pair, asking when
+// there is more than one.
+func chooseService(reader *bufio.Reader, offers []adb.Service) (adb.Service, error) {
+	if len(offers) == 1 {
+		return offers[0], nil
+	}
+
+	console.Println("[*] More than one robot is offering to pair:")
+	for i, s := range offers {
+		console.Printf("    %d  %s at %s\n", i+1, s.Name, s.Addr)
+	}
+	console.Print("Which one? ")
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return adb.Service{}, fmt.Errorf("failed to read the choice: %w", err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(offers) {
+		return adb.Service{}, fmt.Errorf("%q is not one of the robots listed", strings.TrimSpace(line))
+	}
+	return offers[n-1], nil
+}
+
+// pairHost is the IP address of a pairing address, which the robot's
+// connection port shares.
+func pairHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -2,20 +2,30 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/dash"
 	"github.com/andreibanu/pusher/internal/gradle"
+	"github.com/andreibanu/pusher/internal/robotcfg"
 	"github.com/spf13/cobra"
 )
 
-var dashNoSave bool
+var (
+	dashNoSave bool
+	dashYes    bool
+	dashDryRun bool
+)
 
 var dashCmd = &cobra.Command{
 	Use:   "dash",
 	Short: "Compare the robot's tuning against your code",
 	Long: `Reads what FtcDashboard is currently holding and compares it with the
-@Config fields your source declares.
+@Config fields your source declares, and sets values on it.
 
 Tuning lives on the robot until it is written into the source, and the next
 deploy puts the code's values back. This says what you would lose.`,
@@ -35,9 +45,59 @@ Works over USB, by forwarding a port, and over Wi-Fi.`,
 	RunE: runDashDiff,
 }
 
+var dashApplyCmd = &cobra.Command{
+	Use:   "apply [Class.field...]",
+	Short: "Write the robot's tuning back into your source",
+	Long: `Reads FtcDashboard and rewrites the initialiser of every @Config field whose
+value on the robot differs from your source, so the next deploy keeps it.
+
+With names, only those fields are written. Only the value after the = changes:
+the rest of the declaration, the other fields declared beside it and the
+formatting stay as they are. Fields worked out in code, like Math.toRadians(3),
+are left alone and listed.
+
+Shows what it would change and asks first. Afterwards it records a reading, so
+the next 'pusher dash diff' reports these as saved.`,
+	RunE: runDashApply,
+}
+
+var dashSetCmd = &cobra.Command{
+	Use:   "set Class.field=value...",
+	Short: "Set tunables on the robot",
+	Long: `Sends values to FtcDashboard, exactly as editing them on its page would.
+
+Each value is checked against the type the dashboard reports for the field
+before anything is sent: an int does not take 1.5 and an enum only takes one of
+its constants. One bad value sends none of them.
+
+Values go to the robot only. The next deploy puts the code's values back, so
+use 'pusher dash apply' for anything worth keeping.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runDashSet,
+}
+
+var dashLoadCmd = &cobra.Command{
+	Use:   "load <file.json>",
+	Short: "Restore a set of tunables to the robot",
+	Long: `Sends every value in a file to FtcDashboard, so a known-good tuning set
+comes back after a deploy has reset it.
+
+The file is either a reading pusher recorded or an object of "Class.field" to
+value. Fields the robot no longer has are listed and skipped; a value of the
+wrong type sends nothing.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDashLoad,
+}
+
 func init() {
 	dashDiffCmd.Flags().BoolVar(&dashNoSave, "no-save", false, "Do not record this reading for the next comparison")
+	dashApplyCmd.Flags().BoolVarP(&dashYes, "yes", "y", false, "Write without asking")
+	dashSetCmd.Flags().BoolVar(&dashDryRun, "dry-run", false, "Show what would change without sending it")
+	dashLoadCmd.Flags().BoolVar(&dashDryRun, "dry-run", false, "Show what would change without sending it")
 	dashCmd.AddCommand(dashDiffCmd)
+	dashCmd.AddCommand(dashApplyCmd)
+	dashCmd.AddCommand(dashSetCmd)
+	dashCmd.AddCommand(dashLoadCmd)
 }
 
 func runDashDiff(cmd *cobra.Command, args []string) error {
@@ -52,7 +112,7 @@ func runDashDiff(cmd *cobra.Command, args []string) error {
 	}
 	project := gradle.ProjectDir(root)
 
-	fmt.Printf("[*] Reading the dashboard on %s\n", serial)
+	console.Printf("[*] Reading the dashboard on %s\n", serial)
 
 	live, err := dash.Read(serial)
 	if err != nil {
@@ -60,29 +120,271 @@ func runDashDiff(cmd *cobra.Command, args []string) error {
 	}
 
 	code := dash.FromProject(project)
-	fmt.Printf("[*] %d tunables on the robot, %d declared in %s\n",
+	console.Printf("[*] %d tunables on the robot, %d declared in %s\n",
 		len(live), len(code), project)
 
-	path := dash.SnapshotPath(config.Dir(), serial)
+	path := dash.SnapshotPath(config.Dir(), robotcfg.RobotID(serial))
 	previous, taken := dash.Load(path)
 
 	result := dash.Compare(live, code, previous)
 	result.Snapshot = taken
 
-	fmt.Print(result.Report())
+	console.Print(result.Report())
+	emit("diff", dashDiffEvent{Serial: serial, Project: project, Diff: result})
 
 	if previous == nil {
-		fmt.Println("\n    First reading for this robot, so nothing can be reported as")
-		fmt.Println("    already saved yet. Run this again after you tune something.")
+		console.Println("\n    First reading for this robot, so nothing can be reported as")
+		console.Println("    already saved yet. Run this again after you tune something.")
 	} else if !taken.IsZero() {
-		fmt.Printf("\n    Compared against the reading from %s.\n", taken.Format("2 Jan 15:04"))
+		console.Printf("\n    Compared against the reading from %s.\n", taken.Format("2 Jan 15:04"))
 	}
 
 	if !dashNoSave {
 		if err := dash.Save(path, live); err != nil {
-			fmt.Printf("\n[!] Could not record this reading: %v\n", err)
+			console.Printf("\n[!] Could not record this reading: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+func runDashApply(cmd *cobra.Command, args []string) error {
+	serial, err := dash.Robot()
+	if err != nil {
+		return err
+	}
+
+	root, err := gradle.DetectWrapper()
+	if err != nil {
+		return fmt.Errorf("run this from your FTC project: %w", err)
+	}
+	project := gradle.ProjectDir(root)
+
+	console.Printf("[*] Reading the dashboard on %s\n", serial)
+
+	live, err := dash.Read(serial)
+	if err != nil {
+		return err
+	}
+
+	code := dash.FromProject(project)
+	path := dash.SnapshotPath(config.Dir(), robotcfg.RobotID(serial))
+	previous, _ := dash.Load(path)
+
+	changes, err := chooseChanges(dash.Compare(live, code, previous), live, code, args)
+	if err != nil {
+		return err
+	}
+
+	edits, skipped := dash.Plan(changes, code)
+	for _, reason := range skipped {
+		console.Printf("[!] Skipping %s\n", reason)
+	}
+
+	if len(edits) == 0 {
+		console.Println("\n[=] Nothing to write: your source already has what the robot holds.")
+		emit("apply", dashApplyEvent{Serial: serial, Applied: []string{}, Skipped: skipped})
+		return nil
+	}
+
+	byFile := dash.ByFile(edits)
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	console.Println()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		shown := file
+		if rel, err := filepath.Rel(project, file); err == nil {
+			shown = rel
+		}
+
+		preview, err := dash.Preview(shown, string(content), byFile[file])
+		if err != nil {
+			return err
+		}
+		console.Print(preview)
+	}
+
+	what, where := fmt.Sprintf("%d values", len(edits)), fmt.Sprintf("%d files", len(files))
+	if len(edits) == 1 {
+		what = "this value"
+	}
+	if len(files) == 1 {
+		where = filepath.Base(files[0])
+	}
+
+	if !dashYes && !confirm(fmt.Sprintf("\nWrite %s into %s?", what, where)) {
+		console.Println("[=] Nothing written.")
+		return nil
+	}
+
+	if err := dash.Apply(edits); err != nil {
+		return fmt.Errorf("nothing was written: %w", err)
+	}
+
+	applied := make([]string, 0, len(edits))
+	for _, edit := range edits {
+		applied = append(applied, edit.Field.Key())
+	}
+	sort.Strings(applied)
+
+	console.Printf("[OK] Wrote %d values into %d files\n", len(edits), len(files))
+	emit("apply", dashApplyEvent{Serial: serial, Applied: applied, Files: files, Skipped: skipped})
+
+	if err := dash.Save(path, dash.Applied(live, edits)); err != nil {
+		console.Printf("[!] Could not record this reading: %v\n", err)
+	}
+
+	return nil
+}
+
+// chooseChanges picks the unsaved changes an apply writes: all of them, or the
+// ones named. A name that has nothing to write is an error rather than a quiet
+// no-op, because it is usually a typo and the person will assume it was saved.
+func chooseChanges(result dash.Diff, live dash.Values, code dash.Source, names []string) ([]dash.Change, error) {
+	if len(names) == 0 {
+		return result.Unsaved, nil
+	}
+
+	unsaved := map[string]dash.Change{}
+	for _, change := range result.Unsaved {
+		unsaved[change.Key] = change
+	}
+
+	var chosen []dash.Change
+	for _, name := range names {
+		if change, found := unsaved[name]; found {
+			chosen = append(chosen, change)
+			continue
+		}
+
+		_, onRobot := live[name]
+		field, inCode := code[name]
+		switch {
+		case !onRobot && !inCode:
+			return nil, fmt.Errorf("%s is neither on the robot nor in your source", name)
+		case !onRobot:
+			return nil, fmt.Errorf("%s is in your source but the dashboard does not hold it", name)
+		case !inCode:
+			return nil, fmt.Errorf("%s is on the robot but no @Config field in your source declares it", name)
+		case field.Computed:
+			chosen = append(chosen, dash.Change{Key: name, Code: field.Value, Live: live[name]})
+		default:
+			console.Printf("[=] %s already matches your source (%s)\n", name, field.Value)
+		}
+	}
+
+	return chosen, nil
+}
+
+func runDashSet(cmd *cobra.Command, args []string) error {
+	wanted, err := parseSettings(args)
+	if err != nil {
+		return err
+	}
+	return sendTuning(wanted, true)
+}
+
+func runDashLoad(cmd *cobra.Command, args []string) error {
+	wanted, err := dash.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	console.Printf("[*] %d values in %s\n", len(wanted), args[0])
+	return sendTuning(wanted, false)
+}
+
+// parseSettings reads Class.field=value arguments.
+func parseSettings(args []string) (dash.Values, error) {
+	wanted := dash.Values{}
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" || !strings.Contains(key, ".") {
+			return nil, fmt.Errorf("%q is not Class.field=value", arg)
+		}
+		wanted[key] = value
+	}
+	return wanted, nil
+}
+
+// sendTuning checks wanted against what the dashboard holds and sends what
+// differs. strict makes a field the robot does not hold an error rather than
+// a skip, which is right for a name someone typed and wrong for a file written
+// before a field was renamed.
+func sendTuning(wanted dash.Values, strict bool) error {
+	serial, err := dash.Robot()
+	if err != nil {
+		return err
+	}
+
+	console.Printf("[*] Reading the dashboard on %s\n", serial)
+
+	tree, err := dash.ReadTree(serial)
+	if err != nil {
+		return err
+	}
+
+	settings, unknown, err := dash.Check(tree, wanted)
+	if err != nil {
+		return err
+	}
+
+	if len(unknown) > 0 {
+		if strict {
+			return fmt.Errorf("the dashboard holds no %s", strings.Join(unknown, ", "))
+		}
+		for _, key := range unknown {
+			console.Printf("[!] Skipping %s: the dashboard does not hold it\n", key)
 		}
 	}
 
+	event := dashSetEvent{
+		Serial: serial, DryRun: dashDryRun, Changed: settings,
+		Unchanged: len(wanted) - len(settings) - len(unknown), Skipped: unknown,
+	}
+	if event.Changed == nil {
+		event.Changed = []dash.Setting{}
+	}
+
+	if len(settings) == 0 {
+		console.Println("\n[=] The robot already holds these values.")
+		emit("set", event)
+		return nil
+	}
+
+	width := 0
+	for _, setting := range settings {
+		if len(setting.Key) > width {
+			width = len(setting.Key)
+		}
+	}
+
+	console.Println()
+	for _, setting := range settings {
+		console.Printf("  %-*s   %s  ->  %s\n", width, setting.Key, setting.From, setting.To)
+	}
+
+	if dashDryRun {
+		console.Println("\n[=] Dry run: nothing was sent.")
+		emit("set", event)
+		return nil
+	}
+
+	if err := dash.Write(serial, settings); err != nil {
+		return err
+	}
+
+	console.Println("\n[OK] The robot took every change")
+	console.Println("    Until the next deploy. 'pusher dash apply' keeps them for good.")
+	emit("set", event)
 	return nil
 }
@@ -1,10 +1,10 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/dash"
+	"github.com/andreibanu/pusher/internal/robotcfg"
 )
 
 // A deploy puts the code's values back, whether it installs an APK or reloads
@@ -16,9 +16,12 @@ import (
 
 // dashWatch is a reading taken before a deploy.
 type dashWatch struct {
-	serial   string
+	robot    string
 	live     dash.Values
 	previous dash.Values
+	// kept is the snapshot the reading was also stored as, empty when it
+	// could not be.
+	kept string
 }
 
 // beginDashWatch reads the dashboard before a deploy, when that is turned on.
@@ -35,9 +38,19 @@ func beginDashWatch(serial string) *dashWatch {
 		return nil
 	}
 
-	previous, _ := dash.Load(dash.SnapshotPath(config.Dir(), serial))
+	// Kept under the hub's own serial number, since over Wi-Fi the adb one is
+	// the same address for every robot.
+	robot := robotcfg.RobotID(serial)
+	previous, _ := dash.Load(dash.SnapshotPath(config.Dir(), robot))
+
+	// Kept by name as well, because the rolling reading is replaced after the
+	// deploy and this is the last record of what the robot was running.
+	watch := &dashWatch{robot: robot, live: live, previous: previous}
+	if name, err := dash.KeepBeforeDeploy(config.Dir(), robot, live); err == nil {
+		watch.kept = name
+	}
 
-	return &dashWatch{serial: serial, live: live, previous: previous}
+	return watch
 }
 
 // report says what the deploy just overwrote.
@@ -50,13 +63,17 @@ func (w *dashWatch) report(projectRoot string) {
 	result := dash.Compare(w.live, code, w.previous)
 
 	if result.Any() {
-		fmt.Print(result.Report())
+		console.Print(result.Report())
+		if w.kept != "" && len(result.Unsaved) > 0 {
+			console.Printf("    They were kept as %s: 'pusher dash snapshot restore %s' puts them back.\n",
+				w.kept, w.kept)
+		}
 	} else if result.Untouched > 0 {
-		fmt.Printf("\n[=] Dashboard tuning: nothing to save, %d values matched your code.\n",
+		console.Printf("\n[=] Dashboard tuning: nothing to save, %d values matched your code.\n",
 			result.Untouched)
 	}
 
 	// Recorded after the report, so the next deploy can tell what moved since
 	// this one rather than comparing against something older.
-	_ = dash.Save(dash.SnapshotPath(config.Dir(), w.serial), w.live)
+	_ = dash.Save(dash.SnapshotPath(config.Dir(), w.robot), w.live)
 }
@@ -17,7 +17,8 @@ in ` + "`pusher settings` -> Deploy speed" + ` is worth on your hub.
 Do not run this if you are not sure why you want it. It reinstalls the robot
 controller app several times in a row and takes a few minutes.
 
-  Benchmark the deploy       time every deploy configuration against the
+  Benchmark the deploy       time the build with and without a warm daemon,
+                             then every deploy configuration against the
                              Android Studio equivalent
   Hot reload feasibility     time pushing and compiling a team-code-sized dex
                              on the hub, to see what a reload would have to beat
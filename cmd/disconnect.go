@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/andreibanu/pusher/internal/adb"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/spf13/cobra"
 )
 
@@ -16,7 +17,7 @@ var disconnectCmd = &cobra.Command{
 }
 
 func runDisconnect(cmd *cobra.Command, args []string) error {
-	fmt.Println("[+] Disconnecting ADB...")
+	console.Println("[+] Disconnecting ADB...")
 
 	if !adb.IsInstalled() {
 		return fmt.Errorf("adb not found")
@@ -26,6 +27,6 @@ func runDisconnect(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to disconnect: %w", err)
 	}
 
-	fmt.Println("[OK] ADB disconnected")
+	console.Println("[OK] ADB disconnected")
 	return nil
 }
@@ -2,13 +2,13 @@ package cmd
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"runtime"
 	"strings"
 
 	"github.com/andreibanu/pusher/internal/adb"
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/ftcproject"
 	"github.com/andreibanu/pusher/internal/gradle"
 	"github.com/andreibanu/pusher/internal/wifi"
@@ -26,28 +26,95 @@ Run this first when something is not behaving.`,
 	RunE: runDoctor,
 }
 
+// doctorReport is what `pusher doctor --output json` emits, as the data of one
+// "doctor" event. It holds the same findings the text does, field for field.
+type doctorReport struct {
+	Platform    string `json:"platform"`
+	WiFiBackend string `json:"wifi_backend"`
+
+	WiFi    doctorWiFi    `json:"wifi"`
+	RobotAP doctorRobotAP `json:"robot_wifi"`
+	ADB     doctorADB     `json:"adb"`
+	Project doctorProject `json:"project"`
+
+	// Problems is empty when the text would say no problems were detected.
+	Problems []string `json:"problems"`
+}
+
+type doctorWiFi struct {
+	PoweredOn bool   `json:"powered_on"`
+	IP        string `json:"ip,omitempty"`
+	OnRobot   bool   `json:"on_robot"`
+	SSID      string `json:"ssid,omitempty"`
+	// SSIDHidden means the OS would not name the network.
+	SSIDHidden bool   `json:"ssid_hidden"`
+	Inferred   string `json:"inferred,omitempty"`
+	Override   string `json:"override,omitempty"`
+	Saved      int    `json:"saved_networks"`
+	Error      string `json:"error,omitempty"`
+}
+
+type doctorRobotAP struct {
+	SSID string `json:"ssid,omitempty"`
+	// Broadcasting is "yes", "no", or "unknown" when pusher could not look.
+	Broadcasting string `json:"broadcasting"`
+	Error        string `json:"error,omitempty"`
+}
+
+type doctorADB struct {
+	Installed bool           `json:"installed"`
+	Devices   []doctorDevice `json:"devices"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type doctorDevice struct {
+	Serial    string   `json:"serial"`
+	State     string   `json:"state"`
+	Model     string   `json:"model,omitempty"`
+	Transport string   `json:"transport"`
+	ABIs      []string `json:"abis,omitempty"`
+}
+
+type doctorProject struct {
+	Wrapper      string   `json:"wrapper,omitempty"`
+	FTC          bool     `json:"ftc"`
+	Error        string   `json:"error,omitempty"`
+	ABIs         []string `json:"abis,omitempty"`
+	SlimBackups  bool     `json:"slim_backups"`
+	APKMegabytes float64  `json:"apk_mb,omitempty"`
+}
+
 func runDoctor(cmd *cobra.Command, args []string) error {
-	fmt.Println("Pusher doctor")
-	fmt.Println("═════════════════════════════════════════")
-	fmt.Printf("Platform: %s/%s (Wi-Fi via %s)\n", runtime.GOOS, runtime.GOARCH, wifiBackend())
-
-	locationOK := reportWiFi()
-	fmt.Println()
-	reportHubAP()
-	fmt.Println()
-	reportADB()
-	fmt.Println()
-	reportProject()
-
-	fmt.Println()
-	fmt.Println("═════════════════════════════════════════")
+	report := doctorReport{
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+		WiFiBackend: wifiBackend(),
+		Problems:    []string{},
+	}
+
+	console.Println("Pusher doctor")
+	console.Println("═════════════════════════════════════════")
+	console.Printf("Platform: %s (Wi-Fi via %s)\n", report.Platform, report.WiFiBackend)
+
+	locationOK := reportWiFi(&report.WiFi)
+	console.Println()
+	reportHubAP(&report.RobotAP)
+	console.Println()
+	reportADB(&report.ADB)
+	console.Println()
+	reportProject(&report.Project)
+
+	console.Println()
+	console.Println("═════════════════════════════════════════")
 	if !locationOK {
-		fmt.Println("[!] pusher cannot tell which network you are on, and has nothing")
-		fmt.Println("    to fall back on. Set one in 'pusher settings' -> Home Wi-Fi network.")
+		console.Println("[!] pusher cannot tell which network you are on, and has nothing")
+		console.Println("    to fall back on. Set one in 'pusher settings' -> Home Wi-Fi network.")
+		report.Problems = append(report.Problems,
+			"pusher cannot tell which network you are on, and has nothing to fall back on")
 	} else {
-		fmt.Println("[OK] No problems detected.")
+		console.Println("[OK] No problems detected.")
 	}
 
+	emit("doctor", report)
 	return nil
 }
 
@@ -64,60 +131,70 @@ func wifiBackend() string {
 	}
 }
 
-func reportWiFi() bool {
-	fmt.Println("\nWi-Fi")
-	fmt.Println("─────────────────────────────────────────")
+func reportWiFi(out *doctorWiFi) bool {
+	console.Println("\nWi-Fi")
+	console.Println("─────────────────────────────────────────")
 
 	wifiMgr := wifi.NewManager()
 
-	fmt.Printf("  Radio powered on   : %v\n", wifiMgr.IsPoweredOn())
+	out.PoweredOn = wifiMgr.IsPoweredOn()
+	console.Printf("  Radio powered on   : %v\n", out.PoweredOn)
 
 	ip, err := wifiMgr.GetIPv4()
+	out.IP = ip
 	switch {
 	case err != nil:
-		fmt.Printf("  IPv4 address       : error: %v\n", err)
+		console.Printf("  IPv4 address       : error: %v\n", err)
+		out.Error = err.Error()
 	case ip == "":
-		fmt.Println("  IPv4 address       : none (not connected)")
+		console.Println("  IPv4 address       : none (not connected)")
 	default:
-		fmt.Printf("  IPv4 address       : %s\n", ip)
+		console.Printf("  IPv4 address       : %s\n", ip)
 	}
 
 	onRobot, _ := wifiMgr.IsOnRobotNetwork()
-	fmt.Printf("  On robot network   : %v\n", onRobot)
+	out.OnRobot = onRobot
+	console.Printf("  On robot network   : %v\n", onRobot)
 
 	ssid, ssidErr := wifiMgr.CurrentSSID()
 	switch {
 	case errors.Is(ssidErr, wifi.ErrSSIDUnavailable):
-		fmt.Println("  Current network    : hidden by the OS")
+		console.Println("  Current network    : hidden by the OS")
+		out.SSIDHidden = true
 
 		inferred, _ := wifiMgr.MostRecentNetwork(robotSSIDs()...)
+		out.Inferred = inferred
 		if inferred != "" {
-			fmt.Printf("  Inferred as        : %s\n", inferred)
+			console.Printf("  Inferred as        : %s\n", inferred)
 		} else {
-			fmt.Println("  Inferred as        : could not tell")
+			console.Println("  Inferred as        : could not tell")
 		}
 
 		if saved := config.GetHomeSSID(); saved != "" {
-			fmt.Printf("  Overridden to      : %s (from settings)\n", saved)
+			console.Printf("  Overridden to      : %s (from settings)\n", saved)
+			out.Override = saved
 		}
 
-		fmt.Println()
+		console.Println()
 		for _, line := range strings.Split(wifi.LocationHint, "\n") {
-			fmt.Println("  " + line)
+			console.Println("  " + line)
 		}
 
 		return inferred != "" || config.GetHomeSSID() != ""
 	case ssidErr != nil:
-		fmt.Printf("  Current network    : error: %v\n", ssidErr)
+		console.Printf("  Current network    : error: %v\n", ssidErr)
+		out.Error = ssidErr.Error()
 		return false
 	case ssid == "":
-		fmt.Println("  Current network    : not associated")
+		console.Println("  Current network    : not associated")
 	default:
-		fmt.Printf("  Current network    : %s\n", ssid)
+		console.Printf("  Current network    : %s\n", ssid)
+		out.SSID = ssid
 	}
 
 	if networks, err := wifiMgr.PreferredNetworks(); err == nil {
-		fmt.Printf("  Saved networks     : %d\n", len(networks))
+		console.Printf("  Saved networks     : %d\n", len(networks))
+		out.Saved = len(networks)
 	}
 
 	return true
@@ -126,107 +203,129 @@ func reportWiFi() bool {
 // reportHubAP answers the question a failed join leaves open: was the hub even
 // broadcasting? Its own section because the Wi-Fi one gives up early on a macOS
 // that will not name the current network, which is every macOS since 15.
-func reportHubAP() {
-	fmt.Println("Robot Wi-Fi")
-	fmt.Println("─────────────────────────────────────────")
+func reportHubAP(out *doctorRobotAP) {
+	console.Println("Robot Wi-Fi")
+	console.Println("─────────────────────────────────────────")
+
+	out.Broadcasting = "unknown"
 
 	profile, err := config.GetDefaultProfile()
 	if err != nil || profile.SSID == "" {
-		fmt.Println("  Network            : none configured")
-		fmt.Println("  Add one with 'pusher settings' -> Robot profiles.")
+		console.Println("  Network            : none configured")
+		console.Println("  Add one with 'pusher settings' -> Robot profiles.")
 		return
 	}
 
-	fmt.Printf("  Network            : %s\n", profile.SSID)
+	console.Printf("  Network            : %s\n", profile.SSID)
+	out.SSID = profile.SSID
 
 	if !wifi.ScanningEnabled() {
-		fmt.Println("  Broadcasting       : cannot look")
-		fmt.Println()
+		console.Println("  Broadcasting       : cannot look")
+		console.Println()
 		for _, line := range strings.Split(wifi.ScanNote, "\n") {
-			fmt.Println("  " + line)
+			console.Println("  " + line)
 		}
 		return
 	}
 
 	// The label goes out before the scan, which takes a few seconds, so the
 	// pause reads as pusher working rather than pusher hanging.
-	fmt.Print("  Broadcasting       : ")
+	console.Print("  Broadcasting       : ")
 
 	switch present, err := wifi.NewManager().Visible(profile.SSID); {
 	case err != nil:
-		fmt.Printf("could not tell: %v\n", err)
+		console.Printf("could not tell: %v\n", err)
+		out.Error = err.Error()
 	case present:
-		fmt.Println("yes, in range now")
+		console.Println("yes, in range now")
+		out.Broadcasting = "yes"
 	default:
-		fmt.Println("no")
-		fmt.Println("  The hub is switched off, still starting up, or out of range.")
+		console.Println("no")
+		out.Broadcasting = "no"
+		console.Println("  The hub is switched off, still starting up, or out of range.")
 	}
 }
 
-func reportADB() {
-	fmt.Println("ADB")
-	fmt.Println("─────────────────────────────────────────")
+func reportADB(out *doctorADB) {
+	console.Println("ADB")
+	console.Println("─────────────────────────────────────────")
+
+	out.Devices = []doctorDevice{}
 
 	if !adb.IsInstalled() {
-		fmt.Println("  adb                : NOT FOUND")
-		fmt.Println("  Install Android SDK Platform-Tools, or: brew install android-platform-tools")
+		console.Println("  adb                : NOT FOUND")
+		console.Println("  Install Android SDK Platform-Tools, or: brew install android-platform-tools")
 		return
 	}
-	fmt.Println("  adb                : found")
+	console.Println("  adb                : found")
+	out.Installed = true
 
 	devices, err := adb.Devices()
 	if err != nil {
-		fmt.Printf("  Devices            : error: %v\n", err)
+		console.Printf("  Devices            : error: %v\n", err)
+		out.Error = err.Error()
 		return
 	}
 
 	if len(devices) == 0 {
-		fmt.Println("  Devices            : none attached")
+		console.Println("  Devices            : none attached")
 		return
 	}
 
 	for _, device := range devices {
-		fmt.Printf("  %-8s %-9s %s\n", device.Transport, device.State, device.Label())
-		if !device.IsOnline() {
-			continue
+		console.Printf("  %-8s %-9s %s\n", device.Transport, device.State, device.Label())
+
+		found := doctorDevice{
+			Serial: device.Serial, State: device.State,
+			Model: device.Model, Transport: string(device.Transport),
 		}
-		if abis, err := adb.ABIList(device.Serial); err == nil {
-			fmt.Printf("           ABIs      %s\n", strings.Join(abis, ", "))
+
+		if device.IsOnline() {
+			if abis, err := adb.ABIList(device.Serial); err == nil {
+				console.Printf("           ABIs      %s\n", strings.Join(abis, ", "))
+				found.ABIs = abis
+			}
 		}
+
+		out.Devices = append(out.Devices, found)
 	}
 }
 
-func reportProject() {
-	fmt.Println("Project")
-	fmt.Println("─────────────────────────────────────────")
+func reportProject(out *doctorProject) {
+	console.Println("Project")
+	console.Println("─────────────────────────────────────────")
 
 	wrapper, err := gradle.DetectWrapper()
 	if err != nil {
-		fmt.Println("  Gradle wrapper     : not found in this directory")
-		fmt.Println("  Run pusher from inside your FTC project.")
+		console.Println("  Gradle wrapper     : not found in this directory")
+		console.Println("  Run pusher from inside your FTC project.")
 		return
 	}
-	fmt.Printf("  Gradle wrapper     : %s\n", wrapper)
+	console.Printf("  Gradle wrapper     : %s\n", wrapper)
+	out.Wrapper = wrapper
 
 	root := gradle.ProjectDir(wrapper)
 
 	project, err := ftcproject.Detect(root)
 	if err != nil {
-		fmt.Printf("  FTC project        : %v\n", err)
+		console.Printf("  FTC project        : %v\n", err)
+		out.Error = err.Error()
 		return
 	}
+	out.FTC = true
 
 	if analysis, err := project.Analyze(); err == nil {
-		fmt.Printf("  Packaged ABIs      : %s\n", strings.Join(analysis.ABIs, ", "))
+		out.ABIs, out.SlimBackups = analysis.ABIs, analysis.HasBackups
+		console.Printf("  Packaged ABIs      : %s\n", strings.Join(analysis.ABIs, ", "))
 		if len(analysis.ABIs) > 1 {
-			fmt.Println("                       ^ the hub runs one of these; 'pusher slim' drops the rest")
+			console.Println("                       ^ the hub runs one of these; 'pusher slim' drops the rest")
 		}
-		fmt.Printf("  Slim backups       : %v\n", analysis.HasBackups)
+		console.Printf("  Slim backups       : %v\n", analysis.HasBackups)
 	}
 
 	apkPath, err := gradle.FindApk(root)
 	if err != nil {
-		fmt.Println("  Built APK          : none yet")
+		console.Println("  Built APK          : none yet")
 		return
 	}
 
@@ -234,5 +333,6 @@ func reportProject() {
 	if err != nil {
 		return
 	}
-	fmt.Printf("  Built APK          : %.1f MB\n", float64(info.Size())/(1024*1024))
+	out.APKMegabytes = float64(info.Size()) / (1024 * 1024)
+	console.Printf("  Built APK          : %.1f MB\n", out.APKMegabytes)
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/andreibanu/pusher/internal/adb"
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/wifi"
 	"github.com/spf13/cobra"
 )
@@ -18,12 +19,12 @@ var exitCmd = &cobra.Command{
 }
 
 func runExit(cmd *cobra.Command, args []string) error {
-	fmt.Println("[+] Disconnecting ADB...")
+	console.Println("[+] Disconnecting ADB...")
 	if adb.IsInstalled() {
 		if err := adb.Disconnect(); err != nil {
-			fmt.Printf("[!] Warning: failed to disconnect ADB: %v\n", err)
+			console.Printf("[!] Warning: failed to disconnect ADB: %v\n", err)
 		} else {
-			fmt.Println("[OK] ADB disconnected")
+			console.Println("[OK] ADB disconnected")
 		}
 	}
 
@@ -34,7 +35,7 @@ func runExit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to check the current network: %w", err)
 	}
 	if !onRobot {
-		fmt.Println("[OK] Not on the robot network, leaving Wi-Fi alone")
+		console.Println("[OK] Not on the robot network, leaving Wi-Fi alone")
 		return nil
 	}
 
@@ -43,35 +44,35 @@ func runExit(cmd *cobra.Command, args []string) error {
 
 		if inferred, err := wifiMgr.MostRecentNetwork(robotSSIDs()...); err == nil && inferred != "" {
 			home = inferred
-			fmt.Printf("\n[*] Assuming you came from %q\n", home)
+			console.Printf("\n[*] Assuming you came from %q\n", home)
 		}
 	}
 
 	if home == "" {
 
-		fmt.Println("\n[*] No home network known; cycling Wi-Fi so the system re-picks...")
+		console.Println("\n[*] No home network known; cycling Wi-Fi so the system re-picks...")
 		if err := wifiMgr.PowerCycle(); err != nil {
-			fmt.Printf("[!] Warning: failed to power-cycle Wi-Fi: %v\n", err)
-			fmt.Println("    You may need to switch networks manually.")
+			console.Printf("[!] Warning: failed to power-cycle Wi-Fi: %v\n", err)
+			console.Println("    You may need to switch networks manually.")
 			return nil
 		}
-		fmt.Println("[OK] Wi-Fi cycled. Your system should auto-join its usual network.")
-		fmt.Println("    Tip: set a home network in 'pusher settings' for a clean switch back.")
+		console.Println("[OK] Wi-Fi cycled. Your system should auto-join its usual network.")
+		console.Println("    Tip: set a home network in 'pusher settings' for a clean switch back.")
 		return nil
 	}
 
-	fmt.Printf("\n[<] Returning to %s...\n", home)
+	console.Printf("\n[<] Returning to %s...\n", home)
 	if err := wifiMgr.Join(home, ""); err != nil {
-		fmt.Printf("[!] Could not rejoin %s: %v\n", home, err)
-		fmt.Println("    You will need to switch back manually.")
+		console.Printf("[!] Could not rejoin %s: %v\n", home, err)
+		console.Println("    You will need to switch back manually.")
 		return nil
 	}
 
 	if _, err := wifiMgr.WaitForIP("", 30*time.Second); err != nil {
-		fmt.Printf("[!] Rejoined %s but no IP address yet: %v\n", home, err)
+		console.Printf("[!] Rejoined %s but no IP address yet: %v\n", home, err)
 		return nil
 	}
 
-	fmt.Printf("[OK] Back on %s\n", home)
+	console.Printf("[OK] Back on %s\n", home)
 	return nil
 }
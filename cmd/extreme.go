@@ -1,14 +1,42 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/extreme"
 	"github.com/andreibanu/pusher/internal/gradle"
+	"golang.org/x/term"
 )
 
+// whenIdle and stopOpMode are the --when-idle and --stop-opmode flags, which
+// say what a reload does about an OpMode that is running.
+var whenIdle, stopOpMode bool
+
+// reloadBusy is the policy those flags choose.
+//
+// Without either, a running OpMode is refused, after asking whether to stop it
+// if there is a person at a terminal to ask. A script has nobody to answer, and
+// a question it cannot see would hang it, so it gets the refusal outright.
+func reloadBusy() extreme.Busy {
+	busy := extreme.Busy{Progress: func(line string) { console.Printf("    %s\n", line) }}
+	switch {
+	case stopOpMode:
+		busy.When = extreme.StopFirst
+	case whenIdle:
+		busy.When = extreme.WaitForIdle
+	case jsonOut == nil && term.IsTerminal(int(os.Stdin.Fd())):
+		busy.Ask = func(opMode string) bool {
+			return confirm(fmt.Sprintf("\n[?] %s is running on the robot. Stop it and reload?", opMode))
+		}
+	}
+	return busy
+}
+
 // tryExtreme replaces the install with a reload when that is genuinely
 // equivalent, and says why when it is not.
 //
@@ -28,42 +56,66 @@ func tryExtreme(gradlePath, serial, apkPath string) (bool, error) {
 
 	state := extreme.Status(project.Root, serial, apkPath)
 	if !state.Usable() {
-		fmt.Printf("\n[*] Pusher Extreme is on, but installing this time: %s\n", state.Reason)
+		console.Printf("\n[*] Pusher Extreme is on, but installing this time: %s\n", state.Reason)
+		emit("decision", decisionEvent{Serial: serial, Mode: "install", Reason: state.Reason})
 		return false, nil
 	}
 
-	fmt.Println("\n[>] Pusher Extreme: reloading team code, not installing")
+	console.Println("\n[>] Pusher Extreme: reloading team code, not installing")
 
 	classpath, err := extreme.ResolveClasspath(project.Wrapper, extreme.Module)
 	if err != nil {
-		fmt.Printf("[!] Could not work out what to compile against: %v\n", err)
-		fmt.Println("[*] Installing instead.")
+		console.Printf("[!] Could not work out what to compile against: %v\n", err)
+		console.Println("[*] Installing instead.")
+		emit("decision", decisionEvent{Serial: serial, Mode: "install",
+			Reason: "could not work out what to compile against: " + err.Error()})
 		return false, nil
 	}
 
-	result, err := extreme.Reload(project, serial, classpath, extreme.Kept(project.Root))
+	emit("decision", decisionEvent{Serial: serial, Mode: "reload"})
+
+	result, err := extreme.Reload(project, serial, classpath, extreme.Kept(project.Root), reloadBusy())
 	for _, step := range result.Steps {
-		fmt.Printf("    %s\n", step)
+		console.Printf("    %s\n", step)
+	}
+	emitReload(serial, result, err)
+	if errors.Is(err, extreme.ErrOpModeRunning) {
+		// Not a failed reload, and installing instead would be worse: an
+		// install restarts the app, which stops the OpMode just as surely and
+		// with less warning.
+		return false, fmt.Errorf("%w\n    Stop it first, or push with --stop-opmode or --when-idle", err)
 	}
 	if err != nil {
 		// A failed reload leaves the robot with whatever it had, which may now
 		// be a directory the SDK cannot read. Installing puts it back to a
 		// state that certainly works.
-		fmt.Printf("\n[!] Reload failed: %v\n", err)
-		fmt.Println("[*] Falling back to a full install.")
+		console.Printf("\n[!] Reload failed: %v\n", err)
+		console.Println("[*] Falling back to a full install.")
+		emit("decision", decisionEvent{Serial: serial, Mode: "install", Reason: "the reload failed"})
 		return false, nil
 	}
 
 	for _, warning := range result.Warnings {
-		fmt.Printf("[!] %s\n", warning)
+		console.Printf("[!] %s\n", warning)
 	}
 
-	fmt.Printf("\n[OK] Reloaded %d classes in %.1fs, without installing\n",
+	stampRobot(serial, "reload", project.Root)
+
+	console.Printf("\n[OK] Reloaded %d classes in %.1fs, without installing\n",
 		result.Classes, result.Total.Seconds())
 
 	return true, nil
 }
 
+// emitReload reports a reload as an event.
+func emitReload(serial string, result *extreme.Result, err error) {
+	emit("reload", reloadEvent{
+		Serial: serial, Classes: result.Classes, Seconds: result.Total.Seconds(),
+		Warnings: result.Warnings, Busy: result.Busy, OpMode: result.OpMode,
+		OK: err == nil, Error: errText(err),
+	})
+}
+
 // apkCarriesTeamCode reports whether a build still packages the team's classes.
 //
 // Deliberately not a question about settings. The exclusion lives in the
@@ -123,9 +175,9 @@ func reloadAfterInstall(serial string) error {
 	}
 
 	if !config.GetExtreme() {
-		fmt.Println("\n[!] Pusher Extreme is turned off, but this project is still set up for it.")
-		fmt.Println("    The APK that was just installed carries no team code, so it has to be")
-		fmt.Println("    reloaded anyway. Undo the setup in `pusher settings` for ordinary APKs.")
+		console.Println("\n[!] Pusher Extreme is turned off, but this project is still set up for it.")
+		console.Println("    The APK that was just installed carries no team code, so it has to be")
+		console.Println("    reloaded anyway. Undo the setup in `pusher settings` for ordinary APKs.")
 	}
 
 	stranded := func(err error) error {
@@ -134,26 +186,29 @@ func reloadAfterInstall(serial string) error {
 			"    Run `pusher` again, or undo the setup in `pusher settings`", err)
 	}
 
-	fmt.Println("\n[>] Pusher Extreme: that APK has no team code in it, reloading it now")
+	console.Println("\n[>] Pusher Extreme: that APK has no team code in it, reloading it now")
 
 	classpath, err := extreme.ResolveClasspath(project.Wrapper, extreme.Module)
 	if err != nil {
 		return stranded(err)
 	}
 
-	result, err := extreme.Reload(project, serial, classpath, extreme.Kept(project.Root))
+	// The install has just restarted the app, so nothing is running.
+	result, err := extreme.Reload(project, serial, classpath, extreme.Kept(project.Root),
+		extreme.Busy{When: extreme.NoCheck})
 	for _, step := range result.Steps {
-		fmt.Printf("    %s\n", step)
+		console.Printf("    %s\n", step)
 	}
+	emitReload(serial, result, err)
 	if err != nil {
 		return stranded(err)
 	}
 
 	for _, warning := range result.Warnings {
-		fmt.Printf("[!] %s\n", warning)
+		console.Printf("[!] %s\n", warning)
 	}
 
-	fmt.Printf("[OK] Reloaded %d classes, so the robot has its OpModes\n", result.Classes)
+	console.Printf("[OK] Reloaded %d classes, so the robot has its OpModes\n", result.Classes)
 	return nil
 }
 
@@ -175,7 +230,7 @@ func extremeDeploy(gradlePath, serial string) (bool, error) {
 		return done, err
 	}
 
-	fmt.Printf("[OK] Deployed in %.1fs\n", time.Since(start).Seconds())
+	console.Printf("[OK] Deployed in %.1fs\n", time.Since(start).Seconds())
 	return true, nil
 }
 
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/adb"
+	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/wifi"
+)
+
+// pushRobots names the robots one push deploys to, in order. Set by --robots;
+// when it is empty the fleet from settings is used instead.
+var pushRobots []string
+
+// fleetTargets is the robots this push deploys to in turn, or nothing when it
+// is an ordinary push to the default profile.
+//
+// A fleet of one is still a fleet: `--robots practice` is how a push reaches a
+// robot that is not the default without changing the default.
+func fleetTargets() []string {
+	names := pushRobots
+	if len(names) == 0 {
+		names = config.GetFleet()
+	}
+
+	var targets []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		targets = append(targets, name)
+	}
+
+	return targets
+}
+
+// fleetResult is how one robot's turn went.
+type fleetResult struct {
+	name    string
+	ssid    string
+	err     error
+	elapsed time.Duration
+}
+
+// pushFleet builds once and deploys that build to every robot in turn.
+//
+// One robot failing does not stop the others. A robot that is switched off in
+// the pits is ordinary, and the rest of the fleet should not go without the
+// build because of it. The run still fails at the end when any robot did, so a
+// script cannot mistake a partial deploy for a whole one.
+//
+// USB is not consulted. A fleet is a list of Wi-Fi profiles, and a hub that
+// happens to be plugged in is at best one of them and at worst none.
+func pushFleet(gradlePath string, names []string) error {
+	if err := ensureProfile(); err != nil {
+		return err
+	}
+
+	// Every name is checked before anything is built, so a typo costs a second
+	// rather than a build and a trip onto the first robot's network.
+	profiles := make([]*config.Profile, 0, len(names))
+	for _, name := range names {
+		profile, err := config.GetProfile(name)
+		if err != nil {
+			return fmt.Errorf("%w\n\nRun 'pusher settings' -> Robot profiles to see the saved robots", err)
+		}
+		if profile.SSID == "" {
+			return fmt.Errorf("profile '%s' has no Wi-Fi network set", name)
+		}
+		if profile.Paired() {
+			return fmt.Errorf("profile '%s' is paired for wireless debugging, which fleet deploys do not reach; push to it on its own", name)
+		}
+		profiles = append(profiles, profile)
+	}
+
+	console.Printf("[*] Deploying to %d robots: %s\n", len(profiles), strings.Join(names, ", "))
+
+	wifiMgr := wifi.NewManager()
+
+	onRobot, err := wifiMgr.IsOnRobotNetwork()
+	if err != nil {
+		return fmt.Errorf("failed to check the current network: %w", err)
+	}
+
+	switchBack := config.GetSwitchBack()
+
+	// Only the first robot is looked for during the build. Once pusher is on a
+	// robot's network nothing else may touch the radio, so the later ones are
+	// joined without a scan.
+	var watcher *wifi.Watcher
+	if !onRobot {
+		watcher = wifiMgr.Watch(profiles[0].SSID)
+		defer watcher.Stop()
+	}
+
+	home, err := resolveHomeNetwork(wifiMgr, onRobot, switchBack, profiles[0].SSID)
+	if err != nil {
+		return err
+	}
+
+	if home != "" {
+		console.Printf("[OK] Currently on: %s\n", home)
+	}
+
+	slimmedFor := ""
+	if config.GetAutoSlim() {
+		slimmedFor = config.GetHubABI()
+		applyAutoSlim()
+	}
+
+	if err := buildProject(gradlePath); err != nil {
+		return err
+	}
+
+	results := make([]fleetResult, 0, len(profiles))
+	for i, profile := range profiles {
+		console.Printf("\n═══ Robot %d of %d: %s ═══\n", i+1, len(profiles), profile.Name)
+
+		start := time.Now()
+
+		w := watcher
+		if i > 0 {
+			w = nil
+		}
+
+		err := deployToFleetRobot(wifiMgr, w, profile, gradlePath, slimmedFor)
+		if err != nil {
+			console.Printf("\n[!] %s: %v\n", profile.Name, err)
+			if i < len(profiles)-1 {
+				console.Println("    Carrying on with the next robot.")
+			}
+		}
+
+		// Every robot answers on the same address, so a connection left over
+		// from this one would be taken for the next.
+		disconnectADB()
+
+		result := fleetResult{
+			name:    profile.Name,
+			ssid:    profile.SSID,
+			err:     err,
+			elapsed: time.Since(start),
+		}
+		results = append(results, result)
+
+		emit("robot", robotEvent{
+			Robot: result.name, SSID: result.ssid, Seconds: result.elapsed.Seconds(),
+			OK: result.err == nil, Error: errText(result.err),
+		})
+	}
+
+	if switchBack && home != "" {
+		returnHome(wifiMgr, home)
+	}
+
+	console.Print(fleetSummary(results))
+
+	failed := 0
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d robots did not get this build", failed, len(results))
+	}
+
+	return nil
+}
+
+// deployToFleetRobot puts the build onto one robot of the fleet.
+func deployToFleetRobot(wifiMgr *wifi.Manager, watcher *wifi.Watcher, profile *config.Profile, gradlePath, slimmedFor string) error {
+	current, ssidErr := wifiMgr.CurrentSSID()
+
+	if ssidErr == nil && current == profile.SSID {
+		console.Printf("[OK] Already on %s\n", profile.SSID)
+		emit("join", joinEvent{Robot: profile.Name, SSID: profile.SSID, Already: true, OK: true})
+	} else {
+		console.Printf("[>] Joining robot Wi-Fi: %s\n", profile.SSID)
+		ip, err := joinRobot(wifiMgr, watcher, profile)
+
+		// Every hub hands out the same subnet, so an address in it proves only
+		// that pusher is on some robot's network, possibly the previous one's.
+		if err == nil {
+			err = confirmNetwork(wifiMgr, profile.SSID)
+		}
+
+		emit("join", joinEvent{Robot: profile.Name, SSID: profile.SSID, IP: ip, OK: err == nil, Error: errText(err)})
+		if err != nil {
+			return err
+		}
+		console.Printf("[OK] On the robot network (%s)\n", ip)
+	}
+
+	return deployToRobot(gradlePath, slimmedFor, adb.HubAddr())
+}
+
+// confirmNetwork waits until the machine is on the named network, when the
+// system will say which network it is on.
+//
+// A system that will not name it cannot be asked, and that is said rather than
+// treated as a failure: the join has already succeeded as far as anything can
+// tell.
+func confirmNetwork(wifiMgr *wifi.Manager, ssid string) error {
+	deadline := time.Now().Add(joinTimeout)
+
+	for {
+		current, err := wifiMgr.CurrentSSID()
+		switch {
+		case errors.Is(err, wifi.ErrSSIDUnavailable):
+			console.Println("[*] The system will not name the network, so pusher is trusting the join.")
+			return nil
+		case err == nil && current == ssid:
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if current != "" {
+				return fmt.Errorf("joined %s but the system still reports %s", ssid, current)
+			}
+			return fmt.Errorf("joined %s but could not confirm it within %s", ssid, joinTimeout)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// fleetSummary is the table a fleet push finishes with.
+func fleetSummary(results []fleetResult) string {
+	nameWidth, ssidWidth := len("Robot"), len("Network")
+	for _, result := range results {
+		if len(result.name) > nameWidth {
+			nameWidth = len(result.name)
+		}
+		if len(result.ssid) > ssidWidth {
+			ssidWidth = len(result.ssid)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("\nFleet\n")
+	b.WriteString("─────────────────────────────────────────\n")
+	fmt.Fprintf(&b, "  %-*s  %-*s  %s\n", nameWidth, "Robot", ssidWidth, "Network", "Result")
+
+	for _, result := range results {
+		outcome := fmt.Sprintf("[OK] deployed in %.1fs", result.elapsed.Seconds())
+		if result.err != nil {
+			// The whole error was printed when it happened. The table only has
+			// to say which robot to scroll back to.
+			first, _, _ := strings.Cut(result.err.Error(), "\n")
+			outcome = "[!] " + first
+		}
+		fmt.Fprintf(&b, "  %-*s  %-*s  %s\n", nameWidth, result.name, ssidWidth, result.ssid, outcome)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// --robots is typed by hand, so a repeated or padded name must not deploy to
+// the same robot twice.
+func TestFleetTargetsKeepsOrderAndDropsRepeats(t *testing.T) {
+	settings(t, false)
+
+	pushRobots = []string{"comp", " practice", "comp", ""}
+	t.Cleanup(func() { pushRobots = nil })
+
+	if got := fleetTargets(); !reflect.DeepEqual(got, []string{"comp", "practice"}) {
+		t.Errorf("fleetTargets() = %v, want [comp practice]", got)
+	}
+}
+
+// With no flag and no fleet a push is the ordinary one to the default robot.
+func TestNoFleetIsAnOrdinaryPush(t *testing.T) {
+	settings(t, false)
+
+	if got := fleetTargets(); len(got) != 0 {
+		t.Errorf("fleetTargets() = %v, want nothing", got)
+	}
+}
+
+// The table is for finding the robot to scroll back to, so a failure shows
+// only its first line and every robot gets exactly one row.
+func TestFleetSummaryIsOneRowPerRobot(t *testing.T) {
+	summary := fleetSummary([]fleetResult{
+		{name: "comp", ssid: "DIRECT-comp", elapsed: 12 * time.Second},
+		{name: "practice", ssid: "DIRECT-practice",
+			err: errors.New("could not join DIRECT-practice\n    check the password")},
+	})
+
+	if !strings.Contains(summary, "comp") || !strings.Contains(summary, "[OK] deployed in 12.0s") {
+		t.Errorf("the robot that worked is not reported:\n%s", summary)
+	}
+	if !strings.Contains(summary, "[!] could not join DIRECT-practice") {
+		t.Errorf("the robot that failed is not reported:\n%s", summary)
+	}
+	if strings.Contains(summary, "check the password") {
+		t.Errorf("a failure spilled onto a second line:\n%s", summary)
+	}
+}
@@ -1,8 +1,7 @@
 package cmd
 
 import (
-	"fmt"
-
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/feature"
 	"github.com/spf13/cobra"
 )
@@ -33,47 +32,63 @@ var helpCmd = &cobra.Command{
 }
 
 func runHelp(cmd *cobra.Command, args []string) {
-	fmt.Print(asciiArt)
-	fmt.Println("Made with love by:")
-	fmt.Println("	Andrei \"PzmuV1517\" Banu")
-	fmt.Println("")
-	fmt.Println("Commands:")
-	fmt.Println("  pusher                Build and deploy to the robot")
-	fmt.Println("  pusher connect        Join the robot Wi-Fi and connect adb")
-	fmt.Println("  pusher exit           Disconnect adb and go back to your Wi-Fi")
-	fmt.Println("  pusher dc             Disconnect adb only (alias: disconnect)")
-	fmt.Println("  pusher settings       Robot profiles and preferences (alias: config)")
-	fmt.Println("  pusher slim           Shrink the APK so deploys transfer less")
-	fmt.Println("    pusher slim --undo       Put the gradle files back")
-	fmt.Println("  --ignore-warnings     Carry on past a check that would stop a command")
-	fmt.Println("  pusher hwconfig       Hardware config menu and editor (alias: hw)")
-	fmt.Println("    pusher hwconfig list     Print what the robot and the project have")
-	fmt.Println("    pusher hwconfig pull     Copy the robot's configs into your project")
-	fmt.Println("    pusher hwconfig push X   Copy X back to the robot")
-	fmt.Println("  pusher dash diff      What the robot holds that your code does not")
-	fmt.Println("  pusher prepare        Cache dependencies while you have internet")
+	console.Print(asciiArt)
+	console.Println("Made with love by:")
+	console.Println("	Andrei \"PzmuV1517\" Banu")
+	console.Println("")
+	console.Println("Commands:")
+	console.Println("  pusher                Build and deploy to the robot")
+	console.Println("  pusher connect        Join the robot Wi-Fi and connect adb")
+	console.Println("  pusher connect --pair Pair with a robot over wireless debugging")
+	console.Println("  pusher exit           Disconnect adb and go back to your Wi-Fi")
+	console.Println("  pusher dc             Disconnect adb only (alias: disconnect)")
+	console.Println("  pusher settings       Robot profiles and preferences (alias: config)")
+	console.Println("  pusher slim           Shrink the APK so deploys transfer less")
+	console.Println("    pusher slim --undo       Put the gradle files back")
+	console.Println("  pusher cache status   What the robot's chunk cache holds and saves")
+	console.Println("    pusher cache prune       Trim it now (clear: empty it)")
+	console.Println("  pusher rollback [n]   Put back the build from n deploys ago (--list)")
+	console.Println("  pusher status         Which commit each robot runs, against yours")
+	console.Println("  --ignore-warnings     Carry on past a check that would stop a command")
+	console.Println("  pusher hwconfig       Hardware config menu and editor (alias: hw)")
+	console.Println("    pusher hwconfig list     Print what the robot and the project have")
+	console.Println("    pusher hwconfig pull     Copy the robot's configs into your project")
+	console.Println("    pusher hwconfig push X   Copy X back to the robot")
+	console.Println("    pusher hwconfig new X    Lay out X from a template or the hubs attached")
+	console.Println("    pusher hwconfig codegen  Write a class with a typed field per device")
+	console.Println("    pusher hwconfig export X Keep X as YAML (import X goes back to XML)")
+	console.Println("    pusher hwconfig sync     Merge Driver Station and project edits into both")
+	console.Println("    pusher hwconfig check --source  Hold TeamCode's device names to the config")
+	console.Println("  pusher dash diff      What the robot holds that your code does not")
+	console.Println("    pusher dash apply        Write the robot's tuning into your source")
+	console.Println("    pusher dash set K=V      Send a value to the robot (dash load: a file)")
+	console.Println("    pusher dash snapshot     Keep, compare and restore tuning sets")
+	console.Println("    pusher dash watch        Live telemetry, --record to keep it")
+	console.Println("  pusher run <OpMode>   Init, start and stop an OpMode from here")
+	console.Println("  pusher watch          Reload with Pusher Extreme every time you save")
+	console.Println("  pusher prepare        Cache dependencies while you have internet")
 	if feature.Revealed() {
-		fmt.Println("  pusher visualiser     Draw the path an auto drove (alias: vis)")
+		console.Println("  pusher visualiser     Draw the path an auto drove (alias: vis)")
 	}
-	fmt.Println("  pusher dev            Measure what a deploy costs (see the warning)")
-	fmt.Println("  pusher update         Update pusher itself to the latest release")
-	fmt.Println("    pusher update --check    Say what is available, install nothing")
-	fmt.Println("  pusher --version      Show version information")
-	fmt.Println("  pusher help           Show this help")
-	fmt.Println("")
-	fmt.Println("Pusher Extreme:")
-	fmt.Println("  Reloads your OpModes onto a running robot instead of installing an")
-	fmt.Println("  APK: under a second rather than around forty. Set it up in")
-	fmt.Println("  'pusher settings' -> Pusher Extreme, which also undoes it.")
-	fmt.Println("  While it is set up your team code is not part of the APK.")
-	fmt.Println("")
-	fmt.Println("pusher dev:")
-	fmt.Println("  Measuring tools for working on pusher itself. It deploys to the")
-	fmt.Println("  robot repeatedly and reinstalls the app several times. If you do")
-	fmt.Println("  not already know why you want it, you do not want it.")
-	fmt.Println("")
-	fmt.Println("Deploying:")
-	fmt.Println("  A hub on USB is used automatically and your Wi-Fi is left alone.")
-	fmt.Println("  Otherwise pusher builds first, hops to the robot, deploys, and")
-	fmt.Println("  puts you back on the network you started on.")
+	console.Println("  pusher dev            Measure what a deploy costs (see the warning)")
+	console.Println("  pusher update         Update pusher itself to the latest release")
+	console.Println("    pusher update --check    Say what is available, install nothing")
+	console.Println("  pusher --version      Show version information")
+	console.Println("  pusher help           Show this help")
+	console.Println("")
+	console.Println("Pusher Extreme:")
+	console.Println("  Reloads your OpModes onto a running robot instead of installing an")
+	console.Println("  APK: under a second rather than around forty. Set it up in")
+	console.Println("  'pusher settings' -> Pusher Extreme, which also undoes it.")
+	console.Println("  While it is set up your team code is not part of the APK.")
+	console.Println("")
+	console.Println("pusher dev:")
+	console.Println("  Measuring tools for working on pusher itself. It deploys to the")
+	console.Println("  robot repeatedly and reinstalls the app several times. If you do")
+	console.Println("  not already know why you want it, you do not want it.")
+	console.Println("")
+	console.Println("Deploying:")
+	console.Println("  A hub on USB is used automatically and your Wi-Fi is left alone.")
+	console.Println("  Otherwise pusher builds first, hops to the robot, deploys, and")
+	console.Println("  puts you back on the network you started on.")
 }
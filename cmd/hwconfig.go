@@ -8,9 +8,12 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/andreibanu/pusher/internal/adb"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/extreme"
 	"github.com/andreibanu/pusher/internal/gradle"
 	"github.com/andreibanu/pusher/internal/robotcfg"
 	"github.com/andreibanu/pusher/internal/tui"
@@ -23,6 +26,18 @@ var (
 	hwNoBackup bool
 	hwYes      bool
 	hwRaw      bool
+
+	hwPackage string
+	hwClass   string
+	hwKotlin  bool
+	hwCheck   bool
+	hwSource  bool
+
+	hwFrom  string
+	hwProbe bool
+
+	hwOutput string
+	hwPrefer string
 )
 
 var hwconfigCmd = &cobra.Command{
@@ -46,6 +61,10 @@ port, and see a clash the moment it is typed.
   pusher hwconfig pull           copy every configuration into the project
   pusher hwconfig view comp      show what is wired where
   pusher hwconfig push comp      copy it back to the robot
+  pusher hwconfig codegen comp   write a class with a field for each device
+  pusher hwconfig new comp       lay one out from a template or the hubs attached
+  pusher hwconfig export comp    keep it as YAML, which reads better in a diff
+  pusher hwconfig sync           merge what changed on each side into both
 
 Files move byte for byte in both directions. Pusher parses them to check and
 describe them, and rewrites one only when you edit it - and then in the same
@@ -116,7 +135,128 @@ to the same ports reports no change.`,
 var hwCheckCmd = &cobra.Command{
 	Use:   "check [name...]",
 	Short: "Check configurations for what the robot would reject",
-	RunE:  runHWCheck,
+	Long: `Checks configurations for what the robot controller would reject.
+
+With --source it checks TeamCode against them instead: every hardwareMap lookup
+with a literal name is held against the configuration, and a name it does not
+have, or a device asked for as the wrong kind (a motor as a Servo), is
+reported. With no names that is the robot's active configuration when pusher
+can read it, or else every configuration in the project, in which case a name
+is only reported when none of them has it.`,
+	RunE: runHWCheck,
+}
+
+var hwNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Create a configuration from a template, or from the hubs attached",
+	Long: `Creates a configuration in the project without the Driver Station.
+
+--from reads a template: the hubs, and what is plugged into each by port.
+
+  hubs:
+    - name: Control Hub
+      motors:
+        0: leftFront
+        1: {name: rightFront, type: goBILDA5202SeriesMotor}
+      servos: {0: claw}
+      i2c:
+        0: {0: {name: imu, type: ControlHubImuBHI260AP}}
+    - address: 2
+      motors: {0: lift}
+
+The first hub is the Control Hub. A device given only a name is the generic one
+for its ports: Motor, Servo, AnalogInput, DigitalDevice. I2C devices need a
+type. Webcams need their serial number, which only the Driver Station's scan
+can fill in, so they are added there.
+
+--probe asks the robot which Expansion Hubs answered the robot controller when
+it last looked, and lays the hubs out to match: one that is attached but not
+in the template is added empty.
+
+Without either it is an empty Control Hub. The file is checked like any other,
+and written to the project; 'pusher hwconfig push' sends it to the robot.`,
+	RunE: runHWNew,
+}
+
+var hwCodegenCmd = &cobra.Command{
+	Use:   "codegen <name>",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Generate a class with a typed field for each device",
+	Long: `Writes a class into TeamCode with one field for each enabled device in a
+configuration, typed as the interface the SDK hands back for it, and an init
+method that fetches them all from the hardware map:
+
+  CompHardware hw = new CompHardware();
+  hw.init(hardwareMap);
+  hw.leftFront.setPower(1);
+
+A device renamed or removed on the Driver Station then fails the build rather
+than the OpMode's init on the robot.
+
+The class is CompHardware for a configuration called comp, in
+` + robotcfg.DefaultPackage + `. Running it again regenerates it where it
+is, in the same language. A file already there that pusher did not generate
+from this configuration is left alone unless --force is given. Edit the
+configuration, never the class: 'pusher' refuses to build while a generated
+class is out of date with its configuration, and --check does the same check
+on its own.`,
+	RunE: runHWCodegen,
+}
+
+var hwExportCmd = &cobra.Command{
+	Use:   "export <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Keep a configuration as YAML, or write it out as YAML",
+	Long: `Converts a configuration to YAML, which lists each hub's devices one to a line:
+
+  devices:
+    - {port: 0, name: leftFront, type: goBILDA5202SeriesMotor}
+    - {bus: 0, port: 0, name: imu, type: ControlHubImuBHI260AP}
+
+On its own it replaces configs/<name>.xml with configs/<name>.yaml, and from
+then on the YAML is the configuration: pull, edit, push, check and codegen all
+read and write it, and the robot still gets XML identical to what the YAML was
+made from. 'pusher hwconfig import <name>' goes back to XML.
+
+With -o it writes the YAML to a file, or to stdout with -o -, and leaves the
+project alone; the configuration can then come from the robot.`,
+	RunE: runHWExport,
+}
+
+var hwImportCmd = &cobra.Command{
+	Use:   "import <name | file.yaml> [name]",
+	Args:  cobra.RangeArgs(1, 2),
+	Short: "Keep a configuration as XML again, or bring in a YAML file",
+	Long: `Given the name of a configuration kept as YAML, makes it XML again.
+
+Given a .yaml file, checks it and writes it into the project as a
+configuration, named after the file unless a name follows it. A configuration
+of that name is only replaced with --force.`,
+	RunE: runHWImport,
+}
+
+var hwSyncCmd = &cobra.Command{
+	Use:   "sync [name...]",
+	Short: "Merge changes made on the Driver Station and in the project",
+	Long: `Brings the project's and the robot's copies of each configuration into step,
+keeping the changes made on both.
+
+pusher remembers each configuration as it last matched on each robot, after a
+pull, a push or a sync. Against that, a side that has not changed takes the
+other's copy, and when both have changed their changes are merged port by port:
+a motor renamed on the Driver Station and a servo added in the project both
+survive. The result is written to the project and to the robot, and the robot's
+copy is saved to configs/.pusher-backup first.
+
+A port, hub or portal that both sides changed differently is a conflict. With
+conflicts nothing is written unless --prefer names the side to take them from.
+A configuration never synced with this robot has nothing to merge against, so
+every difference in it is a conflict.
+
+A configuration deleted on one side since the last sync is left alone on the
+other; 'pusher hwconfig push' or 'pull' brings it back, and 'rm' deletes it.`,
+	RunE: runHWSync,
 }
 
 var hwRemoveCmd = &cobra.Command{
@@ -136,9 +276,23 @@ func init() {
 	hwEditCmd.Flags().BoolVar(&hwYes, "yes", false, "Push when the edit checks out, without asking")
 	hwRemoveCmd.Flags().BoolVarP(&hwYes, "yes", "y", false, "Delete without asking")
 	hwViewCmd.Flags().BoolVar(&hwRaw, "raw", false, "Print the file instead of a summary")
+	hwNewCmd.Flags().StringVar(&hwFrom, "from", "", "Lay the configuration out from this YAML template")
+	hwNewCmd.Flags().BoolVar(&hwProbe, "probe", false, "Add the Expansion Hubs attached to the robot")
+	hwNewCmd.Flags().BoolVar(&hwForce, "force", false, "Replace a configuration of the same name, or write one with errors")
+	hwCheckCmd.Flags().BoolVar(&hwSource, "source", false, "Check TeamCode's hardwareMap lookups against the configuration")
+	hwCodegenCmd.Flags().StringVar(&hwPackage, "package", "", "Package of the class (default "+robotcfg.DefaultPackage+")")
+	hwCodegenCmd.Flags().StringVar(&hwClass, "class", "", "Name of the class (default: the configuration's name + Hardware)")
+	hwCodegenCmd.Flags().BoolVar(&hwKotlin, "kotlin", false, "Write Kotlin instead of Java")
+	hwCodegenCmd.Flags().BoolVar(&hwCheck, "check", false, "Write nothing; fail if a generated class is out of date")
+	hwCodegenCmd.Flags().BoolVar(&hwForce, "force", false, "Replace a file pusher did not generate from this configuration")
+	hwExportCmd.Flags().StringVarP(&hwOutput, "output", "o", "", "Write the YAML here (- for stdout) instead of keeping it in the project")
+	hwImportCmd.Flags().BoolVar(&hwForce, "force", false, "Replace a configuration of the same name, or import one with errors")
+	hwSyncCmd.Flags().StringVar(&hwPrefer, "prefer", "", "Settle conflicts with this side's changes: project or robot")
+	hwSyncCmd.Flags().BoolVar(&hwForce, "force", false, "Write a result even if it has errors")
+	hwSyncCmd.Flags().BoolVar(&hwNoBackup, "no-backup", false, "Do not save the robot's copy before overwriting it")
 
 	hwconfigCmd.AddCommand(hwListCmd, hwPullCmd, hwPushCmd, hwViewCmd, hwEditCmd,
-		hwDiffCmd, hwCheckCmd, hwRemoveCmd)
+		hwDiffCmd, hwCheckCmd, hwNewCmd, hwCodegenCmd, hwExportCmd, hwImportCmd, hwSyncCmd, hwRemoveCmd)
 }
 
 func runHWMenu(cmd *cobra.Command, args []string) error {
@@ -184,33 +338,33 @@ func runHWList(cmd *cobra.Command, args []string) error {
 	if serial, err = adb.Target(); err == nil {
 		robotNames, err = robotcfg.List(serial)
 		if err != nil {
-			fmt.Printf("[!] Could not read the robot's configurations: %v\n\n", err)
+			console.Printf("[!] Could not read the robot's configurations: %v\n\n", err)
 		}
 		hashes = robotcfg.Hashes(serial)
 		active = robotcfg.ActiveConfig(serial)
 	} else {
-		fmt.Printf("[*] No robot connected, showing only what the project has.\n")
-		fmt.Printf("    (%v)\n\n", err)
+		console.Printf("[*] No robot connected, showing only what the project has.\n")
+		console.Printf("    (%v)\n\n", err)
 	}
 
 	if len(localNames) == 0 && len(robotNames) == 0 {
-		fmt.Printf("[=] Nothing in %s and nothing on the robot.\n", local.Dir)
+		console.Printf("[=] Nothing in %s and nothing on the robot.\n", local.Dir)
 		if serial != "" {
-			fmt.Println("    Make one on the Driver Station, then 'pusher hwconfig pull'.")
+			console.Println("    Make one on the Driver Station, then 'pusher hwconfig pull'.")
 		}
 		return nil
 	}
 
-	fmt.Printf("Project: %s\n\n", local.Dir)
+	console.Printf("Project: %s\n\n", local.Dir)
 
 	if serial == "" {
 		for _, name := range localNames {
-			fmt.Printf("  %s\n", name)
+			console.Printf("  %s\n", name)
 		}
 		return nil
 	}
 
-	fmt.Printf("  %-32s %-24s %s\n", "CONFIGURATION", "WHERE", "STATUS")
+	console.Printf("  %-32s %-24s %s\n", "CONFIGURATION", "WHERE", "STATUS")
 
 	onRobot := set(robotNames)
 	inProject := set(localNames)
@@ -231,13 +385,13 @@ func runHWList(cmd *cobra.Command, args []string) error {
 			where += " (active)"
 		}
 
-		fmt.Printf("  %-32s %-24s %s\n", name, where, status)
+		console.Printf("  %-32s %-24s %s\n", name, where, status)
 	}
 
-	fmt.Println()
+	console.Println()
 	if active == "" && len(robotNames) > 0 {
-		fmt.Println("[*] Pusher could not read which configuration is selected.")
-		fmt.Println("    That needs privileged adb, which a phone robot controller does not give.")
+		console.Println("[*] Pusher could not read which configuration is selected.")
+		console.Println("    That needs privileged adb, which a phone robot controller does not give.")
 	}
 
 	return nil
@@ -283,16 +437,20 @@ func runHWPull(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	robot := robotcfg.RobotID(serial)
 
 	for _, name := range wanted {
 		data, err := robotcfg.Fetch(serial, name)
 		if err != nil {
 			return err
 		}
+		if err := local.SetBase(robot, name, data); err != nil {
+			return err
+		}
 
 		if local.Has(name) {
 			if existing, err := local.Read(name); err == nil && robotcfg.Same(existing, data) {
-				fmt.Printf("[=] %s (unchanged)\n", name)
+				console.Printf("[=] %s (unchanged)\n", name)
 				continue
 			}
 		}
@@ -300,12 +458,12 @@ func runHWPull(cmd *cobra.Command, args []string) error {
 		if err := local.Write(name, data); err != nil {
 			return err
 		}
-		fmt.Printf("[OK] %s -> %s\n", name, local.Path(name))
+		console.Printf("[OK] %s -> %s\n", name, local.Path(name))
 
-		report(name, data)
+		reportAt(name, data, stored(local, name))
 	}
 
-	fmt.Printf("\n[*] Commit %s to keep the wiring with the code that uses it.\n", local.Dir)
+	console.Printf("\n[*] Commit %s to keep the wiring with the code that uses it.\n", local.Dir)
 	return nil
 }
 
@@ -338,7 +496,7 @@ func runHWPush(cmd *cobra.Command, args []string) error {
 		}
 		files[name] = data
 
-		if !report(name, data) && !hwForce {
+		if !reportAt(name, data, stored(local, name)) && !hwForce {
 			blocked = true
 		}
 	}
@@ -353,6 +511,7 @@ func runHWPush(cmd *cobra.Command, args []string) error {
 	}
 
 	active := robotcfg.ActiveConfig(serial)
+	robot := robotcfg.RobotID(serial)
 	replacedActive := false
 
 	for _, name := range wanted {
@@ -362,34 +521,274 @@ func runHWPush(cmd *cobra.Command, args []string) error {
 				if err != nil {
 					return err
 				}
-				fmt.Printf("[*] The robot's %s differed; saved it to %s\n", name, path)
+				console.Printf("[*] The robot's %s differed; saved it to %s\n", name, path)
 			}
 		}
 
 		if err := robotcfg.Send(serial, name, files[name]); err != nil {
 			return err
 		}
-		fmt.Printf("[OK] %s -> robot\n", name)
+		if err := local.SetBase(robot, name, files[name]); err != nil {
+			return err
+		}
+		console.Printf("[OK] %s -> robot\n", name)
 
 		if name == active {
 			replacedActive = true
 		}
 	}
 
-	fmt.Println()
+	console.Println()
 	if replacedActive {
-
-		fmt.Printf("[!] %q is the configuration the robot is running.\n", active)
-		fmt.Println("    It keeps the old wiring until you re-select it:")
-		fmt.Println("    Driver Station -> Configure Robot -> pick it -> Activate.")
+		warnActiveReplaced(active)
 	} else {
-		fmt.Println("[*] Select it on the Driver Station to use it:")
-		fmt.Println("    Configure Robot -> pick it -> Activate.")
+		console.Println("[*] Select it on the Driver Station to use it:")
+		console.Println("    Configure Robot -> pick it -> Activate.")
+	}
+
+	return nil
+}
+
+func warnActiveReplaced(active string) {
+	console.Printf("[!] %q is the configuration the robot is running.\n", active)
+	console.Println("    It keeps the old wiring until you re-select it:")
+	console.Println("    Driver Station -> Configure Robot -> pick it -> Activate.")
+}
+
+func runHWSync(cmd *cobra.Command, args []string) error {
+	var prefer robotcfg.Side
+	switch hwPrefer {
+	case "":
+	case "project":
+		prefer = robotcfg.Project
+	case "robot":
+		prefer = robotcfg.Robot
+	default:
+		return fmt.Errorf("--prefer %s: name the side to take, project or robot", hwPrefer)
+	}
+
+	local, err := store()
+	if err != nil {
+		return err
+	}
+	serial, err := adb.Target()
+	if err != nil {
+		return err
+	}
+
+	robotNames, err := robotcfg.List(serial)
+	if err != nil {
+		return err
+	}
+	localNames, err := local.Names()
+	if err != nil {
+		return err
+	}
+	names := merged(localNames, robotNames)
+	if len(names) == 0 {
+		return fmt.Errorf("there are no configurations in %s or on the robot to sync", local.Dir)
+	}
+	wanted, err := pick(args, names, "in "+local.Dir+" or on the robot")
+	if err != nil {
+		return err
+	}
+
+	s := syncer{
+		local:   local,
+		serial:  serial,
+		robot:   robotcfg.RobotID(serial),
+		onRobot: set(robotNames),
+		hashes:  robotcfg.Hashes(serial),
+		prefer:  prefer,
 	}
+	active := robotcfg.ActiveConfig(serial)
 
+	failed, sentActive := 0, false
+	for _, name := range wanted {
+		sent, err := s.sync(name)
+		if err != nil {
+			console.Printf("[X] %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		if sent && name == active {
+			sentActive = true
+		}
+	}
+
+	if sentActive {
+		console.Println()
+		warnActiveReplaced(active)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d configuration(s) were not synced", failed)
+	}
 	return nil
 }
 
+// syncer is one robot's side of a sync.
+type syncer struct {
+	local   *robotcfg.Store
+	serial  string
+	robot   string
+	onRobot map[string]bool
+	hashes  map[string]string
+	prefer  robotcfg.Side
+}
+
+// sync brings one configuration into step on both sides, and reports whether
+// it sent anything to the robot.
+func (s syncer) sync(name string) (bool, error) {
+	base, hasBase := s.local.Base(s.robot, name)
+	hasMine, onRobot := s.local.Has(name), s.onRobot[name]
+
+	var mine, theirs []byte
+	var err error
+	if hasMine {
+		if mine, err = s.local.Read(name); err != nil {
+			return false, err
+		}
+	}
+	if onRobot {
+		// The robot's copy is only fetched when its hash says it is not the
+		// base, which on a robot nobody has touched is every time.
+		if hash := s.hashes[name]; hasBase && hash != "" && hash == robotcfg.Hash(base) {
+			theirs = base
+		} else if theirs, err = robotcfg.Fetch(s.serial, name); err != nil {
+			return false, err
+		}
+	}
+
+	switch {
+	case !hasMine && !onRobot:
+		return false, s.local.ForgetBase(s.robot, name)
+
+	case !onRobot && hasBase:
+		console.Printf("[!] %s was deleted from the robot since the last sync; 'pusher hwconfig push %s' puts it back\n", name, name)
+		return false, nil
+
+	case !onRobot:
+		return true, s.push(name, nil, mine, "new in the project")
+
+	case !hasMine && hasBase:
+		console.Printf("[!] %s was deleted from the project since the last sync; 'pusher hwconfig pull %s' brings it back\n", name, name)
+		return false, nil
+
+	case !hasMine:
+		return false, s.keep(name, theirs, "new on the robot")
+
+	case robotcfg.Same(mine, theirs):
+		if !hasBase || !robotcfg.Same(base, mine) {
+			if err := s.local.SetBase(s.robot, name, mine); err != nil {
+				return false, err
+			}
+		}
+		console.Printf("[=] %s is the same on both sides\n", name)
+		return false, nil
+
+	case hasBase && robotcfg.Same(theirs, base):
+		return true, s.push(name, theirs, mine, "changed in the project")
+
+	case hasBase && robotcfg.Same(mine, base):
+		return false, s.keep(name, theirs, "changed on the robot")
+	}
+
+	return true, s.merge(name, base, hasBase, mine, theirs)
+}
+
+// merge brings together a configuration both sides changed.
+func (s syncer) merge(name string, base []byte, hasBase bool, mine, theirs []byte) error {
+	var baseCfg *robotcfg.Config
+	if hasBase {
+		var err error
+		if baseCfg, err = robotcfg.Parse(base); err != nil {
+			return fmt.Errorf("what it was at the last sync does not parse: %w", err)
+		}
+	}
+	mineCfg, err := robotcfg.Parse(mine)
+	if err != nil {
+		return fmt.Errorf("the project's copy does not parse: %w", err)
+	}
+	theirsCfg, err := robotcfg.Parse(theirs)
+	if err != nil {
+		return fmt.Errorf("the robot's copy does not parse: %w", err)
+	}
+
+	out, conflicts := robotcfg.Merge(baseCfg, mineCfg, theirsCfg, s.prefer)
+	if len(conflicts) > 0 {
+		if hasBase {
+			console.Printf("[!] %s was changed on both sides, in the same places:\n", name)
+		} else {
+			console.Printf("[!] %s differs between the two sides, which were never synced, so every difference is a conflict:\n", name)
+		}
+		for _, c := range conflicts {
+			console.Printf("      %s\n", c)
+		}
+		if s.prefer == robotcfg.Neither {
+			return fmt.Errorf("nothing was written: use --prefer project or --prefer robot, or change one side to match")
+		}
+		console.Printf("    Taking the %s's side of those, as --prefer says.\n", s.prefer)
+	}
+
+	data := robotcfg.Write(out)
+	if !report(name, data) && !hwForce {
+		return fmt.Errorf("the merged configuration has errors, so nothing was written (--force writes it anyway)")
+	}
+
+	if err := s.local.Write(name, data); err != nil {
+		return err
+	}
+	if err := s.send(name, theirs, data); err != nil {
+		return err
+	}
+
+	console.Printf("[OK] %s merged, in %s and on the robot\n", name, s.local.Path(name))
+	for _, line := range robotcfg.Diff(mineCfg, out) {
+		console.Printf("      from the robot:   %s\n", line)
+	}
+	for _, line := range robotcfg.Diff(theirsCfg, out) {
+		console.Printf("      from the project: %s\n", line)
+	}
+	return nil
+}
+
+// push sends the project's copy, which only the project changed.
+func (s syncer) push(name string, current, data []byte, why string) error {
+	if !reportAt(name, data, stored(s.local, name)) && !hwForce {
+		return fmt.Errorf("it has errors, so it was not sent (--force sends it anyway)")
+	}
+	if err := s.send(name, current, data); err != nil {
+		return err
+	}
+	console.Printf("[OK] %s -> robot (%s)\n", name, why)
+	return nil
+}
+
+// send puts a configuration on the robot and records it as the base, saving
+// what it replaces first.
+func (s syncer) send(name string, current, data []byte) error {
+	if !hwNoBackup && current != nil {
+		path, err := s.local.Backup(name, current)
+		if err != nil {
+			return err
+		}
+		console.Printf("[*] Saved the robot's %s to %s\n", name, path)
+	}
+	if err := robotcfg.Send(s.serial, name, data); err != nil {
+		return err
+	}
+	return s.local.SetBase(s.robot, name, data)
+}
+
+// keep writes the robot's copy into the project and records it as the base.
+func (s syncer) keep(name string, data []byte, why string) error {
+	if err := s.local.Write(name, data); err != nil {
+		return err
+	}
+	console.Printf("[OK] %s -> %s (%s)\n", name, s.local.Path(name), why)
+	return s.local.SetBase(s.robot, name, data)
+}
+
 func runHWView(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
@@ -399,7 +798,7 @@ func runHWView(cmd *cobra.Command, args []string) error {
 	}
 
 	if hwRaw {
-		fmt.Print(string(data))
+		console.Print(string(data))
 		return nil
 	}
 
@@ -408,12 +807,12 @@ func runHWView(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("%s (%s): %w", name, source, err)
 	}
 
-	fmt.Printf("%s  (%s)\n\n", name, source)
-	fmt.Print(robotcfg.Summary(cfg))
+	console.Printf("%s  (%s)\n\n", name, source)
+	console.Print(robotcfg.Summary(cfg))
 
 	names := cfg.Names()
 	sort.Strings(names)
-	fmt.Printf("\n%d device(s) an OpMode can look up: %s\n", len(names), strings.Join(names, ", "))
+	console.Printf("\n%d device(s) an OpMode can look up: %s\n", len(names), strings.Join(names, ", "))
 
 	printIssues(robotcfg.Validate(cfg))
 	return nil
@@ -441,7 +840,7 @@ func runHWEdit(cmd *cobra.Command, args []string) error {
 		if err := local.Write(name, data); err != nil {
 			return err
 		}
-		fmt.Printf("[OK] Pulled %s from the robot\n", name)
+		console.Printf("[OK] Pulled %s from the robot\n", name)
 	}
 
 	before, err := local.Read(name)
@@ -459,7 +858,7 @@ func runHWEdit(cmd *cobra.Command, args []string) error {
 	}
 
 	if robotcfg.Same(before, after) {
-		fmt.Println("[=] Unchanged.")
+		console.Println("[=] Unchanged.")
 		return nil
 	}
 
@@ -467,30 +866,30 @@ func runHWEdit(cmd *cobra.Command, args []string) error {
 	newCfg, newErr := robotcfg.Parse(after)
 
 	if newErr != nil {
-		fmt.Printf("\n[!] %s no longer parses: %v\n", name, newErr)
-		fmt.Printf("    The file is still at %s. Nothing was sent to the robot.\n", local.Path(name))
+		console.Printf("\n[!] %s no longer parses: %v\n", name, newErr)
+		console.Printf("    The file is still at %s. Nothing was sent to the robot.\n", local.Path(name))
 		return fmt.Errorf("%s is broken", name)
 	}
 
 	if oldErr == nil {
 		if changes := robotcfg.Diff(oldCfg, newCfg); len(changes) > 0 {
-			fmt.Println("\nChanged:")
+			console.Println("\nChanged:")
 			for _, change := range changes {
-				fmt.Printf("  %s\n", change)
+				console.Printf("  %s\n", change)
 			}
 		}
 	}
 
-	issues := robotcfg.Validate(newCfg)
+	issues := local.Locate(name, robotcfg.Validate(newCfg))
 	printIssues(issues)
 
 	if issues.Errors() {
-		fmt.Printf("\n[!] Not pushing %s. Fix the errors, or push it yourself with --force.\n", name)
+		console.Printf("\n[!] Not pushing %s. Fix the errors, or push it yourself with --force.\n", name)
 		return fmt.Errorf("%s has errors", name)
 	}
 
 	if !hwYes && !confirm(fmt.Sprintf("\nPush %s to the robot?", name)) {
-		fmt.Printf("[*] Left in %s. Push it later with 'pusher hwconfig push %s'.\n", local.Dir, name)
+		console.Printf("[*] Left in %s. Push it later with 'pusher hwconfig push %s'.\n", local.Dir, name)
 		return nil
 	}
 
@@ -529,35 +928,35 @@ func runHWDiff(cmd *cobra.Command, args []string) error {
 
 		theirs, err := robotcfg.Fetch(serial, name)
 		if err != nil {
-			fmt.Printf("[!] %s is not on the robot\n", name)
+			console.Printf("[!] %s is not on the robot\n", name)
 			continue
 		}
 
 		if robotcfg.Same(mine, theirs) {
-			fmt.Printf("[=] %s is identical\n", name)
+			console.Printf("[=] %s is identical\n", name)
 			continue
 		}
 
 		robotCfg, err := robotcfg.Parse(theirs)
 		if err != nil {
-			fmt.Printf("[!] the robot's %s does not parse: %v\n", name, err)
+			console.Printf("[!] the robot's %s does not parse: %v\n", name, err)
 			continue
 		}
 		myCfg, err := robotcfg.Parse(mine)
 		if err != nil {
-			fmt.Printf("[!] %s does not parse: %v\n", name, err)
+			console.Printf("[!] %s does not parse: %v\n", name, err)
 			continue
 		}
 
 		changes := robotcfg.Diff(robotCfg, myCfg)
 		if len(changes) == 0 {
-			fmt.Printf("[=] %s wires the same things; only the file differs\n", name)
+			console.Printf("[=] %s wires the same things; only the file differs\n", name)
 			continue
 		}
 
-		fmt.Printf("[!] %s (project vs robot)\n", name)
+		console.Printf("[!] %s (project vs robot)\n", name)
 		for _, change := range changes {
-			fmt.Printf("      %s\n", change)
+			console.Printf("      %s\n", change)
 		}
 	}
 
@@ -569,6 +968,9 @@ func runHWCheck(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if hwSource {
+		return runHWCheckSource(local, args)
+	}
 
 	names, err := local.Names()
 	if err != nil {
@@ -589,7 +991,7 @@ func runHWCheck(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
-		if !report(name, data) {
+		if !reportAt(name, data, stored(local, name)) {
 			bad++
 		}
 	}
@@ -598,10 +1000,380 @@ func runHWCheck(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("%d configuration(s) the robot would reject", bad)
 	}
 
-	fmt.Printf("\n[OK] %d configuration(s) checked.\n", len(wanted))
+	console.Printf("\n[OK] %d configuration(s) checked.\n", len(wanted))
 	return nil
 }
 
+func runHWNew(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := robotcfg.CheckName(name); err != nil {
+		return err
+	}
+
+	local, err := store()
+	if err != nil {
+		return err
+	}
+	if local.Has(name) && !hwForce {
+		return fmt.Errorf("%s already has a configuration called %q\n\nPick another name, or use --force to replace it",
+			local.Dir, name)
+	}
+
+	cfg := robotcfg.New()
+	if hwFrom != "" {
+		template, err := os.ReadFile(hwFrom)
+		if err != nil {
+			return err
+		}
+		if cfg, err = robotcfg.FromTemplate(template); err != nil {
+			return fmt.Errorf("%s: %w", hwFrom, err)
+		}
+	}
+
+	if hwProbe {
+		serial, err := adb.Target()
+		if err != nil {
+			return err
+		}
+		addresses, err := robotcfg.ProbeAddresses(serial)
+		if err != nil {
+			return err
+		}
+
+		if len(addresses) == 0 {
+			console.Println("[*] The robot reported no Expansion Hubs, only its Control Hub")
+		} else {
+			console.Printf("[*] The robot reported Expansion Hubs at %s\n", joinInts(addresses))
+		}
+		for _, m := range cfg.ExpectHubs(addresses) {
+			console.Printf("[!] %s, at address %d, did not answer; it is kept in the configuration\n", m.Name, m.Address)
+		}
+	}
+
+	data := robotcfg.Write(cfg)
+	if !report(name, data) && !hwForce {
+		return fmt.Errorf("%s was not written: fix the template, or use --force to write it anyway", name)
+	}
+
+	if err := local.Write(name, data); err != nil {
+		return err
+	}
+	console.Printf("\n%s\n", name)
+	console.Print(robotcfg.Summary(cfg))
+	console.Printf("\n[OK] %s -> %s\n", name, local.Path(name))
+	console.Printf("    Send it to the robot with 'pusher hwconfig push %s'.\n", name)
+	return nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runHWExport(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if hwOutput != "" {
+		data, source, err := readAnywhere(name)
+		if err != nil {
+			return err
+		}
+		cfg, err := robotcfg.Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s (%s): %w", name, source, err)
+		}
+
+		if hwOutput == "-" {
+			_, err := console.Out.Write(robotcfg.ToYAML(cfg))
+			return err
+		}
+		if err := os.WriteFile(hwOutput, robotcfg.ToYAML(cfg), 0o644); err != nil {
+			return err
+		}
+		console.Printf("[OK] %s (%s) -> %s\n", name, source, hwOutput)
+		return nil
+	}
+
+	local, err := store()
+	if err != nil {
+		return err
+	}
+	if local.IsYAML(name) {
+		console.Printf("[=] %s is already kept as YAML, in %s\n", name, local.Path(name))
+		return nil
+	}
+	if err := local.KeepAsYAML(name); err != nil {
+		return err
+	}
+
+	console.Printf("[OK] %s is kept as %s from now on\n", name, local.Path(name))
+	console.Println("    The robot still gets the same XML. 'pusher hwconfig import " + name + "' goes back to it.")
+	return nil
+}
+
+func runHWImport(cmd *cobra.Command, args []string) error {
+	local, err := store()
+	if err != nil {
+		return err
+	}
+
+	source := args[0]
+	if ext := strings.ToLower(filepath.Ext(source)); ext != robotcfg.YAMLExt && ext != ".yml" {
+		if len(args) > 1 {
+			return fmt.Errorf("%s is not a .yaml file, so there is nothing to name %s", source, args[1])
+		}
+		if !local.Has(source) {
+			return fmt.Errorf("no configuration called %q in %s", source, local.Dir)
+		}
+		if !local.IsYAML(source) {
+			console.Printf("[=] %s is already kept as XML, in %s\n", source, local.Path(source))
+			return nil
+		}
+		if err := local.KeepAsXML(source); err != nil {
+			return err
+		}
+		console.Printf("[OK] %s is kept as %s again\n", source, local.Path(source))
+		return nil
+	}
+
+	name := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	if len(args) > 1 {
+		name = args[1]
+	}
+	if err := robotcfg.CheckName(name); err != nil {
+		return err
+	}
+	if local.Has(name) && !hwForce {
+		return fmt.Errorf("%s already has a configuration called %q\n\nName it something else, or use --force to replace it",
+			local.Dir, name)
+	}
+
+	blob, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	cfg, err := robotcfg.FromYAML(blob)
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	data := robotcfg.Write(cfg)
+	inYAML := func(issues robotcfg.Issues) robotcfg.Issues { return robotcfg.LocateInYAML(blob, issues) }
+	if !reportAt(name, data, inYAML) && !hwForce {
+		return fmt.Errorf("%s was not imported: fix %s, or use --force to import it anyway", name, source)
+	}
+	if err := local.Write(name, data); err != nil {
+		return err
+	}
+	console.Printf("[OK] %s -> %s\n", source, local.Path(name))
+	return nil
+}
+
+func runHWCodegen(cmd *cobra.Command, args []string) error {
+	wrapper, err := gradle.DetectWrapper()
+	if err != nil {
+		return fmt.Errorf("pusher cannot tell where TeamCode is: %w\n\nRun this from your FTC project", err)
+	}
+	sourceRoot := filepath.Join(gradle.ProjectDir(wrapper), extreme.SourceRoot)
+
+	local, err := store()
+	if err != nil {
+		return err
+	}
+
+	if hwCheck {
+		if len(args) > 0 {
+			return fmt.Errorf("--check checks every generated class, so it takes no name")
+		}
+		if err := checkGenerated(sourceRoot, local); err != nil {
+			return err
+		}
+		console.Println("[OK] Every generated class matches its configuration.")
+		return nil
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("name the configuration to generate a class from\n\nRun 'pusher hwconfig list' to see them")
+	}
+	name := args[0]
+
+	data, err := local.Read(name)
+	if err != nil {
+		return fmt.Errorf("%w\n\nPull it first: 'pusher hwconfig pull %s'", err, name)
+	}
+	cfg, err := robotcfg.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	reportAt(name, data, stored(local, name))
+
+	// A class generated before is regenerated as it was, so running this again
+	// after an edit needs no flags; the ones given still win.
+	g := robotcfg.Codegen{Config: name, Package: robotcfg.DefaultPackage, Class: robotcfg.ClassName(name)}
+	previous := ""
+	if generated, err := robotcfg.FindGenerated(sourceRoot); err == nil {
+		for path, found := range generated {
+			if found.Config == name {
+				g, previous = found, path
+				break
+			}
+		}
+	}
+	if cmd.Flags().Changed("package") {
+		g.Package = hwPackage
+	}
+	if cmd.Flags().Changed("class") {
+		g.Class = hwClass
+	}
+	if cmd.Flags().Changed("kotlin") {
+		g.Kotlin = hwKotlin
+	}
+
+	path := g.Path(sourceRoot)
+	if err := overwritable(path, name); err != nil && !hwForce {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, robotcfg.Generate(cfg, g), 0o644); err != nil {
+		return err
+	}
+	if previous != "" && previous != path {
+		if err := os.Remove(previous); err != nil {
+			return err
+		}
+		console.Printf("[*] Removed %s, which the class replaces\n", previous)
+	}
+
+	console.Printf("[OK] %s -> %s\n", name, path)
+	console.Printf("    %s.%s, %d device(s) from %s\n", g.Package, g.Class, len(cfg.Names()), local.Path(name))
+	return nil
+}
+
+// overwritable refuses to write a generated class over a file that is not the
+// one generated from this configuration. --class names whatever it is told
+// to, and a team's own RobotHardware.java replaced without asking is work lost.
+func overwritable(path, name string) error {
+	src, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	g, ok := robotcfg.Generated(path, src)
+	switch {
+	case !ok:
+		return fmt.Errorf("%s is already there and pusher did not generate it\n\nPick another --class, or use --force to replace it", path)
+	case g.Config != name:
+		return fmt.Errorf("%s was generated from %q, not %q\n\nPick another --class, or use --force to replace it", path, g.Config, name)
+	}
+	return nil
+}
+
+// checkGenerated fails when a class generated from a configuration no longer
+// matches it, which the compiler cannot see: the class still compiles, and
+// names a device the robot will not find.
+func checkGenerated(sourceRoot string, local *robotcfg.Store) error {
+	stale, err := robotcfg.Stale(sourceRoot, local)
+	if err != nil {
+		return fmt.Errorf("cannot check the generated hardware classes: %w", err)
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	console.Println("[!] A generated hardware class is out of date:")
+	for _, line := range stale {
+		console.Printf("    %s\n", line)
+	}
+	console.Println("    Regenerate it with 'pusher hwconfig codegen <configuration>'.")
+	return fmt.Errorf("generated hardware classes do not match their configurations")
+}
+
+func runHWCheckSource(local *robotcfg.Store, args []string) error {
+	wrapper, err := gradle.DetectWrapper()
+	if err != nil {
+		return fmt.Errorf("pusher cannot tell where TeamCode is: %w\n\nRun this from your FTC project", err)
+	}
+
+	names := args
+	if len(names) == 0 {
+		if serial, err := adb.Target(); err == nil {
+			if active := robotcfg.ActiveConfig(serial); active != "" && local.Has(active) {
+				names = []string{active}
+				console.Printf("[*] Checking against %s, the robot's active configuration\n", active)
+			}
+		}
+	}
+	if len(names) == 0 {
+		if names, err = local.Names(); err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("nothing in %s to check against\n\nRun 'pusher hwconfig pull' first", local.Dir)
+		}
+		if len(names) > 1 {
+			console.Printf("[*] Which configuration the robot runs is not known, so names are checked against all %d\n", len(names))
+		}
+	}
+
+	configs, err := readConfigs(local, names)
+	if err != nil {
+		return err
+	}
+	mismatches, lookups, err := checkSource(gradle.ProjectDir(wrapper), configs)
+	if err != nil {
+		return err
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d of %d hardware map lookup(s) would fail on the robot", len(mismatches), lookups)
+	}
+	console.Printf("[OK] %d hardware map lookup(s) match.\n", lookups)
+	return nil
+}
+
+// readConfigs parses configurations from the project by name.
+func readConfigs(local *robotcfg.Store, names []string) (map[string]*robotcfg.Config, error) {
+	configs := make(map[string]*robotcfg.Config, len(names))
+	for _, name := range names {
+		data, err := local.Read(name)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := robotcfg.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		configs[name] = cfg
+	}
+	return configs, nil
+}
+
+// checkSource lists each lookup in TeamCode the configurations would not
+// answer, and returns them with how many lookups there were.
+func checkSource(root string, configs map[string]*robotcfg.Config) ([]robotcfg.Mismatch, int, error) {
+	sourceRoot := filepath.Join(root, extreme.SourceRoot)
+	lookups, err := robotcfg.FindLookups(sourceRoot)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read %s: %w", sourceRoot, err)
+	}
+
+	mismatches := robotcfg.CheckLookups(lookups, configs)
+	if len(mismatches) > 0 {
+		console.Printf("\n[!] TeamCode asks the hardware map for what the configuration does not have:\n")
+	}
+	for _, m := range mismatches {
+		console.Printf("    %s\n", m)
+	}
+	return mismatches, len(lookups), nil
+}
+
 func runHWRemove(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
@@ -615,19 +1387,19 @@ func runHWRemove(cmd *cobra.Command, args []string) error {
 	}
 
 	if name == robotcfg.ActiveConfig(serial) {
-		fmt.Printf("[!] %q is the configuration the robot is running.\n", name)
+		console.Printf("[!] %q is the configuration the robot is running.\n", name)
 	}
 
 	if local, err := store(); err == nil {
 		if data, err := robotcfg.Fetch(serial, name); err == nil {
 			if path, err := local.Backup(name, data); err == nil {
-				fmt.Printf("[*] Saved the robot's copy to %s\n", path)
+				console.Printf("[*] Saved the robot's copy to %s\n", path)
 			}
 		}
 	}
 
 	if !hwYes && !confirm(fmt.Sprintf("Delete %q from the robot?", name)) {
-		fmt.Println("[*] Left alone.")
+		console.Println("[*] Left alone.")
 		return nil
 	}
 
@@ -635,7 +1407,7 @@ func runHWRemove(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Printf("[OK] Deleted %s from the robot\n", name)
+	console.Printf("[OK] Deleted %s from the robot\n", name)
 	return nil
 }
 
@@ -655,18 +1427,35 @@ func readAnywhere(name string) ([]byte, string, error) {
 }
 
 func report(name string, data []byte) bool {
+	return reportAt(name, data, nil)
+}
+
+// stored places the issues of a configuration in the project at their lines in
+// the file that is edited, YAML or XML.
+func stored(local *robotcfg.Store, name string) func(robotcfg.Issues) robotcfg.Issues {
+	return func(issues robotcfg.Issues) robotcfg.Issues {
+		return local.Locate(name, issues)
+	}
+}
+
+// reportAt is report with the issues moved by locate to where a person will
+// look for them.
+func reportAt(name string, data []byte, locate func(robotcfg.Issues) robotcfg.Issues) bool {
 	cfg, err := robotcfg.Parse(data)
 	if err != nil {
-		fmt.Printf("\n[X] %s: %v\n", name, err)
+		console.Printf("\n[X] %s: %v\n", name, err)
 		return false
 	}
 
 	issues := robotcfg.Validate(cfg)
+	if locate != nil {
+		issues = locate(issues)
+	}
 	if len(issues) == 0 {
 		return true
 	}
 
-	fmt.Printf("\n%s:\n", name)
+	console.Printf("\n%s:\n", name)
 	printIssues(issues)
 
 	return !issues.Errors()
@@ -678,7 +1467,7 @@ func printIssues(issues robotcfg.Issues) {
 		if issue.Level == robotcfg.Error {
 			marker = "[X]"
 		}
-		fmt.Printf("  %s %s\n", marker, issue)
+		console.Printf("  %s %s\n", marker, issue)
 	}
 }
 
@@ -741,7 +1530,7 @@ func openEditor(path string) error {
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("editor %s failed: %w\n\nEdit %s yourself, then run 'pusher hwconfig push %s'",
-			parts[0], err, path, strings.TrimSuffix(filepath.Base(path), robotcfg.Ext))
+			parts[0], err, path, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
 	}
 
 	return nil
@@ -757,7 +1546,7 @@ func firstSet(names ...string) string {
 }
 
 func confirm(question string) bool {
-	fmt.Printf("%s [y/N] ", question)
+	console.Printf("%s [y/N] ", question)
 
 	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
 	if err != nil {
@@ -767,3 +1556,25 @@ func confirm(question string) bool {
 	answer = strings.ToLower(strings.TrimSpace(answer))
 	return answer == "y" || answer == "yes"
 }
+
+// warnLookups is the source check before a deploy, against every
+// configuration in the project since which one the robot runs is not known
+// yet. It only warns: a name pusher cannot see the robot answering may still
+// be one, from a configuration that was never pulled.
+func warnLookups(root string) {
+	local := robotcfg.NewStore(robotcfg.LocalDir(root))
+	names, err := local.Names()
+	if err != nil || len(names) == 0 {
+		return
+	}
+	configs, err := readConfigs(local, names)
+	if err != nil {
+		return
+	}
+
+	mismatches, _, err := checkSource(root, configs)
+	if err != nil || len(mismatches) == 0 {
+		return
+	}
+	console.Println("    These would stop the OpMode at init. 'pusher hwconfig check --source' says more.")
+}
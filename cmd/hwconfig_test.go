@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andreibanu/pusher/internal/robotcfg"
+)
+
+// --class names whatever it is told to, and a file the team wrote there is
+// theirs until they say otherwise.
+func TestCodegenOnlyOverwritesItsOwnClass(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "RobotHardware.java")
+
+	if err := overwritable(path, "comp"); err != nil {
+		t.Errorf("nothing there yet: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("package org.team;\n\npublic class RobotHardware {\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := overwritable(path, "comp"); err == nil || !strings.Contains(err.Error(), "did not generate") {
+		t.Errorf("a team's own class: %v", err)
+	}
+
+	g := robotcfg.Codegen{Config: "comp", Package: "org.team", Class: "RobotHardware"}
+	if err := os.WriteFile(path, robotcfg.Generate(robotcfg.New(), g), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := overwritable(path, "comp"); err != nil {
+		t.Errorf("the class generated from comp: %v", err)
+	}
+	if err := overwritable(path, "practice"); err == nil || !strings.Contains(err.Error(), `generated from "comp"`) {
+		t.Errorf("another configuration's class: %v", err)
+	}
+}
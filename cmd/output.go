@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/dash"
+	"github.com/andreibanu/pusher/internal/gradle"
+)
+
+// With --output json, stdout carries one JSON object per line and nothing
+// else. Everything written for a person goes to stderr instead, so a script
+// reads stdout and a person watching still sees the deploy happen.
+//
+// Every line has the same envelope:
+//
+//	{"event": "build", "time": "2024-01-06T10:04:05Z", "data": {...}}
+//
+// The event names and the fields of each data object are documented in the
+// README and are a contract: fields may be added, never renamed or removed.
+
+var outputFormat string
+
+// jsonOut is where events go, nil unless --output json was given.
+var jsonOut io.Writer
+
+// envelope is one line of machine-readable output.
+type envelope struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Data  any       `json:"data"`
+}
+
+// beginOutput sets the output up once the flags are known.
+func beginOutput() error {
+	switch outputFormat {
+	case "", "text":
+		return nil
+	case "json":
+	default:
+		return fmt.Errorf("unknown output format %q: use text or json", outputFormat)
+	}
+
+	// Every line written for a person, Gradle's and adb's included, goes
+	// through console.Out, so moving it leaves stdout to the events.
+	jsonOut = os.Stdout
+	console.Out = os.Stderr
+	return nil
+}
+
+// emit writes one event, when events are wanted.
+func emit(event string, data any) {
+	if jsonOut == nil {
+		return
+	}
+
+	line, err := json.Marshal(envelope{Event: event, Time: time.Now().UTC(), Data: data})
+	if err != nil {
+		return
+	}
+
+	_, _ = jsonOut.Write(append(line, '\n'))
+}
+
+// errText is an error as a field, empty when there was none.
+func errText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// The shapes below are the data objects of each event.
+
+// buildEvent is "build": one Gradle build, finished or failed.
+type buildEvent struct {
+	Offline bool    `json:"offline"`
+	Seconds float64 `json:"seconds"`
+	OK      bool    `json:"ok"`
+	Error   string  `json:"error,omitempty"`
+
+	// Skipped is a build Gradle was not run for, nothing having changed since
+	// the last one.
+	Skipped bool `json:"skipped,omitempty"`
+
+	// What a failed build's output said: the task it failed in, the compile
+	// errors with files relative to the project, and the known problems it
+	// matched.
+	FailedTask  string              `json:"failed_task,omitempty"`
+	Diagnostics []gradle.Diagnostic `json:"diagnostics,omitempty"`
+	Hints       []string            `json:"hints,omitempty"`
+}
+
+// failedBuild is the event for a build that failed with err.
+func failedBuild(offline bool, seconds float64, root string, err error) buildEvent {
+	event := buildEvent{Offline: offline, Seconds: seconds, Error: err.Error()}
+
+	var build *gradle.BuildError
+	if errors.As(err, &build) {
+		event.FailedTask = build.Report.Failed
+		for _, d := range build.Report.Diagnostics {
+			event.Diagnostics = append(event.Diagnostics, d.Relative(root))
+		}
+		for _, h := range build.Report.Hints {
+			event.Hints = append(event.Hints, h.Problem)
+		}
+	}
+	return event
+}
+
+// joinEvent is "join": pusher moving onto a robot's network.
+type joinEvent struct {
+	Robot   string `json:"robot,omitempty"`
+	SSID    string `json:"ssid"`
+	IP      string `json:"ip,omitempty"`
+	Already bool   `json:"already"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+// pairEvent is "pair": a robot paired over wireless debugging, or not.
+type pairEvent struct {
+	Robot    string `json:"robot,omitempty"`
+	PairAddr string `json:"pair_addr"`
+	Addr     string `json:"addr,omitempty"`
+	Service  string `json:"service,omitempty"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+// decisionEvent is "decision": whether a deploy reloads team code or installs
+// an APK, and why.
+type decisionEvent struct {
+	Serial string `json:"serial"`
+	Mode   string `json:"mode"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// installEvent is "install": what an APK install did.
+type installEvent struct {
+	Serial   string `json:"serial"`
+	APK      string `json:"apk"`
+	Skipped  bool   `json:"skipped"`
+	Streamed bool   `json:"streamed"`
+	Delta    bool   `json:"delta"`
+	Patched  bool   `json:"patched"`
+	Splits   int    `json:"splits"`
+	Reason   string `json:"reason,omitempty"`
+	// BytesSent is what crossed the link, compressed or not.
+	BytesSent int64   `json:"bytes_sent"`
+	Seconds   float64 `json:"seconds"`
+	OK        bool    `json:"ok"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// reloadEvent is "reload": team code sent to a running robot.
+type reloadEvent struct {
+	Serial   string   `json:"serial"`
+	Classes  int      `json:"classes"`
+	Seconds  float64  `json:"seconds"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Busy is what was done about a running OpMode: idle, stopped, waited,
+	// refused or unchecked. Empty when nothing was asked.
+	Busy   string `json:"busy,omitempty"`
+	OpMode string `json:"opmode,omitempty"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+// changeEvent is "change": saves that pusher watch is about to deploy.
+type changeEvent struct {
+	Files []string `json:"files"`
+}
+
+// rejoinEvent is "rejoin": pusher going back to the network it started on.
+type rejoinEvent struct {
+	SSID  string `json:"ssid"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// robotEvent is "robot": one robot's turn in a fleet push.
+type robotEvent struct {
+	Robot   string  `json:"robot"`
+	SSID    string  `json:"ssid"`
+	Seconds float64 `json:"seconds"`
+	OK      bool    `json:"ok"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// dashDiffEvent is "diff": what `pusher dash diff` found. Its fields are
+// dash.Diff's, alongside which robot and project were compared.
+type dashDiffEvent struct {
+	Serial  string `json:"serial"`
+	Project string `json:"project"`
+	dash.Diff
+}
+
+// dashApplyEvent is "apply": tuning `pusher dash apply` wrote into the source.
+type dashApplyEvent struct {
+	Serial  string   `json:"serial"`
+	Applied []string `json:"applied"`
+	Files   []string `json:"files,omitempty"`
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// dashSetEvent is "set": values `pusher dash set` or `dash load` sent to the
+// dashboard, or would have sent on a dry run.
+type dashSetEvent struct {
+	Serial    string         `json:"serial"`
+	DryRun    bool           `json:"dry_run"`
+	Changed   []dash.Setting `json:"changed"`
+	Unchanged int            `json:"unchanged"`
+	Skipped   []string       `json:"skipped,omitempty"`
+}
+
+// snapshotEvent is "snapshot": a named tuning set kept, or shown.
+type snapshotEvent struct {
+	Name      string      `json:"name"`
+	Serial    string      `json:"serial"`
+	Taken     time.Time   `json:"taken"`
+	Automatic bool        `json:"automatic"`
+	Count     int         `json:"count"`
+	Values    dash.Values `json:"values"`
+}
+
+// snapshotsEvent is "snapshots": the list `pusher dash snapshot list` prints.
+type snapshotsEvent struct {
+	Serial    string          `json:"serial"`
+	Snapshots []snapshotEntry `json:"snapshots"`
+}
+
+// snapshotEntry is one snapshot in a list, without its values.
+type snapshotEntry struct {
+	Name      string    `json:"name"`
+	Taken     time.Time `json:"taken"`
+	Automatic bool      `json:"automatic"`
+	Count     int       `json:"count"`
+}
+
+// compareEvent is "compare": what `pusher dash snapshot diff` found.
+type compareEvent struct {
+	Serial      string            `json:"serial"`
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Differences []dash.Difference `json:"differences"`
+}
+
+// statusEvent is "status": what the robot says it is running, about once a
+// second during `pusher dash watch`.
+type statusEvent struct {
+	OpMode  string `json:"opmode"`
+	State   string `json:"state"`
+	Warning string `json:"warning,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// runEvent is "run": an OpMode `pusher run` drove, and how it ended.
+type runEvent struct {
+	Serial      string `json:"serial"`
+	OpMode      string `json:"opmode"`
+	Initialised bool   `json:"initialised"`
+	Started     bool   `json:"started"`
+	// StoppedBy is stop-after, interrupt or opmode, empty with --init-only.
+	StoppedBy string  `json:"stopped_by,omitempty"`
+	Seconds   float64 `json:"seconds"`
+	OK        bool    `json:"ok"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// cacheEvent is "cache": one robot's chunk cache, as `pusher cache` found or
+// left it.
+type cacheEvent struct {
+	Serial string `json:"serial"`
+	// Action is status, prune or clear.
+	Action      string  `json:"action"`
+	Chunks      int     `json:"chunks"`
+	Bytes       int64   `json:"bytes"`
+	Manifests   int     `json:"manifests,omitempty"`
+	Pushes      int     `json:"pushes,omitempty"`
+	SentBytes   int64   `json:"sent_bytes,omitempty"`
+	ReusedBytes int64   `json:"reused_bytes,omitempty"`
+	HitRate     float64 `json:"hit_rate,omitempty"`
+	Removed     int     `json:"removed,omitempty"`
+	Freed       int64   `json:"freed,omitempty"`
+	// Free is the space left on the robot, -1 when not known.
+	Free  int64  `json:"free"`
+	Error string `json:"error,omitempty"`
+}
+
+// rollbackEvent is "rollback": a build put back with `pusher rollback`.
+type rollbackEvent struct {
+	Serial string `json:"serial"`
+	// Back is how many builds back it went.
+	Back        int     `json:"back"`
+	Fingerprint string  `json:"fingerprint"`
+	Commit      string  `json:"commit,omitempty"`
+	BytesSent   int64   `json:"bytes_sent"`
+	Seconds     float64 `json:"seconds"`
+	OK          bool    `json:"ok"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// deploysEvent is "deploys": the builds kept for a robot, newest first.
+type deploysEvent struct {
+	Serial string        `json:"serial"`
+	Builds []deployEntry `json:"builds"`
+}
+
+// deployEntry is one kept build. Back is what `pusher rollback` takes to put
+// it back, negative for one newer than the robot runs.
+type deployEntry struct {
+	Back        int       `json:"back"`
+	Fingerprint string    `json:"fingerprint"`
+	Commit      string    `json:"commit,omitempty"`
+	Dirty       bool      `json:"dirty,omitempty"`
+	Installed   time.Time `json:"installed"`
+	RolledBack  bool      `json:"rolled_back,omitempty"`
+}
+
+// stampEvent is "stamp": what `pusher status` read off one robot.
+type stampEvent struct {
+	Serial  string    `json:"serial"`
+	Commit  string    `json:"commit,omitempty"`
+	Branch  string    `json:"branch,omitempty"`
+	Dirty   bool      `json:"dirty,omitempty"`
+	Author  string    `json:"author,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	Kind    string    `json:"kind,omitempty"`
+	Time    time.Time `json:"time,omitempty"`
+	Version string    `json:"pusher_version,omitempty"`
+	// Stale is an app installed since the stamp by something else.
+	Stale bool `json:"stale,omitempty"`
+	// Local is the commit of the tree status was run in.
+	Local       string   `json:"local,omitempty"`
+	Differences []string `json:"differences,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// doneEvent is "done": the last line of every command, so a reader knows the
+// stream ended on purpose.
+type doneEvent struct {
+	Command string `json:"command"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
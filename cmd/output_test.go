@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/dash"
+)
+
+// Scripts parse these lines, so the envelope and the field names are the
+// contract. Renaming one breaks somebody's tooling without a compile error
+// anywhere, which is why they are pinned here.
+func TestEventsAreOneEnvelopePerLine(t *testing.T) {
+	var out bytes.Buffer
+	jsonOut = &out
+	t.Cleanup(func() { jsonOut = nil })
+
+	emit("build", buildEvent{Offline: true, Seconds: 1.5, OK: true})
+	emit("diff", dashDiffEvent{Serial: "abc", Diff: dash.Compare(
+		dash.Values{"Drive.kP": "0.2"},
+		dash.Source{"Drive.kP": {Section: "Drive", Name: "kP", Value: "0.1", File: "Drive.java", Line: 9}},
+		nil)})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 lines, got %d:\n%s", len(lines), out.String())
+	}
+
+	var build map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &build); err != nil {
+		t.Fatal(err)
+	}
+	if build["event"] != "build" || build["time"] == nil {
+		t.Errorf("the envelope is missing its event or time: %s", lines[0])
+	}
+	data, _ := build["data"].(map[string]any)
+	if data["offline"] != true || data["ok"] != true || data["seconds"] != 1.5 {
+		t.Errorf("build data has the wrong shape: %s", lines[0])
+	}
+	if _, present := data["error"]; present {
+		t.Errorf("a build that worked must not carry an error field: %s", lines[0])
+	}
+
+	var diff struct {
+		Data struct {
+			Serial  string        `json:"serial"`
+			Unsaved []dash.Change `json:"unsaved"`
+			Saved   []dash.Change `json:"saved"`
+			Unknown []string      `json:"unknown"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff.Data.Serial != "abc" || len(diff.Data.Unsaved) != 1 || diff.Data.Unsaved[0].Live != "0.2" {
+		t.Errorf("diff data has the wrong shape: %s", lines[1])
+	}
+	if diff.Data.Saved == nil || diff.Data.Unknown == nil {
+		t.Errorf("empty lists must be [] rather than null: %s", lines[1])
+	}
+}
+
+func TestUnknownOutputFormatIsRefused(t *testing.T) {
+	outputFormat = "yaml"
+	t.Cleanup(func() { outputFormat = "text" })
+
+	if err := beginOutput(); err == nil {
+		t.Error("an output format pusher does not write must be refused")
+	}
+}
+
+// Moving os.Stdout itself would move it for everything in the process, the
+// events included; only the human writer should change.
+func TestJSONOutputMovesOnlyTheHumanWriter(t *testing.T) {
+	stdout := os.Stdout
+	outputFormat = "json"
+	t.Cleanup(func() {
+		outputFormat = "text"
+		jsonOut = nil
+		console.Out = os.Stdout
+	})
+
+	if err := beginOutput(); err != nil {
+		t.Fatal(err)
+	}
+	if os.Stdout != stdout {
+		t.Error("os.Stdout must be left where it was")
+	}
+	if jsonOut != stdout {
+		t.Error("events must go to stdout")
+	}
+	if console.Out != os.Stderr {
+		t.Error("human output must go to stderr")
+	}
+}
@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/gradle"
 	"github.com/spf13/cobra"
 )
@@ -20,22 +20,22 @@ and you want the build to succeed from cache alone.`,
 }
 
 func runPrepare(cmd *cobra.Command, args []string) error {
-	fmt.Println("[*] Detecting Gradle wrapper...")
+	console.Println("[*] Detecting Gradle wrapper...")
 	wrapper, err := gradle.DetectWrapper()
 	if err != nil {
 		return fmt.Errorf("failed to detect Gradle wrapper: %w", err)
 	}
-	fmt.Printf("[OK] Found Gradle wrapper: %s\n", wrapper)
+	console.Printf("[OK] Found Gradle wrapper: %s\n", wrapper)
 
-	fmt.Println("\n[#] Preparing Gradle cache (online build)...")
-	fmt.Println("─────────────────────────────────────────")
+	console.Println("\n[#] Preparing Gradle cache (online build)...")
+	console.Println("─────────────────────────────────────────")
 
-	if err := gradle.Build(wrapper, false, os.Stdout); err != nil {
+	if err := gradle.Build(wrapper, false, console.Out); err != nil {
 		return fmt.Errorf("prepare failed: %w", err)
 	}
 
-	fmt.Println("─────────────────────────────────────────")
-	fmt.Println("\n[OK] Gradle dependencies cached. Builds will now work without internet.")
+	console.Println("─────────────────────────────────────────")
+	console.Println("\n[OK] Gradle dependencies cached. Builds will now work without internet.")
 
 	return nil
 }
@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/andreibanu/pusher/internal/adb"
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/extreme"
 	"github.com/andreibanu/pusher/internal/gradle"
+	"github.com/andreibanu/pusher/internal/robotcfg"
 	"github.com/andreibanu/pusher/internal/updates"
 	"github.com/andreibanu/pusher/internal/wifi"
 	"github.com/spf13/cobra"
@@ -27,17 +31,29 @@ var pushCmd = &cobra.Command{
 
 If a hub is attached over USB, pusher uses it and leaves your Wi-Fi alone.
 Otherwise it joins the robot's network, deploys, and puts you back on the
-network you started on.`,
+network you started on.
+
+With --robots, or a fleet chosen in 'pusher settings', it builds once and
+deploys that build to each robot in turn over Wi-Fi, then returns to your
+network once at the end. One robot failing does not stop the rest.`,
 	RunE: runPush,
 }
 
+func init() {
+	pushCmd.Flags().BoolVar(&whenIdle, "when-idle", false,
+		"With Pusher Extreme, hold a reload until the running OpMode ends")
+	pushCmd.Flags().BoolVar(&stopOpMode, "stop-opmode", false,
+		"With Pusher Extreme, stop a running OpMode and then reload")
+	pushCmd.MarkFlagsMutuallyExclusive("when-idle", "stop-opmode")
+}
+
 func runPush(cmd *cobra.Command, args []string) error {
 
 	gradlePath, err := gradle.DetectWrapper()
 	if err != nil {
 		return fmt.Errorf("failed to detect Gradle wrapper: %w", err)
 	}
-	fmt.Printf("[OK] Gradle wrapper: %s\n", gradlePath)
+	console.Printf("[OK] Gradle wrapper: %s\n", gradlePath)
 
 	// Started before anything else so it overlaps the checks below, and asked
 	// twice: once here and once when the deploy is over.
@@ -67,17 +83,32 @@ func push(gradlePath string, blob *updates.BlobCheck) error {
 		}
 	}
 
+	// Also before anything is built: a class that compiles can still name a
+	// device the configuration no longer has.
+	root := gradle.ProjectDir(gradlePath)
+	if err := checkGenerated(filepath.Join(root, extreme.SourceRoot), robotcfg.NewStore(robotcfg.LocalDir(root))); err != nil {
+		if !ignoreWarnings {
+			return fmt.Errorf("%w.\n    Regenerate it, or pass --ignore-warnings to deploy it as it is", err)
+		}
+		console.Println("    Carrying on anyway because --ignore-warnings was passed.")
+	}
+	warnLookups(root)
+
+	if robots := fleetTargets(); len(robots) > 0 {
+		return pushFleet(gradlePath, robots)
+	}
+
 	if config.GetPreferUSB() {
 		if device, ok := adb.FindUSBDevice(); ok {
-			fmt.Printf("[OK] Hub attached over USB: %s\n", device.Label())
-			fmt.Println("    Using USB - your Wi-Fi will not be touched.")
+			console.Printf("[OK] Hub attached over USB: %s\n", device.Label())
+			console.Println("    Using USB - your Wi-Fi will not be touched.")
 
 			rememberHubABI(device.Serial)
 			if config.GetAutoSlim() {
 				applyAutoSlim()
 			}
 
-			return deploy(gradlePath, device.Serial, false)
+			return deploy(gradlePath, device.Serial)
 		}
 	}
 
@@ -94,6 +125,10 @@ func pushOverWiFi(gradlePath string) error {
 		return fmt.Errorf("no robot profile configured: %w\n\nRun 'pusher settings' to add one", err)
 	}
 
+	if profile.Paired() && profile.SSID == "" {
+		return pushPaired(gradlePath)
+	}
+
 	wifiMgr := wifi.NewManager()
 
 	onRobot, err := wifiMgr.IsOnRobotNetwork()
@@ -119,7 +154,7 @@ func pushOverWiFi(gradlePath string) error {
 	}
 
 	if home != "" {
-		fmt.Printf("[OK] Currently on: %s\n", home)
+		console.Printf("[OK] Currently on: %s\n", home)
 	}
 
 	slimmedFor := ""
@@ -128,22 +163,25 @@ func pushOverWiFi(gradlePath string) error {
 		applyAutoSlim()
 	}
 
-	if err := buildProject(gradlePath, onRobot); err != nil {
+	if err := buildProject(gradlePath); err != nil {
 		return err
 	}
 
 	if !onRobot {
-		fmt.Printf("\n[>] Joining robot Wi-Fi: %s\n", profile.SSID)
+		console.Printf("\n[>] Joining robot Wi-Fi: %s\n", profile.SSID)
 		ip, err := joinRobot(wifiMgr, watcher, profile)
 		if err != nil {
+			emit("join", joinEvent{Robot: profile.Name, SSID: profile.SSID, Error: err.Error()})
 			return err
 		}
-		fmt.Printf("[OK] On the robot network (%s)\n", ip)
+		console.Printf("[OK] On the robot network (%s)\n", ip)
+		emit("join", joinEvent{Robot: profile.Name, SSID: profile.SSID, IP: ip, OK: true})
 	} else {
-		fmt.Println("[OK] Already on the robot network")
+		console.Println("[OK] Already on the robot network")
+		emit("join", joinEvent{Robot: profile.Name, SSID: profile.SSID, Already: true, OK: true})
 	}
 
-	deployErr := deployToRobot(gradlePath, slimmedFor)
+	deployErr := deployToRobot(gradlePath, slimmedFor, adb.Locate())
 
 	leavingRobot := switchBack && home != ""
 
@@ -152,32 +190,61 @@ func pushOverWiFi(gradlePath string) error {
 	}
 
 	if leavingRobot {
-		fmt.Printf("\n[<] Returning to %s...\n", home)
-		if err := wifiMgr.Rejoin(home, robotSSIDs()); err != nil {
-			fmt.Printf("[!] Could not rejoin %s: %v\n", home, err)
-			fmt.Println("    You will need to switch back manually.")
-		} else if _, err := wifiMgr.WaitToLeave(wifi.RobotSubnet, 45*time.Second); err != nil {
-			fmt.Printf("[!] Could not get back onto %s: %v\n", home, err)
-			fmt.Println("    You will need to switch back manually.")
-		} else {
-			fmt.Printf("[OK] Back on %s\n", home)
-		}
+		returnHome(wifiMgr, home)
 	}
 
 	return deployErr
 }
 
+// pushPaired deploys to a robot paired for wireless debugging on the network
+// this computer is already on. There is no Wi-Fi to join or come back from,
+// and the build can reach the internet for anything it has not cached.
+func pushPaired(gradlePath string) error {
+	slimmedFor := ""
+	if config.GetAutoSlim() {
+		slimmedFor = config.GetHubABI()
+		applyAutoSlim()
+	}
+
+	if err := buildProject(gradlePath); err != nil {
+		return err
+	}
+
+	err := deployToRobot(gradlePath, slimmedFor, adb.Locate())
+	if err == nil {
+		disconnectADB()
+	}
+	return err
+}
+
+// returnHome puts the machine back on the network it started on.
+func returnHome(wifiMgr *wifi.Manager, home string) {
+	console.Printf("\n[<] Returning to %s...\n", home)
+	if err := wifiMgr.Rejoin(home, robotSSIDs()); err != nil {
+		console.Printf("[!] Could not rejoin %s: %v\n", home, err)
+		console.Println("    You will need to switch back manually.")
+		emit("rejoin", rejoinEvent{SSID: home, Error: err.Error()})
+	} else if _, err := wifiMgr.WaitToLeave(wifi.RobotSubnet, 45*time.Second); err != nil {
+		console.Printf("[!] Could not get back onto %s: %v\n", home, err)
+		console.Println("    You will need to switch back manually.")
+		emit("rejoin", rejoinEvent{SSID: home, Error: err.Error()})
+	} else {
+		console.Printf("[OK] Back on %s\n", home)
+		emit("rejoin", rejoinEvent{SSID: home, OK: true})
+	}
+}
+
 func disconnectADB() {
 	if !adb.IsInstalled() {
 		return
 	}
 
 	if err := adb.Disconnect(); err != nil {
-		fmt.Printf("[!] Warning: could not disconnect ADB: %v\n", err)
+		console.Printf("[!] Warning: could not disconnect ADB: %v\n", err)
 		return
 	}
 
-	fmt.Println("[OK] ADB disconnected")
+	console.Println("[OK] ADB disconnected")
 }
 
 func resolveHomeNetwork(wifiMgr *wifi.Manager, onRobot, switchBack bool, robotSSID string) (string, error) {
@@ -190,9 +257,9 @@ func resolveHomeNetwork(wifiMgr *wifi.Manager, onRobot, switchBack bool, robotSS
 	}
 
 	if onRobot {
-		fmt.Println("[!] Already on the robot network, so pusher cannot tell where you")
-		fmt.Println("    came from and will leave you here when it finishes.")
-		fmt.Println("    Set one in 'pusher settings' -> Home Wi-Fi network to change that.")
+		console.Println("[!] Already on the robot network, so pusher cannot tell where you")
+		console.Println("    came from and will leave you here when it finishes.")
+		console.Println("    Set one in 'pusher settings' -> Home Wi-Fi network to change that.")
 		return "", nil
 	}
 
@@ -209,50 +276,63 @@ func resolveHomeNetwork(wifiMgr *wifi.Manager, onRobot, switchBack bool, robotSS
 
 		inferred, inferErr := wifiMgr.MostRecentNetwork(robotSSID)
 		if inferErr == nil && inferred != "" {
-			fmt.Printf("[*] The network name is hidden; assuming you are on %q\n", inferred)
-			fmt.Println("    (set it explicitly in 'pusher settings' if that is wrong)")
+			console.Printf("[*] The network name is hidden; assuming you are on %q\n", inferred)
+			console.Println("    (set it explicitly in 'pusher settings' if that is wrong)")
 			return inferred, nil
 		}
 
-		fmt.Println("[!] Cannot tell which network you are on, so pusher will leave you")
-		fmt.Println("    on the robot's network. Set one in 'pusher settings'.")
+		console.Println("[!] Cannot tell which network you are on, so pusher will leave you")
+		console.Println("    on the robot's network. Set one in 'pusher settings'.")
 		return "", nil
 	}
 
 	return "", nil
 }
 
-func buildProject(gradlePath string, offline bool) error {
-	fmt.Println("\n[#] Building...")
+// buildProject builds unless nothing has changed since pusher last built the
+// APK that is there, and builds offline when the repositories cannot be
+// reached, from the robot's network or anywhere else without internet.
+func buildProject(gradlePath string) error {
+	if !gradle.Changed(gradlePath) {
+		console.Println("\n[=] Nothing has changed since the last build, so Gradle is skipped")
+		emit("build", buildEvent{Skipped: true, OK: true})
+		return nil
+	}
+
+	offline := !gradle.Online()
+
+	console.Println("\n[#] Building...")
 	if offline {
-		fmt.Println("    (offline - on the robot network, using cached dependencies)")
+		console.Println("    (offline - no internet here, using cached dependencies)")
 	}
-	fmt.Println("─────────────────────────────────────────")
+	console.Println("─────────────────────────────────────────")
 
 	start := time.Now()
-	if err := gradle.Build(gradlePath, offline, os.Stdout); err != nil {
+	if err := gradle.Build(gradlePath, offline, console.Out); err != nil {
+		emit("build", failedBuild(offline, time.Since(start).Seconds(), gradle.ProjectDir(gradlePath), err))
 		return fmt.Errorf("build failed: %w", err)
 	}
 
-	fmt.Println("─────────────────────────────────────────")
-	fmt.Printf("[OK] Built in %.1fs\n", time.Since(start).Seconds())
+	console.Println("─────────────────────────────────────────")
+	console.Printf("[OK] Built in %.1fs\n", time.Since(start).Seconds())
+	emit("build", buildEvent{Offline: offline, Seconds: time.Since(start).Seconds(), OK: true})
 	return nil
 }
 
-func deployToRobot(gradlePath, slimmedFor string) error {
-	fmt.Println("\n[+] Connecting to robot via ADB...")
-	if err := adb.Connect(); err != nil {
+func deployToRobot(gradlePath, slimmedFor, addr string) error {
+	console.Println("\n[+] Connecting to robot via ADB...")
+	if err := adb.ConnectTo(addr); err != nil {
 		return fmt.Errorf("failed to connect via ADB: %w", err)
 	}
-	fmt.Println("[OK] Connected via ADB")
+	console.Println("[OK] Connected via ADB")
 
-	warnOnABIMismatch(slimmedFor, rememberHubABI(adb.RobotAddr()))
+	warnOnABIMismatch(slimmedFor, rememberHubABI(addr))
 
-	return install(gradlePath, adb.RobotAddr())
+	return install(gradlePath, addr)
 }
 
-func deploy(gradlePath, serial string, offline bool) error {
-	if err := buildProject(gradlePath, offline); err != nil {
+func deploy(gradlePath, serial string) error {
+	if err := buildProject(gradlePath); err != nil {
 		return err
 	}
 	return install(gradlePath, serial)
@@ -287,12 +367,14 @@ func deployOnce(gradlePath, serial string) error {
 		return fmt.Errorf("failed to find APK: %w", err)
 	}
 
-	fmt.Printf("\n[*] APK: %s\n", apkPath)
+	console.Printf("\n[*] APK: %s\n", apkPath)
 
 	opt := adb.Options{
 		Delta:         config.GetDeltaTransfer(),
 		SkipUnchanged: config.GetSkipUnchanged(),
 		Stream:        config.GetStreamInstall(),
+		Compress:      config.GetCompressChunks(),
+		Patch:         config.GetPatchTransfer(),
 	}
 	if config.GetSplitInstall() {
 		opt.Splits = gradle.FindSplits(gradle.ProjectDir(gradlePath))
@@ -300,6 +382,14 @@ func deployOnce(gradlePath, serial string) error {
 
 	start := time.Now()
 	plan, err := adb.InstallWith(serial, apkPath, opt)
+
+	emit("install", installEvent{
+		Serial: serial, APK: apkPath,
+		Skipped: plan.Skipped, Streamed: plan.Streamed, Delta: plan.Delta, Patched: plan.Patched,
+		Splits: plan.Splits, Reason: plan.Reason, BytesSent: plan.BytesSent,
+		Seconds: time.Since(start).Seconds(), OK: err == nil, Error: errText(err),
+	})
+
 	if err != nil {
 		return fmt.Errorf("install failed: %w", err)
 	}
@@ -307,14 +397,16 @@ func deployOnce(gradlePath, serial string) error {
 	// The robot now holds this project's non-team-code state, so the next
 	// deploy can tell that only team code changed and reload instead.
 	recordExtremeState(serial)
+	rememberDeploy(gradlePath, serial, apkPath, plan)
+	stampRobot(serial, "install", gradle.ProjectDir(gradlePath))
 
 	switch {
 	case plan.Skipped:
-		fmt.Printf("\n[=] Nothing to install: %s (%.1fs)\n", plan.Reason, time.Since(start).Seconds())
+		console.Printf("\n[=] Nothing to install: %s (%.1fs)\n", plan.Reason, time.Since(start).Seconds())
 	case plan.Splits > 0:
-		fmt.Printf("\n[OK] Deployed %d changed split(s) in %.1fs\n", plan.Splits, time.Since(start).Seconds())
+		console.Printf("\n[OK] Deployed %d changed split(s) in %.1fs\n", plan.Splits, time.Since(start).Seconds())
 	default:
-		fmt.Printf("\n[OK] Deployed in %.1fs\n", time.Since(start).Seconds())
+		console.Printf("\n[OK] Deployed in %.1fs\n", time.Since(start).Seconds())
 	}
 
 	// The APK just installed has no team code in it, so this is not finished
@@ -351,13 +443,13 @@ func ensureProfile() error {
 }
 
 func firstRunSetup() error {
-	fmt.Println("\nWelcome to Pusher!")
-	fmt.Println("No robot profiles found. Let's set one up.")
-	fmt.Println()
+	console.Println("\nWelcome to Pusher!")
+	console.Println("No robot profiles found. Let's set one up.")
+	console.Println()
 
 	reader := bufio.NewReader(os.Stdin)
 
-	fmt.Print("Robot Wi-Fi SSID: ")
+	console.Print("Robot Wi-Fi SSID: ")
 	ssid, err := reader.ReadString('\n')
 	if err != nil {
 		return fmt.Errorf("failed to read SSID: %w", err)
@@ -367,17 +459,17 @@ func firstRunSetup() error {
 		return fmt.Errorf("SSID cannot be empty")
 	}
 
-	fmt.Print("Robot Wi-Fi Password: ")
+	console.Print("Robot Wi-Fi Password: ")
 	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
 	if err != nil {
 		return fmt.Errorf("failed to read password: %w", err)
 	}
-	fmt.Println()
+	console.Println()
 
 	if err := config.AddProfile("default", ssid, string(passwordBytes)); err != nil {
 		return fmt.Errorf("failed to save profile: %w", err)
 	}
 
-	fmt.Println("\n[OK] Profile saved as 'default'")
+	console.Println("\n[OK] Profile saved as 'default'")
 	return nil
 }
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/adb"
+	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/deployed"
+	"github.com/andreibanu/pusher/internal/extreme"
+	"github.com/andreibanu/pusher/internal/gradle"
+	"github.com/andreibanu/pusher/internal/robotcfg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollbackSerial string
+	rollbackList   bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [n]",
+	Short: "Put back a build the robot had before",
+	Long: `Every APK pusher installs is kept on this computer with the commit it was
+built from, the last 5 for each robot. rollback reinstalls one of them without
+building anything, so a deploy that broke the robot can be undone between
+matches.
+
+  pusher rollback          the build before the one the robot runs
+  pusher rollback 2        two builds back from it
+  pusher rollback --list   what there is to go back to
+
+Builds are counted back from the one the robot runs, so a second rollback goes
+further back rather than forward again. When it runs none of them, they are
+counted from the latest.
+
+The install goes the usual way, so chunks still in the robot's cache are not
+sent again.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRollback,
+}
+
+func init() {
+	rollbackCmd.Flags().StringVar(&rollbackSerial, "serial", "",
+		"Robot to roll back (default: the connected one)")
+	rollbackCmd.Flags().BoolVar(&rollbackList, "list", false, "List the builds kept for the robot")
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	serial := rollbackSerial
+	if serial == "" {
+		target, err := adb.Target()
+		if err != nil {
+			return err
+		}
+		serial = target
+	}
+
+	robot := robotcfg.RobotID(serial)
+	builds := deployed.List(config.Dir(), robot)
+	from := max(deployed.Current(builds, adb.InstalledFingerprint(serial)), 0)
+	if rollbackList {
+		listDeploys(serial, builds, from)
+		return nil
+	}
+
+	// Zero would be the build the robot already runs, which is not a rollback.
+	n := 1
+	if len(args) == 1 {
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil || parsed < 1 {
+			return fmt.Errorf("%q is not a number of builds back: 1 is the one before the robot's", args[0])
+		}
+		n = parsed
+	}
+
+	switch {
+	case len(builds) == 0:
+		return fmt.Errorf("no deploys from pusher recorded for %s yet", serial)
+	case from+n >= len(builds):
+		return fmt.Errorf("only %d builds are kept from before the one %s runs; `pusher rollback --list` shows them",
+			len(builds)-from-1, serial)
+	}
+
+	build := builds[from+n]
+	apk, ok := deployed.APK(config.Dir(), build.Fingerprint)
+	if !ok {
+		return fmt.Errorf("the APK for %s is no longer on this computer", build.Label())
+	}
+
+	console.Printf("[*] Rolling back to %s\n", build.Label())
+
+	start := time.Now()
+	plan, err := adb.InstallWith(serial, apk, adb.Options{
+		Delta:    config.GetDeltaTransfer(),
+		Stream:   config.GetStreamInstall(),
+		Compress: config.GetCompressChunks(),
+		Patch:    config.GetPatchTransfer(),
+	})
+
+	emit("rollback", rollbackEvent{
+		Serial: serial, Back: n, Fingerprint: build.Fingerprint, Commit: build.Commit,
+		BytesSent: plan.BytesSent, Seconds: time.Since(start).Seconds(), OK: err == nil, Error: errText(err),
+	})
+	if err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+
+	// Whatever Pusher Extreme recorded describes the project as it is now,
+	// which this APK is not, so the next deploy must not take it for a match.
+	extreme.ForgetSignature(serial)
+	writeStamp(serial, "rollback", deployed.Source{Commit: build.Commit, Dirty: build.Dirty})
+
+	build.RolledBack = true
+	if err := deployed.Record(config.Dir(), robot, apk, build); err != nil {
+		console.Printf("[!] Could not note the rollback in the history: %v\n", err)
+	}
+
+	console.Printf("\n[OK] Rolled back in %.1fs\n", time.Since(start).Seconds())
+	if build.Reloaded {
+		console.Println("[!] That build's team code was reloaded by Pusher Extreme rather than packaged,")
+		console.Println("    so the robot has the app around it but not its OpModes. Run `pusher` to")
+		console.Println("    reload the team code you have now.")
+	}
+	return nil
+}
+
+// listDeploys prints the builds kept for a robot, numbered as rollback counts
+// them: back from the one at from, which the robot runs.
+func listDeploys(serial string, builds []deployed.Build, from int) {
+	entries := make([]deployEntry, 0, len(builds))
+	for i, b := range builds {
+		entries = append(entries, deployEntry{
+			Back: i - from, Fingerprint: b.Fingerprint, Commit: b.Commit, Dirty: b.Dirty,
+			Installed: b.Installed, RolledBack: b.RolledBack,
+		})
+	}
+	emit("deploys", deploysEvent{Serial: serial, Builds: entries})
+
+	if len(builds) == 0 {
+		console.Printf("No deploys from pusher recorded for %s yet.\n", serial)
+		return
+	}
+
+	current := adb.InstalledFingerprint(serial)
+	console.Printf("Builds kept for %s\n", serial)
+	console.Println("─────────────────────────────────────────")
+	for i, b := range builds {
+		// Newer than the robot's, after a rollback: reached by deploying, not
+		// by rolling back, so they have no number.
+		back := "-"
+		if i >= from {
+			back = strconv.Itoa(i - from)
+		}
+
+		note := ""
+		switch {
+		case b.Fingerprint == current:
+			note = "  on the robot"
+		case b.RolledBack:
+			note = "  rolled back to"
+		}
+		if _, ok := deployed.APK(config.Dir(), b.Fingerprint); !ok {
+			note += "  (APK missing)"
+		}
+		console.Printf("  %s  %-40s %5.1f MB%s\n", back, b.Label(), float64(b.Size)/(1<<20), note)
+	}
+}
+
+// rememberDeploy adds what a deploy installed to the robot's history, for
+// rollback. A history that could not be written costs the rollback, not the
+// deploy, so it is only reported.
+func rememberDeploy(gradlePath, serial, apkPath string, plan adb.InstallPlan) {
+	if plan.Fingerprint == "" {
+		return
+	}
+
+	source := deployed.ReadSource(gradle.ProjectDir(gradlePath))
+	build := deployed.Build{Fingerprint: plan.Fingerprint, Commit: source.Commit, Dirty: source.Dirty}
+	if project, err := extreme.FindProject(); err == nil && !apkCarriesTeamCode(project.Root) {
+		build.Reloaded = true
+	}
+
+	if err := deployed.Record(config.Dir(), robotcfg.RobotID(serial), apkPath, build); err != nil {
+		console.Printf("[!] Could not keep this build for rollback: %v\n", err)
+	}
+}
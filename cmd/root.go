@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/feature"
 	"github.com/andreibanu/pusher/internal/selfupdate"
 	"github.com/andreibanu/pusher/internal/telemetry"
@@ -27,9 +28,12 @@ var rootCmd = &cobra.Command{
 	Short:        "FTC Robot deployment tool",
 	Long:         `Pusher automates connecting to FTC robots and deploying Android Studio projects.`,
 	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return beginOutput()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if versionFlag {
-			fmt.Printf("Pusher version %s\n", appVersion)
+			console.Printf("Pusher version %s\n", appVersion)
 			return nil
 		}
 		return pushCmd.RunE(cmd, args)
@@ -54,7 +58,10 @@ func Execute(version string) {
 	counted := telemetry.Start(version)
 	newer := updates.Watch()
 
-	err := rootCmd.Execute()
+	ran, err := rootCmd.ExecuteC()
+	if ran != nil {
+		emit("done", doneEvent{Command: ran.CommandPath(), OK: err == nil, Error: errText(err)})
+	}
 
 	counted.Finish(pingWait)
 	newer.Finish(pingWait)
@@ -71,6 +78,16 @@ const pingWait = 1500 * time.Millisecond
 func init() {
 
 	rootCmd.Flags().BoolVarP(&versionFlag, "version", "v", false, "Show version information")
+	// Persistent, so 'pusher --robots' and 'pusher push --robots' are one flag.
+	rootCmd.PersistentFlags().StringSliceVar(&pushRobots, "robots", nil,
+		"Deploy to these robot profiles in turn, e.g. --robots comp,practice")
+	rootCmd.Flags().BoolVar(&whenIdle, "when-idle", false,
+		"With Pusher Extreme, hold a reload until the running OpMode ends")
+	rootCmd.Flags().BoolVar(&stopOpMode, "stop-opmode", false,
+		"With Pusher Extreme, stop a running OpMode and then reload")
+	rootCmd.MarkFlagsMutuallyExclusive("when-idle", "stop-opmode")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text",
+		"Output format: text, or json for one event per line on stdout")
 	rootCmd.PersistentFlags().BoolVar(&ignoreWarnings, "ignore-warnings", false,
 		"Carry on past a check that would otherwise stop the command")
 
@@ -81,10 +98,15 @@ func init() {
 	rootCmd.AddCommand(prepareCmd)
 	rootCmd.AddCommand(settingsCmd)
 	rootCmd.AddCommand(slimCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(hwconfigCmd)
 	rootCmd.AddCommand(doctorCmd)
 	rootCmd.AddCommand(updateCmd)
 	rootCmd.AddCommand(dashCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(watchCmd)
 	rootCmd.AddCommand(devCmd)
 	rootCmd.AddCommand(visualiseCmd)
 	rootCmd.AddCommand(helpCmd)
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/adb"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/dash"
+	"github.com/andreibanu/pusher/internal/feature"
+	"github.com/andreibanu/pusher/internal/pathtrace"
+	"github.com/andreibanu/pusher/internal/visual"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runInitOnly  bool
+	runStopAfter time.Duration
+	runDraw      bool
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <OpMode>",
+	Short: "Init, start and stop an OpMode from here",
+	Long: `Initialises an OpMode on the robot, starts it, and waits for it to finish,
+through FtcDashboard, the same way the Driver Station's buttons do.
+
+The name does not have to be exact. "closeblue" finds "Close Blue", and so
+does "cbl" when nothing else fits; more than one match is listed rather than
+guessed between.
+
+  pusher run CloseBlue                   run until the OpMode ends itself
+  pusher run CloseBlue --stop-after 30s  stop it after thirty seconds
+  pusher run CloseBlue --init-only       initialise and leave it waiting
+
+Ctrl-C stops the OpMode before pusher exits, so nothing keeps driving because
+the terminal went away.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runOpMode,
+}
+
+func init() {
+	runCmd.Flags().BoolVar(&runInitOnly, "init-only", false, "Initialise the OpMode and leave it waiting for start")
+	runCmd.Flags().DurationVar(&runStopAfter, "stop-after", 0, "Stop the OpMode after this long, e.g. 30s")
+	runCmd.Flags().BoolVar(&runDraw, "visualise", false, "Draw the path it drove once it ends")
+	_ = runCmd.Flags().MarkHidden("visualise")
+}
+
+func runOpMode(cmd *cobra.Command, args []string) error {
+	if runDraw && !feature.Revealed() {
+		return fmt.Errorf("unknown flag: --visualise")
+	}
+
+	serial, err := dash.Robot()
+	if err != nil {
+		return err
+	}
+
+	modes, err := dash.Registered(serial)
+	if err != nil {
+		return fmt.Errorf("%w\n    Is FtcDashboard running? It needs the robot app started", err)
+	}
+
+	name, err := pickOpMode(dash.Names(modes), args[0])
+	if err != nil {
+		return err
+	}
+
+	stream, err := dash.Watch(serial)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	event := runEvent{Serial: serial, OpMode: name}
+	start := time.Now()
+
+	err = driveOpMode(stream, name, &event)
+	event.Seconds = time.Since(start).Seconds()
+	event.OK, event.Error = err == nil, errText(err)
+	emit("run", event)
+
+	if err != nil {
+		return err
+	}
+
+	if runDraw && event.Started {
+		return visualiseRun(name)
+	}
+	return nil
+}
+
+// pickOpMode turns what was typed into one of the robot's OpModes.
+func pickOpMode(names []string, query string) (string, error) {
+	hits, err := dash.Match(names, query)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(hits) {
+	case 0:
+		return "", fmt.Errorf("the robot has no OpMode like %q\navailable: %s", query, strings.Join(names, ", "))
+	case 1:
+		if hits[0] != query {
+			console.Printf("[*] %s\n", hits[0])
+		}
+		return hits[0], nil
+	}
+
+	return "", fmt.Errorf("%q could be any of: %s", query, strings.Join(hits, ", "))
+}
+
+// driveOpMode takes the OpMode through init, start and stop, recording what
+// happened in event as it goes.
+func driveOpMode(stream *dash.Stream, name string, event *runEvent) error {
+	status, err := stream.Await(func(dash.Status) bool { return true }, dash.Timeout, nil)
+	if err != nil {
+		return fmt.Errorf("the robot did not say what it is doing: %w", err)
+	}
+
+	if !status.Available {
+		return fmt.Errorf("the robot app is not ready to run an OpMode")
+	}
+
+	// The robot stops an active OpMode itself when another is initialised,
+	// but saying so is the difference between knowing and being surprised.
+	if !status.Idle() {
+		console.Printf("[*] Stopping %s first\n", status.OpMode)
+		if err := stream.Stop(); err != nil {
+			return err
+		}
+		if _, err := stream.Await(dash.Status.Idle, dash.StepTimeout, nil); err != nil {
+			return fmt.Errorf("%s would not stop: %w", status.OpMode, err)
+		}
+	}
+
+	console.Printf("[>] Initialising %s\n", name)
+	if err := stream.Init(name); err != nil {
+		return err
+	}
+	status, err = stream.Await(func(s dash.Status) bool {
+		return s.OpMode == name && s.State != "STOPPED"
+	}, dash.StepTimeout, nil)
+	if err != nil {
+		return fmt.Errorf("%s did not initialise: %w%s", name, err, robotSays(status))
+	}
+	event.Initialised = true
+
+	if runInitOnly {
+		console.Printf("[OK] %s is initialised and waiting for start\n", name)
+		return nil
+	}
+
+	// From here on an interrupt stops the OpMode rather than leaving it to
+	// carry on with nobody watching.
+	var interrupted atomic.Bool
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-interrupt:
+			interrupted.Store(true)
+			_ = stream.Stop()
+		case <-finished:
+		}
+	}()
+
+	console.Printf("[>] Starting %s\n", name)
+	if err := stream.Start(); err != nil {
+		return err
+	}
+	status, err = stream.Await(func(s dash.Status) bool {
+		return s.State == "RUNNING" || s.Idle()
+	}, dash.StepTimeout, nil)
+	if err != nil {
+		return fmt.Errorf("%s did not start: %w%s", name, err, robotSays(status))
+	}
+	event.Started = true
+	started := time.Now()
+
+	if !status.Idle() {
+		if runStopAfter > 0 {
+			console.Printf("[*] Running, stopping after %s (Ctrl-C stops it now)\n", runStopAfter)
+		} else {
+			console.Println("[*] Running until it ends (Ctrl-C stops it)")
+		}
+
+		status, err = stream.Await(dash.Status.Idle, runStopAfter, nil)
+		switch {
+		case err == nil:
+		case errors.Is(err, dash.ErrTimedOut):
+			event.StoppedBy = "stop-after"
+			if err := stream.Stop(); err != nil {
+				return err
+			}
+			if status, err = stream.Await(dash.Status.Idle, dash.StepTimeout, nil); err != nil {
+				return fmt.Errorf("%s would not stop: %w", name, err)
+			}
+		default:
+			return fmt.Errorf("lost the robot while %s was running: %w", name, err)
+		}
+	}
+
+	switch {
+	case event.StoppedBy != "":
+	case interrupted.Load():
+		event.StoppedBy = "interrupt"
+	default:
+		event.StoppedBy = "opmode"
+	}
+
+	how := "ended by itself"
+	switch event.StoppedBy {
+	case "stop-after":
+		how = "stopped by --stop-after"
+	case "interrupt":
+		how = "stopped by Ctrl-C"
+	}
+
+	console.Printf("[OK] %s ran for %.1fs, %s%s\n", name, time.Since(started).Seconds(), how, robotSays(status))
+	return nil
+}
+
+// robotSays is the robot's own complaint, when it has one.
+func robotSays(status dash.Status) string {
+	switch {
+	case status.Error != "":
+		return "\n    The robot says: " + status.Error
+	case status.Warning != "":
+		return "\n    The robot says: " + status.Warning
+	}
+	return ""
+}
+
+// visualiseRun draws the trace the run just left.
+//
+// The trace is written when the OpMode stops, so a moment is given for it to
+// land before the robot is asked for it.
+func visualiseRun(name string) error {
+	time.Sleep(time.Second)
+
+	serial, traces, err := visual.List()
+	if err != nil {
+		return err
+	}
+
+	hits := adb.MatchTraces(traces, name)
+	if len(hits) == 0 {
+		return fmt.Errorf("%s left no trace: is BlobParams.recordTrace on?", name)
+	}
+
+	return render(func() (string, error) {
+		return visual.Render(serial, hits[0], "", "", pathtrace.DefaultLimits())
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/wifi"
 )
 
@@ -41,7 +42,7 @@ func joinRobot(mgr *wifi.Manager, watcher *wifi.Watcher, profile *config.Profile
 	// driver having looked at the wrong moment. One more attempt is cheap, and
 	// it is the difference between a deploy and a confusing error.
 	if seen {
-		fmt.Println("[*] The hub is broadcasting but the join did not take. Trying once more...")
+		console.Println("[*] The hub is broadcasting but the join did not take. Trying once more...")
 
 		ip, retryErr := mgr.JoinAndWait(profile.SSID, profile.Password, wifi.RobotSubnet, joinTimeout)
 		if retryErr == nil {
@@ -70,7 +71,7 @@ func awaitRobotAP(watcher *wifi.Watcher, ssid string) bool {
 	// spent confirming what pusher was told three times over.
 	if !last.Present && last.Misses < enoughMisses {
 		if wifi.ScanningEnabled() {
-			fmt.Printf("[*] Looking for %s...\n", ssid)
+			console.Printf("[*] Looking for %s...\n", ssid)
 		}
 
 		if watcher.WaitFor(apWaitTimeout) {
@@ -89,11 +90,11 @@ func awaitRobotAP(watcher *wifi.Watcher, ssid string) bool {
 	// A scan that looked properly and found nothing outranks one the radio
 	// refused afterwards: the refusal says nothing, the empty sky says plenty.
 	case last.Misses > 0:
-		fmt.Printf("[!] %s is not broadcasting.\n", ssid)
-		fmt.Println("    Trying anyway, but check the hub is powered on and nearby.")
+		console.Printf("[!] %s is not broadcasting.\n", ssid)
+		console.Println("    Trying anyway, but check the hub is powered on and nearby.")
 
 	case last.Err != nil:
-		fmt.Printf("[!] Could not check whether %s is broadcasting: %v\n", ssid, last.Err)
+		console.Printf("[!] Could not check whether %s is broadcasting: %v\n", ssid, last.Err)
 	}
 
 	return false
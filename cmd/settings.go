@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"github.com/andreibanu/pusher/internal/gradle"
 	"github.com/andreibanu/pusher/internal/tui"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +14,11 @@ var settingsCmd = &cobra.Command{
 network to return to after deploying, whether to use USB when it is attached,
 and how many threads Gradle may use.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// Settings are usually changed on the way to a deploy, so the daemon
+		// that deploy will build on is started while they are.
+		if wrapper, err := gradle.DetectWrapper(); err == nil {
+			gradle.Warm(wrapper)
+		}
 		return tui.RunSettings()
 	},
 }
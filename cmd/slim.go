@@ -6,6 +6,7 @@ import (
 
 	"github.com/andreibanu/pusher/internal/adb"
 	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/extreme"
 	"github.com/andreibanu/pusher/internal/ftcproject"
 	"github.com/andreibanu/pusher/internal/gradle"
@@ -50,13 +51,13 @@ func warnSlimUnsupported(root string) error {
 		return nil
 	}
 
-	fmt.Printf("\n[!] %v\n", reason)
-	fmt.Println("    Nothing gets patched. Every deploy would go on packaging")
-	fmt.Println("    every architecture, at full size, while appearing to have")
-	fmt.Println("    been slimmed.")
+	console.Printf("\n[!] %v\n", reason)
+	console.Println("    Nothing gets patched. Every deploy would go on packaging")
+	console.Println("    every architecture, at full size, while appearing to have")
+	console.Println("    been slimmed.")
 
 	if ignoreWarnings {
-		fmt.Println("    Carrying on anyway because --ignore-warnings was passed.")
+		console.Println("    Carrying on anyway because --ignore-warnings was passed.")
 		return nil
 	}
 
@@ -77,7 +78,7 @@ func runSlim(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("[OK] FTC project: %s\n", project.Root)
+	console.Printf("[OK] FTC project: %s\n", project.Root)
 
 	if slimUndo {
 		// Undo restores whole files from backups, and Pusher Extreme keeps a
@@ -94,10 +95,10 @@ func runSlim(cmd *cobra.Command, args []string) error {
 			if err := extreme.Exclude(project.Root); err != nil {
 				return fmt.Errorf("restoring the Pusher Extreme block failed: %w", err)
 			}
-			fmt.Println("[*] Kept the Pusher Extreme block; undo it from `pusher settings`")
+			console.Println("[*] Kept the Pusher Extreme block; undo it from `pusher settings`")
 		}
-		fmt.Printf("\n[OK] Restored: %s\n", strings.Join(restored, ", "))
-		fmt.Println("    Your next build will package everything again.")
+		console.Printf("\n[OK] Restored: %s\n", strings.Join(restored, ", "))
+		console.Println("    Your next build will package everything again.")
 		return nil
 	}
 
@@ -106,13 +107,13 @@ func runSlim(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Printf("[*] Currently packaging ABIs: %s\n", strings.Join(analysis.ABIs, ", "))
+	console.Printf("[*] Currently packaging ABIs: %s\n", strings.Join(analysis.ABIs, ", "))
 
 	abi, err := resolveTargetABI(analysis.ABIs)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("[*] Keeping: %s\n", abi)
+	console.Printf("[*] Keeping: %s\n", abi)
 
 	changed := false
 
@@ -122,9 +123,9 @@ func runSlim(cmd *cobra.Command, args []string) error {
 	}
 	if abiChanged {
 		changed = true
-		fmt.Println("\n[OK] build.common.gradle now packages one ABI")
+		console.Println("\n[OK] build.common.gradle now packages one ABI")
 	} else {
-		fmt.Println("\n[=] ABI filters already set to that, nothing to do")
+		console.Println("\n[=] ABI filters already set to that, nothing to do")
 	}
 
 	if slimSourceMaps {
@@ -134,9 +135,9 @@ func runSlim(cmd *cobra.Command, args []string) error {
 		}
 		if mapsChanged {
 			changed = true
-			fmt.Println("[OK] TeamCode/build.gradle now excludes *.map source maps")
+			console.Println("[OK] TeamCode/build.gradle now excludes *.map source maps")
 		} else {
-			fmt.Println("[=] Source maps already excluded")
+			console.Println("[=] Source maps already excluded")
 		}
 	}
 
@@ -147,10 +148,10 @@ func runSlim(cmd *cobra.Command, args []string) error {
 		}
 		if libsChanged {
 			changed = true
-			fmt.Println("[OK] Native libraries are now stored, not compressed")
-			fmt.Println("    The APK gets bigger; the install stops extracting them.")
+			console.Println("[OK] Native libraries are now stored, not compressed")
+			console.Println("    The APK gets bigger; the install stops extracting them.")
 		} else {
-			fmt.Println("[=] Native libraries already stored")
+			console.Println("[=] Native libraries already stored")
 		}
 	}
 
@@ -158,8 +159,8 @@ func runSlim(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	fmt.Println("\nRun 'pusher' to rebuild and deploy the slimmer APK.")
-	fmt.Println("Undo any time with 'pusher slim --undo'.")
+	console.Println("\nRun 'pusher' to rebuild and deploy the slimmer APK.")
+	console.Println("Undo any time with 'pusher slim --undo'.")
 
 	return nil
 }
@@ -180,26 +181,26 @@ func rememberHubABI(serial string) string {
 func applyAutoSlim() {
 	abi := config.GetHubABI()
 	if abi == "" {
-		fmt.Println("\n[!] Slim-before-push is on, but pusher has not seen your hub yet.")
-		fmt.Println("    Connect the robot and run 'pusher slim' once; after that")
-		fmt.Println("    every push will slim automatically.")
+		console.Println("\n[!] Slim-before-push is on, but pusher has not seen your hub yet.")
+		console.Println("    Connect the robot and run 'pusher slim' once; after that")
+		console.Println("    every push will slim automatically.")
 		return
 	}
 
 	project, err := detectFTCProject()
 	if err != nil {
-		fmt.Printf("\n[!] Slim-before-push skipped: %v\n", err)
+		console.Printf("\n[!] Slim-before-push skipped: %v\n", err)
 		return
 	}
 
 	changed, err := project.SetABI(abi)
 	if err != nil {
-		fmt.Printf("\n[!] Slim-before-push failed: %v\n", err)
+		console.Printf("\n[!] Slim-before-push failed: %v\n", err)
 		return
 	}
 
 	if changed {
-		fmt.Printf("\n[OK] Slimmed: packaging %s only (undo with 'pusher slim --undo')\n", abi)
+		console.Printf("\n[OK] Slimmed: packaging %s only (undo with 'pusher slim --undo')\n", abi)
 	}
 }
 
@@ -208,8 +209,8 @@ func warnOnABIMismatch(patchedFor, actual string) {
 		return
 	}
 
-	fmt.Printf("\n[!] This APK was built for %s but the hub runs %s.\n", patchedFor, actual)
-	fmt.Println("    pusher has corrected its records; rerun 'pusher' to rebuild.")
+	console.Printf("\n[!] This APK was built for %s but the hub runs %s.\n", patchedFor, actual)
+	console.Println("    pusher has corrected its records; rerun 'pusher' to rebuild.")
 }
 
 func detectFTCProject() (*ftcproject.Project, error) {
@@ -230,10 +231,10 @@ func resolveTargetABI(projectABIs []string) (string, error) {
 	serial := ""
 	if ok {
 		serial = device.Serial
-		fmt.Printf("[*] Asking %s which ABI it runs...\n", device.Label())
+		console.Printf("[*] Asking %s which ABI it runs...\n", device.Label())
 	} else if adb.IsConnected() {
 		serial = adb.RobotAddr()
-		fmt.Println("[*] Asking the robot which ABI it runs...")
+		console.Println("[*] Asking the robot which ABI it runs...")
 	} else {
 		return "", fmt.Errorf("no hub connected, so pusher cannot tell which ABI to keep\n\n" +
 			"Either connect to the robot first (USB or 'pusher connect'),\n" +
@@ -244,7 +245,7 @@ func resolveTargetABI(projectABIs []string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to read the hub's ABI: %w", err)
 	}
-	fmt.Printf("[OK] Hub supports: %s\n", strings.Join(deviceABIs, ", "))
+	console.Printf("[OK] Hub supports: %s\n", strings.Join(deviceABIs, ", "))
 
 	if deviceABIs[0] != config.GetHubABI() {
 		_ = config.SetHubABI(deviceABIs[0])
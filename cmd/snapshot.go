@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/dash"
+	"github.com/andreibanu/pusher/internal/gradle"
+	"github.com/andreibanu/pusher/internal/robotcfg"
+	"github.com/spf13/cobra"
+)
+
+// snapshotSerial is the serial number of the robot whose snapshots are meant,
+// when it is not the one connected.
+var snapshotSerial string
+
+var dashSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Keep named tuning sets, and compare or restore them",
+	Long: `Keeps what the dashboard holds under a name, per robot, until you delete it.
+
+Any snapshot compares against another, against the robot as it is now ("live")
+or against your source ("source"), and restores to the robot.
+
+With dash-watch on in settings, every deploy keeps one first, named after when
+it was taken, so tuning a deploy overwrote can be put back. The newest 30 of
+those are kept; named ones are never removed.
+
+Snapshots belong to the robot, by the hub's own serial number, so they are the
+same over USB and Wi-Fi. With no robot connected they are those of --serial,
+or of the only robot that has any.`,
+}
+
+var dashSnapshotSaveCmd = &cobra.Command{
+	Use:   "save <name>",
+	Short: "Keep what the robot holds now under a name",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSnapshotSave,
+}
+
+var dashSnapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List this robot's snapshots",
+	Args:  cobra.NoArgs,
+	RunE:  runSnapshotList,
+}
+
+var dashSnapshotShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print the values in a snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSnapshotShow,
+}
+
+var dashSnapshotDiffCmd = &cobra.Command{
+	Use:   "diff <a> [b]",
+	Short: "Compare two snapshots, or one against live or source",
+	Long: `Lists every value on which a and b disagree. Either may be a snapshot's
+name, "live" for what the robot holds now or "source" for what your code
+declares. b defaults to live.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runSnapshotDiff,
+}
+
+var dashSnapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Send a snapshot's values back to the robot",
+	Long: `Sends every value in a snapshot to the dashboard, checked against the
+types it reports first, exactly as 'pusher dash load' does with a file.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotRestore,
+}
+
+var dashSnapshotDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSnapshotDelete,
+}
+
+func init() {
+	dashSnapshotCmd.PersistentFlags().StringVar(&snapshotSerial, "serial", "",
+		"Serial number of the robot whose snapshots to use (default: the connected one)")
+	dashSnapshotRestoreCmd.Flags().BoolVar(&dashDryRun, "dry-run", false, "Show what would change without sending it")
+
+	dashSnapshotCmd.AddCommand(dashSnapshotSaveCmd)
+	dashSnapshotCmd.AddCommand(dashSnapshotListCmd)
+	dashSnapshotCmd.AddCommand(dashSnapshotShowCmd)
+	dashSnapshotCmd.AddCommand(dashSnapshotDiffCmd)
+	dashSnapshotCmd.AddCommand(dashSnapshotRestoreCmd)
+	dashSnapshotCmd.AddCommand(dashSnapshotDeleteCmd)
+	dashCmd.AddCommand(dashSnapshotCmd)
+}
+
+// snapshotRobot is whose snapshots are meant, as the hub's own serial number.
+//
+// Looking through old tuning is something done at home as often as in the pits,
+// so a robot does not have to be connected. When nothing answers, a team with
+// one robot means that one; a team with several has to say which.
+func snapshotRobot() (string, error) {
+	if snapshotSerial != "" {
+		return snapshotSerial, nil
+	}
+	if serial, err := dash.Robot(); err == nil {
+		return robotcfg.RobotID(serial), nil
+	}
+
+	robots := dash.Robots(config.Dir())
+	switch len(robots) {
+	case 0:
+		return "", fmt.Errorf("no robot connected, and no snapshots kept for any")
+	case 1:
+		return robots[0], nil
+	}
+	return "", fmt.Errorf("no robot connected: name one with --serial (%s)", strings.Join(robots, ", "))
+}
+
+func runSnapshotSave(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := dash.ValidName(name); err != nil {
+		return err
+	}
+
+	serial, err := dash.Robot()
+	if err != nil {
+		return err
+	}
+
+	console.Printf("[*] Reading the dashboard on %s\n", serial)
+
+	live, err := dash.Read(serial)
+	if err != nil {
+		return err
+	}
+
+	robot := robotcfg.RobotID(serial)
+	snap := dash.Snapshot{Name: name, Serial: robot, Taken: time.Now(), Values: live}
+	if err := dash.Keep(config.Dir(), snap); err != nil {
+		return err
+	}
+
+	console.Printf("[OK] Kept %d values as %s\n", len(live), name)
+	emit("snapshot", snapshotEvent{Name: name, Serial: robot, Taken: snap.Taken, Count: len(live), Values: live})
+	return nil
+}
+
+func runSnapshotList(cmd *cobra.Command, args []string) error {
+	serial, err := snapshotRobot()
+	if err != nil {
+		return err
+	}
+	history := dash.History(config.Dir(), serial)
+
+	entries := make([]snapshotEntry, 0, len(history))
+	for _, snap := range history {
+		entries = append(entries, snapshotEntry{
+			Name: snap.Name, Taken: snap.Taken,
+			Automatic: snap.Automatic, Count: len(snap.Values),
+		})
+	}
+	emit("snapshots", snapshotsEvent{Serial: serial, Snapshots: entries})
+
+	if len(history) == 0 {
+		console.Printf("No snapshots for %s. 'pusher dash snapshot save <name>' keeps one.\n", serial)
+		return nil
+	}
+
+	width := len("Name")
+	for _, snap := range history {
+		if len(snap.Name) > width {
+			width = len(snap.Name)
+		}
+	}
+
+	console.Printf("Snapshots for %s\n", serial)
+	console.Println("─────────────────────────────────────────")
+	for _, snap := range history {
+		kind := ""
+		if snap.Automatic {
+			kind = "  before a deploy"
+		}
+		console.Printf("  %-*s  %s  %3d values%s\n", width, snap.Name,
+			snap.Taken.Format("Mon 2 Jan 15:04"), len(snap.Values), kind)
+	}
+
+	return nil
+}
+
+func runSnapshotShow(cmd *cobra.Command, args []string) error {
+	serial, err := snapshotRobot()
+	if err != nil {
+		return err
+	}
+
+	snap, err := dash.Recall(config.Dir(), serial, args[0])
+	if err != nil {
+		return err
+	}
+
+	console.Printf("%s, taken %s on %s\n", snap.Name, snap.Taken.Format("Mon 2 Jan 15:04"), snap.Serial)
+	console.Println("─────────────────────────────────────────")
+
+	names := snap.Values.Names()
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	for _, name := range names {
+		console.Printf("  %-*s   %s\n", width, name, snap.Values[name])
+	}
+
+	emit("snapshot", snapshotEvent{
+		Name: snap.Name, Serial: snap.Serial, Taken: snap.Taken,
+		Automatic: snap.Automatic, Count: len(snap.Values), Values: snap.Values,
+	})
+	return nil
+}
+
+func runSnapshotDiff(cmd *cobra.Command, args []string) error {
+	serial, err := snapshotRobot()
+	if err != nil {
+		return err
+	}
+
+	from, to := args[0], "live"
+	if len(args) == 2 {
+		to = args[1]
+	}
+
+	a, err := snapshotValues(serial, from)
+	if err != nil {
+		return err
+	}
+	b, err := snapshotValues(serial, to)
+	if err != nil {
+		return err
+	}
+
+	// The source declares every tunable in the project and a robot only holds
+	// the classes it has loaded, so against the source only what both have is
+	// a comparison. The rest would be a list of everything never opened.
+	if strings.EqualFold(from, "source") || strings.EqualFold(to, "source") {
+		a, b = common(a, b), common(b, a)
+	}
+
+	differences := dash.Differences(a, b)
+	emit("compare", compareEvent{Serial: serial, From: from, To: to, Differences: differences})
+
+	if len(differences) == 0 {
+		console.Printf("%s and %s agree on all %d values.\n", from, to, len(a))
+		return nil
+	}
+
+	width := 0
+	for _, d := range differences {
+		if len(d.Key) > width {
+			width = len(d.Key)
+		}
+	}
+
+	console.Printf("%d differ, %s  ->  %s\n\n", len(differences), from, to)
+	for _, d := range differences {
+		console.Printf("  %-*s   %s  ->  %s\n", width, d.Key, orNone(d.From), orNone(d.To))
+	}
+
+	return nil
+}
+
+func runSnapshotRestore(cmd *cobra.Command, args []string) error {
+	robot, err := snapshotRobot()
+	if err != nil {
+		return err
+	}
+
+	snap, err := dash.Recall(config.Dir(), robot, args[0])
+	if err != nil {
+		return err
+	}
+
+	console.Printf("[*] %d values in %s, taken %s\n", len(snap.Values), snap.Name, snap.Taken.Format("Mon 2 Jan 15:04"))
+	return sendTuning(snap.Values, false)
+}
+
+func runSnapshotDelete(cmd *cobra.Command, args []string) error {
+	robot, err := snapshotRobot()
+	if err != nil {
+		return err
+	}
+
+	if err := dash.Forget(config.Dir(), robot, args[0]); err != nil {
+		return err
+	}
+	console.Printf("[OK] Deleted %s\n", args[0])
+	return nil
+}
+
+// snapshotValues is what name refers to: the robot, the source or a snapshot.
+func snapshotValues(serial, name string) (dash.Values, error) {
+	switch strings.ToLower(name) {
+	case "live":
+		robot, err := dash.Robot()
+		if err != nil {
+			return nil, err
+		}
+		return dash.Read(robot)
+
+	case "source":
+		root, err := gradle.DetectWrapper()
+		if err != nil {
+			return nil, fmt.Errorf("run this from your FTC project to compare against source: %w", err)
+		}
+		return dash.FromProject(gradle.ProjectDir(root)).Values(), nil
+	}
+
+	snap, err := dash.Recall(config.Dir(), serial, name)
+	if err != nil {
+		return nil, err
+	}
+	return snap.Values, nil
+}
+
+// common is the part of values whose keys other also holds.
+func common(values, other dash.Values) dash.Values {
+	out := dash.Values{}
+	for key, value := range values {
+		if _, found := other[key]; found {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/adb"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/deployed"
+	"github.com/andreibanu/pusher/internal/gradle"
+	"github.com/spf13/cobra"
+)
+
+var statusSerial string
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which commit each robot is running",
+	Long: `Every install and reload leaves a stamp on the robot: the git commit and
+branch it came from, whether there were uncommitted changes, who pushed it, from
+which computer, when, and with which version of pusher. status reads it back
+from each connected robot and compares it with the tree you are in, so a robot
+running somebody else's branch shows up before a match rather than during it.`,
+	Args: cobra.NoArgs,
+	RunE: runStatus,
+}
+
+func init() {
+	statusCmd.Flags().StringVar(&statusSerial, "serial", "",
+		"Robot to ask (default: every connected one)")
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	robots, err := connectedRobots(statusSerial)
+	if err != nil {
+		return err
+	}
+
+	local := deployed.Source{}
+	inProject := false
+	if wrapper, err := gradle.DetectWrapper(); err == nil {
+		local = deployed.ReadSource(gradle.ProjectDir(wrapper))
+		inProject = true
+	}
+	host, _ := os.Hostname()
+
+	for i, dev := range robots {
+		if i > 0 {
+			console.Println()
+		}
+		console.Println(dev.Label())
+
+		stamp, err := readStamp(dev.Serial)
+		if err != nil {
+			console.Printf("  [!] %v\n", err)
+			emit("stamp", stampEvent{Serial: dev.Serial, Local: local.Commit, Error: err.Error()})
+			continue
+		}
+
+		// A stamp the app has been installed over describes an APK that is no
+		// longer there, and comparing it with the tree would only mislead.
+		stale := stamp.Stale(adb.AppUpdated(dev.Serial, stamp.Package))
+		if stale {
+			console.Println("  [!] The app was installed since, by something other than pusher,")
+			console.Println("      so what follows is what pusher last gave it, not what it runs")
+		}
+
+		console.Printf("  running  %s\n", stamp.Source.Describe())
+		by := stamp.Author
+		if by == "" {
+			by = "someone"
+		}
+		if stamp.Host != "" {
+			by += " on " + stamp.Host
+		}
+		console.Printf("  %-8s by %s, %s", stamp.Kind, by, stamp.Time.Local().Format("Mon 2 Jan 15:04"))
+		if stamp.Version != "" {
+			console.Printf(", pusher %s", stamp.Version)
+		}
+		console.Println()
+
+		var differences []string
+		if inProject && !stale {
+			console.Printf("  here     %s\n", local.Describe())
+			differences = deployed.Differences(stamp, local, host)
+			if len(differences) == 0 {
+				console.Println("  [OK] The robot runs what you have here")
+			}
+			for _, d := range differences {
+				console.Printf("  [!] %s\n", strings.ToUpper(d[:1])+d[1:])
+			}
+		}
+
+		emit("stamp", stampEvent{
+			Serial: dev.Serial, Commit: stamp.Commit, Branch: stamp.Branch, Dirty: stamp.Dirty,
+			Author: stamp.Author, Host: stamp.Host, Kind: stamp.Kind, Time: stamp.Time,
+			Version: stamp.Version, Stale: stale, Local: local.Commit, Differences: differences,
+		})
+	}
+	return nil
+}
+
+// errNoStamp is a robot nobody has deployed to since stamps were written.
+var errNoStamp = fmt.Errorf("no deploy stamp: pusher has not installed or reloaded here since it started writing them")
+
+func readStamp(serial string) (deployed.Stamp, error) {
+	out, err := adb.Shell(serial, "cat", deployed.StampPath, "2>/dev/null")
+	if err != nil || strings.TrimSpace(out) == "" {
+		return deployed.Stamp{}, errNoStamp
+	}
+	return deployed.ParseStamp([]byte(out))
+}
+
+// stampRobot writes what the robot was just given, read from the tree at root.
+// A stamp that could not be written leaves `pusher status` out of date, not
+// the deploy broken, so failing is quiet.
+func stampRobot(serial, kind, root string) {
+	writeStamp(serial, kind, deployed.ReadSource(root))
+}
+
+func writeStamp(serial, kind string, source deployed.Source) {
+	host, _ := os.Hostname()
+	pkg := adb.ControllerPackage(serial)
+	stamp := deployed.Stamp{
+		Source:     source,
+		Kind:       kind,
+		Time:       time.Now().UTC(),
+		Version:    appVersion,
+		Host:       host,
+		Package:    pkg,
+		AppUpdated: adb.AppUpdated(serial, pkg),
+	}
+
+	local, err := os.CreateTemp("", "pusher-stamp-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(local.Name())
+	if _, err := local.Write(stamp.Encode()); err != nil {
+		local.Close()
+		return
+	}
+	local.Close()
+
+	_, _ = adb.Shell(serial, "mkdir", "-p", path.Dir(deployed.StampPath))
+	_ = adb.Push(serial, local.Name(), deployed.StampPath)
+}
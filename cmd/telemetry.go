@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/dash"
+	"github.com/andreibanu/pusher/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+// dashRecord is where `pusher dash watch` records the stream, if anywhere.
+var dashRecord string
+
+var dashWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Show the robot's telemetry live in the terminal",
+	Long: `Keeps a connection to FtcDashboard open and shows the telemetry the running
+OpMode sends, each line updating in place with a sparkline of its recent
+history beside every number. No browser needed.
+
+--record writes every packet to a file as it arrives: .csv for one row per
+value (time, seconds, key, value), .jsonl for one packet per line.
+
+With --output json, each packet is a "telemetry" event instead of the view,
+until interrupted.`,
+	Args: cobra.NoArgs,
+	RunE: runDashWatch,
+}
+
+func init() {
+	dashWatchCmd.Flags().StringVar(&dashRecord, "record", "", "Record the stream to a .csv or .jsonl file")
+	dashCmd.AddCommand(dashWatchCmd)
+}
+
+func runDashWatch(cmd *cobra.Command, args []string) error {
+	serial, err := dash.Robot()
+	if err != nil {
+		return err
+	}
+
+	var recorder *dash.Recorder
+	if dashRecord != "" {
+		if recorder, err = dash.Record(dashRecord); err != nil {
+			return err
+		}
+	}
+
+	stream, err := dash.Watch(serial)
+	if err != nil {
+		if recorder != nil {
+			recorder.Close()
+			os.Remove(dashRecord)
+		}
+		return fmt.Errorf("%w\n    Is FtcDashboard running? It needs the robot app started", err)
+	}
+
+	if jsonOut != nil {
+		err = streamTelemetry(stream, recorder)
+	} else {
+		err = tui.RunTelemetry(stream, recorder, serial, dashRecord)
+	}
+
+	if recorder != nil {
+		if closeErr := recorder.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			console.Printf("[OK] Recorded to %s\n", dashRecord)
+		}
+	}
+	return err
+}
+
+// streamTelemetry writes the stream as events until interrupted, for a script
+// rather than a person.
+func streamTelemetry(stream *dash.Stream, recorder *dash.Recorder) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	// An interrupt closes the stream, which ends the read below, so the
+	// recording is flushed and the "done" line still goes out.
+	var interrupted atomic.Bool
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-interrupt:
+			interrupted.Store(true)
+			stream.Close()
+		case <-finished:
+		}
+	}()
+	defer stream.Close()
+
+	for {
+		update, err := stream.Next()
+		if err != nil {
+			if interrupted.Load() {
+				return nil
+			}
+			return fmt.Errorf("lost the dashboard: %w", err)
+		}
+
+		if update.Status != nil {
+			status := update.Status
+			emit("status", statusEvent{
+				OpMode: status.OpMode, State: status.State, Warning: status.Warning, Error: status.Error,
+			})
+		}
+		for _, p := range update.Packets {
+			if recorder != nil {
+				if err := recorder.Write(p); err != nil {
+					return fmt.Errorf("the recording stopped: %w", err)
+				}
+			}
+			emit("telemetry", p)
+		}
+	}
+}
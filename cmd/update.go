@@ -1,8 +1,7 @@
 package cmd
 
 import (
-	"fmt"
-
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/selfupdate"
 	"github.com/spf13/cobra"
 )
@@ -36,45 +35,45 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		via += " (formula " + install.Formula + ")"
 	}
 
-	fmt.Printf("[*] Installed via %s\n", via)
-	fmt.Printf("[*] Location: %s\n", install.Path)
-	fmt.Printf("[*] Running:  %s\n", selfupdate.Current())
+	console.Printf("[*] Installed via %s\n", via)
+	console.Printf("[*] Location: %s\n", install.Path)
+	console.Printf("[*] Running:  %s\n", selfupdate.Current())
 
 	release, err := selfupdate.Latest()
 	if err != nil {
 		return err
 	}
-	fmt.Printf("[*] Latest:   %s\n", release.Tag)
+	console.Printf("[*] Latest:   %s\n", release.Tag)
 
 	if !release.Newer() {
-		fmt.Println("\n[OK] Already up to date.")
+		console.Println("\n[OK] Already up to date.")
 		return nil
 	}
 
 	if updateCheckOnly {
-		fmt.Printf("\n[!] %s is available. Run 'pusher update' to install it.\n", release.Tag)
+		console.Printf("\n[!] %s is available. Run 'pusher update' to install it.\n", release.Tag)
 		return nil
 	}
 
 	if install.Method == selfupdate.Homebrew {
-		fmt.Printf("\n[>] brew upgrade %s\n", install.Formula)
+		console.Printf("\n[>] brew upgrade %s\n", install.Formula)
 
 		// Homebrew says plenty and only the end of it is the outcome, which is
 		// what somebody watching a one-line command wants to see.
 		out, err := selfupdate.UpgradeBrew(install.Formula, release.Version())
 		if line := selfupdate.LastLine(out); line != "" {
-			fmt.Printf("    %s\n", line)
+			console.Printf("    %s\n", line)
 		}
 		if err != nil {
 			return err
 		}
 	} else {
-		fmt.Printf("\n[>] Replacing this binary with %s\n", release.Tag)
+		console.Printf("\n[>] Replacing this binary with %s\n", release.Tag)
 		if err := selfupdate.Apply(release, install.Path); err != nil {
 			return err
 		}
 	}
 
-	fmt.Printf("\n[OK] Updated to %s. Run pusher again to use it.\n", release.Tag)
+	console.Printf("\n[OK] Updated to %s. Run pusher again to use it.\n", release.Tag)
 	return nil
 }
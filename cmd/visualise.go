@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/andreibanu/pusher/internal/adb"
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/feature"
 	"github.com/andreibanu/pusher/internal/pathtrace"
 	"github.com/andreibanu/pusher/internal/tui"
@@ -103,7 +104,7 @@ func render(run func() (string, error)) error {
 		return err
 	}
 
-	fmt.Println(out)
+	console.Println(out)
 	if !visNoOpen {
 		visual.Open(out)
 	}
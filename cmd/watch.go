@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/adb"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/extreme"
+	"github.com/andreibanu/pusher/internal/gradle"
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Reload onto the robot every time you save",
+	Long: `Watches TeamCode/src and reloads your code onto the robot each time you
+save, with Pusher Extreme. Saves that land together are reloaded together.
+
+What a reload cannot carry, such as a change to a gradle file or the
+manifest, is built and installed instead, the same as 'pusher' would. Code
+that does not compile is reported as file:line and left until the next save.
+
+  pusher watch               refuse to reload under a running OpMode
+  pusher watch --when-idle   reload once the running OpMode ends
+  pusher watch --stop-opmode stop the running OpMode and reload
+
+Ctrl-C stops watching once whatever is under way has finished; press it again
+to stop at once.`,
+	Args: cobra.NoArgs,
+	RunE: runWatch,
+}
+
+func init() {
+	watchCmd.Flags().BoolVar(&whenIdle, "when-idle", false, "Hold a reload until the running OpMode ends")
+	watchCmd.Flags().BoolVar(&stopOpMode, "stop-opmode", false, "Stop a running OpMode and then reload")
+	watchCmd.MarkFlagsMutuallyExclusive("when-idle", "stop-opmode")
+}
+
+// watcher is what stays resolved between saves. Resolving the classpath runs
+// Gradle, which takes longer than the reload it is for, so it is done once
+// here rather than once a save, and again only when the build files it came
+// from have changed.
+type watcher struct {
+	project   *extreme.Project
+	classpath extreme.Classpath
+	// built is the build files the classpath was resolved from.
+	built string
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	if _, err := adb.Target(); err != nil {
+		return err
+	}
+
+	project, err := extreme.FindProject()
+	if err != nil {
+		return err
+	}
+	if !extreme.Excluded(project.Root) {
+		return fmt.Errorf("Pusher Extreme is not set up in this project\n" +
+			"    Set it up in 'pusher settings' -> Pusher Extreme, then watch")
+	}
+
+	console.Println("[>] Working out what to compile against...")
+	start := time.Now()
+	w := &watcher{project: project}
+	if err := w.resolve(); err != nil {
+		return err
+	}
+	if err := project.Hold(); err != nil {
+		return err
+	}
+	defer project.Release()
+	console.Printf("[OK] Ready in %.1fs\n", time.Since(start).Seconds())
+
+	// A save that cannot be reloaded is installed, which builds. The classpath
+	// was asked of a daemon that may run on another JDK, so the one a build
+	// uses is started now, while nobody is waiting on it.
+	gradle.Warm(project.Wrapper)
+
+	saves, err := extreme.WatchSaves(project)
+	if err != nil {
+		return fmt.Errorf("cannot watch %s: %w", filepath.Join(extreme.Module, "src"), err)
+	}
+	defer saves.Close()
+
+	// The first Ctrl-C lets a reload or an install that is under way finish,
+	// since stopping one halfway leaves the robot with neither version. The
+	// second is not caught, for when that is taking too long.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+		saves.Close()
+	}()
+
+	console.Printf("[*] Watching %s. Save to reload, Ctrl-C to stop.\n\n", filepath.Join(extreme.Module, "src"))
+
+	for {
+		files, err := saves.Next()
+		if errors.Is(err, extreme.ErrStopped) {
+			console.Println("\n[*] Stopped watching")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		emit("change", changeEvent{Files: files})
+		w.deploy(files)
+	}
+}
+
+// deploy puts one batch of saves onto the robot and says how that went in a
+// line, or a few when the code does not compile.
+func (w *watcher) deploy(files []string) {
+	stamp := time.Now().Format("15:04:05")
+	what := describeFiles(files)
+
+	serial, err := adb.Target()
+	if err != nil {
+		console.Printf("[!] %s  %s  not sent: %v\n", stamp, what, err)
+		return
+	}
+
+	apkPath, _ := gradle.FindApk(w.project.Root)
+	if state := extreme.Status(w.project.Root, serial, apkPath); !state.Usable() {
+		console.Printf("[*] %s  %s  installing: %s\n", stamp, what, state.Reason)
+		emit("decision", decisionEvent{Serial: serial, Mode: "install", Reason: state.Reason})
+		w.install(serial)
+		return
+	}
+
+	emit("decision", decisionEvent{Serial: serial, Mode: "reload"})
+	result, err := extreme.Reload(w.project, serial, w.classpath, extreme.Kept(w.project.Root), reloadBusy())
+	emitReload(serial, result, err)
+
+	var compile *extreme.CompileError
+	switch {
+	case errors.As(err, &compile):
+		// Nothing is sent, so the robot still has the last code that compiled
+		// and there is nothing to put right.
+		console.Printf("[!] %s  %s  does not compile\n", stamp, what)
+		diagnostics := compile.Diagnostics()
+		if len(diagnostics) == 0 {
+			console.Printf("    %v\n", err)
+		}
+		for _, d := range diagnostics {
+			console.Printf("    %s\n", d.Relative(w.project.Root))
+		}
+
+	case errors.Is(err, extreme.ErrOpModeRunning):
+		console.Printf("[!] %s  %s  not reloaded: %v\n", stamp, what, err)
+		console.Println("    Save again once it has stopped, or watch with --when-idle")
+
+	case err != nil:
+		// The same reasoning as a deploy: a reload that failed partway may
+		// have left the robot unable to read what it has, and an install puts
+		// it back to something that certainly works.
+		console.Printf("[!] %s  %s  reload failed, installing: %v\n", stamp, what, err)
+		emit("decision", decisionEvent{Serial: serial, Mode: "install", Reason: "the reload failed"})
+		w.install(serial)
+
+	default:
+		stampRobot(serial, "reload", w.project.Root)
+		line := fmt.Sprintf("reloaded %d classes in %.1fs", result.Classes, result.Total.Seconds())
+		switch result.Busy {
+		case extreme.BusyStopped:
+			line += fmt.Sprintf(", after stopping %s", result.OpMode)
+		case extreme.BusyWaited:
+			line += fmt.Sprintf(", after %s ended", result.OpMode)
+		}
+		console.Printf("[OK] %s  %s  %s\n", stamp, what, line)
+		for _, warning := range result.Warnings {
+			console.Printf("    %s\n", warning)
+		}
+	}
+}
+
+// install builds and installs, the way 'pusher' does when a reload will not do.
+// A save that changed nothing the build reads installs without building.
+//
+// An install is also what a changed build file leads to, and a dependency
+// added there is one the next reload has to compile against, so the classpath
+// is worked out again when they moved.
+func (w *watcher) install(serial string) {
+	start := time.Now()
+	if err := deploy(w.project.Wrapper, serial); err != nil {
+		console.Printf("[!] %s  install failed: %v\n\n", time.Now().Format("15:04:05"), err)
+		return
+	}
+	console.Printf("[OK] %s  installed in %.1fs\n",
+		time.Now().Format("15:04:05"), time.Since(start).Seconds())
+
+	if built, _ := extreme.BuildSignature(w.project.Root); built != w.built {
+		console.Println("[>] The build files changed, working out what to compile against again...")
+		if err := w.resolve(); err != nil {
+			console.Printf("[!] %v\n    Reloads compile against the old classpath until the next install\n", err)
+		}
+	}
+	console.Printf("[*] %s  watching again\n\n", time.Now().Format("15:04:05"))
+}
+
+// resolve works out what team code compiles against, and notes which build
+// files that came from.
+func (w *watcher) resolve() error {
+	built, _ := extreme.BuildSignature(w.project.Root)
+	classpath, err := extreme.ResolveClasspath(w.project.Wrapper, extreme.Module)
+	if err != nil {
+		return fmt.Errorf("could not work out what to compile against: %w", err)
+	}
+	w.classpath, w.built = classpath, built
+	return nil
+}
+
+// describeFiles names what was saved in a few words.
+func describeFiles(files []string) string {
+	switch len(files) {
+	case 0:
+		return "nothing"
+	case 1:
+		return filepath.Base(files[0])
+	case 2:
+		return filepath.Base(files[0]) + " and " + filepath.Base(files[1])
+	}
+	return fmt.Sprintf("%s and %d more", filepath.Base(files[0]), len(files)-1)
+}
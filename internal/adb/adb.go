@@ -7,6 +7,9 @@ import (
 	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
 )
 
 // The robot's fixed address on its own network.
@@ -48,8 +51,17 @@ func (d Device) Label() string {
 	return d.Serial
 }
 
-// RobotAddr is the robot's adb address over Wi-Fi.
+// RobotAddr is the robot's adb address over Wi-Fi: the one the default profile
+// was paired at, or the hub's fixed address.
 func RobotAddr() string {
+	if addr := config.GetPairedAddr(); addr != "" {
+		return addr
+	}
+	return HubAddr()
+}
+
+// HubAddr is a Control Hub's fixed adb address on its own network.
+func HubAddr() string {
 	return fmt.Sprintf("%s:%s", RobotIP, RobotPort)
 }
 
@@ -61,6 +73,13 @@ func IsInstalled() bool {
 
 // Devices lists what adb can currently see.
 func Devices() ([]Device, error) {
+	if native() {
+		devices, err := nativeDevices()
+		if !fallback(err) {
+			return devices, err
+		}
+	}
+
 	if !IsInstalled() {
 		return nil, fmt.Errorf("adb not found - please install Android SDK Platform-Tools")
 	}
@@ -158,7 +177,20 @@ func ABIList(serial string) ([]string, error) {
 	return abis, nil
 }
 
+// run is one adb command. Shell commands and single-file copies go to the adb
+// server directly; anything else, or a server that is not running yet, goes
+// through the executable.
 func run(serial string, args ...string) (string, error) {
+	if native() {
+		out, handled, err := runNative(serial, args)
+		if handled && !fallback(err) {
+			if err != nil {
+				return "", fmt.Errorf("adb %s failed: %w", strings.Join(args, " "), err)
+			}
+			return out, nil
+		}
+	}
+
 	full := args
 	if serial != "" {
 		full = append([]string{"-s", serial}, args...)
@@ -172,21 +204,55 @@ func run(serial string, args ...string) (string, error) {
 	return string(out), nil
 }
 
-// Connect establishes an adb connection to the robot over Wi-Fi, retrying.
+// runNative is run without the executable, for the commands that have a
+// native equivalent. handled is false for the rest.
+func runNative(serial string, args []string) (out string, handled bool, err error) {
+	if len(args) == 0 {
+		return "", false, nil
+	}
+
+	switch {
+	case args[0] == "shell" && len(args) > 1:
+		out, err = nativeShell(serial, strings.Join(args[1:], " "))
+		return out, true, err
+
+	case args[0] == "push" && len(args) == 3:
+		// A directory is left to the executable, which walks it.
+		if info, statErr := os.Stat(args[1]); statErr != nil || !info.Mode().IsRegular() {
+			return "", false, nil
+		}
+		return "", true, nativePush(serial, args[1], args[2])
+
+	case args[0] == "pull" && len(args) == 3:
+		return "", true, nativePull(serial, args[1], args[2])
+	}
+
+	return "", false, nil
+}
+
+// Connect establishes an adb connection to the robot over Wi-Fi, retrying. A
+// paired robot is looked up first, in case its port has changed.
 func Connect() error {
 	if !IsInstalled() {
 		return fmt.Errorf("adb not found - please install Android SDK Platform-Tools")
 	}
+	return ConnectTo(Locate())
+}
+
+// ConnectTo establishes an adb connection to one address, retrying.
+func ConnectTo(addr string) error {
+	if !IsInstalled() {
+		return fmt.Errorf("adb not found - please install Android SDK Platform-Tools")
+	}
 
-	addr := RobotAddr()
-	fmt.Printf("[*] Attempting ADB connection to %s...\n", addr)
+	console.Printf("[*] Attempting ADB connection to %s...\n", addr)
 
 	maxRetries := 5
 	var lastErr error
 
 	for i := 0; i < maxRetries; i++ {
 		if i > 0 {
-			fmt.Printf("[*] ADB retry %d/%d...\n", i+1, maxRetries)
+			console.Printf("[*] ADB retry %d/%d...\n", i+1, maxRetries)
 			time.Sleep(3 * time.Second)
 		}
 
@@ -207,6 +273,9 @@ func Connect() error {
 		lastErr = fmt.Errorf("unexpected response: %s", outputStr)
 	}
 
+	if addr != HubAddr() {
+		return fmt.Errorf("ADB connection failed after %d attempts: %w\n\n[!] Troubleshooting:\n  1. Ensure this computer is on the same network as the robot\n  2. Check Wireless debugging is still on in the robot's Developer options\n  3. Run 'pusher connect --pair' again if the robot has forgotten this computer", maxRetries, lastErr)
+	}
 	return fmt.Errorf("ADB connection failed after %d attempts: %w\n\n[!] Troubleshooting:\n  1. Ensure you're connected to the robot's Wi-Fi\n  2. Enable ADB debugging on Robot Controller\n  3. Try 'adb connect %s' manually\n  4. Check robot app is running", maxRetries, lastErr, addr)
 }
 
@@ -248,6 +317,14 @@ type Options struct {
 
 	Stream bool
 
+	// Compress sends the changed parts of a delta transfer compressed, when
+	// the hub can decompress them.
+	Compress bool
+
+	// Patch sends a patch against the APK the robot already has, falling back
+	// to the delta transfer when it cannot.
+	Patch bool
+
 	Splits []string
 }
 
@@ -273,6 +350,7 @@ func InstallWith(serial, apkPath string, opt Options) (InstallPlan, error) {
 			if opt.SkipUnchanged && alreadyInstalled(serial, sum, pkg) {
 				plan.Skipped = true
 				plan.Reason = "the robot already has this exact build"
+				plan.Fingerprint = sum
 				return plan, nil
 			}
 		}
@@ -282,8 +360,8 @@ func InstallWith(serial, apkPath string, opt Options) (InstallPlan, error) {
 		count, err := SplitInstall(serial, pkg, opt.Splits)
 		switch {
 		case err != nil:
-			fmt.Printf("\n[!] Split install failed: %v\n", err)
-			fmt.Println("[*] Falling back to installing the whole APK.")
+			console.Printf("\n[!] Split install failed: %v\n", err)
+			console.Println("[*] Falling back to installing the whole APK.")
 		case count == 0:
 			plan.Skipped = true
 			plan.Reason = "no split changed"
@@ -299,43 +377,68 @@ func InstallWith(serial, apkPath string, opt Options) (InstallPlan, error) {
 	// how the bytes get to the robot, streaming decides how they are installed.
 	// Trying streaming first sent the whole APK and left delta as dead code.
 	remote := ""
-	if opt.Delta {
-		result, err := deltaInstall(serial, apkPath)
+	if opt.Patch && pkg != "" {
+		result, err := patchInstall(serial, apkPath, pkg, opt.Compress)
+		if err == nil {
+			remote = remoteDeltaAPK
+			plan.Patched = true
+			plan.BytesSent = result.WireBytes
+		} else {
+			console.Printf("\n[!] Patch unavailable: %v\n", err)
+			if opt.Delta {
+				console.Println("[*] Sending changed chunks instead.")
+			}
+		}
+	}
+	if opt.Delta && remote == "" {
+		result, err := deltaInstall(serial, apkPath, opt.Compress)
 		if err == nil {
 			remote = remoteDeltaAPK
-			defer pruneCache(serial, result.chunks)
+			plan.BytesSent = result.WireBytes
+			defer collectGarbage(serial)
 		} else {
 			var unavailable ErrDeltaUnavailable
 			if errors.As(err, &unavailable) {
-				fmt.Printf("\n[!] Delta transfer unavailable: %s\n", unavailable.Reason)
+				console.Printf("\n[!] Delta transfer unavailable: %s\n", unavailable.Reason)
 			} else {
-				fmt.Printf("\n[!] Delta transfer failed: %v\n", err)
+				console.Printf("\n[!] Delta transfer failed: %v\n", err)
 			}
-			fmt.Println("[*] Sending the whole APK instead.")
+			console.Println("[*] Sending the whole APK instead.")
 		}
 	}
 
 	forgetInstalled(serial)
 
+	installed := func() {
+		if fingerprint != "" {
+			recordInstalled(serial, fingerprint, pkg)
+			plan.Fingerprint = fingerprint
+		}
+	}
+
+	if remote == "" {
+		if info, err := os.Stat(apkPath); err == nil {
+			plan.BytesSent = info.Size()
+		}
+	}
+
 	if opt.Stream {
 		// Binary on a device shell's stdin is not uniformly reliable on older
 		// Android, so a failure here is expected and must not end the deploy.
 		err := streamFrom(serial, apkPath, remote)
 		if err == nil {
-			plan.Streamed, plan.Delta = true, remote != ""
-			if fingerprint != "" {
-				recordInstalled(serial, fingerprint, pkg)
-			}
+			plan.Streamed, plan.Delta = true, remote != "" && !plan.Patched
+			installed()
 			return plan, nil
 		}
 
-		fmt.Printf("\n[!] Streaming install unavailable: %v\n", err)
-		fmt.Println("[*] Falling back to a staged install.")
+		console.Printf("\n[!] Streaming install unavailable: %v\n", err)
+		console.Println("[*] Falling back to a staged install.")
 	}
 
 	var err error
 	if remote != "" {
-		fmt.Println("[*] Installing...")
+		console.Println("[*] Installing...")
 		err = runInstall(serial, remote)
 	} else {
 		err = tryInstall(serial, apkPath)
@@ -344,10 +447,8 @@ func InstallWith(serial, apkPath string, opt Options) (InstallPlan, error) {
 		return plan, err
 	}
 
-	plan.Delta = remote != ""
-	if fingerprint != "" {
-		recordInstalled(serial, fingerprint, pkg)
-	}
+	plan.Delta = remote != "" && !plan.Patched
+	installed()
 
 	return plan, nil
 }
@@ -359,19 +460,19 @@ func Install(serial, apkPath string, useDelta bool) error {
 	}
 
 	if useDelta {
-		err := installDelta(serial, apkPath)
+		err := installDelta(serial, apkPath, config.GetCompressChunks())
 		if err == nil {
 			return nil
 		}
 
 		var unavailable ErrDeltaUnavailable
 		if errors.As(err, &unavailable) {
-			fmt.Printf("\n[!] Delta transfer unavailable: %s\n", unavailable.Reason)
-			fmt.Println("[*] Falling back to a full transfer.")
+			console.Printf("\n[!] Delta transfer unavailable: %s\n", unavailable.Reason)
+			console.Println("[*] Falling back to a full transfer.")
 		} else {
 
-			fmt.Printf("\n[!] Delta install failed: %v\n", err)
-			fmt.Println("[*] Falling back to a full transfer.")
+			console.Printf("\n[!] Delta install failed: %v\n", err)
+			console.Println("[*] Falling back to a full transfer.")
 		}
 	}
 
@@ -391,11 +492,11 @@ func Install(serial, apkPath string, useDelta bool) error {
 		strings.Contains(errLower, "closed") ||
 		strings.Contains(errLower, "error:") {
 
-		fmt.Printf("\n[!] Install failed: %v\n", err)
-		fmt.Println("[*] Attempting recovery: disconnect and reconnect...")
+		console.Printf("\n[!] Install failed: %v\n", err)
+		console.Println("[*] Attempting recovery: disconnect and reconnect...")
 
 		if disconnectErr := Disconnect(); disconnectErr != nil {
-			fmt.Printf("[!] Warning: disconnect failed: %v\n", disconnectErr)
+			console.Printf("[!] Warning: disconnect failed: %v\n", disconnectErr)
 		}
 
 		time.Sleep(2 * time.Second)
@@ -406,12 +507,12 @@ func Install(serial, apkPath string, useDelta bool) error {
 
 		time.Sleep(1 * time.Second)
 
-		fmt.Println("[*] Retrying install...")
+		console.Println("[*] Retrying install...")
 		if retryErr := tryInstall(serial, apkPath); retryErr != nil {
 			return fmt.Errorf("install failed after reconnect: %w", retryErr)
 		}
 
-		fmt.Println("[OK] Install succeeded after reconnect")
+		console.Println("[OK] Install succeeded after reconnect")
 		return nil
 	}
 
@@ -425,7 +526,7 @@ func tryInstall(serial, apkPath string) error {
 	}
 	sizeMB := float64(fileInfo.Size()) / (1024 * 1024)
 
-	fmt.Printf("[*] Transferring APK (%.1f MB)...\n", sizeMB)
+	console.Printf("[*] Transferring APK (%.1f MB)...\n", sizeMB)
 
 	pushArgs := []string{"push", apkPath, remoteAPKPath}
 	if serial != "" {
@@ -433,19 +534,26 @@ func tryInstall(serial, apkPath string) error {
 	}
 
 	pushStart := time.Now()
-	pushCmd := exec.Command("adb", pushArgs...)
-	pushCmd.Stdout = os.Stdout
-	pushCmd.Stderr = os.Stderr
-	if pushErr := pushCmd.Run(); pushErr != nil {
+	pushErr := errNoServer
+	if native() {
+		pushErr = nativePush(serial, apkPath, remoteAPKPath)
+	}
+	if fallback(pushErr) {
+		pushCmd := exec.Command("adb", pushArgs...)
+		pushCmd.Stdout = console.Out
+		pushCmd.Stderr = os.Stderr
+		pushErr = pushCmd.Run()
+	}
+	if pushErr != nil {
 		return fmt.Errorf("adb push failed: %w", pushErr)
 	}
 	pushSecs := time.Since(pushStart).Seconds()
 
 	if pushSecs > 0 {
-		fmt.Printf("[OK] Transferred in %.1fs (%.1f MB/s)\n", pushSecs, sizeMB/pushSecs)
+		console.Printf("[OK] Transferred in %.1fs (%.1f MB/s)\n", pushSecs, sizeMB/pushSecs)
 	}
 
-	fmt.Println("[*] Installing...")
+	console.Println("[*] Installing...")
 
 	defer func() {
 		_, _ = run(serial, "shell", "rm", "-f", remoteAPKPath)
@@ -0,0 +1,390 @@
+package adb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/delta"
+)
+
+// The chunk cache only pays for itself while it holds what the next push will
+// reuse, and on a Control Hub it competes with the app itself for a few
+// gigabytes of /data. Keeping only the last APK's chunks throws away the ones
+// a push of the branch someone switches back to would have reused; keeping
+// everything fills the hub, which has happened, and a full /data fails the
+// install rather than just the delta.
+//
+// So the hub keeps a copy of the manifest of each recent push, and the chunks
+// those manifests name stay. Each push touches the chunks it used, which makes
+// a chunk's modification time the last time anything referred to it, and when
+// the cache is over its cap or the hub is short of space the chunks referred
+// to longest ago go first. Everything lives on the hub, so a robot pushed to
+// from two laptops still has one record of what it holds.
+
+const (
+	remoteManifests = remoteRoot + "/manifests"
+	remoteHistory   = remoteRoot + "/history"
+
+	// historyLines is how many pushes the hit rate is worked out over.
+	historyLines = 200
+)
+
+// CachePolicy is how much of the chunk cache is kept.
+type CachePolicy struct {
+	// Keep is how many recent pushes keep every chunk they used.
+	Keep int
+	// MaxBytes caps the cache, zero for no cap. The most recent push's chunks
+	// stay even when they alone are over it.
+	MaxBytes int64
+}
+
+// ConfiguredCachePolicy is the policy from settings.
+func ConfiguredCachePolicy() CachePolicy {
+	return CachePolicy{
+		Keep:     config.GetCacheKeep(),
+		MaxBytes: int64(config.GetCacheLimitMB()) << 20,
+	}
+}
+
+// CacheUsage is what the chunk cache on one robot holds, and how much it has
+// saved.
+type CacheUsage struct {
+	Chunks    int
+	Bytes     int64
+	Manifests int
+
+	Pushes      int
+	SentBytes   int64
+	ReusedBytes int64
+
+	// Free is the space left on /data, -1 when the hub would not say.
+	Free int64
+}
+
+// HitRate is the share of pushed bytes the cache already had, and false
+// before there has been a delta push to work it out from.
+func (u CacheUsage) HitRate() (float64, bool) {
+	total := u.SentBytes + u.ReusedBytes
+	if total == 0 {
+		return 0, false
+	}
+	return float64(u.ReusedBytes) / float64(total), true
+}
+
+// PruneResult is what a prune removed and left.
+type PruneResult struct {
+	Removed   int
+	Freed     int64
+	Kept      int
+	KeptBytes int64
+}
+
+// cachedChunk is one chunk on the hub.
+type cachedChunk struct {
+	hash string
+	size int64
+	used int64
+	// packed is a chunk still compressed: one uploaded by a push that was
+	// interrupted before it could unpack, which takes the space all the same.
+	packed bool
+}
+
+// file is the chunk's name in the cache.
+func (c cachedChunk) file() string {
+	if c.packed {
+		return c.hash + ".chunk" + packedSuffix
+	}
+	return c.hash + ".chunk"
+}
+
+// CacheStatus reports on the chunk cache of one robot.
+func CacheStatus(serial string) (CacheUsage, error) {
+	usage := CacheUsage{Free: -1}
+
+	chunks, err := listCache(serial)
+	if err != nil {
+		return usage, err
+	}
+	usage.Chunks = len(chunks)
+	for _, c := range chunks {
+		usage.Bytes += c.size
+	}
+
+	manifests, err := readManifests(serial)
+	if err != nil {
+		return usage, err
+	}
+	usage.Manifests = len(manifests)
+
+	usage.Pushes, usage.SentBytes, usage.ReusedBytes = readHistory(serial)
+
+	if free, ok := freeBytes(serial, "/data"); ok {
+		usage.Free = free
+	}
+	return usage, nil
+}
+
+// PruneCache removes what the policy does not keep.
+func PruneCache(serial string, policy CachePolicy) (PruneResult, error) {
+	return prune(serial, policy, 0, nil)
+}
+
+// ClearCache removes every cached chunk and the manifests that referred to
+// them. The history stays, since it is a record of what past pushes saved.
+func ClearCache(serial string) error {
+	_, err := run(serial, "shell", fmt.Sprintf("rm -rf %s %s", remoteCacheDir, remoteManifests))
+	return err
+}
+
+// prune removes what the policy does not keep, and then, oldest use first, as
+// much more as it takes to free need bytes, never removing a pinned chunk.
+func prune(serial string, policy CachePolicy, need int64, pinned map[string]bool) (PruneResult, error) {
+	var result PruneResult
+
+	chunks, err := listCache(serial)
+	if err != nil {
+		return result, err
+	}
+	manifests, err := readManifests(serial)
+	if err != nil {
+		return result, err
+	}
+
+	keep := policy.Keep
+	if keep < 1 {
+		keep = 1
+	}
+
+	// A hub from before manifests were kept says nothing about what is still
+	// wanted, so only the cap applies to it until its next push.
+	referenced := map[string]bool{}
+	if len(manifests) == 0 {
+		for _, c := range chunks {
+			referenced[c.hash] = true
+		}
+	}
+	latest := map[string]bool{}
+	for i, m := range manifests {
+		if i >= keep {
+			break
+		}
+		for _, hash := range m.hashes {
+			referenced[hash] = true
+			if i == 0 {
+				latest[hash] = true
+			}
+		}
+	}
+	if pinned == nil {
+		pinned = latest
+	}
+
+	gone := evict(chunks, referenced, pinned, policy.MaxBytes, need)
+	for _, c := range gone {
+		result.Freed += c.size
+	}
+	if err := removeChunks(serial, gone); err != nil {
+		return result, err
+	}
+	result.Removed = len(gone)
+	result.Kept = len(chunks) - len(gone)
+	for _, c := range chunks {
+		result.KeptBytes += c.size
+	}
+	result.KeptBytes -= result.Freed
+
+	var old []string
+	for _, m := range manifests[min(keep, len(manifests)):] {
+		old = append(old, remoteManifests+"/"+m.name)
+	}
+	if len(old) > 0 {
+		_, _ = run(serial, "shell", "rm -f "+strings.Join(old, " "))
+	}
+	_, _ = run(serial, "shell", fmt.Sprintf("tail -n %d %s > %s.new 2>/dev/null && mv %s.new %s; true",
+		historyLines, remoteHistory, remoteHistory, remoteHistory, remoteHistory))
+
+	return result, nil
+}
+
+// evict chooses which chunks go: everything no kept push referred to, then the
+// ones referred to longest ago until the cache is under maxBytes and need bytes
+// have been freed. Pinned chunks stay whatever that leaves.
+func evict(chunks []cachedChunk, referenced, pinned map[string]bool, maxBytes, need int64) []cachedChunk {
+	var gone, rest []cachedChunk
+	var total, freed int64
+
+	for _, c := range chunks {
+		if !referenced[c.hash] && !pinned[c.hash] {
+			gone = append(gone, c)
+			freed += c.size
+			continue
+		}
+		rest = append(rest, c)
+		total += c.size
+	}
+
+	sort.SliceStable(rest, func(a, b int) bool { return rest[a].used < rest[b].used })
+	for _, c := range rest {
+		over := maxBytes > 0 && total > maxBytes
+		if !over && freed >= need {
+			break
+		}
+		if pinned[c.hash] {
+			continue
+		}
+		gone = append(gone, c)
+		total -= c.size
+		freed += c.size
+	}
+
+	return gone
+}
+
+// recordPush remembers a delta push that rebuilt its APK: its manifest joins
+// the kept ones, the chunks it used count as used now, and the history gains
+// a line for the hit rate.
+func recordPush(serial string, result *DeltaResult) {
+	stamp := fmt.Sprintf("%019d", time.Now().UnixNano())
+	_, _ = run(serial, "shell", fmt.Sprintf(
+		"mkdir -p %s && cp %s %s/%s && cd %s && touch -c $(cat %s); echo %d %d %d >> %s",
+		remoteManifests, remoteManifest, remoteManifests, stamp,
+		remoteCacheDir, remoteManifest,
+		time.Now().Unix(), result.SentBytes, result.SkippedBytes, remoteHistory))
+}
+
+// collectGarbage prunes to the configured policy after a push, quietly: a
+// cache that could not be pruned costs space, not the deploy.
+func collectGarbage(serial string) {
+	_, _ = PruneCache(serial, ConfiguredCachePolicy())
+}
+
+// makeRoom frees need bytes from the cache, keeping the chunks of the APK
+// about to be pushed.
+func makeRoom(serial string, need int64, chunks []delta.Chunk) (int64, error) {
+	pinned := make(map[string]bool, len(chunks))
+	for _, c := range chunks {
+		pinned[c.Hash] = true
+	}
+	result, err := prune(serial, ConfiguredCachePolicy(), need, pinned)
+	return result.Freed, err
+}
+
+// listCache lists the chunks on the hub with their sizes and last use, packed
+// ones included: left behind by a push that never unpacked them, they would
+// otherwise sit outside the cap for good.
+func listCache(serial string) ([]cachedChunk, error) {
+	out, err := run(serial, "shell", fmt.Sprintf(
+		"cd %s 2>/dev/null && stat -c '%%s %%Y %%n' *.chunk *.chunk%s 2>/dev/null; true", remoteCacheDir, packedSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("cannot list the chunk cache: %w", err)
+	}
+	return parseCacheList(out), nil
+}
+
+func parseCacheList(out string) []cachedChunk {
+	var chunks []cachedChunk
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		name, packed := strings.CutSuffix(fields[2], packedSuffix)
+		hash, ok := strings.CutSuffix(name, ".chunk")
+		if !ok || hash == "*" {
+			continue
+		}
+		size, err1 := strconv.ParseInt(fields[0], 10, 64)
+		used, err2 := strconv.ParseInt(fields[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		chunks = append(chunks, cachedChunk{hash: hash, size: size, used: used, packed: packed})
+	}
+	return chunks
+}
+
+// keptManifest is one recent push's list of chunks.
+type keptManifest struct {
+	name   string
+	hashes []string
+}
+
+// readManifests reads the kept manifests, newest first.
+func readManifests(serial string) ([]keptManifest, error) {
+	out, err := run(serial, "shell", fmt.Sprintf(
+		`cd %s 2>/dev/null && for f in *; do [ -f "$f" ] && echo "# $f" && cat "$f"; done; true`, remoteManifests))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the kept manifests: %w", err)
+	}
+	return parseManifests(out), nil
+}
+
+func parseManifests(out string) []keptManifest {
+	var manifests []keptManifest
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if name, ok := strings.CutPrefix(line, "# "); ok {
+			manifests = append(manifests, keptManifest{name: name})
+			continue
+		}
+		if hash, ok := strings.CutSuffix(line, ".chunk"); ok && len(manifests) > 0 {
+			last := &manifests[len(manifests)-1]
+			last.hashes = append(last.hashes, hash)
+		}
+	}
+
+	// Names are zero-padded times, so the newest sorts last.
+	sort.Slice(manifests, func(a, b int) bool { return manifests[a].name > manifests[b].name })
+	return manifests
+}
+
+// readHistory totals the recorded pushes.
+func readHistory(serial string) (pushes int, sent, reused int64) {
+	out, err := run(serial, "shell", "cat "+remoteHistory+" 2>/dev/null; true")
+	if err != nil {
+		return 0, 0, 0
+	}
+	return parseHistory(out)
+}
+
+func parseHistory(out string) (pushes int, sent, reused int64) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		s, err1 := strconv.ParseInt(fields[1], 10, 64)
+		r, err2 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		pushes++
+		sent += s
+		reused += r
+	}
+	return pushes, sent, reused
+}
+
+// removeChunks deletes chunks from the cache, a batch at a time so no command
+// line gets too long for the hub's shell.
+func removeChunks(serial string, chunks []cachedChunk) error {
+	const batch = 200
+	for start := 0; start < len(chunks); start += batch {
+		end := min(start+batch, len(chunks))
+
+		var b strings.Builder
+		b.WriteString("cd " + remoteCacheDir + " && rm -f")
+		for _, c := range chunks[start:end] {
+			b.WriteString(" " + c.file())
+		}
+
+		if _, err := run(serial, "shell", b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
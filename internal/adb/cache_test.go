@@ -0,0 +1,148 @@
+package adb
+
+import (
+	"sort"
+	"testing"
+)
+
+func hashesOf(chunks []cachedChunk) []string {
+	var out []string
+	for _, c := range chunks {
+		out = append(out, c.hash)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func set(names ...string) map[string]bool {
+	m := map[string]bool{}
+	for _, n := range names {
+		m[n] = true
+	}
+	return m
+}
+
+func TestEvictDropsWhatNoKeptPushUses(t *testing.T) {
+	chunks := []cachedChunk{
+		{hash: "a", size: 10, used: 100},
+		{hash: "b", size: 10, used: 200},
+		{hash: "old", size: 10, used: 50},
+	}
+
+	gone := evict(chunks, set("a", "b"), set("b"), 0, 0)
+	if got := hashesOf(gone); len(got) != 1 || got[0] != "old" {
+		t.Errorf("evicted %v, want only the unreferenced chunk", got)
+	}
+}
+
+func TestEvictHonoursTheCapOldestFirst(t *testing.T) {
+	chunks := []cachedChunk{
+		{hash: "newest", size: 40, used: 300},
+		{hash: "middle", size: 40, used: 200},
+		{hash: "oldest", size: 40, used: 100},
+	}
+	all := set("newest", "middle", "oldest")
+
+	gone := evict(chunks, all, set("newest"), 90, 0)
+	if got := hashesOf(gone); len(got) != 1 || got[0] != "oldest" {
+		t.Errorf("evicted %v, want the chunk used longest ago", got)
+	}
+
+	// The latest push stays even when it alone is over the cap.
+	gone = evict(chunks, all, set("newest"), 10, 0)
+	if got := hashesOf(gone); len(got) != 2 {
+		t.Errorf("evicted %v, want everything but the pinned chunk", got)
+	}
+}
+
+func TestEvictFreesWhatAPushNeeds(t *testing.T) {
+	chunks := []cachedChunk{
+		{hash: "x", size: 30, used: 100},
+		{hash: "y", size: 30, used: 200},
+		{hash: "z", size: 30, used: 300},
+		{hash: "unused", size: 5, used: 400},
+	}
+
+	// Under pressure the unreferenced chunk counts towards the need, and then
+	// the least recently used go until enough is free.
+	gone := evict(chunks, set("x", "y", "z"), set("z"), 0, 50)
+	got := hashesOf(gone)
+	want := []string{"unused", "x", "y"}
+	if len(got) != len(want) {
+		t.Fatalf("evicted %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("evicted %v, want %v", got, want)
+		}
+	}
+
+	if gone := evict(chunks, set("x", "y", "z"), nil, 0, 0); len(gone) != 1 {
+		t.Errorf("with no pressure and no cap only the unreferenced chunk should go, got %v", hashesOf(gone))
+	}
+}
+
+func TestParseCacheList(t *testing.T) {
+	out := "262144 1700000000 aa.chunk\n131072 1700000100 bb.chunk\n" +
+		"stat: '*.chunk': No such file or directory\n0 0 *.chunk\nnonsense\n"
+
+	chunks := parseCacheList(out)
+	if len(chunks) != 2 {
+		t.Fatalf("got %+v", chunks)
+	}
+	if chunks[0] != (cachedChunk{hash: "aa", size: 262144, used: 1700000000}) {
+		t.Errorf("got %+v", chunks[0])
+	}
+}
+
+// A push interrupted between upload and unpack leaves packed chunks, which
+// take space and have to count against the cap like any other.
+func TestPackedChunksAreListedAndRemovedByTheirOwnName(t *testing.T) {
+	out := "262144 1700000000 aa.chunk\n4096 1700000100 bb.chunk.gz\n" +
+		"stat: '*.chunk.gz': No such file or directory\n"
+
+	chunks := parseCacheList(out)
+	if len(chunks) != 2 {
+		t.Fatalf("got %+v", chunks)
+	}
+	if chunks[1] != (cachedChunk{hash: "bb", size: 4096, used: 1700000100, packed: true}) {
+		t.Errorf("got %+v", chunks[1])
+	}
+	if chunks[0].file() != "aa.chunk" || chunks[1].file() != "bb.chunk.gz" {
+		t.Errorf("files %s and %s", chunks[0].file(), chunks[1].file())
+	}
+
+	if gone := evict(chunks, set("aa"), nil, 0, 0); len(gone) != 1 || !gone[0].packed {
+		t.Errorf("evicted %+v, want the packed leftover", gone)
+	}
+}
+
+func TestParseManifestsNewestFirst(t *testing.T) {
+	out := "# 0001700000000000000000\naa.chunk\nbb.chunk\n# 0001700000500000000000\nbb.chunk\ncc.chunk\n"
+
+	manifests := parseManifests(out)
+	if len(manifests) != 2 {
+		t.Fatalf("got %+v", manifests)
+	}
+	if manifests[0].name != "0001700000500000000000" {
+		t.Errorf("the newest manifest should come first, got %s", manifests[0].name)
+	}
+	if len(manifests[0].hashes) != 2 || manifests[0].hashes[1] != "cc" {
+		t.Errorf("got %v", manifests[0].hashes)
+	}
+}
+
+func TestHitRate(t *testing.T) {
+	pushes, sent, reused := parseHistory("1700000000 1000 0\n1700000100 100 900\nbroken line\n")
+	if pushes != 2 || sent != 1100 || reused != 900 {
+		t.Fatalf("got %d pushes, %d sent, %d reused", pushes, sent, reused)
+	}
+
+	rate, ok := CacheUsage{SentBytes: sent, ReusedBytes: reused}.HitRate()
+	if !ok || rate != 0.45 {
+		t.Errorf("hit rate %v, %v", rate, ok)
+	}
+	if _, ok := (CacheUsage{}).HitRate(); ok {
+		t.Error("no pushes should have no hit rate")
+	}
+}
@@ -0,0 +1,477 @@
+package adb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Starting the adb executable costs tens of milliseconds before it does
+// anything, a deploy runs it dozens of times, and what comes back is text meant
+// for a person that changes between platform-tools releases. The executable is
+// itself only a client of the adb server, which listens on localhost:5037 and
+// speaks a small documented protocol, so Pusher speaks it too.
+//
+// The executable stays as the fallback. It is what starts the server when none
+// is running, and it is the way out if this client ever disagrees with a
+// device: PUSHER_ADB=exec goes back to it for everything.
+
+const (
+	defaultServerPort = "5037"
+
+	dialTimeout = 2 * time.Second
+)
+
+// A server or device that stops answering would otherwise hold a read open
+// for good, and with it the deploy, since a wedged hub keeps its connection
+// up. Each read and write has as long as the other end should need and no
+// longer; they are the longest waits of what they cover, not totals, so a
+// big transfer that keeps moving is never cut off.
+var (
+	// requestTimeout covers the server's own replies: OKAY, FAIL, a list.
+	requestTimeout = 10 * time.Second
+	// shellTimeout covers a command's output. pm install says nothing until it
+	// is done, which on a full hub is the better part of a minute.
+	shellTimeout = 2 * time.Minute
+	// syncTimeout covers one packet of a file and its reply, which a hub
+	// writing to slow flash can take several seconds over.
+	syncTimeout = 30 * time.Second
+)
+
+// serverAddr is where the adb server listens. adb itself honours
+// ANDROID_ADB_SERVER_PORT, so Pusher does too, or the two would disagree about
+// which server they meant.
+var serverAddr = func() string {
+	port := strings.TrimSpace(os.Getenv("ANDROID_ADB_SERVER_PORT"))
+	if port == "" {
+		port = defaultServerPort
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}()
+
+// errNoServer is a native request that could not reach the server at all. It is
+// the one failure worth retrying through the executable, which starts the
+// server; a device that refused is refused whichever way it is asked.
+var errNoServer = errors.New("adb server not reachable")
+
+// native reports whether to try the server before the executable.
+func native() bool {
+	return strings.TrimSpace(strings.ToLower(os.Getenv("PUSHER_ADB"))) != "exec"
+}
+
+// hostConn is one connection to the adb server. Each carries a single request,
+// or a transport and then a single service, because that is how the server
+// works: it hands the socket to the device once a transport is chosen.
+type hostConn struct {
+	net.Conn
+
+	// wait is how long any one read or write may take.
+	wait time.Duration
+}
+
+func dialServer() (*hostConn, error) {
+	conn, err := net.DialTimeout("tcp", serverAddr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoServer, err)
+	}
+	return &hostConn{Conn: conn, wait: requestTimeout}, nil
+}
+
+func (c *hostConn) Read(p []byte) (int, error) {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.wait))
+	n, err := c.Conn.Read(p)
+	return n, c.stalled(err)
+}
+
+func (c *hostConn) Write(p []byte) (int, error) {
+	_ = c.Conn.SetWriteDeadline(time.Now().Add(c.wait))
+	n, err := c.Conn.Write(p)
+	return n, c.stalled(err)
+}
+
+// stalled says which wait ran out, when one did, rather than leaving a bare
+// i/o timeout.
+func (c *hostConn) stalled(err error) error {
+	var timeout net.Error
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return fmt.Errorf("nothing from the adb server in %s: %w", c.wait, err)
+	}
+	return err
+}
+
+// request sends one request, four hex digits of length and then the text, and
+// reads the server's OKAY or FAIL.
+func (c *hostConn) request(req string) error {
+	if _, err := fmt.Fprintf(c, "%04x%s", len(req), req); err != nil {
+		return err
+	}
+	return c.status()
+}
+
+func (c *hostConn) status() error {
+	var word [4]byte
+	if _, err := io.ReadFull(c, word[:]); err != nil {
+		return err
+	}
+
+	switch string(word[:]) {
+	case "OKAY":
+		return nil
+	case "FAIL":
+		msg, err := c.message()
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	return fmt.Errorf("unexpected reply %q from the adb server", word[:])
+}
+
+// message reads a length-prefixed reply.
+func (c *hostConn) message() (string, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(c, length[:]); err != nil {
+		return "", err
+	}
+
+	n, err := strconv.ParseUint(string(length[:]), 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("bad length %q from the adb server", length[:])
+	}
+
+	body := make([]byte, n)
+	if _, err := io.ReadFull(c, body); err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// query is a request answered by the server itself, not a device.
+func query(req string) (string, error) {
+	c, err := dialServer()
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	if err := c.request(req); err != nil {
+		return "", fmt.Errorf("%s: %w", req, err)
+	}
+	return c.message()
+}
+
+// transport connects to the server and chooses the device the rest of the
+// connection talks to. With no serial the server picks, and complains when
+// there is more than one, as the executable does.
+func transport(serial string) (*hostConn, error) {
+	c, err := dialServer()
+	if err != nil {
+		return nil, err
+	}
+
+	req := "host:transport-any"
+	if serial != "" {
+		req = "host:transport:" + serial
+	}
+	if err := c.request(req); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func nativeDevices() ([]Device, error) {
+	out, err := query("host:devices-l")
+	if err != nil {
+		return nil, err
+	}
+	return parseDevices(out), nil
+}
+
+// exitMarker follows a command's output with its exit status. The shell:
+// service predates exit statuses and only carries output, but every caller
+// that asks whether a file exists relies on one.
+const exitMarker = "\x1ePUSHER_EXIT:"
+
+// nativeShell runs a command on the device and returns its combined output,
+// failing on a non-zero exit the way the executable does.
+func nativeShell(serial, command string) (string, error) {
+	c, err := transport(serial)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	full := fmt.Sprintf("%s; printf '%%s%%d' '%s' $?", command, exitMarker)
+	if err := c.request("shell:" + full); err != nil {
+		return "", err
+	}
+
+	c.wait = shellTimeout
+
+	raw, err := io.ReadAll(c)
+	if err != nil {
+		return "", err
+	}
+
+	out, code, ok := splitExit(string(raw))
+	if !ok {
+		return out, fmt.Errorf("the device closed the shell before %q finished", command)
+	}
+	if code != 0 {
+		return "", fmt.Errorf("exit status %d (output: %s)", code, strings.TrimSpace(out))
+	}
+	return out, nil
+}
+
+// splitExit separates a command's output from the status printed after it.
+func splitExit(raw string) (string, int, bool) {
+	at := strings.LastIndex(raw, exitMarker)
+	if at < 0 {
+		return raw, 0, false
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(raw[at+len(exitMarker):]))
+	if err != nil {
+		return raw[:at], 0, false
+	}
+	return raw[:at], code, true
+}
+
+// fallback reports whether a native attempt failed in a way the executable
+// might not.
+func fallback(err error) bool {
+	return errors.Is(err, errNoServer)
+}
+
+// sync protocol, for files. Every request and reply is four letters and a
+// little-endian length.
+
+const syncChunk = 64 * 1024
+
+// syncConn is a connection switched into the sync service.
+type syncConn struct {
+	*hostConn
+}
+
+func openSync(serial string) (*syncConn, error) {
+	c, err := transport(serial)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.request("sync:"); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	c.wait = syncTimeout
+	return &syncConn{c}, nil
+}
+
+func (s *syncConn) send(id string, body []byte) error {
+	head := make([]byte, 8, 8+len(body))
+	copy(head, id)
+	binary.LittleEndian.PutUint32(head[4:], uint32(len(body)))
+	_, err := s.Write(append(head, body...))
+	return err
+}
+
+func (s *syncConn) sendValue(id string, value uint32) error {
+	head := make([]byte, 8)
+	copy(head, id)
+	binary.LittleEndian.PutUint32(head[4:], value)
+	_, err := s.Write(head)
+	return err
+}
+
+// reply reads one reply header: its id and the length or value after it.
+func (s *syncConn) reply() (string, uint32, error) {
+	var head [8]byte
+	if _, err := io.ReadFull(s, head[:]); err != nil {
+		return "", 0, err
+	}
+	return string(head[:4]), binary.LittleEndian.Uint32(head[4:]), nil
+}
+
+// failure reads the message of a FAIL reply.
+func (s *syncConn) failure(length uint32) error {
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(s, msg); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// Close says goodbye before closing, so the device does not log a broken sync.
+func (s *syncConn) Close() error {
+	_ = s.sendValue("QUIT", 0)
+	return s.hostConn.Close()
+}
+
+// remoteStat is what STAT says about a path. A zero mode means nothing is
+// there.
+type remoteStat struct {
+	Mode  uint32
+	Size  uint32
+	MTime uint32
+}
+
+func (r remoteStat) exists() bool { return r.Mode != 0 }
+
+func (r remoteStat) isDir() bool { return r.Mode&0o170000 == 0o040000 }
+
+func (s *syncConn) stat(path string) (remoteStat, error) {
+	if err := s.send("STAT", []byte(path)); err != nil {
+		return remoteStat{}, err
+	}
+
+	var reply [16]byte
+	if _, err := io.ReadFull(s, reply[:]); err != nil {
+		return remoteStat{}, err
+	}
+	if string(reply[:4]) != "STAT" {
+		return remoteStat{}, fmt.Errorf("unexpected reply %q to STAT", reply[:4])
+	}
+	return remoteStat{
+		Mode:  binary.LittleEndian.Uint32(reply[4:]),
+		Size:  binary.LittleEndian.Uint32(reply[8:]),
+		MTime: binary.LittleEndian.Uint32(reply[12:]),
+	}, nil
+}
+
+// put writes r to path on the device. The device makes any missing parent
+// directories itself.
+func (s *syncConn) put(r io.Reader, path string, mode os.FileMode, mtime time.Time) error {
+	if err := s.send("SEND", []byte(fmt.Sprintf("%s,%d", path, uint32(mode.Perm())|0o100000))); err != nil {
+		return err
+	}
+
+	buf := make([]byte, syncChunk)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if werr := s.send("DATA", buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.sendValue("DONE", uint32(mtime.Unix())); err != nil {
+		return err
+	}
+
+	id, length, err := s.reply()
+	if err != nil {
+		return err
+	}
+	switch id {
+	case "OKAY":
+		return nil
+	case "FAIL":
+		return s.failure(length)
+	}
+	return fmt.Errorf("unexpected reply %q to SEND", id)
+}
+
+// get copies path on the device into w.
+func (s *syncConn) get(path string, w io.Writer) error {
+	if err := s.send("RECV", []byte(path)); err != nil {
+		return err
+	}
+
+	for {
+		id, length, err := s.reply()
+		if err != nil {
+			return err
+		}
+
+		switch id {
+		case "DATA":
+			if _, err := io.CopyN(w, s, int64(length)); err != nil {
+				return err
+			}
+		case "DONE":
+			return nil
+		case "FAIL":
+			return s.failure(length)
+		default:
+			return fmt.Errorf("unexpected reply %q to RECV", id)
+		}
+	}
+}
+
+// nativePush copies a local file onto the device. A directory at remote gets
+// the file inside it, as adb push does.
+func nativePush(serial, local, remote string) error {
+	file, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	s, err := openSync(serial)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if st, err := s.stat(remote); err == nil && st.isDir() {
+		remote = strings.TrimSuffix(remote, "/") + "/" + info.Name()
+	}
+
+	if err := s.put(file, remote, info.Mode(), info.ModTime()); err != nil {
+		return fmt.Errorf("push %s: %w", remote, err)
+	}
+	return nil
+}
+
+// nativePull copies a file off the device. Nothing is left at local when the
+// copy fails halfway.
+func nativePull(serial, remote, local string) error {
+	if info, err := os.Stat(local); err == nil && info.IsDir() {
+		local = strings.TrimSuffix(local, string(os.PathSeparator)) + string(os.PathSeparator) + remoteBase(remote)
+	}
+
+	s, err := openSync(serial)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	file, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+
+	if err := s.get(remote, file); err != nil {
+		file.Close()
+		os.Remove(local)
+		return fmt.Errorf("pull %s: %w", remote, err)
+	}
+	return file.Close()
+}
+
+func remoteBase(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
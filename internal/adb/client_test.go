@@ -0,0 +1,480 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer speaks enough of the adb server's protocol to stand in for one
+// with a single device attached, whose files are a map.
+type fakeServer struct {
+	t      *testing.T
+	serial string
+	shell  func(command string) (string, int)
+
+	// refuse, when set, fails a SEND to the path it names.
+	refuse func(path string) bool
+	// slow holds each SEND back, so several streams overlap.
+	slow time.Duration
+
+	// mdns is the server's list of wireless debugging services, and pairCode
+	// the code a pairing has to give.
+	mdns     func() string
+	pairCode string
+
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+	ran   []string
+	syncs int
+}
+
+func startFake(t *testing.T, serial string) *fakeServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeServer{
+		t:      t,
+		serial: serial,
+		files:  map[string][]byte{},
+		dirs:   map[string]bool{},
+		shell:  func(string) (string, int) { return "", 0 },
+	}
+
+	old := serverAddr
+	serverAddr = listener.Addr().String()
+	t.Setenv("PUSHER_ADB", "")
+	t.Cleanup(func() {
+		serverAddr = old
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func readRequest(conn net.Conn) (string, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(string(length[:]), 16, 32)
+	if err != nil {
+		return "", err
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(conn, body)
+	return string(body), err
+}
+
+func fail(conn net.Conn, msg string) {
+	fmt.Fprintf(conn, "FAIL%04x%s", len(msg), msg)
+}
+
+func (f *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	req, err := readRequest(conn)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case req == "host:devices-l":
+		list := f.serial + "          device usb:1-1 product:sdm660 model:Control_Hub device:hub\n"
+		fmt.Fprintf(conn, "OKAY%04x%s", len(list), list)
+		return
+
+	case req == "host:mdns:services":
+		list := ""
+		if f.mdns != nil {
+			list = f.mdns()
+		}
+		fmt.Fprintf(conn, "OKAY%04x%s", len(list), list)
+		return
+
+	case strings.HasPrefix(req, "host:pair:"):
+		code, addr, _ := strings.Cut(strings.TrimPrefix(req, "host:pair:"), ":")
+		reply := "Successfully paired to " + addr + " [guid=adb-R58M-x1y2z3]"
+		if code != f.pairCode {
+			reply = "Failed: Wrong password or connection was dropped."
+		}
+		fmt.Fprintf(conn, "OKAY%04x%s", len(reply), reply)
+		return
+
+	case req == "host:transport-any", req == "host:transport:"+f.serial:
+		conn.Write([]byte("OKAY"))
+
+	case strings.HasPrefix(req, "host:transport:"):
+		fail(conn, fmt.Sprintf("device '%s' not found", strings.TrimPrefix(req, "host:transport:")))
+		return
+
+	default:
+		fail(conn, "unknown request "+req)
+		return
+	}
+
+	service, err := readRequest(conn)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(service, "shell:"):
+		conn.Write([]byte("OKAY"))
+		command, _, found := strings.Cut(strings.TrimPrefix(service, "shell:"), "; printf ")
+		if !found {
+			f.t.Errorf("shell command went without its exit status: %q", service)
+		}
+		f.mu.Lock()
+		f.ran = append(f.ran, command)
+		f.mu.Unlock()
+		out, code := f.shell(command)
+		fmt.Fprintf(conn, "%s%s%d", out, exitMarker, code)
+
+	case service == "sync:":
+		f.mu.Lock()
+		f.syncs++
+		f.mu.Unlock()
+		conn.Write([]byte("OKAY"))
+		f.sync(conn)
+
+	default:
+		fail(conn, "unknown service "+service)
+	}
+}
+
+func (f *fakeServer) sync(conn net.Conn) {
+	head := make([]byte, 8)
+	reply := func(id string, value uint32, body []byte) {
+		out := make([]byte, 8)
+		copy(out, id)
+		binary.LittleEndian.PutUint32(out[4:], value)
+		conn.Write(append(out, body...))
+	}
+
+	for {
+		if _, err := io.ReadFull(conn, head); err != nil {
+			return
+		}
+		id, length := string(head[:4]), binary.LittleEndian.Uint32(head[4:])
+		body := make([]byte, length)
+		if id != "QUIT" {
+			if _, err := io.ReadFull(conn, body); err != nil {
+				return
+			}
+		}
+
+		f.mu.Lock()
+		switch id {
+		case "QUIT":
+			f.mu.Unlock()
+			return
+
+		case "STAT":
+			var mode, size uint32
+			if data, ok := f.files[string(body)]; ok {
+				mode, size = 0o100644, uint32(len(data))
+			} else if f.dirs[string(body)] {
+				mode = 0o040755
+			}
+			// The one reply with more than a length after its id: mode, size
+			// and modification time.
+			rest := make([]byte, 8)
+			binary.LittleEndian.PutUint32(rest, size)
+			reply("STAT", mode, rest)
+
+		case "SEND":
+			f.mu.Unlock()
+			time.Sleep(f.slow)
+			f.mu.Lock()
+			path, _, _ := strings.Cut(string(body), ",")
+			var data []byte
+			for {
+				if _, err := io.ReadFull(conn, head); err != nil {
+					f.mu.Unlock()
+					return
+				}
+				n := binary.LittleEndian.Uint32(head[4:])
+				if string(head[:4]) == "DONE" {
+					break
+				}
+				chunk := make([]byte, n)
+				io.ReadFull(conn, chunk)
+				data = append(data, chunk...)
+			}
+			if f.refuse != nil && f.refuse(path) {
+				msg := "No space left on device"
+				reply("FAIL", uint32(len(msg)), []byte(msg))
+				break
+			}
+			f.files[path] = data
+			reply("OKAY", 0, nil)
+
+		case "RECV":
+			data, ok := f.files[string(body)]
+			if !ok {
+				msg := "No such file or directory"
+				reply("FAIL", uint32(len(msg)), []byte(msg))
+				break
+			}
+			for len(data) > 0 {
+				n := len(data)
+				if n > syncChunk {
+					n = syncChunk
+				}
+				reply("DATA", uint32(n), data[:n])
+				data = data[n:]
+			}
+			reply("DONE", 0, nil)
+		}
+		f.mu.Unlock()
+	}
+}
+
+func TestDevicesComeFromTheServer(t *testing.T) {
+	startFake(t, "HUB123")
+
+	devices, err := Devices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || devices[0].Serial != "HUB123" || devices[0].Model != "Control Hub" {
+		t.Errorf("got %+v", devices)
+	}
+}
+
+func TestShellCarriesOutputAndExitStatus(t *testing.T) {
+	f := startFake(t, "HUB123")
+	f.shell = func(command string) (string, int) {
+		if command == "ls -d /missing 2>/dev/null" {
+			return "", 1
+		}
+		return "ro.product.cpu.abilist=arm64-v8a\n", 0
+	}
+
+	out, err := Shell("HUB123", "getprop", "ro.product.cpu.abilist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "ro.product.cpu.abilist=arm64-v8a\n" {
+		t.Errorf("output was %q", out)
+	}
+	if f.ran[0] != "getprop ro.product.cpu.abilist" {
+		t.Errorf("the device ran %q", f.ran[0])
+	}
+
+	// Callers ask whether a file exists by whether ls failed.
+	if _, err := Shell("HUB123", "ls", "-d", "/missing", "2>/dev/null"); err == nil {
+		t.Error("a command that exited 1 reported success")
+	}
+}
+
+func TestAnUnknownDeviceIsNotRetriedThroughTheExecutable(t *testing.T) {
+	startFake(t, "HUB123")
+
+	_, err := Shell("OTHER", "true")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("got %v", err)
+	}
+	if fallback(err) {
+		t.Error("a device the server refused would be asked again through the executable")
+	}
+}
+
+func TestPushAndPullRoundTrip(t *testing.T) {
+	f := startFake(t, "HUB123")
+	f.dirs["/sdcard/FIRST"] = true
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "robot.xml")
+
+	// Bigger than one DATA packet, so it has to be split and rejoined.
+	content := bytes.Repeat([]byte("0123456789abcdef"), syncChunk/8)
+	if err := os.WriteFile(local, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Push("HUB123", local, "/sdcard/FIRST/a.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.files["/sdcard/FIRST/a.xml"], content) {
+		t.Fatalf("the device got %d bytes, want %d", len(f.files["/sdcard/FIRST/a.xml"]), len(content))
+	}
+
+	// A directory as the destination takes the file's own name.
+	if err := Push("HUB123", local, "/sdcard/FIRST"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.files["/sdcard/FIRST/robot.xml"]; !ok {
+		t.Error("pushing into a directory did not keep the file's name")
+	}
+
+	back := filepath.Join(dir, "back.xml")
+	if err := Pull("HUB123", "/sdcard/FIRST/a.xml", back); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(back)
+	if !bytes.Equal(got, content) {
+		t.Errorf("pulled %d bytes, want %d", len(got), len(content))
+	}
+}
+
+func TestAFailedPullLeavesNothingBehind(t *testing.T) {
+	startFake(t, "HUB123")
+
+	local := filepath.Join(t.TempDir(), "nothing.xml")
+	err := Pull("HUB123", "/sdcard/FIRST/nothing.xml", local)
+	if err == nil || !strings.Contains(err.Error(), "No such file") {
+		t.Fatalf("got %v", err)
+	}
+	if _, statErr := os.Stat(local); !os.IsNotExist(statErr) {
+		t.Error("a failed pull left a file behind")
+	}
+}
+
+func TestNoServerFallsBack(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	old := serverAddr
+	serverAddr = addr
+	defer func() { serverAddr = old }()
+
+	if _, err := nativeDevices(); !fallback(err) {
+		t.Errorf("a server that is not running should send adb through the executable, got %v", err)
+	}
+}
+
+// stallingServer answers the first okays requests on each connection and then
+// goes quiet with the connection still open, as a wedged hub does.
+func stallingServer(t *testing.T, okays int) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := serverAddr
+	serverAddr = listener.Addr().String()
+	t.Setenv("PUSHER_ADB", "")
+
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		serverAddr = old
+		close(stop)
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for i := 0; i < okays; i++ {
+					if _, err := readRequest(conn); err != nil {
+						return
+					}
+					fmt.Fprint(conn, "OKAY")
+				}
+				<-stop
+			}()
+		}
+	}()
+}
+
+// shortWaits makes every wait short enough for a test to sit through.
+func shortWaits(t *testing.T) {
+	old := [3]time.Duration{requestTimeout, shellTimeout, syncTimeout}
+	requestTimeout, shellTimeout, syncTimeout = 50*time.Millisecond, 100*time.Millisecond, 100*time.Millisecond
+	t.Cleanup(func() { requestTimeout, shellTimeout, syncTimeout = old[0], old[1], old[2] })
+}
+
+// A server that stops answering must fail the request, not hold it for good.
+// Nor is it a reason to try the executable, which would wait on the same
+// server.
+func TestAStalledServerIsGivenUpOn(t *testing.T) {
+	shortWaits(t)
+
+	for _, c := range []struct {
+		name  string
+		okays int
+		call  func() error
+	}{
+		{"request", 0, func() error { _, err := nativeDevices(); return err }},
+		{"shell", 2, func() error { _, err := nativeShell("HUB123", "pm install /data/local/tmp/a.apk"); return err }},
+		{"sync", 2, func() error {
+			local := filepath.Join(t.TempDir(), "a.xml")
+			if err := os.WriteFile(local, []byte("<Robot/>"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return nativePush("HUB123", local, "/sdcard/FIRST/a.xml")
+		}},
+	} {
+		stallingServer(t, c.okays)
+
+		done := make(chan error, 1)
+		go func() { done <- c.call() }()
+
+		select {
+		case err := <-done:
+			if err == nil || !strings.Contains(err.Error(), "nothing from the adb server") {
+				t.Errorf("%s: got %v, want the wait that ran out named", c.name, err)
+			}
+			if fallback(err) {
+				t.Errorf("%s: a stalled server would be asked again through the executable", c.name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: still waiting on a server that stopped answering", c.name)
+		}
+	}
+}
+
+func TestSplitExit(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		out  string
+		code int
+		ok   bool
+	}{
+		{"hello\n" + exitMarker + "0", "hello\n", 0, true},
+		{"no newline" + exitMarker + "2", "no newline", 2, true},
+		{"\r\n" + exitMarker + "127\r\n", "\r\n", 127, true},
+		{"cut off", "cut off", 0, false},
+	} {
+		out, code, ok := splitExit(tc.raw)
+		if out != tc.out || code != tc.code || ok != tc.ok {
+			t.Errorf("splitExit(%q) = %q, %d, %v", tc.raw, out, code, ok)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package adb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The hub's Wi-Fi is the slow part of a delta transfer, and much of an APK
+// squeezes: dex, resources.arsc and stored native libraries all shrink by half
+// or more. The entries the build already deflated do not, so each chunk is
+// compressed on its own and sent that way only when that saves something.
+//
+// The hub has to undo it with whatever its shell offers. gzip is the one
+// format every toybox and busybox build can read back, where zstd needs a tool
+// most hubs do not have, so it is gzip, and the hub is asked first rather than
+// assumed: a hub that cannot decompress gets the chunks as they are.
+
+const (
+	// packedSuffix marks a chunk sent compressed, until the hub unpacks it
+	// under its real name.
+	packedSuffix = ".gz"
+
+	remoteProbe = remoteRoot + "/probe" + packedSuffix
+)
+
+// packWorthwhile is the most a compressed chunk may weigh, as a share of the
+// raw one, and still be sent compressed. Below a tenth saved, the hub's time
+// decompressing outweighs the transfer saved.
+const packWorthwhile = 0.9
+
+// gunzipCommands are tried in order; the first that reads the probe back is
+// the one the hub uses.
+var gunzipCommands = []string{"gzip -dc", "zcat", "toybox gzip -dc", "busybox gzip -dc"}
+
+// pack compresses a chunk, and reports whether the result is worth sending
+// instead of the chunk.
+func pack(raw []byte) ([]byte, bool) {
+	var b bytes.Buffer
+	w, err := gzip.NewWriterLevel(&b, gzip.BestCompression)
+	if err != nil {
+		return nil, false
+	}
+	if _, err := w.Write(raw); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+
+	if float64(b.Len()) > float64(len(raw))*packWorthwhile {
+		return nil, false
+	}
+	return b.Bytes(), true
+}
+
+// hubGunzip finds how the hub decompresses gzip, empty when it cannot. It
+// sends a small compressed file and reads it back through each candidate, so
+// the answer is what the hub did rather than what its tools claim.
+func hubGunzip(serial string) string {
+	probe, ok := pack([]byte(strings.Repeat(okMarker, 16)))
+	if !ok {
+		return ""
+	}
+
+	s, err := openSync(serial)
+	if err != nil {
+		return ""
+	}
+	err = s.put(bytes.NewReader(probe), remoteProbe, 0o644, time.Now())
+	s.Close()
+	if err != nil {
+		return ""
+	}
+	defer func() { _, _ = run(serial, "shell", "rm -f "+remoteProbe) }()
+
+	for _, command := range gunzipCommands {
+		out, err := run(serial, "shell", fmt.Sprintf("%s %s 2>/dev/null", command, remoteProbe))
+		if err == nil && strings.Count(out, okMarker) == 16 {
+			return command
+		}
+	}
+	return ""
+}
+
+// unpackChunks decompresses every packed chunk in the cache under its real
+// name. Each is written beside itself and renamed, so a chunk name in the
+// cache is never a half-written file.
+func unpackChunks(serial, gunzip string) error {
+	script := fmt.Sprintf(`cd %s && for f in *.chunk%s; do [ -f "$f" ] || continue; `+
+		`c="${f%%%s}"; %s "$f" > "$c.part" && mv "$c.part" "$c" && rm -f "$f" || { rm -f "$c.part" "$f"; echo FAILED "$c"; }; done; echo %s`,
+		remoteCacheDir, packedSuffix, packedSuffix, gunzip, okMarker)
+
+	out, err := run(serial, "shell", script)
+	if err != nil {
+		return ErrDeltaUnavailable{"cannot decompress chunks on the hub: " + err.Error()}
+	}
+	if strings.Contains(out, "FAILED") || !strings.Contains(out, okMarker) {
+		return ErrDeltaUnavailable{"the hub could not decompress every chunk: " + strings.TrimSpace(out)}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package adb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPackRoundTrip(t *testing.T) {
+	raw := []byte(strings.Repeat("classes.dex ", 4096))
+
+	packed, ok := pack(raw)
+	if !ok {
+		t.Fatal("text that repeats should be worth compressing")
+	}
+	r, err := gzip.NewReader(bytes.NewReader(packed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, raw) {
+		t.Error("the chunk did not survive compression")
+	}
+}
+
+func TestAlreadyCompressedChunksGoAsTheyAre(t *testing.T) {
+	raw := make([]byte, 64<<10)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pack(raw); ok {
+		t.Error("random bytes cannot shrink, so they should be sent raw")
+	}
+}
+
+func TestCompressedChunksLandPacked(t *testing.T) {
+	f := startFake(t, "HUB123")
+
+	data, chunks := apkChunks(4, 4096)
+	progress := &uploadProgress{total: int64(len(data))}
+	wire, err := uploadChunks("HUB123", data, chunks, 2, progress, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range chunks {
+		if _, ok := f.files[remoteCacheDir+"/"+c.Filename()+packedSuffix]; !ok {
+			t.Errorf("%s did not arrive compressed", c.Filename())
+		}
+	}
+	if wire >= int64(len(data)) {
+		t.Errorf("%d bytes crossed the link for %d of chunks", wire, len(data))
+	}
+
+	// The bar counts the APK, not what crossed the link.
+	if got := progress.sent.Load(); got != int64(len(data)) {
+		t.Errorf("progress counted %d bytes sent, want %d", got, len(data))
+	}
+}
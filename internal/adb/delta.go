@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/andreibanu/pusher/internal/console"
 	"github.com/andreibanu/pusher/internal/delta"
 )
 
@@ -40,10 +41,13 @@ type DeltaResult struct {
 	SentBytes    int64
 	SkippedBytes int64
 
-	chunks []delta.Chunk
+	// WireBytes is what actually crossed the link, which is less than
+	// SentBytes when chunks went compressed.
+	WireBytes int64
+	Packed    bool
 }
 
-func deltaInstall(serial, apkPath string) (*DeltaResult, error) {
+func deltaInstall(serial, apkPath string, compress bool) (*DeltaResult, error) {
 	chunks, data, err := delta.SplitFile(apkPath)
 	if err != nil {
 		return nil, ErrDeltaUnavailable{err.Error()}
@@ -52,7 +56,7 @@ func deltaInstall(serial, apkPath string) (*DeltaResult, error) {
 		return nil, ErrDeltaUnavailable{"APK is empty"}
 	}
 
-	if err := ensureSpace(serial, int64(len(data))); err != nil {
+	if err := ensureSpace(serial, int64(len(data)), chunks); err != nil {
 		return nil, err
 	}
 
@@ -67,26 +71,33 @@ func deltaInstall(serial, apkPath string) (*DeltaResult, error) {
 		TotalChunks: len(chunks),
 		SentChunks:  len(missing),
 		SentBytes:   delta.TotalSize(missing),
-		chunks:      chunks,
 	}
 	result.SkippedBytes = int64(len(data)) - result.SentBytes
 
 	if len(present) == 0 {
-		fmt.Printf("[*] No cache on the hub yet - sending all %d chunks this time.\n", len(chunks))
-		fmt.Println("    Future pushes will only send what changed.")
+		console.Printf("[*] No cache on the hub yet - sending all %d chunks this time.\n", len(chunks))
+		console.Println("    Future pushes will only send what changed.")
 	} else {
-		fmt.Printf("[*] Hub already has %d of %d chunks (%.1f MB reused)\n",
+		console.Printf("[*] Hub already has %d of %d chunks (%.1f MB reused)\n",
 			len(chunks)-len(missing), len(chunks), mb(result.SkippedBytes))
 	}
 
+	// The manifest depends only on which chunks make the APK, so it goes while
+	// they do.
+	manifest := make(chan error, 1)
+	go func() { manifest <- pushManifest(serial, chunks) }()
+
 	if len(missing) > 0 {
-		fmt.Printf("[*] Sending %d chunks (%.1f MB)...\n", len(missing), mb(result.SentBytes))
-		if err := pushChunks(serial, data, missing); err != nil {
+		console.Printf("[*] Sending %d chunks (%.1f MB)...\n", len(missing), mb(result.SentBytes))
+		wire, packed, err := pushChunks(serial, data, missing, result.SkippedBytes, compress)
+		if err != nil {
+			<-manifest
 			return nil, err
 		}
+		result.WireBytes, result.Packed = wire, packed
 	}
 
-	if err := pushManifest(serial, chunks); err != nil {
+	if err := <-manifest; err != nil {
 		return nil, err
 	}
 
@@ -98,6 +109,7 @@ func deltaInstall(serial, apkPath string) (*DeltaResult, error) {
 		return nil, err
 	}
 
+	recordPush(serial, result)
 	return result, nil
 }
 
@@ -119,7 +131,62 @@ func listCachedChunks(serial string) map[string]bool {
 	return present
 }
 
-func pushChunks(serial string, data []byte, missing []delta.Chunk) error {
+// pushChunks gets the missing chunks into the hub's cache: over several sync
+// streams at once when the adb server can be reached, and as one directory
+// through the executable when it cannot. It returns the bytes that crossed the
+// link and whether any chunk went compressed.
+//
+// Compression needs the sync streams, since the executable pushes a directory
+// as it is, and a hub that can decompress; without either the chunks go raw.
+func pushChunks(serial string, data []byte, missing []delta.Chunk, reused int64, compress bool) (int64, bool, error) {
+	logical := delta.TotalSize(missing)
+	wire := logical
+
+	err := errNoServer
+	gunzip := ""
+	if native() {
+		if compress {
+			if gunzip = hubGunzip(serial); gunzip == "" {
+				console.Println("[*] The hub cannot decompress gzip - sending chunks as they are.")
+			}
+		}
+
+		progress := newUploadProgress(int64(len(data)), reused)
+		progress.start()
+		wire, err = uploadChunks(serial, data, missing, uploadStreams(len(missing)), progress, gunzip != "")
+		progress.finish()
+		if err != nil && !fallback(err) {
+			return 0, false, ErrDeltaUnavailable{"chunk push failed: " + err.Error()}
+		}
+	}
+	if fallback(err) {
+		gunzip, wire = "", logical
+		if err := pushChunkDir(serial, data, missing); err != nil {
+			return 0, false, err
+		}
+	}
+
+	packed := gunzip != "" && wire < logical
+	if packed {
+		if err := unpackChunks(serial, gunzip); err != nil {
+			return 0, false, err
+		}
+	}
+
+	cached := listCachedChunks(serial)
+	for _, c := range missing {
+		if !cached[c.Hash] {
+			return 0, false, ErrDeltaUnavailable{"chunks did not reach the cache on the hub"}
+		}
+	}
+
+	return wire, packed, nil
+}
+
+// pushChunkDir is pushChunks through the executable, which cannot write from
+// memory: the chunks are written out, pushed as a directory into staging, and
+// moved into the cache once they are all there.
+func pushChunkDir(serial string, data []byte, missing []delta.Chunk) error {
 	stagingDir, err := os.MkdirTemp("", "pusher-chunks-")
 	if err != nil {
 		return ErrDeltaUnavailable{"cannot create staging directory: " + err.Error()}
@@ -143,7 +210,7 @@ func pushChunks(serial string, data []byte, missing []delta.Chunk) error {
 	}
 
 	cmd := exec.Command("adb", pushArgs...)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = console.Out
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return ErrDeltaUnavailable{"chunk push failed: " + err.Error()}
@@ -155,13 +222,6 @@ func pushChunks(serial string, data []byte, missing []delta.Chunk) error {
 		return ErrDeltaUnavailable{"cannot move chunks into cache: " + err.Error()}
 	}
 
-	cached := listCachedChunks(serial)
-	for _, c := range missing {
-		if !cached[c.Hash] {
-			return ErrDeltaUnavailable{"chunks did not reach the cache on the hub"}
-		}
-	}
-
 	return nil
 }
 
@@ -202,6 +262,22 @@ func reassemble(serial string) error {
 }
 
 func verifyRemote(serial string, data []byte) error {
+	match, detail, err := remoteChecksum(serial, remoteDeltaAPK, data)
+	if err != nil {
+		return ErrDeltaUnavailable{"could not checksum the rebuilt APK on the device"}
+	}
+	if !match {
+		// Some chunk is not what its name says, and which one cannot be told
+		// from here. Keeping them would fail every delta from now on.
+		_, _ = run(serial, "shell", "rm -rf "+remoteCacheDir)
+		return ErrDeltaUnavailable{"rebuilt APK does not match (" + detail + ")"}
+	}
+	return nil
+}
+
+// remoteChecksum compares a file on the hub with data, using whichever
+// checksum tool the hub has. When they differ, detail says by which tool.
+func remoteChecksum(serial, path string, data []byte) (match bool, detail string, err error) {
 	sha := sha1.Sum(data)
 	md5sum := md5.Sum(data)
 
@@ -214,7 +290,7 @@ func verifyRemote(serial string, data []byte) error {
 	}
 
 	for _, candidate := range candidates {
-		out, err := run(serial, "shell", candidate.command+" "+remoteDeltaAPK)
+		out, err := run(serial, "shell", candidate.command+" "+path)
 		if err != nil {
 			continue
 		}
@@ -230,55 +306,39 @@ func verifyRemote(serial string, data []byte) error {
 		}
 
 		if got != candidate.want {
-			return ErrDeltaUnavailable{fmt.Sprintf(
-				"rebuilt APK does not match (%s %s != %s)", candidate.command, got, candidate.want)}
+			return false, fmt.Sprintf("%s %s != %s", candidate.command, got, candidate.want), nil
 		}
-
-		return nil
+		return true, "", nil
 	}
 
-	return ErrDeltaUnavailable{"could not checksum the rebuilt APK on the device"}
+	return false, "", fmt.Errorf("no checksum tool on the hub could read %s", path)
 }
 
-func pruneCache(serial string, chunks []delta.Chunk) {
-	stale := delta.Unreferenced(chunks, listCachedChunks(serial))
-	if len(stale) == 0 {
-		return
-	}
-
-	const batch = 200
-	for start := 0; start < len(stale); start += batch {
-		end := start + batch
-		if end > len(stale) {
-			end = len(stale)
-		}
-
-		var b strings.Builder
-		b.WriteString("cd " + remoteCacheDir + " && rm -f")
-		for _, hash := range stale[start:end] {
-			b.WriteString(" " + hash + ".chunk")
-		}
-
-		if _, err := run(serial, "shell", b.String()); err != nil {
-			return
-		}
-	}
-}
-
-func ensureSpace(serial string, apkSize int64) error {
+// ensureSpace checks the hub has room for the APK in all its forms: the chunks,
+// the rebuilt APK and the package manager's copy. Short of it, the cache gives
+// up what the APK does not need, the least recently used first.
+func ensureSpace(serial string, apkSize int64, chunks []delta.Chunk) error {
 	free, ok := freeBytes(serial, "/data")
 	if !ok {
 
 		return nil
 	}
 
-	if need := apkSize * spaceFactor; free < need {
-		return ErrDeltaUnavailable{fmt.Sprintf(
-			"not enough free space on the hub (%.0f MB free, needs about %.0f MB)",
-			mb(free), mb(need))}
+	need := apkSize * spaceFactor
+	if free >= need {
+		return nil
 	}
 
-	return nil
+	if freed, err := makeRoom(serial, need-free, chunks); err == nil && freed > 0 {
+		console.Printf("[*] Hub was short of space: removed %.1f MB of old chunks\n", mb(freed))
+		if free, ok = freeBytes(serial, "/data"); !ok || free >= need {
+			return nil
+		}
+	}
+
+	return ErrDeltaUnavailable{fmt.Sprintf(
+		"not enough free space on the hub (%.0f MB free, needs about %.0f MB)",
+		mb(free), mb(need))}
 }
 
 func freeBytes(serial, path string) (int64, bool) {
@@ -309,28 +369,31 @@ func mb(bytes int64) float64 {
 	return float64(bytes) / (1024 * 1024)
 }
 
-func installDelta(serial, apkPath string) error {
+func installDelta(serial, apkPath string, compress bool) error {
 	start := time.Now()
 
-	result, err := deltaInstall(serial, apkPath)
+	result, err := deltaInstall(serial, apkPath, compress)
 	if err != nil {
 		return err
 	}
 
 	elapsed := time.Since(start).Seconds()
 	if elapsed > 0 && result.SentBytes > 0 {
-		fmt.Printf("[OK] Transferred %.1f MB in %.1fs (%.1f MB/s), reused %.1f MB\n",
+		console.Printf("[OK] Transferred %.1f MB in %.1fs (%.1f MB/s), reused %.1f MB\n",
 			mb(result.SentBytes), elapsed, mb(result.SentBytes)/elapsed, mb(result.SkippedBytes))
+		if result.Packed {
+			console.Printf("     %.1f MB of it crossed the link compressed\n", mb(result.WireBytes))
+		}
 	} else {
-		fmt.Printf("[OK] Nothing to transfer - the hub already had every chunk (%.1fs)\n", elapsed)
+		console.Printf("[OK] Nothing to transfer - the hub already had every chunk (%.1fs)\n", elapsed)
 	}
 
-	fmt.Println("[*] Installing...")
+	console.Println("[*] Installing...")
 	if err := runInstall(serial, remoteDeltaAPK); err != nil {
 		return err
 	}
 
-	pruneCache(serial, result.chunks)
+	collectGarbage(serial)
 
 	return nil
 }
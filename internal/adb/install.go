@@ -28,9 +28,14 @@ type InstallPlan struct {
 	Skipped   bool
 	Streamed  bool
 	Delta     bool
+	Patched   bool
 	Splits    int
 	Reason    string
 	BytesSent int64
+
+	// Fingerprint is the APK the robot now holds, empty when the install did
+	// not leave one APK there that pusher could record.
+	Fingerprint string
 }
 
 // APKFingerprint identifies an APK by content.
@@ -79,6 +84,29 @@ func packageStamp(serial, pkg string) string {
 	return strings.TrimSpace(strings.ReplaceAll(out, " ", ""))
 }
 
+// AppUpdated is when the package manager last installed pkg, as the robot
+// reports it, empty when it cannot tell. Every install moves it, pusher's or
+// anything else's.
+func AppUpdated(serial, pkg string) string { return packageStamp(serial, pkg) }
+
+// ControllerPackage finds the installed robot controller, empty when there is
+// none.
+func ControllerPackage(serial string) string {
+	out, err := Shell(serial, "pm", "list", "packages", "2>/dev/null")
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(strings.TrimRight(line, "\r"))
+		name := strings.TrimPrefix(line, "package:")
+		if strings.Contains(name, "ftcrobotcontroller") {
+			return name
+		}
+	}
+	return ""
+}
+
 func recordInstalled(serial, fingerprint, pkg string) {
 	_, _ = Shell(serial, "mkdir", "-p", remoteRoot)
 	_, _ = Shell(serial, "sh", "-c",
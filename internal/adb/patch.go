@@ -0,0 +1,199 @@
+package adb
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/deployed"
+	"github.com/andreibanu/pusher/internal/patch"
+)
+
+// The robot already holds the APK pusher last installed, and the installed
+// marker says which one by its fingerprint. The deploy history on this
+// computer keeps a copy of it, so the new APK can go as a patch against it:
+// the few kilobytes a one-line change moves rather than the chunk or two
+// around them.
+//
+// Both ends have to agree on the old APK before that is safe, so the copy kept
+// here must still be the recorded fingerprint, and the package manager's copy
+// on the hub must checksum the same as it. Anything else, and the deploy goes
+// by chunks as it would have without this.
+
+const (
+	remotePatch       = remoteRoot + "/patch.bin"
+	remotePatchScript = remoteRoot + "/patch.sh"
+
+	// patchWorthwhile is the largest share of the APK a patch may carry as new
+	// bytes. Past it the patch saves little over chunking, which at least
+	// leaves the chunks cached for next time.
+	patchWorthwhile = 0.5
+
+	// maxPatchOps bounds the dd commands the hub runs to apply a patch, which
+	// each cost it a process.
+	maxPatchOps = 4000
+)
+
+// PatchResult is what a patch carried.
+type PatchResult struct {
+	Copied    int64
+	Literal   int64
+	WireBytes int64
+}
+
+// patchInstall rebuilds the APK on the hub at remoteDeltaAPK from the
+// installed one and a patch. It fails with ErrDeltaUnavailable whenever the
+// patch cannot be trusted or is not worth it.
+func patchInstall(serial, apkPath, pkg string, compress bool) (*PatchResult, error) {
+	installed := InstalledFingerprint(serial)
+	if installed == "" {
+		return nil, ErrDeltaUnavailable{"the robot has no install from pusher recorded to patch against"}
+	}
+
+	basePath, ok := deployed.APK(config.Dir(), installed)
+	if !ok {
+		return nil, ErrDeltaUnavailable{"this computer no longer has the APK the robot was last given"}
+	}
+	if sum, err := APKFingerprint(basePath); err != nil || sum != installed {
+		return nil, ErrDeltaUnavailable{"the kept copy of the installed APK has changed"}
+	}
+
+	base, err := os.ReadFile(basePath)
+	if err != nil {
+		return nil, ErrDeltaUnavailable{"cannot read the kept APK: " + err.Error()}
+	}
+	target, err := os.ReadFile(apkPath)
+	if err != nil {
+		return nil, ErrDeltaUnavailable{"cannot read the APK: " + err.Error()}
+	}
+
+	hubBase := installedPath(serial, pkg)
+	if hubBase == "" {
+		return nil, ErrDeltaUnavailable{"cannot find the installed APK on the robot"}
+	}
+	if match, _, err := remoteChecksum(serial, hubBase, base); err != nil || !match {
+		return nil, ErrDeltaUnavailable{"the robot's APK is not the one pusher recorded installing"}
+	}
+
+	p := patch.Diff(base, target)
+	if len(p.Ops) > maxPatchOps || float64(len(p.Literal)) > float64(len(target))*patchWorthwhile {
+		return nil, ErrDeltaUnavailable{fmt.Sprintf(
+			"a patch would carry %.1f MB of the %.1f MB APK, no better than chunks",
+			mb(int64(len(p.Literal))), mb(int64(len(target))))}
+	}
+
+	result := &PatchResult{Copied: p.Copied(), Literal: int64(len(p.Literal))}
+	console.Printf("[*] Patching the installed APK: %.1f MB new, %.1f MB reused\n",
+		mb(result.Literal), mb(result.Copied))
+
+	defer func() {
+		_, _ = run(serial, "shell", fmt.Sprintf("rm -f %s %s %s%s",
+			remotePatch, remotePatchScript, remotePatch, packedSuffix))
+	}()
+
+	body, unpack := p.Literal, ""
+	if compress && native() {
+		if gunzip := hubGunzip(serial); gunzip != "" {
+			if packed, ok := pack(body); ok {
+				body = packed
+				unpack = fmt.Sprintf("%s %s%s > %s || exit 1\n", gunzip, remotePatch, packedSuffix, remotePatch)
+			}
+		}
+	}
+	literalPath := remotePatch
+	if unpack != "" {
+		literalPath += packedSuffix
+	}
+	if err := pushBytes(serial, body, literalPath); err != nil {
+		return nil, ErrDeltaUnavailable{"cannot send the patch: " + err.Error()}
+	}
+	result.WireBytes = int64(len(body))
+
+	script := unpack + applyScript(p, hubBase, remotePatch, remoteDeltaAPK)
+	if err := pushBytes(serial, []byte(script), remotePatchScript); err != nil {
+		return nil, ErrDeltaUnavailable{"cannot send the patch: " + err.Error()}
+	}
+
+	out, err := run(serial, "shell", "sh "+remotePatchScript)
+	if err != nil || !strings.Contains(out, okMarker) {
+		return nil, ErrDeltaUnavailable{"the hub could not apply the patch: " + strings.TrimSpace(out)}
+	}
+
+	if match, _, err := remoteChecksum(serial, remoteDeltaAPK, target); err != nil || !match {
+		return nil, ErrDeltaUnavailable{"the patched APK does not match the one built"}
+	}
+
+	return result, nil
+}
+
+// applyScript is the shell that rebuilds out from base and the literal file:
+// one dd per copy, and for a literal one dd of its whole blocks and one of the
+// bytes left over, a byte at a time.
+func applyScript(p patch.Patch, base, literal, out string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "b='%s'\nl='%s'\n{\n", base, literal)
+
+	for _, op := range p.Ops {
+		if op.FromBase {
+			fmt.Fprintf(&b, "dd if=\"$b\" bs=%d skip=%d count=%d\n",
+				patch.Block, op.Offset/patch.Block, op.Size/patch.Block)
+			continue
+		}
+
+		whole, rest := op.Size/patch.Block, op.Size%patch.Block
+		if whole > 0 {
+			fmt.Fprintf(&b, "dd if=\"$l\" bs=%d skip=%d count=%d\n",
+				patch.Block, op.Offset/patch.Block, whole)
+		}
+		if rest > 0 {
+			fmt.Fprintf(&b, "dd if=\"$l\" bs=1 skip=%d count=%d\n",
+				op.Offset+whole*patch.Block, rest)
+		}
+	}
+
+	fmt.Fprintf(&b, "} 2>/dev/null > '%s' && echo %s\n", out, okMarker)
+	return b.String()
+}
+
+// installedPath is where the package manager keeps the installed base APK.
+func installedPath(serial, pkg string) string {
+	out, err := run(serial, "shell", "pm path "+pkg)
+	if err != nil {
+		return ""
+	}
+
+	first := ""
+	for _, line := range strings.Split(out, "\n") {
+		path, ok := strings.CutPrefix(strings.TrimSpace(line), "package:")
+		if !ok {
+			continue
+		}
+		if strings.HasSuffix(path, "/base.apk") {
+			return path
+		}
+		if first == "" {
+			first = path
+		}
+	}
+	return first
+}
+
+// pushBytes writes data to a file on the hub.
+func pushBytes(serial string, data []byte, remote string) error {
+	file, err := os.CreateTemp("", "pusher-patch-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	file.Close()
+
+	_, err = run(serial, "push", file.Name(), remote)
+	return err
+}
@@ -0,0 +1,70 @@
+package adb
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andreibanu/pusher/internal/patch"
+)
+
+// The hub applies a patch with its own sh and dd, and so does this test, which
+// is as close to the hub as a test machine gets.
+func TestTheApplyScriptRebuildsTheAPK(t *testing.T) {
+	if _, err := exec.LookPath("dd"); err != nil {
+		t.Skip("no dd to run the script with")
+	}
+
+	base := make([]byte, 300<<10)
+	rand.New(rand.NewSource(1)).Read(base)
+	target := append([]byte(nil), base[:100<<10]...)
+	target = append(target, []byte("a changed line of team code")...)
+	target = append(target, base[100<<10+5:]...)
+	target = append(target, 1, 2, 3)
+
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "base.apk")
+	literalPath := filepath.Join(dir, "patch.bin")
+	outPath := filepath.Join(dir, "app.apk")
+
+	p := patch.Diff(base, target)
+	if err := os.WriteFile(basePath, base, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(literalPath, p.Literal, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command("sh", "-c", applyScript(p, basePath, literalPath, outPath)).CombinedOutput()
+	if err != nil || !strings.Contains(string(out), okMarker) {
+		t.Fatalf("the script failed: %v\n%s", err, out)
+	}
+
+	got, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Errorf("the script rebuilt %d bytes that differ from the %d built", len(got), len(target))
+	}
+}
+
+func TestTheInstalledBaseAPKIsFound(t *testing.T) {
+	f := startFake(t, "HUB123")
+	f.shell = func(command string) (string, int) {
+		if command != "pm path org.firstinspires.ftc.robotcontroller" {
+			return "", 1
+		}
+		return "package:/data/app/org.firstinspires.ftc.robotcontroller-2/split_config.arm64_v8a.apk\n" +
+			"package:/data/app/org.firstinspires.ftc.robotcontroller-2/base.apk\n", 0
+	}
+
+	got := installedPath("HUB123", "org.firstinspires.ftc.robotcontroller")
+	if want := "/data/app/org.firstinspires.ftc.robotcontroller-2/base.apk"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
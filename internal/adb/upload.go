@@ -0,0 +1,249 @@
+package adb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
+	"github.com/andreibanu/pusher/internal/delta"
+	"golang.org/x/term"
+)
+
+// A cold cache over the hub's hotspot is the slowest deploy there is, and one
+// sync stream spends much of it waiting: each chunk ends with the device
+// acknowledging it before the next can start, and a single TCP stream over a
+// lossy 2.4 GHz link never opens its window far. Several streams at once keep
+// the link busy through each other's pauses.
+//
+// Chunks are written straight into the cache under their final names rather
+// than staged and moved afterwards, so whatever arrived before a push was
+// interrupted is still there next time and is not sent again. adbd removes a
+// file whose transfer did not finish, so a name in the cache is a whole chunk;
+// the rebuilt APK is checksummed anyway, and a mismatch clears the cache.
+
+const (
+	// chunkAttempts is how many times one chunk is tried before the upload
+	// gives up on it.
+	chunkAttempts = 3
+
+	retryPause = 500 * time.Millisecond
+)
+
+// uploadStreams is how many chunks go at once: the thread count from settings,
+// and never more streams than chunks.
+func uploadStreams(chunks int) int {
+	streams := config.GetThreads()
+	if streams > chunks {
+		streams = chunks
+	}
+	if streams < 1 {
+		streams = 1
+	}
+	return streams
+}
+
+// uploadChunks sends the missing chunks into the cache over several sync
+// streams, and returns how many bytes went over the wire. Each chunk is retried
+// on its own, on a fresh connection, so one bad moment on the link costs one
+// chunk rather than the whole upload.
+//
+// With compress, a chunk that shrinks is sent compressed under a name that
+// says so, for unpackChunks to put right on the hub.
+func uploadChunks(serial string, data []byte, missing []delta.Chunk, streams int, progress *uploadProgress, compress bool) (int64, error) {
+	jobs := make(chan delta.Chunk)
+	errs := make(chan error, len(missing))
+	var wire atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var s *syncConn
+			defer func() {
+				if s != nil {
+					s.Close()
+				}
+			}()
+
+			for c := range jobs {
+				raw := data[c.Offset : c.Offset+c.Size]
+				body, path := raw, remoteCacheDir+"/"+c.Filename()
+				if compress {
+					if packed, ok := pack(raw); ok {
+						body, path = packed, path+packedSuffix
+					}
+				}
+
+				var err error
+				for attempt := 1; attempt <= chunkAttempts; attempt++ {
+					if attempt > 1 {
+						time.Sleep(retryPause * time.Duration(attempt-1))
+					}
+					if s == nil {
+						if s, err = openSync(serial); err != nil {
+							if fallback(err) {
+								break
+							}
+							continue
+						}
+					}
+
+					r := progress.reader(body, c.Size)
+					if err = s.put(r, path, 0o644, time.Now()); err == nil {
+						wire.Add(int64(len(body)))
+						break
+					}
+
+					// Whatever was under way on this connection is lost with it.
+					progress.undo(r)
+					s.Close()
+					s = nil
+				}
+				if err != nil {
+					errs <- fmt.Errorf("%s: %w", c.Filename(), err)
+				}
+			}
+		}()
+	}
+
+	for _, c := range missing {
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	var failed []error
+	for err := range errs {
+		failed = append(failed, err)
+	}
+	switch len(failed) {
+	case 0:
+		return wire.Load(), nil
+	case 1:
+		return wire.Load(), failed[0]
+	}
+	return wire.Load(), fmt.Errorf("%d chunks failed, the first: %w", len(failed), failed[0])
+}
+
+// uploadProgress draws bytes sent, reused and still to go on one line that
+// redraws itself, when there is a terminal to draw it on.
+type uploadProgress struct {
+	total  int64
+	reused int64
+	sent   atomic.Int64
+
+	out  io.Writer
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+func newUploadProgress(total, reused int64) *uploadProgress {
+	p := &uploadProgress{total: total, reused: reused}
+	if f, ok := console.Out.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		p.out = f
+	}
+	return p
+}
+
+// start redraws the line until finish is called.
+func (p *uploadProgress) start() {
+	if p.out == nil {
+		return
+	}
+
+	p.stop = make(chan struct{})
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			p.draw()
+			select {
+			case <-p.stop:
+				p.draw()
+				fmt.Fprintln(p.out)
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *uploadProgress) finish() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	p.done.Wait()
+}
+
+func (p *uploadProgress) draw() {
+	fmt.Fprintf(p.out, "\r    %s", p.line())
+}
+
+// line is the progress as text.
+func (p *uploadProgress) line() string {
+	const width = 24
+
+	sent := p.sent.Load()
+	done := sent + p.reused
+	filled := 0
+	if p.total > 0 {
+		filled = int(done * width / p.total)
+	}
+	if filled > width {
+		filled = width
+	}
+
+	return fmt.Sprintf("[%s%s] %.1f MB sent, %.1f MB reused, %.1f MB to go ",
+		strings.Repeat("#", filled), strings.Repeat("-", width-filled),
+		mb(sent), mb(p.reused), mb(p.total-done))
+}
+
+// countingReader counts what has gone through it into the progress, in the
+// chunk's own bytes rather than the compressed ones, so the bar measures the
+// APK whichever way each chunk travels.
+type countingReader struct {
+	r       io.Reader
+	p       *uploadProgress
+	size    int64
+	logical int64
+	wire    int64
+	read    int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	if n > 0 && c.size > 0 {
+		c.wire += int64(n)
+		now := c.wire * c.logical / c.size
+		if c.p != nil {
+			c.p.sent.Add(now - c.read)
+		}
+		c.read = now
+	}
+	return n, err
+}
+
+func (p *uploadProgress) reader(b []byte, logical int64) *countingReader {
+	return &countingReader{r: bytes.NewReader(b), p: p, size: int64(len(b)), logical: logical}
+}
+
+// undo takes back what a failed attempt counted, so a retry is not counted
+// twice.
+func (p *uploadProgress) undo(r *countingReader) {
+	if p != nil {
+		p.sent.Add(-r.read)
+	}
+}
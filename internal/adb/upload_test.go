@@ -0,0 +1,135 @@
+package adb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/delta"
+)
+
+// apkChunks cuts n chunks of size bytes out of made-up data.
+func apkChunks(n, size int) ([]byte, []delta.Chunk) {
+	var data []byte
+	var chunks []delta.Chunk
+	for i := 0; i < n; i++ {
+		piece := bytes.Repeat([]byte{byte('a' + i%26)}, size)
+		chunks = append(chunks, delta.Chunk{
+			Hash:   fmt.Sprintf("%02d%s", i, strings.Repeat("f", 6)),
+			Offset: int64(len(data)),
+			Size:   int64(size),
+		})
+		data = append(data, piece...)
+	}
+	return data, chunks
+}
+
+// listsCache makes the fake's shell answer ls on the cache directory, which is
+// how the delta transfer learns what the hub already has.
+func listsCache(f *fakeServer) {
+	f.shell = func(command string) (string, int) {
+		if !strings.HasPrefix(command, "ls -1 "+remoteCacheDir) {
+			return "", 0
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var names []string
+		for path := range f.files {
+			if name, ok := strings.CutPrefix(path, remoteCacheDir+"/"); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return strings.Join(names, "\n") + "\n", 0
+	}
+}
+
+func TestChunksGoOverSeveralStreams(t *testing.T) {
+	f := startFake(t, "HUB123")
+	f.slow = 20 * time.Millisecond
+
+	data, chunks := apkChunks(12, 1000)
+	progress := &uploadProgress{total: int64(len(data))}
+	if _, err := uploadChunks("HUB123", data, chunks, 4, progress, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range chunks {
+		got := f.files[remoteCacheDir+"/"+c.Filename()]
+		if want := data[c.Offset : c.Offset+c.Size]; !bytes.Equal(got, want) {
+			t.Errorf("%s holds %d bytes, want %d", c.Filename(), len(got), len(want))
+		}
+	}
+	if f.syncs < 2 {
+		t.Errorf("every chunk went over %d stream", f.syncs)
+	}
+	if f.syncs > 4 {
+		t.Errorf("opened %d streams with a limit of 4", f.syncs)
+	}
+	if got := progress.sent.Load(); got != int64(len(data)) {
+		t.Errorf("progress counted %d bytes sent, want %d", got, len(data))
+	}
+}
+
+func TestAChunkIsRetriedOnItsOwn(t *testing.T) {
+	f := startFake(t, "HUB123")
+
+	data, chunks := apkChunks(3, 500)
+	flaky := remoteCacheDir + "/" + chunks[1].Filename()
+	refused := 0
+	f.refuse = func(path string) bool {
+		if path == flaky && refused == 0 {
+			refused++
+			return true
+		}
+		return false
+	}
+
+	progress := &uploadProgress{total: int64(len(data))}
+	if _, err := uploadChunks("HUB123", data, chunks, 1, progress, false); err != nil {
+		t.Fatalf("one refusal should have been retried: %v", err)
+	}
+	if _, ok := f.files[flaky]; !ok {
+		t.Error("the retried chunk never arrived")
+	}
+
+	// The failed attempt is taken back, or the bar would pass 100%.
+	if got := progress.sent.Load(); got != int64(len(data)) {
+		t.Errorf("progress counted %d bytes sent, want %d", got, len(data))
+	}
+}
+
+func TestAnInterruptedUploadResumes(t *testing.T) {
+	f := startFake(t, "HUB123")
+	listsCache(f)
+
+	data, chunks := apkChunks(6, 500)
+	broken := remoteCacheDir + "/" + chunks[4].Filename()
+	f.refuse = func(path string) bool { return path == broken }
+
+	_, err := uploadChunks("HUB123", data, chunks, 3, nil, false)
+	if err == nil || !strings.Contains(err.Error(), chunks[4].Filename()) {
+		t.Fatalf("a chunk the hub kept refusing should fail the upload, got %v", err)
+	}
+
+	// Next time only what did not arrive is missing.
+	missing := delta.Missing(chunks, listCachedChunks("HUB123"))
+	if len(missing) != 1 || missing[0].Hash != chunks[4].Hash {
+		t.Errorf("after an interruption %d chunks were still missing, want only the one that failed", len(missing))
+	}
+}
+
+func TestProgressLine(t *testing.T) {
+	p := &uploadProgress{total: 4 << 20, reused: 1 << 20}
+	p.sent.Store(1 << 20)
+
+	line := p.line()
+	for _, want := range []string{"[############------------]", "1.0 MB sent", "1.0 MB reused", "2.0 MB to go"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("%q is missing %q", line, want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package adb
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/config"
+	"github.com/andreibanu/pusher/internal/console"
+)
+
+// A Control Hub takes adb connections at a fixed address on its own network.
+// A phone robot controller, and anything on Android 11 or later with wireless
+// debugging on, takes them on whatever network it has joined, at a port that
+// changes every time wireless debugging restarts, and only from a computer it
+// has been paired with by a code shown on its screen.
+//
+// The adb server already browses mDNS for such devices and knows how to pair,
+// so both go through it: pusher asks for the services it has seen, and for the
+// pairing, rather than running a resolver of its own alongside it.
+
+// The mDNS service types Android advertises for wireless debugging.
+const (
+	PairingService = "_adb-tls-pairing._tcp"
+	ConnectService = "_adb-tls-connect._tcp"
+)
+
+// Service is one wireless debugging endpoint the adb server has found.
+type Service struct {
+	// Name is the instance name, adb-<serial>-<suffix>. It stays the same
+	// across restarts of wireless debugging, which the port does not.
+	Name string
+	Type string
+	Addr string
+}
+
+// Host is the service's IP address without the port.
+func (s Service) Host() string {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return s.Addr
+	}
+	return host
+}
+
+// Services lists what the adb server has discovered over mDNS.
+func Services() ([]Service, error) {
+	if native() {
+		out, err := query("host:mdns:services")
+		if err == nil {
+			return parseServices(out), nil
+		}
+		if !fallback(err) {
+			return nil, fmt.Errorf("cannot list wireless debugging services: %w", err)
+		}
+	}
+
+	out, err := exec.Command("adb", "mdns", "services").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("adb mdns services failed: %w (output: %s)", err, strings.TrimSpace(string(out)))
+	}
+	return parseServices(string(out)), nil
+}
+
+// parseServices reads the adb server's list: a name, a type and an address to
+// a line, separated by tabs. The executable puts a heading above them, which
+// has no address and is skipped with anything else that does not parse.
+func parseServices(out string) []Service {
+	var services []Service
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(fields[2]); err != nil {
+			continue
+		}
+		services = append(services, Service{
+			Name: fields[0],
+			Type: strings.TrimSuffix(strings.TrimSuffix(fields[1], "."), ".local"),
+			Addr: fields[2],
+		})
+	}
+	return services
+}
+
+// Discover waits up to wait for services of one type, returning as soon as
+// any appear. The server only hears of a device when it next announces itself,
+// which is every few seconds, so an empty list straight away means little.
+func Discover(kind string, wait time.Duration) ([]Service, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		all, err := Services()
+		if err != nil {
+			return nil, err
+		}
+
+		var found []Service
+		for _, s := range all {
+			if s.Type == kind {
+				found = append(found, s)
+			}
+		}
+		if len(found) > 0 || time.Now().After(deadline) {
+			return found, nil
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// Pair exchanges a pairing code with the device at addr, the address its
+// pairing dialog shows, which is not the one it takes connections at.
+func Pair(addr, code string) error {
+	if native() {
+		out, err := query(fmt.Sprintf("host:pair:%s:%s", code, addr))
+		if !fallback(err) {
+			if err != nil {
+				return fmt.Errorf("pairing with %s failed: %w", addr, err)
+			}
+			return paired(addr, out)
+		}
+	}
+
+	out, err := exec.Command("adb", "pair", addr, code).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("adb pair failed: %w (output: %s)", err, strings.TrimSpace(string(out)))
+	}
+	return paired(addr, string(out))
+}
+
+// paired reads the outcome of a pairing, which the server reports as text
+// either way rather than as a failure.
+func paired(addr, out string) error {
+	if !strings.Contains(strings.ToLower(out), "successfully paired") {
+		return fmt.Errorf("pairing with %s failed: %s", addr, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// Resolve finds where the named device now takes connections.
+func Resolve(name string, wait time.Duration) (string, bool) {
+	deadline := time.Now().Add(wait)
+	for {
+		if services, err := Services(); err == nil {
+			for _, s := range services {
+				if s.Type == ConnectService && s.Name == name {
+					return s.Addr, true
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			return "", false
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// Locate is where the default profile's robot takes adb connections now. A
+// paired robot is looked up by name, in case wireless debugging has restarted
+// on another port since it was last reached, and where it is now is saved.
+// Without a name, or without an answer, the saved address is all there is.
+func Locate() string {
+	profile, err := config.GetDefaultProfile()
+	if err != nil || !profile.Paired() {
+		return RobotAddr()
+	}
+	if profile.Service == "" {
+		return profile.Addr
+	}
+
+	addr, ok := Resolve(profile.Service, 5*time.Second)
+	if !ok || addr == profile.Addr {
+		return profile.Addr
+	}
+
+	console.Printf("[*] %s moved to %s\n", profile.Service, addr)
+	if err := config.SetPaired(profile.Name, profile.Service, addr); err != nil {
+		console.Printf("[!] Could not save the new address: %v\n", err)
+	}
+	return addr
+}
@@ -0,0 +1,88 @@
+package adb
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServicesAreReadFromEitherListing(t *testing.T) {
+	// The executable's output, heading and all, and the server's are the same
+	// lines.
+	out := "List of discovered mdns services\n" +
+		"adb-R58M-x1y2z3\t_adb-tls-connect._tcp.\t192.168.1.23:41235\n" +
+		"adb-R58M-x1y2z3\t_adb-tls-pairing._tcp.\t192.168.1.23:37099\n" +
+		"half a line\n"
+
+	got := parseServices(out)
+	want := []Service{
+		{Name: "adb-R58M-x1y2z3", Type: ConnectService, Addr: "192.168.1.23:41235"},
+		{Name: "adb-R58M-x1y2z3", Type: PairingService, Addr: "192.168.1.23:37099"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("service %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if host := got[0].Host(); host != "192.168.1.23" {
+		t.Errorf("Host() = %q", host)
+	}
+}
+
+func TestDiscoverWaitsForTheRobotToAnnounceItself(t *testing.T) {
+	f := startFake(t, "hub")
+
+	var asked atomic.Int32
+	f.mdns = func() string {
+		if asked.Add(1) < 3 {
+			return ""
+		}
+		return "adb-R58M-x1y2z3\t_adb-tls-pairing._tcp.\t192.168.1.23:37099\n"
+	}
+
+	found, err := Discover(PairingService, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Addr != "192.168.1.23:37099" {
+		t.Errorf("found %+v", found)
+	}
+
+	found, err = Discover(ConnectService, 0)
+	if err != nil || len(found) != 0 {
+		t.Errorf("a pairing offer was taken for a connection: %+v, %v", found, err)
+	}
+}
+
+func TestPairingNeedsTheRightCode(t *testing.T) {
+	f := startFake(t, "hub")
+	f.pairCode = "482913"
+
+	if err := Pair("192.168.1.23:37099", "482913"); err != nil {
+		t.Errorf("the right code failed: %v", err)
+	}
+
+	err := Pair("192.168.1.23:37099", "111111")
+	if err == nil || !strings.Contains(err.Error(), "Wrong password") {
+		t.Errorf("the wrong code gave %v", err)
+	}
+}
+
+func TestAPairedRobotIsFoundByName(t *testing.T) {
+	f := startFake(t, "hub")
+	f.mdns = func() string {
+		return "adb-other-aaaaaa\t_adb-tls-connect._tcp.\t192.168.1.40:40001\n" +
+			"adb-R58M-x1y2z3\t_adb-tls-connect._tcp.\t192.168.1.23:41999\n"
+	}
+
+	if addr, ok := Resolve("adb-R58M-x1y2z3", 0); !ok || addr != "192.168.1.23:41999" {
+		t.Errorf("Resolve() = %q, %v", addr, ok)
+	}
+	if _, ok := Resolve("adb-gone-bbbbbb", 0); ok {
+		t.Error("a robot that is not announcing was found")
+	}
+}
@@ -22,6 +22,11 @@ type Run struct {
 	Bytes    int64
 	Skipped  bool
 
+	// Transferred is what crossed the link, against Bytes for the APK itself.
+	// A delta sends less than the APK and compression less again, and timing
+	// alone cannot say which of the two a difference came from.
+	Transferred int64
+
 	// Spread is the gap between the fastest and slowest sample. A deploy is
 	// noisy enough that without it a few seconds of variance reads as a
 	// finding.
@@ -116,35 +121,56 @@ func Deploy(opt Options) []Run {
 		}
 	}
 
+	// The cold runs start each attempt from an empty chunk cache, which is the
+	// only time a delta sends everything and so the only time compression has
+	// the whole APK to work on.
 	configs := []struct {
 		name string
 		what string
 		opts adb.Options
+		cold bool
 	}{
 		{
 			"Android Studio equivalent",
 			"one streamed session install of the whole APK, no delta",
 			adb.Options{Stream: true},
+			false,
 		},
 		{
 			"pusher, staged install",
 			"push to a temporary file, then install from it",
 			adb.Options{},
+			false,
 		},
 		{
 			"pusher, streamed install",
 			"stream the APK into the install session",
 			adb.Options{Stream: true},
+			false,
 		},
 		{
 			"pusher, delta transfer",
 			"send only changed chunks, then install",
 			adb.Options{Delta: true},
+			false,
+		},
+		{
+			"pusher, cold delta",
+			"empty chunk cache, every chunk sent as it is",
+			adb.Options{Delta: true},
+			true,
+		},
+		{
+			"pusher, cold delta compressed",
+			"empty chunk cache, every chunk sent compressed",
+			adb.Options{Delta: true, Compress: true},
+			true,
 		},
 		{
 			"pusher, delta + streamed",
 			"changed chunks, streamed into the session",
 			adb.Options{Delta: true, Stream: true},
+			false,
 		},
 	}
 
@@ -159,6 +185,12 @@ func Deploy(opt Options) []Run {
 			report(fmt.Sprintf("%s (%d/%d)", cfg.name, attempt+1, opt.Repeat))
 
 			adb.ForgetInstalled(opt.Serial)
+			if cfg.cold {
+				if err := adb.ClearCache(opt.Serial); err != nil {
+					best = Run{Name: cfg.name, What: cfg.what, Err: fmt.Errorf("cannot empty the chunk cache: %w", err)}
+					break
+				}
+			}
 
 			run := measure(opt.Serial, opt.APK, cfg.opts, opt.Timeout)
 			run.Name, run.What = cfg.name, cfg.what
@@ -210,17 +242,21 @@ func measure(serial, apk string, opts adb.Options, limit time.Duration) Run {
 
 	// Buffered, so a run that outlives its deadline can still finish writing
 	// rather than leaking a blocked goroutine.
-	done := make(chan error, 1)
+	type result struct {
+		plan adb.InstallPlan
+		err  error
+	}
+	done := make(chan result, 1)
 
 	start := time.Now()
 	go func() {
-		_, err := adb.InstallWith(serial, apk, opts)
-		done <- err
+		plan, err := adb.InstallWith(serial, apk, opts)
+		done <- result{plan, err}
 	}()
 
 	select {
-	case err := <-done:
-		return Run{Install: time.Since(start), Bytes: info.Size(), Err: err}
+	case r := <-done:
+		return Run{Install: time.Since(start), Bytes: info.Size(), Transferred: r.plan.BytesSent, Err: r.err}
 	case <-time.After(limit):
 		return Run{Err: fmt.Errorf("gave up after %s; the robot stopped responding", limit)}
 	}
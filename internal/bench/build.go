@@ -0,0 +1,70 @@
+package bench
+
+import (
+	"io"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/gradle"
+)
+
+// Build is what the build in front of a deploy costs, measured apart from it.
+// A deploy benchmark that started its clock at Gradle would mostly measure
+// Gradle, and the part pusher can do anything about would vanish into it.
+type Build struct {
+	// Cold is a build with no daemon running, the first of a session.
+	Cold time.Duration
+	// Warm is the same build again, with the daemon still up.
+	Warm time.Duration
+	// Check is how long pusher takes to see that nothing changed, which is
+	// all a deploy of an untouched project now spends on the build.
+	Check time.Duration
+	// Skips is whether that check did find nothing changed. It should, right
+	// after a build; if not, something in the project is rewritten by every
+	// build and the skip never happens.
+	Skips bool
+
+	// Measured distinguishes a run that happened from one that was never
+	// asked for, as it does for Reload.
+	Measured bool
+
+	Err error
+}
+
+// MeasureBuild times the project's build with and without a warm daemon, and
+// the check that lets a deploy skip it. None of the builds change anything:
+// the first brings the project up to date, so the two timed after it are
+// Gradle finding nothing to do, which is the cost a deploy cannot avoid.
+func MeasureBuild(wrapper string) Build {
+	out := Build{Measured: true}
+	offline := !gradle.Online()
+
+	if err := gradle.Build(wrapper, offline, io.Discard); err != nil {
+		out.Err = err
+		return out
+	}
+
+	if err := gradle.StopDaemon(wrapper); err != nil {
+		out.Err = err
+		return out
+	}
+
+	start := time.Now()
+	if err := gradle.Build(wrapper, offline, io.Discard); err != nil {
+		out.Err = err
+		return out
+	}
+	out.Cold = time.Since(start)
+
+	start = time.Now()
+	if err := gradle.Build(wrapper, offline, io.Discard); err != nil {
+		out.Err = err
+		return out
+	}
+	out.Warm = time.Since(start)
+
+	start = time.Now()
+	out.Skips = !gradle.Changed(wrapper)
+	out.Check = time.Since(start)
+
+	return out
+}
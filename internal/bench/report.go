@@ -17,13 +17,14 @@ const (
 )
 
 // Report renders everything measured into something readable.
-func Report(apk APK, runs []Run, reload Reload, settings map[string]bool) string {
+func Report(apk APK, build Build, runs []Run, reload Reload, settings map[string]bool) string {
 	var b strings.Builder
 
 	b.WriteString("# Pusher deploy report\n\n")
 	fmt.Fprintf(&b, "%s\n\n", time.Now().Format("2 January 2006, 15:04"))
 
 	writeAPK(&b, apk)
+	writeBuild(&b, build)
 	writeRuns(&b, runs)
 	writeSettingEffects(&b, apk, runs, settings)
 	writeReload(&b, reload, runs)
@@ -61,6 +62,43 @@ func writeAPK(b *strings.Builder, apk APK) {
 	b.WriteString("as it does, and it is why the settings below split into transfer and install.\n\n")
 }
 
+func writeBuild(b *strings.Builder, build Build) {
+	b.WriteString("## Building\n\n")
+
+	if !build.Measured {
+		b.WriteString("Not run. `pusher dev` -> Benchmark the deploy times the build too.\n\n")
+		return
+	}
+
+	if build.Err != nil {
+		fmt.Fprintf(b, "Could not measure: %s\n\n", build.Err)
+		return
+	}
+
+	b.WriteString("Every deploy starts with a build, and none of the times below include it.\n")
+	b.WriteString("These are builds of a project with nothing to compile, so they are what\n")
+	b.WriteString("Gradle costs before any of the team's code is involved.\n\n")
+
+	fmt.Fprintf(b, "| | |\n|---|---|\n")
+	fmt.Fprintf(b, "| first build, no daemon | %s |\n", secs(build.Cold))
+	fmt.Fprintf(b, "| with the daemon warm | %s |\n", secs(build.Warm))
+	if build.Skips {
+		fmt.Fprintf(b, "| pusher skipping it, nothing changed | %s |\n\n", secs(build.Check))
+	} else {
+		fmt.Fprintf(b, "| pusher skipping it | never: the project changes with every build |\n\n")
+	}
+
+	if build.Cold > build.Warm {
+		fmt.Fprintf(b, "A warm daemon saves %s on the first deploy of a session, which is why\n",
+			secs(build.Cold-build.Warm))
+		b.WriteString("`pusher watch` and `pusher settings` start one as they open.\n\n")
+	}
+	if !build.Skips {
+		b.WriteString("Something under the project is written by the build itself, outside a\n")
+		b.WriteString("`build` directory, so every deploy runs Gradle even when no code changed.\n\n")
+	}
+}
+
 func writeRuns(b *strings.Builder, runs []Run) {
 	b.WriteString("## Measured\n\n")
 
@@ -101,7 +139,7 @@ func writeRuns(b *strings.Builder, runs []Run) {
 		}
 
 		fmt.Fprintf(b, "| %s | %s | %s | %s |\n",
-			run.Name, timing(run), compareWithin(run.Total(), baseline, noise), run.What)
+			run.Name, timing(run), compareWithin(run.Total(), baseline, noise), run.What+sent(run))
 	}
 
 	b.WriteString("\n")
@@ -121,6 +159,15 @@ func writeRuns(b *strings.Builder, runs []Run) {
 	}
 }
 
+// sent is what crossed the link when that was less than the APK, since that is
+// the whole point of a delta and the time alone does not show it.
+func sent(run Run) string {
+	if run.Skipped || run.Bytes <= 0 || run.Transferred >= run.Bytes {
+		return ""
+	}
+	return fmt.Sprintf("; sent %s of %s", mb(run.Transferred), mb(run.Bytes))
+}
+
 func samplesOf(runs []Run) int {
 	for _, run := range runs {
 		if run.Samples > 1 {
@@ -190,6 +237,8 @@ func writeSettingEffects(b *strings.Builder, apk APK, runs []Run, settings map[s
 	staged, hasStaged := find("pusher, staged install")
 	streamed, hasStreamed := find("pusher, streamed install")
 	delta, hasDelta := find("pusher, delta transfer")
+	cold, hasCold := find("pusher, cold delta")
+	packed, hasPacked := find("pusher, cold delta compressed")
 	skip, hasSkip := find("pusher, nothing changed")
 	split, hasSplit := find("pusher, changed split only")
 
@@ -202,6 +251,14 @@ func writeSettingEffects(b *strings.Builder, apk APK, runs []Run, settings map[s
 	}
 	fmt.Fprintf(b, "| Send only changed parts | %s | %s |\n", onOff(settings["delta"]), deltaEffect)
 
+	compressEffect := "needs the deploy benchmark"
+	if hasCold && hasPacked {
+		compressEffect = fmt.Sprintf("%s sent instead of %s from an empty cache; %s vs %s (%s)",
+			mb(packed.Transferred), mb(cold.Transferred),
+			secs(packed.Total()), secs(cold.Total()), compare(packed.Total(), cold.Total()))
+	}
+	fmt.Fprintf(b, "| Compress changed parts | %s | %s |\n", onOff(settings["compress"]), compressEffect)
+
 	skipEffect := "needs the deploy benchmark"
 	if hasSkip {
 		skipEffect = fmt.Sprintf("%s when nothing changed", secs(skip.Total()))
@@ -241,10 +298,11 @@ func writeSettingEffects(b *strings.Builder, apk APK, runs []Run, settings map[s
 	fmt.Fprintf(b, "| Install only changed splits | %s | %s |\n", onOff(settings["split"]), splitEffect)
 
 	b.WriteString("\n")
-	b.WriteString("Two of these are not free. Storing the libraries makes the APK bigger, which\n")
+	b.WriteString("Three of these are not free. Storing the libraries makes the APK bigger, which\n")
 	b.WriteString("costs transfer time, so it is a win on USB or 5 GHz and a question on 2.4 GHz.\n")
 	b.WriteString("Delta costs a little work on the hub to rebuild the APK, which is why it can\n")
-	b.WriteString("lose over USB where the transfer was never the problem.\n\n")
+	b.WriteString("lose over USB where the transfer was never the problem, and compressing the\n")
+	b.WriteString("chunks costs the hub a decompress for every byte it saves on the link.\n\n")
 
 	b.WriteString("Not swept here, because changing them needs a rebuild:\n\n")
 	fmt.Fprintf(b, "- **One ABI** (`pusher slim`): the APK carries %s of native libraries. A stock\n", mb(apk.LibPacked))
@@ -6,7 +6,7 @@ import (
 	"time"
 )
 
-func sample() (APK, []Run, Reload, map[string]bool) {
+func sample() (APK, Build, []Run, Reload, map[string]bool) {
 	apk := APK{
 		Path:          "TeamCode-debug.apk",
 		Size:          68 << 20,
@@ -36,9 +36,17 @@ func sample() (APK, []Run, Reload, map[string]bool) {
 		Overhead:  190 * time.Millisecond,
 	}
 
+	build := Build{
+		Measured: true,
+		Cold:     24 * time.Second,
+		Warm:     3 * time.Second,
+		Check:    40 * time.Millisecond,
+		Skips:    true,
+	}
+
 	settings := map[string]bool{"delta": true, "skip": true, "stream": true}
 
-	return apk, runs, reload, settings
+	return apk, build, runs, reload, settings
 }
 
 func TestReportCoversEverythingItPromises(t *testing.T) {
@@ -82,8 +90,8 @@ func TestReportQuantifiesEachSetting(t *testing.T) {
 }
 
 func TestComparisonsAreRelativeToAndroidStudio(t *testing.T) {
-	apk, runs, reload, settings := sample()
-	report := Report(apk, runs, reload, settings)
+	apk, build, runs, reload, settings := sample()
+	report := Report(apk, build, runs, reload, settings)
 
 	if !strings.Contains(report, "2.2x faster") {
 		t.Errorf("the best run is not compared to the baseline:\n%s", report)
@@ -95,10 +103,10 @@ func TestComparisonsAreRelativeToAndroidStudio(t *testing.T) {
 }
 
 func TestFailedRunsAppearInTheReport(t *testing.T) {
-	apk, runs, reload, settings := sample()
+	apk, build, runs, reload, settings := sample()
 	runs = append(runs, Run{Name: "pusher, changed split only", Err: errString("no splits")})
 
-	report := Report(apk, runs, reload, settings)
+	report := Report(apk, build, runs, reload, settings)
 
 	if !strings.Contains(report, "failed") || !strings.Contains(report, "no splits") {
 		t.Errorf("a failed run was hidden:\n%s", report)
@@ -106,7 +114,7 @@ func TestFailedRunsAppearInTheReport(t *testing.T) {
 }
 
 func TestAnUnmeasurableCompileIsSaidOutLoud(t *testing.T) {
-	apk, runs, _, settings := sample()
+	apk, build, runs, _, settings := sample()
 
 	reload := Reload{
 		Measured:   true,
@@ -115,7 +123,7 @@ func TestAnUnmeasurableCompileIsSaidOutLoud(t *testing.T) {
 		CompileWhy: "dex2oat is not available to the shell on this hub",
 	}
 
-	report := Report(apk, runs, reload, settings)
+	report := Report(apk, build, runs, reload, settings)
 
 	if !strings.Contains(report, "not available to the shell") {
 		t.Errorf("the reason was dropped:\n%s", report)
@@ -126,7 +134,7 @@ func TestAnUnmeasurableCompileIsSaidOutLoud(t *testing.T) {
 }
 
 func TestSummaryOrdersByTime(t *testing.T) {
-	_, runs, _, _ := sample()
+	_, _, runs, _, _ := sample()
 
 	lines := strings.Split(strings.TrimSpace(Summary(runs)), "\n")
 	if len(lines) != len(runs) {
@@ -173,10 +181,10 @@ func (e errString) Error() string { return string(e) }
 // zeros, which reads as a measurement of nothing rather than as nothing
 // measured. Both real reports hit this.
 func TestSectionsThatWereNotRunSaySo(t *testing.T) {
-	apk, runs, reload, settings := sample()
+	apk, build, runs, reload, settings := sample()
 
 	t.Run("deploy only", func(t *testing.T) {
-		report := Report(apk, runs, Reload{}, settings)
+		report := Report(apk, build, runs, Reload{}, settings)
 
 		if !strings.Contains(report, "Not run.") {
 			t.Errorf("the unrun reload section does not say so:\n%s", report)
@@ -188,7 +196,7 @@ func TestSectionsThatWereNotRunSaySo(t *testing.T) {
 	})
 
 	t.Run("reload only", func(t *testing.T) {
-		report := Report(apk, nil, reload, settings)
+		report := Report(apk, build, nil, reload, settings)
 
 		if !strings.Contains(report, "deploy benchmark was not run") {
 			t.Errorf("the unrun deploy section does not say so:\n%s", report)
@@ -206,11 +214,36 @@ func TestSectionsThatWereNotRunSaySo(t *testing.T) {
 	})
 }
 
+// The build is timed apart from the deploy, and a project the skip never
+// applies to is called out, since then every deploy pays for Gradle.
+func TestTheBuildIsItsOwnPhase(t *testing.T) {
+	apk, build, runs, reload, settings := sample()
+
+	report := Report(apk, build, runs, reload, settings)
+	for _, want := range []string{"## Building", "24.0s", "3.0s", "40ms", "saves 21.0s"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("the build section is missing %q:\n%s", want, report)
+		}
+	}
+	if strings.Index(report, "## Building") > strings.Index(report, "## Measured") {
+		t.Error("the build comes after the deploys it happens before")
+	}
+
+	build.Skips = false
+	if report := Report(apk, build, runs, reload, settings); !strings.Contains(report, "changes with every build") {
+		t.Error("a project that is never skipped is not called out")
+	}
+
+	if report := Report(apk, Build{}, runs, reload, settings); !strings.Contains(report, "times the build too") {
+		t.Error("an unrun build section does not say so")
+	}
+}
+
 // A 1 KB stub compiles in about the time dex2oat takes to start, so timing one
 // measures startup and reports it as the cost of a reload.
 func TestTheReloadSampleIsNotAStub(t *testing.T) {
-	apk, runs, reload, settings := sample()
-	report := Report(apk, runs, reload, settings)
+	apk, build, runs, reload, settings := sample()
+	report := Report(apk, build, runs, reload, settings)
 
 	if !strings.Contains(report, "smallest non-stub dex") {
 		t.Error("the report does not say the sample avoids stubs")
@@ -238,11 +271,11 @@ func TestMarginalNeverGoesNegative(t *testing.T) {
 // Benchmarking without rebuilding measures the previous APK, and the setting
 // then looks like it did nothing. That is what cost a real run.
 func TestAStaleAPKIsCalledOut(t *testing.T) {
-	apk, runs, reload, settings := sample()
+	apk, build, runs, reload, settings := sample()
 	apk.Stale = true
 	settings["storeLibs"] = true
 
-	report := Report(apk, runs, reload, settings)
+	report := Report(apk, build, runs, reload, settings)
 
 	if !strings.Contains(report, "older than the project's gradle files") {
 		t.Errorf("a stale APK is not flagged:\n%s", report)
@@ -254,11 +287,11 @@ func TestAStaleAPKIsCalledOut(t *testing.T) {
 
 // A setting that is on and actually built must not be nagged about.
 func TestABuiltSettingIsNotNagged(t *testing.T) {
-	apk, runs, reload, settings := sample()
+	apk, build, runs, reload, settings := sample()
 	apk.LibCompressed = false
 	settings["storeLibs"] = true
 
-	report := Report(apk, runs, reload, settings)
+	report := Report(apk, build, runs, reload, settings)
 
 	if strings.Contains(report, "ON BUT NOT BUILT") {
 		t.Error("a setting that is in the APK was reported as not built")
@@ -271,7 +304,7 @@ func TestABuiltSettingIsNotNagged(t *testing.T) {
 // Single samples cannot tell a small difference from run-to-run variance, and
 // reporting one as a finding is how a benchmark misleads.
 func TestDifferencesInsideTheSpreadAreNotClaimed(t *testing.T) {
-	apk, _, reload, settings := sample()
+	apk, build, _, reload, settings := sample()
 
 	runs := []Run{
 		{Name: "Android Studio equivalent", Install: 43 * time.Second, Spread: 3 * time.Second, Samples: 3},
@@ -279,7 +312,7 @@ func TestDifferencesInsideTheSpreadAreNotClaimed(t *testing.T) {
 		{Name: "pusher, delta transfer", Install: 20 * time.Second, Spread: 2 * time.Second, Samples: 3},
 	}
 
-	report := Report(apk, runs, reload, settings)
+	report := Report(apk, build, runs, reload, settings)
 
 	if !strings.Contains(report, "within noise") {
 		t.Errorf("a one-second gap on a three-second spread was claimed:\n%s", report)
@@ -294,10 +327,28 @@ func TestDifferencesInsideTheSpreadAreNotClaimed(t *testing.T) {
 }
 
 func TestOneSampleSaysSo(t *testing.T) {
-	apk, runs, reload, settings := sample()
-	report := Report(apk, runs, reload, settings)
+	apk, build, runs, reload, settings := sample()
+	report := Report(apk, build, runs, reload, settings)
 
 	if !strings.Contains(report, "One sample each") {
 		t.Errorf("a single-sample run does not warn:\n%s", report)
 	}
 }
+
+func TestCompressionIsMeasuredInBytesAndTime(t *testing.T) {
+	apk, build, runs, reload, settings := sample()
+	runs = append(runs,
+		Run{Name: "pusher, cold delta", What: "cold", Install: 30 * time.Second, Bytes: 68 << 20, Transferred: 68 << 20},
+		Run{Name: "pusher, cold delta compressed", What: "cold packed", Install: 20 * time.Second, Bytes: 68 << 20, Transferred: 34 << 20},
+	)
+
+	report := Report(apk, build, runs, reload, settings)
+
+	if !strings.Contains(report, "sent 34.0 MB of 68.0 MB") {
+		t.Errorf("the compressed run does not say what crossed the link:\n%s", report)
+	}
+	section := report[strings.Index(report, "What each setting does"):]
+	if !strings.Contains(section, "34.0 MB sent instead of 68.0 MB") {
+		t.Errorf("the compression setting is not quantified:\n%s", section)
+	}
+}
@@ -14,8 +14,19 @@ type Profile struct {
 	Name     string `mapstructure:"name"`
 	SSID     string `mapstructure:"ssid"`
 	Password string `mapstructure:"password"`
+
+	// Addr is where a robot paired for wireless debugging was last reached.
+	// Its port changes whenever wireless debugging restarts, so Service, the
+	// name the robot advertises over mDNS, is what finds it again.
+	Addr    string `mapstructure:"addr"`
+	Service string `mapstructure:"service"`
 }
 
+// Paired reports whether the robot is reached by wireless debugging, on the
+// network the computer is already on, rather than at the hub's fixed address
+// on its own.
+func (p *Profile) Paired() bool { return p != nil && p.Addr != "" }
+
 // Config is everything pusher remembers between runs.
 type Config struct {
 	DefaultProfile string              `mapstructure:"default_profile"`
@@ -33,6 +44,10 @@ type Config struct {
 
 	DeltaTransfer bool `mapstructure:"delta_transfer"`
 
+	CompressChunks bool `mapstructure:"compress_chunks"`
+
+	PatchTransfer bool `mapstructure:"patch_transfer"`
+
 	HubABI string `mapstructure:"hub_abi"`
 
 	SkipUnchanged bool `mapstructure:"skip_unchanged"`
@@ -50,6 +65,12 @@ type Config struct {
 	UpdateNotify bool `mapstructure:"update_notify"`
 
 	BlobBranch string `mapstructure:"blob_branch"`
+
+	Fleet []string `mapstructure:"fleet"`
+
+	CacheKeep int `mapstructure:"cache_keep"`
+
+	CacheLimitMB int `mapstructure:"cache_limit_mb"`
 }
 
 var (
@@ -91,6 +112,8 @@ func Initialize() error {
 	viper.SetDefault("prefer_usb", true)
 	viper.SetDefault("auto_slim", false)
 	viper.SetDefault("delta_transfer", true)
+	viper.SetDefault("compress_chunks", true)
+	viper.SetDefault("patch_transfer", false)
 	viper.SetDefault("hub_abi", "")
 	viper.SetDefault("skip_unchanged", true)
 	viper.SetDefault("stream_install", true)
@@ -100,6 +123,9 @@ func Initialize() error {
 	viper.SetDefault("dash_watch", false)
 	viper.SetDefault("update_notify", true)
 	viper.SetDefault("blob_branch", "main")
+	viper.SetDefault("fleet", []string{})
+	viper.SetDefault("cache_keep", 3)
+	viper.SetDefault("cache_limit_mb", 256)
 	viper.SetDefault("telemetry", true)
 
 	if _, err := os.Stat(configFile); os.IsNotExist(err) {
@@ -137,6 +163,8 @@ func Save(cfg *Config) error {
 	viper.Set("prefer_usb", cfg.PreferUSB)
 	viper.Set("auto_slim", cfg.AutoSlim)
 	viper.Set("delta_transfer", cfg.DeltaTransfer)
+	viper.Set("compress_chunks", cfg.CompressChunks)
+	viper.Set("patch_transfer", cfg.PatchTransfer)
 	viper.Set("hub_abi", cfg.HubABI)
 	viper.Set("skip_unchanged", cfg.SkipUnchanged)
 	viper.Set("stream_install", cfg.StreamInstall)
@@ -146,6 +174,9 @@ func Save(cfg *Config) error {
 	viper.Set("dash_watch", cfg.DashWatch)
 	viper.Set("update_notify", cfg.UpdateNotify)
 	viper.Set("blob_branch", cfg.BlobBranch)
+	viper.Set("fleet", cfg.Fleet)
+	viper.Set("cache_keep", cfg.CacheKeep)
+	viper.Set("cache_limit_mb", cfg.CacheLimitMB)
 
 	if err := viper.WriteConfig(); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
@@ -177,6 +208,45 @@ func AddProfile(name, ssid, password string) error {
 	return Save(cfg)
 }
 
+// SetPaired records where a profile's robot takes wireless debugging
+// connections, creating the profile if there is none by that name.
+func SetPaired(name, service, addr string) error {
+	cfg, err := Load()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]*Profile)
+	}
+
+	profile := cfg.Profiles[name]
+	if profile == nil {
+		profile = &Profile{Name: name}
+		cfg.Profiles[name] = profile
+	}
+	profile.Addr = addr
+	if service != "" {
+		profile.Service = service
+	}
+
+	if cfg.DefaultProfile == "" {
+		cfg.DefaultProfile = name
+	}
+
+	return Save(cfg)
+}
+
+// GetPairedAddr is the default profile's wireless debugging address, empty
+// when it is not paired.
+func GetPairedAddr() string {
+	profile, err := GetDefaultProfile()
+	if err != nil || !profile.Paired() {
+		return ""
+	}
+	return profile.Addr
+}
+
 // GetDefaultProfile returns the profile deploys use.
 func GetDefaultProfile() (*Profile, error) {
 	cfg, err := Load()
@@ -196,6 +266,21 @@ func GetDefaultProfile() (*Profile, error) {
 	return profile, nil
 }
 
+// GetProfile returns one saved robot by name.
+func GetProfile(name string) (*Profile, error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	profile, ok := cfg.Profiles[name]
+	if !ok || profile == nil {
+		return nil, fmt.Errorf("profile '%s' not found", name)
+	}
+
+	return profile, nil
+}
+
 // SetDefaultProfile chooses which profile deploys use.
 func SetDefaultProfile(name string) error {
 	cfg, err := Load()
@@ -246,7 +331,8 @@ func HasProfiles() (bool, error) {
 	return len(cfg.Profiles) > 0, nil
 }
 
-// GetThreads is how many workers Gradle may use.
+// GetThreads is how many workers Gradle may use, and how many chunks a delta
+// transfer sends at once.
 func GetThreads() int {
 	threads := viper.GetInt("threads")
 	if threads <= 0 {
@@ -475,6 +561,7 @@ func DeleteProfile(name string) error {
 	}
 
 	delete(cfg.Profiles, name)
+	cfg.Fleet = without(cfg.Fleet, name)
 
 	if cfg.DefaultProfile == name {
 		cfg.DefaultProfile = ""
@@ -487,6 +574,52 @@ func DeleteProfile(name string) error {
 	return Save(cfg)
 }
 
+// GetCacheKeep is how many recent pushes keep their chunks in the hub's cache.
+func GetCacheKeep() int {
+	keep := viper.GetInt("cache_keep")
+	if keep <= 0 {
+		return 3
+	}
+	return keep
+}
+
+// GetCacheLimitMB caps the hub's chunk cache, in megabytes. Zero is no cap.
+func GetCacheLimitMB() int {
+	limit := viper.GetInt("cache_limit_mb")
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// GetCompressChunks reports whether a delta transfer compresses what it sends.
+func GetCompressChunks() bool { return viper.GetBool("compress_chunks") }
+
+// SetCompressChunks controls whether a delta transfer compresses what it sends.
+func SetCompressChunks(enabled bool) error {
+	cfg, err := Load()
+	if err != nil {
+		return err
+	}
+	cfg.CompressChunks = enabled
+	return Save(cfg)
+}
+
+// GetPatchTransfer reports whether a deploy sends a patch against the APK the
+// robot already has.
+func GetPatchTransfer() bool { return viper.GetBool("patch_transfer") }
+
+// SetPatchTransfer controls whether a deploy sends a patch against the APK the
+// robot already has.
+func SetPatchTransfer(enabled bool) error {
+	cfg, err := Load()
+	if err != nil {
+		return err
+	}
+	cfg.PatchTransfer = enabled
+	return Save(cfg)
+}
+
 // GetSkipUnchanged reports whether an install is skipped when the robot already has this build.
 func GetSkipUnchanged() bool { return viper.GetBool("skip_unchanged") }
 
@@ -568,3 +701,55 @@ func SetDashWatch(enabled bool) error {
 
 // Dir is where pusher keeps everything it remembers.
 func Dir() string { return configDir }
+
+// GetFleet is the robots a push deploys to in turn, in order. Empty means only
+// the default profile.
+func GetFleet() []string { return viper.GetStringSlice("fleet") }
+
+// SetFleet chooses the robots a push deploys to in turn.
+func SetFleet(names []string) error {
+	cfg, err := Load()
+	if err != nil {
+		return err
+	}
+	cfg.Fleet = names
+	return Save(cfg)
+}
+
+// ToggleFleet adds a robot to the fleet, or takes it out if it is already in.
+// It reports whether the robot is in the fleet afterwards.
+func ToggleFleet(name string) (bool, error) {
+	cfg, err := Load()
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := cfg.Profiles[name]; !ok {
+		return false, fmt.Errorf("profile '%s' not found", name)
+	}
+
+	in := false
+	for _, member := range cfg.Fleet {
+		if member == name {
+			in = true
+		}
+	}
+
+	if in {
+		cfg.Fleet = without(cfg.Fleet, name)
+	} else {
+		cfg.Fleet = append(cfg.Fleet, name)
+	}
+
+	return !in, Save(cfg)
+}
+
+func without(names []string, name string) []string {
+	kept := names[:0:0]
+	for _, member := range names {
+		if member != name {
+			kept = append(kept, member)
+		}
+	}
+	return kept
+}
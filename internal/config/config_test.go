@@ -76,6 +76,51 @@ func TestAddProfile(t *testing.T) {
 	}
 }
 
+func TestSetPaired(t *testing.T) {
+	cleanup := setupTest(t)
+	defer cleanup()
+
+	if GetPairedAddr() != "" {
+		t.Error("A fresh config had a paired address")
+	}
+
+	if err := AddProfile("hub", "DIRECT-Robot", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPaired("phone", "adb-R58M-x1y2z3", "192.168.1.23:41235"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPaired("hub", "", "192.168.43.1:37011"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read back from the file, which is where the field names have to agree.
+	viper.Reset()
+	if err := Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	phone, err := GetProfile("phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !phone.Paired() || phone.Addr != "192.168.1.23:41235" || phone.Service != "adb-R58M-x1y2z3" {
+		t.Errorf("Paired profile read back as %+v", phone)
+	}
+
+	hub, err := GetProfile("hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hub.SSID != "DIRECT-Robot" || hub.Password != "password123" || hub.Addr != "192.168.43.1:37011" {
+		t.Errorf("Pairing an existing profile lost what it had: %+v", hub)
+	}
+
+	if got := GetPairedAddr(); got != "192.168.43.1:37011" {
+		t.Errorf("GetPairedAddr() = %q, want the default profile's", got)
+	}
+}
+
 func TestGetDefaultProfile(t *testing.T) {
 	cleanup := setupTest(t)
 	defer cleanup()
@@ -252,3 +297,42 @@ func TestEverySettingSurvivesASave(t *testing.T) {
 		}
 	}
 }
+
+// A fleet names profiles rather than holding them, so deleting a robot has to
+// take it out of the fleet too. Leaving the name behind makes every later push
+// stop on a profile nobody can see in the menu.
+func TestFleetFollowsTheProfiles(t *testing.T) {
+	cleanup := setupTest(t)
+	defer cleanup()
+
+	AddProfile("comp", "DIRECT-comp", "pass1")
+	AddProfile("practice", "DIRECT-practice", "pass2")
+
+	for _, name := range []string{"comp", "practice"} {
+		if in, err := ToggleFleet(name); err != nil || !in {
+			t.Fatalf("ToggleFleet(%q) = %v, %v; want it added", name, in, err)
+		}
+	}
+
+	if got := GetFleet(); !reflect.DeepEqual(got, []string{"comp", "practice"}) {
+		t.Fatalf("fleet is %v, want comp then practice in the order they joined", got)
+	}
+
+	if _, err := ToggleFleet("nonexistent"); err == nil {
+		t.Error("a profile that does not exist must not join the fleet")
+	}
+
+	if err := DeleteProfile("comp"); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetFleet(); !reflect.DeepEqual(got, []string{"practice"}) {
+		t.Errorf("fleet is %v after deleting comp, want only practice", got)
+	}
+
+	if in, err := ToggleFleet("practice"); err != nil || in {
+		t.Errorf("ToggleFleet on a member = %v, %v; want it taken out", in, err)
+	}
+	if got := GetFleet(); len(got) != 0 {
+		t.Errorf("fleet is %v, want it empty", got)
+	}
+}
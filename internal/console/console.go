@@ -0,0 +1,31 @@
+// Package console is where everything pusher writes for a person goes.
+//
+// That is stdout, until --output json claims stdout for events; then it is
+// stderr. Writing through here rather than fmt.Print means moving it moves
+// every line at once, without any of them having to know which mode they are
+// in, and without touching os.Stdout, which the events themselves still need.
+package console
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Out is the writer for human output.
+var Out io.Writer = os.Stdout
+
+// Printf is fmt.Printf, to Out.
+func Printf(format string, a ...any) {
+	fmt.Fprintf(Out, format, a...)
+}
+
+// Println is fmt.Println, to Out.
+func Println(a ...any) {
+	fmt.Fprintln(Out, a...)
+}
+
+// Print is fmt.Print, to Out.
+func Print(a ...any) {
+	fmt.Fprint(Out, a...)
+}
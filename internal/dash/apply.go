@@ -0,0 +1,214 @@
+package dash
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Writing tuning back is the other half of the comparison: the robot holds a
+// value the source does not, and the only place it survives a deploy is the
+// initialiser it came from. So the initialiser is replaced and nothing else is,
+// not the declaration, not its neighbours in the declarator list, not the
+// whitespace around it. A rewrite that reformatted the line would turn a
+// one-number change into a diff nobody wants to review.
+
+// Edit is one initialiser to rewrite.
+type Edit struct {
+	Field Field
+	// To is the new initialiser, as Java source.
+	To string
+}
+
+// Line is the 1-indexed line the initialiser itself is on, which in a
+// declarator list that spans lines is not the line the declaration starts on.
+func (e Edit) Line(content string) int {
+	if e.Field.Offset > len(content) {
+		return e.Field.Line
+	}
+	return 1 + strings.Count(content[:e.Field.Offset], "\n")
+}
+
+// Plan works out the edits that write the robot's values into the source.
+//
+// A change with no field to write into is skipped, and so is a computed
+// initialiser: the robot holds what it evaluated to, and replacing the
+// expression with its value would throw away how it was worked out. Each skip
+// comes back with its reason.
+func Plan(changes []Change, code Source) ([]Edit, []string) {
+	var edits []Edit
+	var skipped []string
+
+	for _, change := range changes {
+		field, found := code[change.Key]
+		switch {
+		case !found || field.File == "":
+			skipped = append(skipped, change.Key+": no field in the source to write it into")
+			continue
+		case field.Computed:
+			skipped = append(skipped, change.Key+": worked out in code, so there is no value to replace")
+			continue
+		}
+
+		edits = append(edits, Edit{Field: field, To: Literal(change.Live, field.Initialiser)})
+	}
+
+	return edits, skipped
+}
+
+// Literal renders a value from the robot as Java, in the shape the initialiser
+// it replaces was written in.
+//
+// The dashboard reports 1.0 as 1 and a float without its suffix, and writing
+// either straight in would change the type of the literal or fail to compile.
+func Literal(live, was string) string {
+	if live == "true" || live == "false" || strings.HasPrefix(live, `"`) {
+		return live
+	}
+
+	if !looksNumeric(live) {
+		// An enum constant. The robot sends the bare name, the source may
+		// qualify it, and the qualification is kept.
+		if i := strings.LastIndex(was, "."); i >= 0 && !looksNumeric(was) {
+			return was[:i+1] + live
+		}
+		return live
+	}
+
+	body := strings.TrimPrefix(strings.TrimPrefix(was, "-"), "+")
+	suffix := ""
+	if n := len(body); n > 0 && !strings.HasPrefix(strings.ToLower(body), "0x") &&
+		strings.ContainsAny(body[n-1:], "fFdDlL") {
+		suffix = body[n-1:]
+		body = body[:n-1]
+	}
+
+	integral := !strings.ContainsAny(live, ".eE")
+	decimal := strings.ContainsAny(body, ".eE") || strings.ContainsAny(suffix, "fFdD")
+
+	if integral && decimal {
+		live += ".0"
+	}
+
+	return live + suffix
+}
+
+// Rewrite applies edits to one file's content.
+//
+// Every edit is checked against the text it expects to replace, so a file that
+// changed since it was read is refused rather than written over at an offset
+// that now means something else.
+func Rewrite(content string, edits []Edit) (string, error) {
+	sorted := append([]Edit(nil), edits...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Field.Offset > sorted[b].Field.Offset })
+
+	for _, edit := range sorted {
+		start := edit.Field.Offset
+		end := start + len(edit.Field.Initialiser)
+
+		if start < 0 || end > len(content) || content[start:end] != edit.Field.Initialiser {
+			return "", fmt.Errorf("%s changed since it was read: %s is no longer where it was",
+				edit.Field.File, edit.Field.Key())
+		}
+
+		content = content[:start] + edit.To + content[end:]
+	}
+
+	return content, nil
+}
+
+// Preview shows the lines applying edits to one file would change, before and
+// after. A literal never contains a newline, so a line keeps its number.
+func Preview(file, content string, edits []Edit) (string, error) {
+	updated, err := Rewrite(content, edits)
+	if err != nil {
+		return "", err
+	}
+
+	before, after := strings.Split(content, "\n"), strings.Split(updated, "\n")
+
+	keys := map[int][]string{}
+	var lines []int
+	for _, edit := range edits {
+		line := edit.Line(content)
+		if _, seen := keys[line]; !seen {
+			lines = append(lines, line)
+		}
+		keys[line] = append(keys[line], edit.Field.Key())
+	}
+	sort.Ints(lines)
+
+	var b strings.Builder
+	for _, line := range lines {
+		if line < 1 || line > len(before) || line > len(after) {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d  %s\n", file, line, strings.Join(keys[line], ", "))
+		fmt.Fprintf(&b, "  - %s\n", strings.TrimSpace(before[line-1]))
+		fmt.Fprintf(&b, "  + %s\n", strings.TrimSpace(after[line-1]))
+	}
+
+	return b.String(), nil
+}
+
+// Apply writes edits into their files, grouped by file. Every file is
+// rewritten in memory before any is written, so one that cannot be changed
+// leaves all of them as they were.
+func Apply(edits []Edit) error {
+	byFile := ByFile(edits)
+
+	rewritten := map[string]string{}
+	modes := map[string]os.FileMode{}
+	for file, group := range byFile {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		updated, err := Rewrite(string(content), group)
+		if err != nil {
+			return err
+		}
+		rewritten[file], modes[file] = updated, info.Mode().Perm()
+	}
+
+	for file, content := range rewritten {
+		if err := os.WriteFile(file, []byte(content), modes[file]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ByFile groups edits by the file they change.
+func ByFile(edits []Edit) map[string][]Edit {
+	out := map[string][]Edit{}
+	for _, edit := range edits {
+		out[edit.Field.File] = append(out[edit.Field.File], edit)
+	}
+	return out
+}
+
+// Applied is the reading to record once edits are written, so the next
+// comparison reports them as saved.
+//
+// The applied keys are recorded at the value the code held before, because
+// that is the only reading under which "the robot now agrees with the code and
+// used not to" is true. Recording the live value instead would file them with
+// everything never touched.
+func Applied(live Values, edits []Edit) Values {
+	out := Values{}
+	for key, value := range live {
+		out[key] = value
+	}
+	for _, edit := range edits {
+		out[edit.Field.Key()] = edit.Field.Value
+	}
+	return out
+}
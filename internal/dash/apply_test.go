@@ -0,0 +1,112 @@
+package dash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Only the number changes. The declarator list, the comment, the spacing and
+// the other fields stay exactly as they were, so the diff a person reviews is
+// the tuning and nothing else.
+func TestApplyRewritesOnlyTheInitialiser(t *testing.T) {
+	source := `@Config
+public class Constants {
+    public static double hP = 1.2, hI = 0,  hD = 0.11; // tuned on Saturday
+    public static float gain = 0.5f;
+    public static int ticks = 5;
+}`
+
+	code := Source{}
+	for _, f := range FromFile("Constants.java", source) {
+		code[f.Key()] = f
+	}
+
+	live := Values{"Constants.hI": "0.05", "Constants.gain": "1", "Constants.ticks": "7"}
+	edits, skipped := Plan(Compare(live, code, nil).Unsaved, code)
+	if len(skipped) != 0 {
+		t.Fatalf("nothing should be skipped: %v", skipped)
+	}
+
+	got, err := Rewrite(source, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `@Config
+public class Constants {
+    public static double hP = 1.2, hI = 0.05,  hD = 0.11; // tuned on Saturday
+    public static float gain = 1.0f;
+    public static int ticks = 7;
+}`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// The dashboard writes 1.0 as 1 and drops suffixes. Written in as it arrives,
+// a double becomes an int literal and a long loses its L.
+func TestLiteralKeepsTheShapeOfWhatItReplaces(t *testing.T) {
+	for _, tc := range []struct{ live, was, want string }{
+		{"1", "1.2", "1.0"},
+		{"1", "0.5f", "1.0f"},
+		{"0.25", "0.5F", "0.25F"},
+		{"7", "5", "7"},
+		{"7", "5L", "7L"},
+		{"-3.5", "2.0d", "-3.5d"},
+		{"true", "false", "true"},
+		{`"left"`, `"right"`, `"left"`},
+		{"FAST", "Mode.SLOW", "Mode.FAST"},
+		{"FAST", "SLOW", "FAST"},
+	} {
+		if got := Literal(tc.live, tc.was); got != tc.want {
+			t.Errorf("Literal(%q, %q) = %q, want %q", tc.live, tc.was, got, tc.want)
+		}
+	}
+}
+
+// A file edited between reading and writing must not be written at offsets
+// that now point at something else.
+func TestRewriteRefusesAFileThatMoved(t *testing.T) {
+	source := "@Config\npublic class C {\n    public static double kP = 1.0;\n}"
+	fields := FromFile("C.java", source)
+
+	edits := []Edit{{Field: fields[0], To: "2.0"}}
+	if _, err := Rewrite("// a new comment\n"+source, edits); err == nil {
+		t.Error("a shifted file was rewritten")
+	}
+}
+
+func TestComputedFieldsAreSkippedWithAReason(t *testing.T) {
+	code := Source{"C.angle": {Section: "C", Name: "angle", Computed: true, File: "C.java"}}
+
+	edits, skipped := Plan([]Change{{Key: "C.angle", Live: "0.05"}}, code)
+	if len(edits) != 0 || len(skipped) != 1 || !strings.Contains(skipped[0], "C.angle") {
+		t.Errorf("edits %v, skipped %v", edits, skipped)
+	}
+}
+
+// After an apply the next diff should say the values were saved, not that
+// they were never touched.
+func TestAnApplyIsReportedAsSavedNextTime(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Drive.java")
+	source := "@Config\npublic class Drive {\n    public static double kP = 0.1;\n}\n"
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	live := Values{"Drive.kP": "0.2"}
+	code := FromProject(dir)
+
+	edits, _ := Plan(Compare(live, code, nil).Unsaved, code)
+	if err := Apply(edits); err != nil {
+		t.Fatal(err)
+	}
+
+	after := Compare(live, FromProject(dir), Applied(live, edits))
+	if len(after.Unsaved) != 0 || len(after.Saved) != 1 || after.Saved[0].Was != "0.1" {
+		t.Errorf("after applying: unsaved %v, saved %v", after.Unsaved, after.Saved)
+	}
+}
@@ -0,0 +1,139 @@
+package dash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The dashboard's page has init, start and stop buttons, and they send the
+// same three messages the Driver Station's do: INIT_OP_MODE naming the OpMode,
+// then START_OP_MODE and STOP_OP_MODE for whichever is active. The robot
+// answers through its status rather than a reply, so each step is sent and
+// then waited on until the status shows it happened.
+
+// DefaultOpMode is what the robot reports as active when nothing is: the SDK's
+// placeholder that holds the motors still between OpModes.
+const DefaultOpMode = "$Stop$Robot$"
+
+// StepTimeout is how long the robot has to show a step happened. Init runs the
+// OpMode's own init code, which can take seconds on a robot with cameras.
+const StepTimeout = 15 * time.Second
+
+// ErrTimedOut is Await running out of time, as opposed to losing the robot.
+var ErrTimedOut = errors.New("the robot did not get there")
+
+// Idle reports whether nothing is initialised or running.
+func (s Status) Idle() bool {
+	return s.OpMode == "" || s.OpMode == DefaultOpMode || s.State == "STOPPED"
+}
+
+// Init asks the robot to initialise the named OpMode.
+func (s *Stream) Init(name string) error {
+	message, err := json.Marshal(map[string]string{"type": "INIT_OP_MODE", "opModeName": name})
+	if err != nil {
+		return err
+	}
+	return s.socket.send(string(message))
+}
+
+// Start starts the OpMode that is initialised.
+func (s *Stream) Start() error { return s.socket.send(`{"type":"START_OP_MODE"}`) }
+
+// Stop stops whatever OpMode is active.
+func (s *Stream) Stop() error { return s.socket.send(`{"type":"STOP_OP_MODE"}`) }
+
+// Await reads the stream until the robot's status satisfies done, returning
+// that status. Packets that arrive meanwhile are handed to each, which may be
+// nil. A timeout of zero waits for as long as it takes.
+func (s *Stream) Await(done func(Status) bool, timeout time.Duration, each func(Packet)) (Status, error) {
+	deadline := time.Now().Add(timeout)
+
+	var last Status
+	for timeout <= 0 || time.Now().Before(deadline) {
+		update, err := s.Next()
+		if err != nil {
+			return last, err
+		}
+
+		for _, p := range update.Packets {
+			if each != nil {
+				each(p)
+			}
+		}
+
+		if update.Status == nil {
+			continue
+		}
+		last = *update.Status
+		if done(last) {
+			return last, nil
+		}
+	}
+
+	return last, fmt.Errorf("%w within %s", ErrTimedOut, timeout)
+}
+
+// Match finds the OpMode a person meant out of the names the robot has.
+//
+// Names on a Driver Station are typed by people, with spaces and capitals
+// wherever they fell, and nobody remembers them exactly. So a query matches
+// exactly, then ignoring case, spaces and punctuation, then as the start of a
+// name, then anywhere in one, and finally as letters in order, the way a
+// fuzzy finder does. The first of those that matches anything decides, and
+// more than one match there is ambiguous rather than a guess.
+func Match(names []string, query string) ([]string, error) {
+	want := squash(query)
+	if want == "" {
+		return nil, fmt.Errorf("name an OpMode")
+	}
+
+	tiers := []func(name string) bool{
+		func(name string) bool { return name == query },
+		func(name string) bool { return squash(name) == want },
+		func(name string) bool { return strings.HasPrefix(squash(name), want) },
+		func(name string) bool { return strings.Contains(squash(name), want) },
+		func(name string) bool { return inOrder(squash(name), want) },
+	}
+
+	for _, matches := range tiers {
+		var hits []string
+		for _, name := range names {
+			if matches(name) {
+				hits = append(hits, name)
+			}
+		}
+		if len(hits) > 0 {
+			sort.Strings(hits)
+			return hits, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// squash is a name with only its letters and digits, in lower case.
+func squash(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// inOrder reports whether every letter of want appears in name, in order.
+func inOrder(name, want string) bool {
+	rest := []rune(want)
+	for _, r := range name {
+		if len(rest) > 0 && r == rest[0] {
+			rest = rest[1:]
+		}
+	}
+	return len(rest) == 0
+}
@@ -0,0 +1,64 @@
+package dash
+
+import (
+	"strings"
+	"testing"
+)
+
+var driverStation = []string{"Close Blue", "Close Red", "Far Blue", "TeleOp", "TeleOp Field Centric", "RST TUNING"}
+
+func TestAnOpModeIsFoundTheWayItWasRemembered(t *testing.T) {
+	cases := map[string]string{
+		"Close Blue": "Close Blue",
+		"closeblue":  "Close Blue",
+		"CloseBlue":  "Close Blue",
+		"far":        "Far Blue",
+		"tuning":     "RST TUNING",
+		"tfc":        "TeleOp Field Centric",
+		"TeleOp":     "TeleOp",
+	}
+
+	for query, want := range cases {
+		hits, err := Match(driverStation, query)
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if len(hits) != 1 || hits[0] != want {
+			t.Errorf("%q matched %v, want %s", query, hits, want)
+		}
+	}
+}
+
+// Two close matches are a question for the person, not a coin toss: starting
+// the wrong autonomous drives the robot somewhere nobody expected.
+func TestAnAmbiguousNameIsNotGuessed(t *testing.T) {
+	hits, err := Match(driverStation, "close")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(hits, ",") != "Close Blue,Close Red" {
+		t.Errorf("hits = %v", hits)
+	}
+
+	if hits, _ := Match(driverStation, "xyz"); len(hits) != 0 {
+		t.Errorf("nonsense matched %v", hits)
+	}
+	if _, err := Match(driverStation, " - "); err == nil {
+		t.Error("a query with no letters in it was accepted")
+	}
+}
+
+func TestTheStopRobotPlaceholderIsIdle(t *testing.T) {
+	for _, status := range []Status{
+		{OpMode: DefaultOpMode, State: "RUNNING"},
+		{OpMode: "Close Blue", State: "STOPPED"},
+		{},
+	} {
+		if !status.Idle() {
+			t.Errorf("%+v is not idle", status)
+		}
+	}
+	if (Status{OpMode: "Close Blue", State: "INIT"}).Idle() {
+		t.Error("an initialised OpMode was taken as idle")
+	}
+}
@@ -20,38 +20,38 @@ import (
 
 // Change is one tunable that differs, or used to.
 type Change struct {
-	Key string
+	Key string `json:"key"`
 	// Code is the value the source declares.
-	Code string
+	Code string `json:"code"`
 	// Live is the value the robot holds.
-	Live string
+	Live string `json:"live"`
 	// Was is what the robot held at the last snapshot.
-	Was string
+	Was string `json:"was,omitempty"`
 	// File and Line locate the declaration, empty when the source has no such
 	// field.
-	File string
-	Line int
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
 }
 
 // Diff is what a comparison found.
 type Diff struct {
 	// Unsaved are tuned on the robot and not in the source. These are what a
 	// deploy throws away.
-	Unsaved []Change
+	Unsaved []Change `json:"unsaved"`
 	// Saved were tuned since the last snapshot and now match the source.
-	Saved []Change
+	Saved []Change `json:"saved"`
 	// Untouched is how many agree and always did.
-	Untouched int
+	Untouched int `json:"untouched"`
 	// Computed is how many the source works out rather than states, which
 	// cannot be compared.
-	Computed int
+	Computed int `json:"computed"`
 	// Unknown are held by the robot with no field in the source to match, which
 	// is what a stale build on the robot looks like.
-	Unknown []string
+	Unknown []string `json:"unknown"`
 
 	// Snapshot is when the previous reading was taken, zero when there was not
 	// one.
-	Snapshot time.Time
+	Snapshot time.Time `json:"snapshot"`
 }
 
 // Any reports whether there is anything worth showing.
@@ -61,7 +61,9 @@ func (d Diff) Any() bool { return len(d.Unsaved) > 0 || len(d.Saved) > 0 }
 //
 // previous may be nil, in which case nothing can be reported as saved.
 func Compare(live Values, code Source, previous Values) Diff {
-	var out Diff
+	// Empty rather than nil, so a diff written out as JSON always has its
+	// lists and a reader never has to tell null from none.
+	out := Diff{Unsaved: []Change{}, Saved: []Change{}, Unknown: []string{}}
 
 	for _, key := range live.Names() {
 		value := live[key]
@@ -112,10 +114,11 @@ type snapshot struct {
 
 // SnapshotPath is where a robot's last reading is kept.
 //
-// Per serial, because two robots do not hold the same tuning and reporting one
-// against the other's history would be nonsense.
-func SnapshotPath(dir, serial string) string {
-	safe := strings.NewReplacer("/", "_", ":", "_", "\\", "_", ".", "_").Replace(serial)
+// Per robot, because two robots do not hold the same tuning and reporting one
+// against the other's history would be nonsense. robot is the hub's own serial
+// number, which HistoryDir explains.
+func SnapshotPath(dir, robot string) string {
+	safe := strings.NewReplacer("/", "_", ":", "_", "\\", "_", ".", "_").Replace(robot)
 	if safe == "" {
 		safe = "robot"
 	}
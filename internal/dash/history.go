@@ -0,0 +1,247 @@
+package dash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The reading at SnapshotPath is there for Compare and is overwritten every
+// run, which is right for "what moved since last time" and useless for "what
+// were we running on Saturday". Named snapshots are the second kind: kept
+// until deleted, per robot, next to the rolling reading.
+//
+// Each is written in the same shape as the rolling reading with its name
+// beside it, so any of them can be handed to `pusher dash load` as a file.
+
+// maxAutomatic is how many deploy-time snapshots a robot keeps. They are taken
+// on every deploy, and a season of deploys is thousands of files nobody reads.
+// Named ones are never pruned.
+const maxAutomatic = 30
+
+// Snapshot is a named reading of a robot. Serial is the robot's own serial
+// number, the same over USB and Wi-Fi, and says whose history it is kept in.
+type Snapshot struct {
+	Name   string    `json:"name"`
+	Serial string    `json:"serial"`
+	Taken  time.Time `json:"taken"`
+	// Automatic is set on the ones taken before a deploy.
+	Automatic bool   `json:"automatic,omitempty"`
+	Values    Values `json:"values"`
+}
+
+// Reserved are names that mean something other than a snapshot wherever one
+// is expected.
+var Reserved = []string{"live", "source"}
+
+// HistoryDir is where a robot's named snapshots are kept.
+//
+// robot is the hub's own serial number rather than the adb one. Over Wi-Fi
+// every hub is 192.168.43.1:5555, and keyed by that a team's robots would
+// share one history and restore each other's tuning.
+func HistoryDir(dir, robot string) string {
+	return strings.TrimSuffix(SnapshotPath(dir, robot), ".json")
+}
+
+// Robots is every robot with snapshots kept, as HistoryDir takes them.
+func Robots(dir string) []string {
+	entries, err := os.ReadDir(filepath.Join(dir, "dash"))
+	if err != nil {
+		return nil
+	}
+
+	var out []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			out = append(out, entry.Name())
+		}
+	}
+	return out
+}
+
+// ValidName reports whether name can be used for a snapshot. Names become file
+// names, so they are kept to what every file system takes.
+func ValidName(name string) error {
+	if name == "" {
+		return fmt.Errorf("a snapshot needs a name")
+	}
+	for _, reserved := range Reserved {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("%q means the %s values, so it cannot name a snapshot", name, reserved)
+		}
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.", r)) {
+			return fmt.Errorf("%q: use letters, digits, - _ and . in a snapshot name", name)
+		}
+	}
+	if strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%q: a snapshot name cannot start with a dot", name)
+	}
+	return nil
+}
+
+// Keep stores a named snapshot, replacing one of the same name.
+func Keep(dir string, snap Snapshot) error {
+	if err := ValidName(snap.Name); err != nil {
+		return err
+	}
+
+	history := HistoryDir(dir, snap.Serial)
+	if err := os.MkdirAll(history, 0o755); err != nil {
+		return err
+	}
+
+	if snap.Taken.IsZero() {
+		snap.Taken = time.Now()
+	}
+
+	blob, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(history, snap.Name+".json"), blob, 0o644); err != nil {
+		return err
+	}
+
+	if snap.Automatic {
+		prune(dir, snap.Serial)
+	}
+	return nil
+}
+
+// KeepBeforeDeploy stores the reading taken before a deploy, named after when
+// it was taken, and returns the name.
+//
+// The name goes down to the millisecond. Pusher watch can deploy twice inside
+// a second, and a name to the second would have the second reading replace
+// the first, which is the one holding what the robot ran before either.
+func KeepBeforeDeploy(dir, robot string, values Values) (string, error) {
+	now := time.Now()
+	snap := Snapshot{
+		Name:      "deploy-" + now.Format("20060102-150405.000"),
+		Serial:    robot,
+		Taken:     now,
+		Automatic: true,
+		Values:    values,
+	}
+	return snap.Name, Keep(dir, snap)
+}
+
+// Recall reads back a named snapshot.
+func Recall(dir, robot, name string) (Snapshot, error) {
+	if err := ValidName(name); err != nil {
+		return Snapshot{}, err
+	}
+
+	blob, err := os.ReadFile(filepath.Join(HistoryDir(dir, robot), name+".json"))
+	if os.IsNotExist(err) {
+		return Snapshot{}, fmt.Errorf("no snapshot called %s for this robot", name)
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(blob, &snap); err != nil {
+		return Snapshot{}, fmt.Errorf("snapshot %s is unreadable: %w", name, err)
+	}
+	snap.Name = name
+	return snap, nil
+}
+
+// History is every snapshot kept for a robot, oldest first. One that cannot be
+// read is left out rather than failing the list.
+func History(dir, robot string) []Snapshot {
+	entries, err := os.ReadDir(HistoryDir(dir, robot))
+	if err != nil {
+		return nil
+	}
+
+	var out []Snapshot
+	for _, entry := range entries {
+		name, isJSON := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !isJSON {
+			continue
+		}
+		if snap, err := Recall(dir, robot, name); err == nil {
+			out = append(out, snap)
+		}
+	}
+
+	sort.SliceStable(out, func(a, b int) bool { return out[a].Taken.Before(out[b].Taken) })
+	return out
+}
+
+// Forget deletes a named snapshot.
+func Forget(dir, robot, name string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(HistoryDir(dir, robot), name+".json"))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no snapshot called %s for this robot", name)
+	}
+	return err
+}
+
+// prune drops the oldest automatic snapshots beyond maxAutomatic.
+func prune(dir, robot string) {
+	var automatic []Snapshot
+	for _, snap := range History(dir, robot) {
+		if snap.Automatic {
+			automatic = append(automatic, snap)
+		}
+	}
+
+	for len(automatic) > maxAutomatic {
+		_ = os.Remove(filepath.Join(HistoryDir(dir, robot), automatic[0].Name+".json"))
+		automatic = automatic[1:]
+	}
+}
+
+// Values is what the source declares, as the robot would report it. Computed
+// fields are left out, since the source does not say what they come to.
+func (s Source) Values() Values {
+	out := Values{}
+	for key, field := range s {
+		if !field.Computed {
+			out[key] = field.Value
+		}
+	}
+	return out
+}
+
+// Difference is one key two readings disagree on. From or To is empty when
+// only one of them holds it.
+type Difference struct {
+	Key  string `json:"key"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Differences is every key on which from and to disagree, in key order.
+func Differences(from, to Values) []Difference {
+	keys := map[string]bool{}
+	for key := range from {
+		keys[key] = true
+	}
+	for key := range to {
+		keys[key] = true
+	}
+
+	out := []Difference{}
+	for key := range keys {
+		if from[key] != to[key] {
+			out = append(out, Difference{Key: key, From: from[key], To: to[key]})
+		}
+	}
+
+	sort.Slice(out, func(a, b int) bool { return out[a].Key < out[b].Key })
+	return out
+}
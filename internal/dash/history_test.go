@@ -0,0 +1,175 @@
+package dash
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestASnapshotIsKeptUnderItsName(t *testing.T) {
+	dir := t.TempDir()
+	saturday := time.Date(2026, 10, 10, 14, 0, 0, 0, time.Local)
+
+	if err := Keep(dir, Snapshot{Name: "saturday", Serial: "1c0f2a3b", Taken: saturday,
+		Values: Values{"Lift.kP": "0.035"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := Recall(dir, "1c0f2a3b", "saturday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Values["Lift.kP"] != "0.035" || !snap.Taken.Equal(saturday) {
+		t.Errorf("recalled %+v", snap)
+	}
+
+	if _, err := Recall(dir, "another-robot", "saturday"); err == nil {
+		t.Error("one robot's snapshot was found under another's")
+	}
+}
+
+// The rolling reading is replaced every run, and a named snapshot must not be
+// replaced with it.
+func TestTheRollingReadingLeavesNamedOnesAlone(t *testing.T) {
+	dir := t.TempDir()
+	serial := "1c0f2a3b"
+
+	if err := Keep(dir, Snapshot{Name: "good", Serial: serial, Values: Values{"Lift.kP": "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(SnapshotPath(dir, serial), Values{"Lift.kP": "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := Recall(dir, serial, "good")
+	if err != nil || snap.Values["Lift.kP"] != "1" {
+		t.Errorf("snapshot = %+v, %v", snap, err)
+	}
+	if history := History(dir, serial); len(history) != 1 {
+		t.Errorf("history = %+v, want only the named one", history)
+	}
+}
+
+// Pusher watch can deploy twice in a second, and the second reading must not
+// replace the first.
+func TestDeploysInTheSameSecondKeepBoth(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := KeepBeforeDeploy(dir, "1c0f2a3b", Values{"Lift.kP": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	second, err := KeepBeforeDeploy(dir, "1c0f2a3b", Values{"Lift.kP": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Fatalf("both deploys were kept as %s", first)
+	}
+	if history := History(dir, "1c0f2a3b"); len(history) != 2 {
+		t.Errorf("history = %+v, want both readings", history)
+	}
+}
+
+// With nothing connected, the robots with snapshots are the only guide to
+// which was meant.
+func TestRobotsAreTheOnesWithSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	for _, robot := range []string{"1c0f2a3b", "9e8d7c6b"} {
+		if err := Keep(dir, Snapshot{Name: "good", Serial: robot}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Save(SnapshotPath(dir, "5a4b3c2d"), Values{"Lift.kP": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	robots := Robots(dir)
+	if len(robots) != 2 || robots[0] != "1c0f2a3b" || robots[1] != "9e8d7c6b" {
+		t.Errorf("robots = %v, want the two with snapshots", robots)
+	}
+	if len(History(dir, robots[0])) != 1 {
+		t.Error("a robot Robots named has no history under that name")
+	}
+}
+
+func TestNamesThatMeanSomethingElseAreRefused(t *testing.T) {
+	for _, name := range []string{"", "live", "Source", "../escape", "a/b", ".hidden"} {
+		if ValidName(name) == nil {
+			t.Errorf("%q was accepted as a snapshot name", name)
+		}
+	}
+	for _, name := range []string{"saturday", "worlds-2026", "v1.2_final"} {
+		if err := ValidName(name); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+}
+
+// A deploy keeps one every time, so those are capped. The ones someone named
+// are what they meant to keep.
+func TestOnlyAutomaticSnapshotsArePruned(t *testing.T) {
+	dir := t.TempDir()
+	serial := "robot"
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := Keep(dir, Snapshot{Name: "kept", Serial: serial, Taken: start}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxAutomatic+5; i++ {
+		taken := start.Add(time.Duration(i+1) * time.Minute)
+		err := Keep(dir, Snapshot{
+			Name: "deploy-" + taken.Format("150405"), Serial: serial, Taken: taken, Automatic: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history := History(dir, serial)
+	if len(history) != maxAutomatic+1 {
+		t.Fatalf("%d snapshots kept, want %d", len(history), maxAutomatic+1)
+	}
+	if history[0].Name != "kept" {
+		t.Errorf("oldest = %s, want the named one to survive", history[0].Name)
+	}
+	if history[1].Name != "deploy-000600" {
+		t.Errorf("oldest automatic = %s, want the five before it pruned", history[1].Name)
+	}
+}
+
+func TestDifferencesIncludeWhatOnlyOneSideHolds(t *testing.T) {
+	got := Differences(
+		Values{"A.x": "1", "A.y": "2", "A.gone": "3"},
+		Values{"A.x": "1", "A.y": "5", "A.new": "4"},
+	)
+
+	want := []Difference{
+		{Key: "A.gone", From: "3"},
+		{Key: "A.new", To: "4"},
+		{Key: "A.y", From: "2", To: "5"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// A snapshot is a file `pusher dash load` reads as it is.
+func TestASnapshotLoadsAsAFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := Keep(dir, Snapshot{Name: "good", Serial: "robot", Values: Values{"Lift.kP": "0.035"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := ReadFile(filepath.Join(HistoryDir(dir, "robot"), "good.json"))
+	if err != nil || values["Lift.kP"] != "0.035" {
+		t.Errorf("values = %v, %v", values, err)
+	}
+}
@@ -21,6 +21,11 @@ const Timeout = 6 * time.Second
 type node struct {
 	Type  string          `json:"__type"`
 	Value json.RawMessage `json:"__value"`
+
+	// An enum also carries its class, which the robot needs to turn a name back
+	// into a constant, and the constants it may be set to.
+	EnumClass  string   `json:"__enumClass,omitempty"`
+	EnumValues []string `json:"__enumValues,omitempty"`
 }
 
 // envelope is every message the dashboard sends.
@@ -61,6 +66,16 @@ func (v Values) Names() []string {
 
 // Fetch asks the dashboard at addr for everything it currently holds.
 func Fetch(addr string) (Values, error) {
+	tree, err := FetchTree(addr)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Values(), nil
+}
+
+// FetchTree asks the dashboard at addr for everything it currently holds, with
+// the type of each.
+func FetchTree(addr string) (Tree, error) {
 	socket, err := dial(addr, Timeout)
 	if err != nil {
 		return nil, err
@@ -74,13 +89,25 @@ func Fetch(addr string) (Values, error) {
 		return nil, err
 	}
 
-	// Telemetry, gamepad state and images all arrive on this socket whether or
-	// not anything asked, so the answer has to be waited for rather than simply
-	// read.
+	root, err := socket.config(deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := Tree{}
+	describe("", root, tree)
+	return tree, nil
+}
+
+// config waits for the next RECEIVE_CONFIG.
+//
+// Telemetry, gamepad state and images all arrive on this socket whether or not
+// anything asked, so the answer has to be waited for rather than simply read.
+func (c *conn) config(deadline time.Time) (node, error) {
 	for time.Now().Before(deadline) {
-		message, err := socket.receive()
+		message, err := c.receive()
 		if err != nil {
-			return nil, err
+			return node{}, err
 		}
 
 		var wrapper envelope
@@ -93,25 +120,31 @@ func Fetch(addr string) (Values, error) {
 
 		var root node
 		if err := json.Unmarshal(wrapper.ConfigRoot, &root); err != nil {
-			return nil, fmt.Errorf("cannot read the dashboard's config: %w", err)
+			return node{}, fmt.Errorf("cannot read the dashboard's config: %w", err)
 		}
-
-		values := Values{}
-		flatten("", root, values)
-		return values, nil
+		return root, nil
 	}
 
-	return nil, fmt.Errorf("the dashboard never sent its config")
+	return node{}, fmt.Errorf("the dashboard never sent its config")
 }
 
 // flatten walks the tree into "Class.field" keys.
+func flatten(prefix string, n node, into Values) {
+	tree := Tree{}
+	describe(prefix, n, tree)
+	for key, leaf := range tree {
+		into[key] = leaf.Value
+	}
+}
+
+// describe walks the tree into "Class.field" keys, keeping each leaf's type.
 //
 // Only leaves are recorded. A class with no tunables in it says nothing, and an
 // empty section is not a value anybody wants reported.
-func flatten(prefix string, n node, into Values) {
+func describe(prefix string, n node, into Tree) {
 	if n.Type != "custom" {
 		if text, ok := literal(n); ok && prefix != "" {
-			into[prefix] = text
+			into[prefix] = Leaf{Type: n.Type, Value: text, Choices: n.EnumValues, enumClass: n.EnumClass}
 		}
 		return
 	}
@@ -126,7 +159,7 @@ func flatten(prefix string, n node, into Values) {
 		if prefix != "" {
 			next = prefix + "." + name
 		}
-		flatten(next, child, into)
+		describe(next, child, into)
 	}
 }
 
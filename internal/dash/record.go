@@ -0,0 +1,108 @@
+package dash
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A recording is for reading afterwards in something else: a spreadsheet, a
+// notebook, a plotting script. Which one decides the format, so it follows the
+// file name.
+//
+// CSV is one row per value rather than one column per key. Telemetry keys come
+// and go as an OpMode moves between states, and a header written from the first
+// packet would silently drop every key that appeared later. Pivoting a long
+// table is one line in anything that reads CSV.
+
+// Recorder writes packets to a file as they arrive.
+type Recorder struct {
+	file   *os.File
+	buffer *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+
+	// start is the first packet's time, which CSV counts from.
+	start time.Time
+}
+
+// Record creates path and starts a recording into it, as CSV or JSON Lines by
+// its extension.
+func Record(path string) (*Recorder, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".csv" && ext != ".jsonl" && ext != ".ndjson" {
+		return nil, fmt.Errorf("%s: record to a .csv or a .jsonl file", path)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{file: file, buffer: bufio.NewWriter(file)}
+	if ext == ".csv" {
+		r.csv = csv.NewWriter(r.buffer)
+		if err := r.csv.Write([]string{"time", "seconds", "key", "value"}); err != nil {
+			file.Close()
+			return nil, err
+		}
+	} else {
+		r.json = json.NewEncoder(r.buffer)
+	}
+
+	return r, nil
+}
+
+// Write records one packet.
+func (r *Recorder) Write(p Packet) error {
+	if r.start.IsZero() {
+		r.start = p.Time
+	}
+
+	if r.json != nil {
+		return r.json.Encode(p)
+	}
+
+	stamp := p.Time.UTC().Format(time.RFC3339Nano)
+	seconds := fmt.Sprintf("%.3f", p.Time.Sub(r.start).Seconds())
+
+	keys := make([]string, 0, len(p.Data))
+	for key := range p.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := r.csv.Write([]string{stamp, seconds, key, p.Data[key]}); err != nil {
+			return err
+		}
+	}
+	for _, line := range p.Log {
+		if err := r.csv.Write([]string{stamp, seconds, "", line}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes and closes the file.
+func (r *Recorder) Close() error {
+	if r.csv != nil {
+		r.csv.Flush()
+		if err := r.csv.Error(); err != nil {
+			r.file.Close()
+			return err
+		}
+	}
+	if err := r.buffer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
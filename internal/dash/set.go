@@ -0,0 +1,332 @@
+package dash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Writing to the dashboard is the message its own web page sends when a value
+// is edited: SAVE_CONFIG, carrying a tree shaped like the one GET_CONFIG
+// returns but holding only what changed. The robot walks the tree into the
+// static fields and then sends every client the config it now holds, which is
+// how a write is confirmed rather than assumed.
+//
+// The robot turns each value back into a Java one by the type in the tree, and
+// a value of the wrong shape throws on the robot rather than being refused. So
+// everything is checked against the tree the robot sent before anything is
+// sent back.
+
+// Leaf is one tunable as the dashboard describes it.
+type Leaf struct {
+	// Type is the dashboard's name for it: boolean, int, long, float, double,
+	// string or enum.
+	Type string
+	// Value is what it holds, rendered the way the source would write it.
+	Value string
+	// Choices are the constants an enum may be set to.
+	Choices []string
+
+	enumClass string
+}
+
+// Tree is every tunable the dashboard holds, keyed by "Class.field".
+type Tree map[string]Leaf
+
+// Values is what the tree holds, without the types.
+func (t Tree) Values() Values {
+	out := Values{}
+	for key, leaf := range t {
+		out[key] = leaf.Value
+	}
+	return out
+}
+
+// Setting is one value to write.
+type Setting struct {
+	Key  string `json:"key"`
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	leaf  Leaf
+	value any
+}
+
+// Check works out what writing wanted to the dashboard would change.
+//
+// A value that is already held is left out rather than sent again. Keys the
+// dashboard does not hold come back as unknown, since whether that is fatal
+// depends on where they came from: a typed name is a typo, a key in an old
+// snapshot is a field since renamed. Every value of the wrong type is reported
+// at once, and none of them are sent.
+func Check(tree Tree, wanted Values) ([]Setting, []string, error) {
+	var settings []Setting
+	var unknown, problems []string
+
+	for _, key := range wanted.Names() {
+		leaf, found := tree[key]
+		if !found {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		text, value, err := convert(leaf, wanted[key])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+
+		if text == leaf.Value {
+			continue
+		}
+
+		settings = append(settings, Setting{Key: key, From: leaf.Value, To: text, leaf: leaf, value: value})
+	}
+
+	if len(problems) > 0 {
+		return nil, unknown, fmt.Errorf("nothing was sent:\n    %s", strings.Join(problems, "\n    "))
+	}
+
+	return settings, unknown, nil
+}
+
+// convert reads text as a value of the leaf's type, returning it rendered the
+// way Values holds it and as it goes over the wire.
+//
+// Text is accepted the way Java would write it, suffixes and all, so a value
+// copied out of the source or out of a snapshot goes straight in.
+func convert(leaf Leaf, text string) (string, any, error) {
+	text = strings.TrimSpace(text)
+
+	switch leaf.Type {
+	case "boolean":
+		switch strings.ToLower(text) {
+		case "true":
+			return "true", true, nil
+		case "false":
+			return "false", false, nil
+		}
+		return "", nil, fmt.Errorf("is a boolean, and %s is not true or false", text)
+
+	case "int", "long":
+		bits, kind := 32, "an int"
+		if leaf.Type == "long" {
+			bits, kind = 64, "a long"
+		}
+		value, err := strconv.ParseInt(Number(text), 10, bits)
+		if err != nil {
+			return "", nil, fmt.Errorf("is %s, and %s is not one", kind, text)
+		}
+		return strconv.FormatInt(value, 10), value, nil
+
+	case "float", "double":
+		bits := 64
+		if leaf.Type == "float" {
+			bits = 32
+		}
+		value, err := strconv.ParseFloat(Number(text), bits)
+		if err != nil {
+			return "", nil, fmt.Errorf("is a %s, and %s is not a number", leaf.Type, text)
+		}
+		// A float is written at its own width, the way the robot writes it
+		// back. At 64 bits, 0.1 as a float is 0.10000000149011612, which
+		// matches nothing the dashboard holds.
+		shortest := Number(strconv.FormatFloat(value, 'f', -1, bits))
+		value, _ = strconv.ParseFloat(shortest, 64)
+		return shortest, value, nil
+
+	case "string":
+		// Quoted is how Values holds a string, and bare is how a person types
+		// one on a command line. Both mean the same thing.
+		value := text
+		if strings.HasPrefix(text, `"`) {
+			unquoted, err := strconv.Unquote(text)
+			if err != nil {
+				return "", nil, fmt.Errorf("%s is not a complete string", text)
+			}
+			value = unquoted
+		}
+		return strconv.Quote(value), value, nil
+
+	case "enum":
+		// The source may qualify a constant with its class. The robot wants the
+		// bare name.
+		name := text
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		if len(leaf.Choices) > 0 && !contains(leaf.Choices, name) {
+			return "", nil, fmt.Errorf("is one of %s, not %s", strings.Join(leaf.Choices, ", "), text)
+		}
+		return name, name, nil
+	}
+
+	return "", nil, fmt.Errorf("is a %s, which cannot be set from here", leaf.Type)
+}
+
+func contains(list []string, want string) bool {
+	for _, item := range list {
+		if item == want {
+			return true
+		}
+	}
+	return false
+}
+
+// saveMessage is the SAVE_CONFIG that writes settings.
+func saveMessage(settings []Setting) (string, error) {
+	root := map[string]any{}
+
+	for _, setting := range settings {
+		parts := strings.Split(setting.Key, ".")
+
+		children := root
+		for _, part := range parts[:len(parts)-1] {
+			section, found := children[part].(map[string]any)
+			if !found {
+				section = map[string]any{"__type": "custom", "__value": map[string]any{}}
+				children[part] = section
+			}
+			children = section["__value"].(map[string]any)
+		}
+
+		leaf := map[string]any{"__type": setting.leaf.Type, "__value": setting.value}
+		if setting.leaf.enumClass != "" {
+			leaf["__enumClass"] = setting.leaf.enumClass
+		}
+		children[parts[len(parts)-1]] = leaf
+	}
+
+	blob, err := json.Marshal(map[string]any{
+		"type":       "SAVE_CONFIG",
+		"configDiff": map[string]any{"__type": "custom", "__value": root},
+	})
+	return string(blob), err
+}
+
+// Send writes settings to the dashboard at addr and waits for it to say it
+// holds them.
+//
+// The dashboard sends its config to a client as soon as it connects, so the
+// first one to arrive may predate the write. Answers are read until one agrees
+// or the time runs out, and what disagreed is what gets reported.
+func Send(addr string, settings []Setting) error {
+	if len(settings) == 0 {
+		return nil
+	}
+
+	message, err := saveMessage(settings)
+	if err != nil {
+		return err
+	}
+
+	socket, err := dial(addr, Timeout)
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+
+	deadline := time.Now().Add(Timeout)
+	socket.deadline(deadline)
+
+	if err := socket.send(message); err != nil {
+		return err
+	}
+
+	var missed []string
+	for {
+		root, err := socket.config(deadline)
+		if err != nil {
+			if missed == nil {
+				return fmt.Errorf("sent, but the dashboard never confirmed it: %w", err)
+			}
+			return fmt.Errorf("the dashboard did not take %s", strings.Join(missed, ", "))
+		}
+
+		held := Values{}
+		flatten("", root, held)
+
+		missed = missed[:0]
+		for _, setting := range settings {
+			if held[setting.Key] != setting.To {
+				missed = append(missed, setting.Key)
+			}
+		}
+		if len(missed) == 0 {
+			return nil
+		}
+	}
+}
+
+// ReadTree opens a route to the connected robot and fetches what the
+// dashboard holds, with types.
+func ReadTree(serial string) (Tree, error) {
+	route, err := Open(serial)
+	if err != nil {
+		return nil, err
+	}
+	defer route.Close()
+
+	tree, err := FetchTree(route.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w\n    Is FtcDashboard running? It needs the robot app started", err)
+	}
+	return tree, nil
+}
+
+// Write opens a route to the connected robot and sends it settings.
+func Write(serial string, settings []Setting) error {
+	route, err := Open(serial)
+	if err != nil {
+		return err
+	}
+	defer route.Close()
+
+	return Send(route.Addr, settings)
+}
+
+// ReadFile reads a set of values to load.
+//
+// Either a reading pusher recorded, or a plain object of "Class.field" to
+// value, which is what a person writes by hand. In the plain form a value may
+// be a JSON number, boolean or string, and a string holds the value as it
+// would be typed.
+func ReadFile(path string) (Values, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var recorded snapshot
+	if json.Unmarshal(blob, &recorded) == nil && recorded.Values != nil {
+		return recorded.Values, nil
+	}
+
+	var plain map[string]any
+	if err := json.Unmarshal(blob, &plain); err != nil {
+		return nil, fmt.Errorf("%s is not a set of values: %w", path, err)
+	}
+
+	out := Values{}
+	for key, raw := range plain {
+		switch value := raw.(type) {
+		case string:
+			out[key] = value
+		case bool:
+			out[key] = strconv.FormatBool(value)
+		case float64:
+			out[key] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("%s: %s is not a single value", path, key)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s holds no values", path)
+	}
+
+	return out, nil
+}
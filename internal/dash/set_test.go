@@ -0,0 +1,304 @@
+package dash
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tuningTree() Tree {
+	return Tree{
+		"Lift.kP":      {Type: "double", Value: "0.02"},
+		"Lift.ticks":   {Type: "int", Value: "500"},
+		"Lift.enabled": {Type: "boolean", Value: "true"},
+		"Lift.label":   {Type: "string", Value: `"arm"`},
+		"Lift.mode": {Type: "enum", Value: "SLOW", Choices: []string{"SLOW", "FAST"},
+			enumClass: "org.firstinspires.ftc.teamcode.Lift$Mode"},
+	}
+}
+
+// The robot turns each value into a Java one by its type and throws on one that
+// does not fit, so the wrong shape has to be caught here instead.
+func TestValuesAreCheckedAgainstTheirType(t *testing.T) {
+	bad := []Values{
+		{"Lift.ticks": "1.5"},
+		{"Lift.ticks": "9999999999"},
+		{"Lift.enabled": "yes"},
+		{"Lift.kP": "fast"},
+		{"Lift.mode": "MEDIUM"},
+		{"Lift.label": `"unfinished`},
+	}
+	for _, wanted := range bad {
+		if _, _, err := Check(tuningTree(), wanted); err == nil {
+			t.Errorf("%v was accepted", wanted)
+		}
+	}
+
+	settings, _, err := Check(tuningTree(), Values{
+		"Lift.kP":      "0.035d",
+		"Lift.ticks":   "1_000",
+		"Lift.enabled": "FALSE",
+		"Lift.label":   "claw",
+		"Lift.mode":    "Mode.FAST",
+	})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	want := map[string]string{
+		"Lift.kP": "0.035", "Lift.ticks": "1000", "Lift.enabled": "false",
+		"Lift.label": `"claw"`, "Lift.mode": "FAST",
+	}
+	if len(settings) != len(want) {
+		t.Fatalf("settings = %+v", settings)
+	}
+	for _, setting := range settings {
+		if setting.To != want[setting.Key] {
+			t.Errorf("%s -> %q, want %q", setting.Key, setting.To, want[setting.Key])
+		}
+	}
+}
+
+// A float is compared and sent at its own width. Widened to a double, 0.1 is
+// 0.10000000149011612, which the robot never writes back.
+func TestAFloatIsHeldAtItsOwnWidth(t *testing.T) {
+	tree := Tree{"Arm.gain": {Type: "float", Value: "0.1"}}
+
+	settings, _, err := Check(tree, Values{"Arm.gain": "0.1f"})
+	if err != nil || len(settings) != 0 {
+		t.Errorf("a float already held was sent again: %+v, %v", settings, err)
+	}
+
+	settings, _, err = Check(tree, Values{"Arm.gain": "0.3f"})
+	if err != nil || len(settings) != 1 {
+		t.Fatalf("settings = %+v, %v", settings, err)
+	}
+	if settings[0].To != "0.3" || settings[0].value != 0.3 {
+		t.Errorf("0.3f -> %q and %v, want 0.3", settings[0].To, settings[0].value)
+	}
+
+	if _, _, err := Check(tree, Values{"Arm.gain": "1e39"}); err == nil {
+		t.Error("a value too big for a float was accepted")
+	}
+}
+
+// Every problem is reported at once, so fixing a snapshot is one edit rather
+// than one run per mistake.
+func TestEveryBadValueIsReportedTogether(t *testing.T) {
+	_, _, err := Check(tuningTree(), Values{"Lift.ticks": "x", "Lift.kP": "y"})
+	if err == nil || !strings.Contains(err.Error(), "Lift.ticks") || !strings.Contains(err.Error(), "Lift.kP") {
+		t.Errorf("err = %v, want both keys named", err)
+	}
+}
+
+func TestOnlyWhatDiffersIsSent(t *testing.T) {
+	settings, unknown, err := Check(tuningTree(), Values{
+		"Lift.kP":   "0.020",
+		"Lift.mode": "FAST",
+		"Gone.kD":   "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(settings) != 1 || settings[0].Key != "Lift.mode" || settings[0].From != "SLOW" {
+		t.Errorf("settings = %+v, want only Lift.mode", settings)
+	}
+	if len(unknown) != 1 || unknown[0] != "Gone.kD" {
+		t.Errorf("unknown = %v", unknown)
+	}
+}
+
+// The save is the tree GET_CONFIG returns with only the changes in it, and an
+// enum names its class, which is how the robot finds the constant.
+func TestASaveIsTheTreeTheDashboardReads(t *testing.T) {
+	settings, _, err := Check(tuningTree(), Values{"Lift.kP": "0.035", "Lift.mode": "FAST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := saveMessage(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wrapper struct {
+		Type       string `json:"type"`
+		ConfigDiff node   `json:"configDiff"`
+	}
+	if err := json.Unmarshal([]byte(message), &wrapper); err != nil {
+		t.Fatal(err)
+	}
+	if wrapper.Type != "SAVE_CONFIG" {
+		t.Errorf("type = %q", wrapper.Type)
+	}
+
+	sent := Tree{}
+	describe("", wrapper.ConfigDiff, sent)
+
+	if len(sent) != 2 || sent["Lift.kP"].Value != "0.035" || sent["Lift.mode"].Value != "FAST" {
+		t.Errorf("sent %+v", sent)
+	}
+	if sent["Lift.mode"].enumClass != "org.firstinspires.ftc.teamcode.Lift$Mode" {
+		t.Errorf("the enum went without its class: %s", message)
+	}
+}
+
+// savingDashboard accepts one connection, volunteers the config it held before
+// as the real one does on connect, reads the save and answers with after.
+func savingDashboard(t *testing.T, before, after string) (string, <-chan string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+
+	go func() {
+		socket, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer socket.Close()
+
+		read := bufio.NewReader(socket)
+		request, err := http.ReadRequest(read)
+		if err != nil {
+			return
+		}
+
+		sum := sha1.Sum([]byte(request.Header.Get("Sec-WebSocket-Key") + magic))
+		io.WriteString(socket, "HTTP/1.1 101 Switching Protocols\r\n"+
+			"Upgrade: websocket\r\n"+
+			"Connection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: "+base64.StdEncoding.EncodeToString(sum[:])+"\r\n\r\n")
+
+		socket.Write(textFrame(before))
+
+		message, err := clientFrame(read)
+		if err != nil {
+			return
+		}
+		received <- message
+
+		socket.Write(textFrame(after))
+		time.Sleep(2 * time.Second)
+	}()
+
+	return listener.Addr().String(), received
+}
+
+// clientFrame reads one masked frame, which is what a client sends.
+func clientFrame(read *bufio.Reader) (string, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(read, head[:]); err != nil {
+		return "", err
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(read, ext[:]); err != nil {
+			return "", err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(read, ext[:]); err != nil {
+			return "", err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(read, mask[:]); err != nil {
+		return "", err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(read, payload); err != nil {
+		return "", err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return string(payload), nil
+}
+
+func configMessage(kP string) string {
+	return `{"type":"RECEIVE_CONFIG","configRoot":{"__type":"custom","__value":` +
+		`{"Lift":{"__type":"custom","__value":{"kP":{"__type":"double","__value":` + kP + `}}}}}}`
+}
+
+// The config the dashboard volunteers on connect predates the write, and
+// taking it as the answer would report every write as refused.
+func TestAWriteIsConfirmedByTheConfigThatFollowsIt(t *testing.T) {
+	addr, received := savingDashboard(t, configMessage("0.02"), configMessage("0.035"))
+
+	settings, _, err := Check(tuningTree(), Values{"Lift.kP": "0.035"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Send(addr, settings); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if message := <-received; !strings.Contains(message, `"SAVE_CONFIG"`) {
+		t.Errorf("the dashboard was sent %s", message)
+	}
+}
+
+func TestAWriteTheRobotDidNotTakeIsAnError(t *testing.T) {
+	addr, _ := savingDashboard(t, configMessage("0.02"), configMessage("0.02"))
+
+	settings, _, err := Check(tuningTree(), Values{"Lift.kP": "0.035"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Send(addr, settings)
+	if err == nil || !strings.Contains(err.Error(), "Lift.kP") {
+		t.Errorf("err = %v, want Lift.kP reported as not taken", err)
+	}
+}
+
+// A reading pusher recorded and an object written by hand both load.
+func TestAFileLoadsInEitherShape(t *testing.T) {
+	dir := t.TempDir()
+
+	recorded := filepath.Join(dir, "recorded.json")
+	if err := Save(recorded, Values{"Lift.kP": "0.035", "Lift.label": `"arm"`}); err != nil {
+		t.Fatal(err)
+	}
+
+	plain := filepath.Join(dir, "plain.json")
+	if err := os.WriteFile(plain, []byte(`{"Lift.kP": 0.035, "Lift.enabled": false, "Lift.mode": "FAST"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadFile(recorded)
+	if err != nil || got["Lift.kP"] != "0.035" || got["Lift.label"] != `"arm"` {
+		t.Errorf("recorded: %v, %v", got, err)
+	}
+
+	got, err = ReadFile(plain)
+	if err != nil || got["Lift.kP"] != "0.035" || got["Lift.enabled"] != "false" || got["Lift.mode"] != "FAST" {
+		t.Errorf("plain: %v, %v", got, err)
+	}
+}
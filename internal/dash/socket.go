@@ -11,6 +11,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -28,6 +29,10 @@ const magic = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
 type conn struct {
 	net  net.Conn
 	read *bufio.Reader
+
+	// writing keeps frames whole when a stream sends from one goroutine while
+	// answering pings from another.
+	writing sync.Mutex
 }
 
 // dial opens a WebSocket to the dashboard at addr, which is host:port.
@@ -123,6 +128,9 @@ func (c *conn) send(payload string) error {
 		masked[i] = b ^ mask[i%4]
 	}
 
+	c.writing.Lock()
+	defer c.writing.Unlock()
+
 	if _, err := c.net.Write(append(header, masked...)); err != nil {
 		return fmt.Errorf("cannot ask the dashboard: %w", err)
 	}
@@ -184,6 +192,9 @@ func (c *conn) control(opcode byte, payload []byte) error {
 		frame = append(frame, b^mask[i%4])
 	}
 
+	c.writing.Lock()
+	defer c.writing.Unlock()
+
 	_, err := c.net.Write(frame)
 	return err
 }
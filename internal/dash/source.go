@@ -46,6 +46,11 @@ type Field struct {
 	File string
 	// Line is the 1-indexed line of the declaration.
 	Line int
+	// Initialiser is the text after the = exactly as the file has it, and
+	// Offset is where in the file it starts. Together they are what rewriting
+	// it in place needs, and what tells a file edited since it was read.
+	Initialiser string
+	Offset      int
 }
 
 // Key is how this field is addressed in the dashboard.
@@ -136,7 +141,8 @@ func FromFile(path, content string) []Field {
 		line := 1 + strings.Count(content[:head[1]]+rest[:at[0]], "\n")
 
 		for _, part := range declarators(masked[at[2]:at[3]]) {
-			name, value, found := strings.Cut(rest[at[2]+part[0]:at[2]+part[1]], "=")
+			text := rest[at[2]+part[0] : at[2]+part[1]]
+			name, value, found := strings.Cut(text, "=")
 			if !found {
 				continue
 			}
@@ -146,13 +152,18 @@ func FromFile(path, content string) []Field {
 				continue
 			}
 
+			trimmed := strings.TrimSpace(value)
+			lead := len(value) - len(strings.TrimLeft(value, " \t\r\n"))
+
 			out = append(out, Field{
-				Section:  section,
-				Name:     name,
-				Value:    Normalise(strings.TrimSpace(value)),
-				Computed: !isLiteral(strings.TrimSpace(value)),
-				File:     path,
-				Line:     line,
+				Section:     section,
+				Name:        name,
+				Value:       Normalise(trimmed),
+				Computed:    !isLiteral(trimmed),
+				File:        path,
+				Line:        line,
+				Initialiser: trimmed,
+				Offset:      head[1] + at[2] + part[0] + len(text) - len(value) + lead,
 			})
 		}
 	}
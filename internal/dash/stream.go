@@ -0,0 +1,201 @@
+package dash
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Telemetry is not asked for. While an OpMode runs, the dashboard sends every
+// connected client each packet the OpMode sends it, so watching is connecting
+// and then reading for as long as anyone wants.
+//
+// The one thing a watcher does send is GET_ROBOT_STATUS, once a second, which
+// is what the dashboard's own page does. The answer says which OpMode is
+// loaded and whether it is running, and a socket that has heard nothing for a
+// while has lost the robot rather than found a quiet OpMode.
+
+// heartbeat is how often the robot is asked for its status.
+const heartbeat = time.Second
+
+// Packet is one telemetry packet.
+type Packet struct {
+	// Time is when the robot sent it.
+	Time time.Time `json:"time"`
+	// Data is every telemetry line, by caption. The robot sends them as text
+	// whatever they were put as.
+	Data map[string]string `json:"data"`
+	// Log is the lines added with addLine, in order.
+	Log []string `json:"log,omitempty"`
+}
+
+// packet is how a packet arrives.
+type packet struct {
+	Timestamp int64                      `json:"timestamp"`
+	Data      map[string]json.RawMessage `json:"data"`
+	Log       []string                   `json:"log"`
+}
+
+// Status is what the robot says it is doing.
+type Status struct {
+	Available bool   `json:"available"`
+	OpMode    string `json:"activeOpMode"`
+	// State is INIT, RUNNING or STOPPED.
+	State   string `json:"activeOpModeStatus"`
+	Warning string `json:"warningMessage"`
+	Error   string `json:"errorMessage"`
+}
+
+// Update is what one message from the robot carried: packets or a status.
+type Update struct {
+	Packets []Packet
+	Status  *Status
+}
+
+// streamed is the part of a message a stream reads.
+//
+// Telemetry has arrived as a list of packets since the dashboard started
+// batching them, and as a single packet before that. Both are read.
+type streamed struct {
+	Type      string          `json:"type"`
+	Telemetry json.RawMessage `json:"telemetry"`
+	Status    *Status         `json:"status"`
+}
+
+// Stream is an open connection that stays open.
+type Stream struct {
+	socket *conn
+	route  Reach
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// Listen opens a stream to the dashboard at addr.
+func Listen(addr string) (*Stream, error) {
+	socket, err := dial(addr, Timeout)
+	if err != nil {
+		return nil, err
+	}
+	socket.deadline(time.Time{})
+
+	s := &Stream{socket: socket, stop: make(chan struct{})}
+	go s.beat()
+	return s, nil
+}
+
+// Watch opens a route to the connected robot and a stream over it.
+func Watch(serial string) (*Stream, error) {
+	route, err := Open(serial)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := Listen(route.Addr)
+	if err != nil {
+		route.Close()
+		return nil, err
+	}
+	s.route = route
+	return s, nil
+}
+
+// beat asks for the robot's status until the stream closes.
+func (s *Stream) beat() {
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		if s.socket.send(`{"type":"GET_ROBOT_STATUS"}`) != nil {
+			return
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Next waits for the next packets or status.
+//
+// Everything else on the socket is read past. Silence for longer than Timeout
+// is an error, since the robot answers the heartbeat whether or not anything
+// is running.
+func (s *Stream) Next() (Update, error) {
+	for {
+		s.socket.net.SetReadDeadline(time.Now().Add(Timeout))
+
+		message, err := s.socket.receive()
+		if err != nil {
+			return Update{}, err
+		}
+
+		update, ok := parseUpdate(message)
+		if ok {
+			return update, nil
+		}
+	}
+}
+
+// Close ends the stream.
+func (s *Stream) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		s.socket.Close()
+		s.route.Close()
+	})
+}
+
+// parseUpdate reads one message, reporting whether it was one a stream wants.
+func parseUpdate(message string) (Update, bool) {
+	var wrapper streamed
+	if json.Unmarshal([]byte(message), &wrapper) != nil {
+		return Update{}, false
+	}
+
+	switch wrapper.Type {
+	case "RECEIVE_ROBOT_STATUS":
+		if wrapper.Status == nil {
+			return Update{}, false
+		}
+		return Update{Status: wrapper.Status}, true
+
+	case "RECEIVE_TELEMETRY":
+		var batch []packet
+		if json.Unmarshal(wrapper.Telemetry, &batch) != nil {
+			var one packet
+			if json.Unmarshal(wrapper.Telemetry, &one) != nil {
+				return Update{}, false
+			}
+			batch = []packet{one}
+		}
+
+		update := Update{Packets: make([]Packet, 0, len(batch))}
+		for _, p := range batch {
+			update.Packets = append(update.Packets, Packet{
+				Time: time.UnixMilli(p.Timestamp),
+				Data: text(p.Data),
+				Log:  p.Log,
+			})
+		}
+		return update, true
+	}
+
+	return Update{}, false
+}
+
+// text renders packet data as the text it is shown as. The SDK puts every value
+// through toString, but a number sent as one is kept as written rather than
+// dropping the whole packet.
+func text(data map[string]json.RawMessage) map[string]string {
+	out := make(map[string]string, len(data))
+	for key, raw := range data {
+		var value string
+		if json.Unmarshal(raw, &value) != nil {
+			value = string(raw)
+		}
+		out[key] = value
+	}
+	return out
+}
@@ -0,0 +1,134 @@
+package dash
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelemetryIsReadOffAnOpenStream(t *testing.T) {
+	addr := fakeDashboard(t,
+		`{"type":"RECEIVE_CONFIG","configRoot":{"__type":"custom","__value":{}}}`,
+		`{"type":"RECEIVE_ROBOT_STATUS","status":{"available":true,"activeOpMode":"Lift Test","activeOpModeStatus":"RUNNING"}}`,
+		`{"type":"RECEIVE_TELEMETRY","telemetry":[{"timestamp":1700000000000,"data":{"height":"12.5"},"log":["go"]},`+
+			`{"timestamp":1700000000050,"data":{"height":"13.0"},"log":[]}]}`,
+	)
+
+	stream, err := Listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	first, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status == nil || first.Status.OpMode != "Lift Test" || first.Status.State != "RUNNING" {
+		t.Errorf("status = %+v", first.Status)
+	}
+
+	second, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Packets) != 2 || second.Packets[1].Data["height"] != "13.0" || second.Packets[0].Log[0] != "go" {
+		t.Errorf("packets = %+v", second.Packets)
+	}
+	if !second.Packets[0].Time.Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("time = %s", second.Packets[0].Time)
+	}
+}
+
+// Older dashboards sent one packet rather than a list, and a number put as a
+// number rather than as text must not cost the whole packet.
+func TestASinglePacketAndARawNumberAreRead(t *testing.T) {
+	update, ok := parseUpdate(`{"type":"RECEIVE_TELEMETRY","telemetry":{"timestamp":5,"data":{"x":"1","y":2.5}}}`)
+	if !ok || len(update.Packets) != 1 {
+		t.Fatalf("update = %+v, %v", update, ok)
+	}
+	if data := update.Packets[0].Data; data["x"] != "1" || data["y"] != "2.5" {
+		t.Errorf("data = %v", data)
+	}
+}
+
+func TestARecordingFollowsItsFileName(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	packets := []Packet{
+		{Time: start, Data: map[string]string{"b": "2", "a": "1"}},
+		{Time: start.Add(1500 * time.Millisecond), Data: map[string]string{"a": "3", "new": "x"}, Log: []string{"done"}},
+	}
+
+	if _, err := Record(filepath.Join(dir, "run.txt")); err == nil {
+		t.Error("a recording to a .txt file was started")
+	}
+
+	for _, name := range []string{"run.csv", "run.jsonl"} {
+		path := filepath.Join(dir, name)
+		recorder, err := Record(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range packets {
+			if err := recorder.Write(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(filepath.Join(dir, "run.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One row per value, so the key that only arrived later is still there.
+	want := [][]string{
+		{"time", "seconds", "key", "value"},
+		{"2026-10-17T09:00:00Z", "0.000", "a", "1"},
+		{"2026-10-17T09:00:00Z", "0.000", "b", "2"},
+		{"2026-10-17T09:00:01.5Z", "1.500", "a", "3"},
+		{"2026-10-17T09:00:01.5Z", "1.500", "new", "x"},
+		{"2026-10-17T09:00:01.5Z", "1.500", "", "done"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("rows = %v", rows)
+	}
+	for i := range want {
+		if strings.Join(rows[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("row %d = %v, want %v", i, rows[i], want[i])
+		}
+	}
+
+	lines, err := os.Open(filepath.Join(dir, "run.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lines.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(lines)
+	for scanner.Scan() {
+		var p Packet
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			t.Fatalf("line %d: %v", count+1, err)
+		}
+		count++
+	}
+	if count != len(packets) {
+		t.Errorf("%d lines, want one per packet", count)
+	}
+}
@@ -0,0 +1,206 @@
+package deployed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A deploy that breaks the robot at a competition has to be undone in the
+// minute before the next match, and rebuilding the last good commit takes
+// longer than that even when someone remembers which commit it was. So every
+// APK pusher installs is kept here, with the commit it was built from, and
+// putting one back is an install of a file already on disk.
+//
+// Each robot has its own list, since two robots are rarely on the same build,
+// but an APK is stored once however many robots were given it. The same copies
+// are what a patch transfer diffs against.
+//
+// A robot is named by its own serial number rather than by adb's. Every
+// Control Hub is 192.168.43.1:5555 on its own Wi-Fi, and keyed by that, a team
+// with two robots would roll one back to the other's build.
+
+// Keep is how many builds each robot's history holds. Five is a morning of
+// deploys, which is as far back as anyone rolls back at an event.
+const Keep = 5
+
+// Build is one APK a robot was given.
+type Build struct {
+	Fingerprint string    `json:"fingerprint"`
+	Commit      string    `json:"commit,omitempty"`
+	Dirty       bool      `json:"dirty,omitempty"`
+	Size        int64     `json:"size"`
+	Installed   time.Time `json:"installed"`
+
+	// Reloaded is set when the APK carried no team code, because Pusher
+	// Extreme reloaded it separately. Putting such an APK back does not put
+	// back the team code it ran with.
+	Reloaded bool `json:"reloaded,omitempty"`
+
+	// RolledBack is set on an entry made by putting an older build back.
+	RolledBack bool `json:"rolled_back,omitempty"`
+}
+
+// Label is the build in a few words for a list.
+func (b Build) Label() string {
+	commit := b.Commit
+	if commit == "" {
+		commit = "no commit"
+	} else if b.Dirty {
+		commit += " with uncommitted changes"
+	}
+	return fmt.Sprintf("%s, %s", commit, b.Installed.Format("Mon 15:04"))
+}
+
+func robotPath(dir, robot string) string {
+	safe := strings.NewReplacer("/", "_", ":", "_", "\\", "_", ".", "_").Replace(robot)
+	if safe == "" {
+		safe = "robot"
+	}
+	return filepath.Join(dir, "deployed", safe+".json")
+}
+
+func apkDir(dir string) string { return filepath.Join(dir, "deployed", "apks") }
+
+// APK is where the APK with this fingerprint is kept, false when it is not.
+func APK(dir, fingerprint string) (string, bool) {
+	if fingerprint == "" {
+		return "", false
+	}
+	path := filepath.Join(apkDir(dir), fingerprint+".apk")
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// List is a robot's history, the most recent install first. robot is its own
+// serial number, as robotcfg.RobotID reads it. A history that cannot be read
+// is an empty one.
+func List(dir, robot string) []Build {
+	blob, err := os.ReadFile(robotPath(dir, robot))
+	if err != nil {
+		return nil
+	}
+	var builds []Build
+	if json.Unmarshal(blob, &builds) != nil {
+		return nil
+	}
+	return builds
+}
+
+// Record adds an install to a robot's history and keeps a copy of its APK.
+// Reinstalling the build the robot already had moves it to the top rather
+// than listing it twice. A rollback only marks the build where it is, so the
+// list stays in the order builds were deployed and the next rollback counts
+// back from the right place. Builds that fall off the end of every robot's
+// history have their APKs deleted.
+func Record(dir, robot, apkPath string, build Build) error {
+	if build.Installed.IsZero() {
+		build.Installed = time.Now()
+	}
+
+	if _, ok := APK(dir, build.Fingerprint); !ok {
+		size, err := copyAPK(apkPath, filepath.Join(apkDir(dir), build.Fingerprint+".apk"))
+		if err != nil {
+			return fmt.Errorf("cannot keep the APK: %w", err)
+		}
+		build.Size = size
+	} else if build.Size == 0 {
+		if info, err := os.Stat(apkPath); err == nil {
+			build.Size = info.Size()
+		}
+	}
+
+	previous := List(dir, robot)
+	builds := []Build{build}
+	for _, old := range previous {
+		if old.Fingerprint != build.Fingerprint && len(builds) < Keep {
+			builds = append(builds, old)
+		}
+	}
+	if at := Current(previous, build.Fingerprint); build.RolledBack && at >= 0 {
+		previous[at].RolledBack = true
+		builds = previous
+	}
+
+	blob, err := json.MarshalIndent(builds, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(robotPath(dir, robot), blob, 0o644); err != nil {
+		return err
+	}
+
+	collect(dir)
+	return nil
+}
+
+// Current is where in builds the one with this fingerprint is, -1 when it is
+// not there. It is what a rollback counts back from, since after one rollback
+// the newest build is no longer the one the robot runs.
+func Current(builds []Build, fingerprint string) int {
+	for i, b := range builds {
+		if fingerprint != "" && b.Fingerprint == fingerprint {
+			return i
+		}
+	}
+	return -1
+}
+
+func copyAPK(from, to string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return 0, err
+	}
+
+	in, err := os.Open(from)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	// Written aside and renamed, so a copy cut short is never taken for the
+	// APK it was a copy of.
+	tmp := to + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, to)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return n, nil
+}
+
+// collect deletes kept APKs no robot's history names.
+func collect(dir string) {
+	wanted := map[string]bool{}
+	histories, _ := filepath.Glob(filepath.Join(dir, "deployed", "*.json"))
+	for _, path := range histories {
+		var builds []Build
+		if blob, err := os.ReadFile(path); err == nil && json.Unmarshal(blob, &builds) == nil {
+			for _, b := range builds {
+				wanted[b.Fingerprint] = true
+			}
+		}
+	}
+
+	apks, _ := filepath.Glob(filepath.Join(apkDir(dir), "*.apk"))
+	for _, path := range apks {
+		if !wanted[strings.TrimSuffix(filepath.Base(path), ".apk")] {
+			os.Remove(path)
+		}
+	}
+}
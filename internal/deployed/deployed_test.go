@@ -0,0 +1,119 @@
+package deployed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeAPK(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "TeamCode-debug.apk")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTheLatestInstallComesFirst(t *testing.T) {
+	dir := t.TempDir()
+	robot := "1c0f2a3b"
+
+	for i, commit := range []string{"a1", "b2", "c3"} {
+		apk := writeAPK(t, "apk "+commit)
+		at := time.Date(2026, 10, 10, 9, i, 0, 0, time.Local)
+		if err := Record(dir, robot, apk, Build{Fingerprint: "fp-" + commit, Commit: commit, Installed: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	builds := List(dir, robot)
+	if len(builds) != 3 || builds[0].Commit != "c3" || builds[2].Commit != "a1" {
+		t.Fatalf("got %+v", builds)
+	}
+	if builds[0].Size != int64(len("apk c3")) {
+		t.Errorf("size recorded as %d", builds[0].Size)
+	}
+
+	path, ok := APK(dir, "fp-a1")
+	if !ok {
+		t.Fatal("the oldest build's APK is gone")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "apk a1" {
+		t.Errorf("kept %q", got)
+	}
+}
+
+func TestReinstallingABuildMovesItUp(t *testing.T) {
+	dir := t.TempDir()
+	apk := writeAPK(t, "apk")
+
+	Record(dir, "hub", apk, Build{Fingerprint: "one"})
+	Record(dir, "hub", apk, Build{Fingerprint: "two"})
+	Record(dir, "hub", apk, Build{Fingerprint: "one"})
+
+	builds := List(dir, "hub")
+	if len(builds) != 2 || builds[0].Fingerprint != "one" {
+		t.Errorf("got %+v", builds)
+	}
+}
+
+// Moved to the top, a rolled back build would make the one just undone the
+// next "one before", and a second rollback would go forward again.
+func TestARollbackKeepsItsPlace(t *testing.T) {
+	dir := t.TempDir()
+	apk := writeAPK(t, "apk")
+
+	for _, fp := range []string{"a", "b", "c", "d"} {
+		Record(dir, "hub", apk, Build{Fingerprint: fp})
+	}
+	Record(dir, "hub", apk, Build{Fingerprint: "b", RolledBack: true})
+
+	builds := List(dir, "hub")
+	at := Current(builds, "b")
+	if at != 2 || !builds[at].RolledBack {
+		t.Fatalf("the rolled back build is at %d in %+v", at, builds)
+	}
+	if next := builds[at+1].Fingerprint; next != "a" {
+		t.Errorf("the next rollback would go to %s, want a", next)
+	}
+	if Current(builds, "") != -1 || Current(builds, "elsewhere") != -1 {
+		t.Error("a build not in the list was found in it")
+	}
+}
+
+func TestOldBuildsAreLetGoUnlessAnotherRobotHasThem(t *testing.T) {
+	dir := t.TempDir()
+	apk := writeAPK(t, "apk")
+
+	Record(dir, "practice", apk, Build{Fingerprint: "fp-0"})
+	for i := 0; i <= Keep; i++ {
+		Record(dir, "comp", apk, Build{Fingerprint: fmt.Sprintf("fp-%d", i)})
+	}
+
+	if got := len(List(dir, "comp")); got != Keep {
+		t.Errorf("kept %d builds, want %d", got, Keep)
+	}
+	if _, ok := APK(dir, "fp-0"); !ok {
+		t.Error("the practice robot's build was deleted with the competition robot's")
+	}
+
+	Record(dir, "practice", apk, Build{Fingerprint: "fp-9"})
+	for i := 0; i < Keep; i++ {
+		Record(dir, "practice", apk, Build{Fingerprint: fmt.Sprintf("fp-p%d", i)})
+	}
+	if _, ok := APK(dir, "fp-0"); ok {
+		t.Error("an APK no robot lists was kept")
+	}
+}
+
+func TestAMissingHistoryIsEmpty(t *testing.T) {
+	if builds := List(t.TempDir(), "hub"); len(builds) != 0 {
+		t.Errorf("got %+v", builds)
+	}
+	if _, ok := APK(t.TempDir(), ""); ok {
+		t.Error("an empty fingerprint found an APK")
+	}
+}
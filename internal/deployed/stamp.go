@@ -0,0 +1,144 @@
+package deployed
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// The fingerprint and the Pusher Extreme signature say whether the robot holds
+// what this laptop last gave it, and nothing about what that was. With two
+// laptops pushing to one robot, which is every team the week before an event,
+// neither can say that the robot is running somebody else's branch.
+//
+// So each install and reload leaves a stamp on the hub saying what source it
+// came from, who pushed it and from where. The stamp lives on the robot rather
+// than here, so whichever laptop asks gets the same answer.
+//
+// Only pusher writes it, though, and Android Studio installs over it without a
+// word. The stamp notes when the package manager last installed the app, and
+// an app installed at any other time is not the one it describes.
+
+// StampPath is where the stamp is kept on the robot.
+const StampPath = "/data/local/tmp/pusher/stamp.json"
+
+// Source is the state of a project's git tree.
+type Source struct {
+	Commit string `json:"commit,omitempty"`
+	Branch string `json:"branch,omitempty"`
+	Dirty  bool   `json:"dirty,omitempty"`
+	// Author is the git user who deployed, which is who to ask about it.
+	Author string `json:"author,omitempty"`
+}
+
+// Describe is the source in a few words.
+func (s Source) Describe() string {
+	if s.Commit == "" {
+		return "no git commit"
+	}
+	out := s.Commit
+	if s.Branch != "" {
+		out += " on " + s.Branch
+	}
+	if s.Dirty {
+		out += " with uncommitted changes"
+	}
+	return out
+}
+
+// Stamp is what the robot was last given.
+type Stamp struct {
+	Source
+
+	// Kind is install, reload or rollback.
+	Kind    string    `json:"kind"`
+	Time    time.Time `json:"time"`
+	Version string    `json:"pusher_version,omitempty"`
+	Host    string    `json:"host,omitempty"`
+
+	// Package is the robot controller app, and AppUpdated when the package
+	// manager last installed it, as of the stamp.
+	Package    string `json:"package,omitempty"`
+	AppUpdated string `json:"app_updated,omitempty"`
+}
+
+// Stale reports whether the app has been installed since the stamp was
+// written, by something that wrote no stamp of its own. updated is the app's
+// install time as the robot reports it now. A time not known on either side
+// says nothing.
+func (s Stamp) Stale(updated string) bool {
+	return s.AppUpdated != "" && updated != "" && updated != s.AppUpdated
+}
+
+// Encode is the stamp as it is written to the robot.
+func (s Stamp) Encode() []byte {
+	blob, _ := json.MarshalIndent(s, "", "  ")
+	return blob
+}
+
+// ParseStamp reads a stamp back.
+func ParseStamp(blob []byte) (Stamp, error) {
+	var s Stamp
+	if err := json.Unmarshal(blob, &s); err != nil {
+		return Stamp{}, fmt.Errorf("the robot's deploy stamp is unreadable: %w", err)
+	}
+	return s, nil
+}
+
+// ReadSource reads the git state of the tree at root. Everything is empty when
+// the project is not in git or git is missing.
+func ReadSource(root string) Source {
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", root}, args...)...).Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	s := Source{Commit: git("rev-parse", "--short", "HEAD")}
+	if s.Commit == "" {
+		return Source{}
+	}
+	if branch := git("rev-parse", "--abbrev-ref", "HEAD"); branch != "HEAD" {
+		s.Branch = branch
+	}
+	s.Dirty = git("status", "--porcelain", "--untracked-files=no") != ""
+	s.Author = git("config", "user.name")
+	return s
+}
+
+// Differences are the ways the robot's stamp does not describe the tree here,
+// as sentences; none means it does.
+func Differences(robot Stamp, local Source, host string) []string {
+	var out []string
+
+	switch {
+	case robot.Commit == "" || local.Commit == "":
+		// Nothing to compare a commit with.
+	case !sameCommit(robot.Commit, local.Commit):
+		out = append(out, fmt.Sprintf("the robot runs %s, and this tree is at %s",
+			robot.Source.Describe(), local.Describe()))
+	case robot.Dirty || local.Dirty:
+		out = append(out, "the commit is the same, but with uncommitted changes that may not be")
+	}
+
+	if robot.Host != "" && host != "" && robot.Host != host {
+		who := robot.Host
+		if robot.Author != "" {
+			who = robot.Author + " on " + robot.Host
+		}
+		out = append(out, "it was last deployed from another computer, by "+who)
+	}
+
+	return out
+}
+
+// sameCommit compares abbreviated hashes, which git may abbreviate to
+// different lengths on two machines.
+func sameCommit(a, b string) bool {
+	n := min(len(a), len(b))
+	return n > 0 && a[:n] == b[:n]
+}
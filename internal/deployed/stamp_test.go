@@ -0,0 +1,118 @@
+package deployed
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAStampReadsBackAsWritten(t *testing.T) {
+	want := Stamp{
+		Source:  Source{Commit: "a1b2c3d", Branch: "main", Dirty: true, Author: "Sam"},
+		Kind:    "reload",
+		Time:    time.Date(2026, 10, 10, 14, 2, 0, 0, time.UTC),
+		Version: "1.4.2",
+		Host:    "team-laptop",
+		Package: "com.qualcomm.ftcrobotcontroller",
+		// dumpsys's line with its spaces taken out.
+		AppUpdated: "lastUpdateTime=2026-10-1014:01:52",
+	}
+
+	got, err := ParseStamp(want.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := ParseStamp([]byte("not json")); err == nil {
+		t.Error("garbage was taken for a stamp")
+	}
+}
+
+// An install from Android Studio writes no stamp, and moves the app's install
+// time past the one the last stamp noted.
+func TestAStampIsStaleOnceSomethingElseInstalls(t *testing.T) {
+	stamp := Stamp{AppUpdated: "lastUpdateTime=2026-10-1014:01:52"}
+
+	if stamp.Stale("lastUpdateTime=2026-10-1014:01:52") {
+		t.Error("the app pusher installed was taken for another")
+	}
+	if !stamp.Stale("lastUpdateTime=2026-10-1015:30:07") {
+		t.Error("an app installed since was not noticed")
+	}
+	if stamp.Stale("") || (Stamp{}).Stale("lastUpdateTime=2026-10-1015:30:07") {
+		t.Error("a time nobody knows made the stamp stale")
+	}
+}
+
+func TestDifferencesNameWhatDoesNotMatch(t *testing.T) {
+	robot := Stamp{Source: Source{Commit: "a1b2c3d", Branch: "auto-fix", Author: "Sam"}, Host: "laptop-2"}
+
+	cases := []struct {
+		name  string
+		local Source
+		host  string
+		want  []string
+	}{
+		{"the same", Source{Commit: "a1b2c3d4", Branch: "auto-fix"}, "laptop-2", nil},
+		{"another commit", Source{Commit: "e5f6a7b", Branch: "main"}, "laptop-2", []string{"a1b2c3d on auto-fix"}},
+		{"local changes", Source{Commit: "a1b2c3d", Dirty: true}, "laptop-2", []string{"uncommitted"}},
+		{"another laptop", Source{Commit: "a1b2c3d"}, "laptop-1", []string{"by Sam on laptop-2"}},
+		{"no git here", Source{}, "", nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Differences(robot, c.local, c.host)
+			if len(got) != len(c.want) {
+				t.Fatalf("got %q", got)
+			}
+			for i, want := range c.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("%q does not mention %q", got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTheSourceComesFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+
+	root := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("config", "user.name", "Sam")
+	git("config", "user.email", "sam@example.com")
+	file := filepath.Join(root, "Auto.java")
+	os.WriteFile(file, []byte("class Auto {}"), 0o644)
+	git("add", ".")
+	git("commit", "-q", "-m", "auto")
+
+	s := ReadSource(root)
+	if s.Commit == "" || s.Branch != "main" || s.Author != "Sam" || s.Dirty {
+		t.Errorf("clean tree read as %+v", s)
+	}
+
+	os.WriteFile(file, []byte("class Auto { int x; }"), 0o644)
+	if !ReadSource(root).Dirty {
+		t.Error("an edited file did not make the tree dirty")
+	}
+
+	if got := ReadSource(t.TempDir()); got != (Source{}) {
+		t.Errorf("a directory outside git read as %+v", got)
+	}
+}
@@ -0,0 +1,126 @@
+package extreme
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/dash"
+)
+
+// A reload rebuilds the classloader under a live app. What the SDK does with
+// that while an OpMode is running has not been established, and finding out at
+// the side of a field with a motor running under code that no longer exists is
+// not a way anyone should find out. So the robot is asked first, over the same
+// dashboard socket the OpMode list comes from, and a running OpMode is never
+// reloaded under.
+//
+// Sloth's answer is to apply the change when the OpMode ends, and that is one
+// of the choices here. The others are stopping it, and the default: refusing,
+// after offering to stop it when someone is there to answer.
+
+// WhenBusy is what a reload does about a running OpMode.
+type WhenBusy int
+
+const (
+	// Refuse stops the reload, unless Ask agrees to stopping the OpMode.
+	Refuse WhenBusy = iota
+	// StopFirst stops the OpMode and then reloads.
+	StopFirst
+	// WaitForIdle holds the reload until the OpMode ends.
+	WaitForIdle
+	// NoCheck does not ask. It is for a robot whose app has just been
+	// restarted by an install, where nothing can be running and the dashboard
+	// may not be up yet to say so.
+	NoCheck
+)
+
+// Busy says how to handle a running OpMode.
+type Busy struct {
+	When WhenBusy
+	// Ask offers to stop the named OpMode under Refuse, reporting whether that
+	// was agreed to. Nil means nobody is there to ask.
+	Ask func(opMode string) bool
+	// Progress is told what is being waited for. May be nil.
+	Progress func(string)
+}
+
+// What a reload did about OpModes, as Result.Busy reports it.
+const (
+	BusyIdle      = "idle"
+	BusyStopped   = "stopped"
+	BusyWaited    = "waited"
+	BusyRefused   = "refused"
+	BusyUnchecked = "unchecked"
+)
+
+// ErrOpModeRunning is a reload refused because an OpMode was running.
+var ErrOpModeRunning = errors.New("an OpMode is running")
+
+// robot is the part of a dashboard stream a reload needs, so the decision can
+// be tested without one.
+type robot interface {
+	Await(done func(dash.Status) bool, timeout time.Duration, each func(dash.Packet)) (dash.Status, error)
+	Stop() error
+}
+
+// clearToReload makes sure nothing is running before a reload is delivered,
+// returning what was done, which OpMode it was done about and a line for the
+// steps.
+//
+// A robot without the dashboard cannot be asked. That is not a reason to stop,
+// since reloading without asking is what happened before this existed, but it
+// is said rather than passed over.
+func clearToReload(serial string, busy Busy) (outcome, opMode, step string, err error) {
+	stream, err := dash.Watch(serial)
+	if err != nil {
+		return BusyUnchecked, "", "could not check for a running OpMode without FtcDashboard", nil
+	}
+	defer stream.Close()
+	return settle(stream, busy)
+}
+
+// settle reads the robot's status and does what busy says about it.
+func settle(r robot, busy Busy) (outcome, opMode, step string, err error) {
+	status, err := r.Await(func(dash.Status) bool { return true }, dash.Timeout, nil)
+	if err != nil {
+		return BusyUnchecked, "", "could not check for a running OpMode: the dashboard did not answer", nil
+	}
+	if status.Idle() {
+		return BusyIdle, "", "", nil
+	}
+
+	opMode = status.OpMode
+	when := busy.When
+	if when == Refuse && busy.Ask != nil && busy.Ask(opMode) {
+		when = StopFirst
+	}
+
+	switch when {
+	case StopFirst:
+		if err := r.Stop(); err != nil {
+			return BusyRefused, opMode, "", err
+		}
+		if _, err := r.Await(dash.Status.Idle, dash.StepTimeout, nil); err != nil {
+			return BusyRefused, opMode, "", fmt.Errorf("%s would not stop: %w", opMode, err)
+		}
+		return BusyStopped, opMode, fmt.Sprintf("stopped %s before reloading", opMode), nil
+
+	case WaitForIdle:
+		if busy.Progress != nil {
+			busy.Progress(fmt.Sprintf("waiting for %s to end before reloading", opMode))
+		}
+		start := time.Now()
+		if _, err := r.Await(dash.Status.Idle, 0, nil); err != nil {
+			return BusyRefused, opMode, "", fmt.Errorf("lost the robot waiting for %s to end: %w", opMode, err)
+		}
+		return BusyWaited, opMode, fmt.Sprintf("waited %s for %s to end",
+			time.Since(start).Round(100*time.Millisecond), opMode), nil
+	}
+
+	state := "running"
+	if status.State == "INIT" {
+		state = "initialised"
+	}
+	return BusyRefused, opMode, "", fmt.Errorf("%w: %s is %s on the robot", ErrOpModeRunning, opMode, state)
+}
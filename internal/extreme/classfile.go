@@ -0,0 +1,280 @@
+package extreme
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// An incremental compile needs two things from each class javac produced:
+// which other classes it mentions, so a change can be followed to the files
+// that depend on it, and what it offers to them, so a change that offers
+// nothing new can stop at the file that was edited. Both are in the class
+// file, and reading them back is cheaper and more certain than reading them
+// out of the source.
+
+// classInfo is what one class file says about itself.
+type classInfo struct {
+	// Name is the internal name, a/b/C$D.
+	Name string
+	// Source is the SourceFile attribute, the file name without a directory.
+	Source string
+	// Uses is every class the constant pool names.
+	Uses []string
+	// ABI identifies what the class offers other classes: everything a
+	// dependent could have compiled against, and nothing a method body does.
+	ABI string
+	// Constants are its compile-time constants and their values, empty when
+	// it has none. javac copies these into whoever reads them and leaves no
+	// reference behind, so Uses cannot say who that is.
+	Constants string
+}
+
+const (
+	accPrivate = 0x0002
+	accSuper   = 0x0020
+)
+
+// descriptorClass finds the class names inside descriptors and generic
+// signatures, where they appear as Lname; or Lname<.
+var descriptorClass = regexp.MustCompile(`L([A-Za-z_$][\w$/]*)[;<]`)
+
+var errNotClass = errors.New("not a class file")
+
+// classReader walks a class file.
+type classReader struct {
+	data []byte
+	at   int
+	err  error
+}
+
+func (r *classReader) u1() int {
+	if r.err != nil || r.at+1 > len(r.data) {
+		r.err = errNotClass
+		return 0
+	}
+	v := r.data[r.at]
+	r.at++
+	return int(v)
+}
+
+func (r *classReader) u2() int {
+	if r.err != nil || r.at+2 > len(r.data) {
+		r.err = errNotClass
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.data[r.at:])
+	r.at += 2
+	return int(v)
+}
+
+func (r *classReader) u4() uint32 {
+	if r.err != nil || r.at+4 > len(r.data) {
+		r.err = errNotClass
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data[r.at:])
+	r.at += 4
+	return v
+}
+
+func (r *classReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.at+n > len(r.data) {
+		r.err = errNotClass
+		return nil
+	}
+	v := r.data[r.at : r.at+n]
+	r.at += n
+	return v
+}
+
+// constant is one constant pool entry, as much of it as is needed here.
+type constant struct {
+	tag   int
+	text  string // Utf8
+	index int    // Class, String: the Utf8 it points at
+	value string // Integer, Float, Long, Double, rendered
+}
+
+// member is a field or a method, for the ABI.
+type member struct {
+	access     int
+	name, desc string
+	extra      []string
+}
+
+// readClass reads a class file.
+func readClass(data []byte) (classInfo, error) {
+	r := &classReader{data: data}
+	if r.u4() != 0xCAFEBABE {
+		return classInfo{}, errNotClass
+	}
+	r.u2() // minor
+	r.u2() // major
+
+	pool := make([]constant, r.u2())
+	for i := 1; i < len(pool) && r.err == nil; i++ {
+		c := constant{tag: r.u1()}
+		switch c.tag {
+		case 1:
+			c.text = string(r.bytes(r.u2()))
+		case 3:
+			c.value = fmt.Sprint(int32(r.u4()))
+		case 4:
+			c.value = fmt.Sprint(math.Float32frombits(r.u4()))
+		case 5:
+			c.value = fmt.Sprint(int64(uint64(r.u4())<<32 | uint64(r.u4())))
+		case 6:
+			c.value = fmt.Sprint(math.Float64frombits(uint64(r.u4())<<32 | uint64(r.u4())))
+		case 7, 8, 16, 19, 20:
+			c.index = r.u2()
+		case 9, 10, 11, 12, 17, 18:
+			r.u4()
+		case 15:
+			r.bytes(3)
+		default:
+			return classInfo{}, fmt.Errorf("unknown constant tag %d", c.tag)
+		}
+		pool[i] = c
+		// Longs and doubles take two slots, for reasons the specification
+		// itself calls a poor choice.
+		if c.tag == 5 || c.tag == 6 {
+			i++
+		}
+	}
+
+	utf := func(i int) string {
+		if i > 0 && i < len(pool) && pool[i].tag == 1 {
+			return pool[i].text
+		}
+		return ""
+	}
+	class := func(i int) string {
+		if i > 0 && i < len(pool) && pool[i].tag == 7 {
+			return utf(pool[i].index)
+		}
+		return ""
+	}
+	value := func(i int) string {
+		if i <= 0 || i >= len(pool) {
+			return ""
+		}
+		if pool[i].tag == 8 {
+			return fmt.Sprintf("%q", utf(pool[i].index))
+		}
+		return pool[i].value
+	}
+
+	var info classInfo
+	access := r.u2() &^ accSuper
+	info.Name = class(r.u2())
+	super := class(r.u2())
+	interfaces := make([]string, r.u2())
+	for i := range interfaces {
+		interfaces[i] = class(r.u2())
+	}
+
+	// attributes reads an attribute table, keeping what the ABI cares about.
+	attributes := func() (extra []string, source string) {
+		for n := r.u2(); n > 0 && r.err == nil; n-- {
+			name := utf(r.u2())
+			body := &classReader{data: r.bytes(int(r.u4()))}
+			switch name {
+			case "Signature":
+				extra = append(extra, "signature "+utf(body.u2()))
+			case "ConstantValue":
+				// Constants are copied into whoever uses them, so changing one
+				// changes its users without changing anything they reference.
+				extra = append(extra, "constant "+value(body.u2()))
+			case "Exceptions":
+				var thrown []string
+				for k := body.u2(); k > 0 && body.err == nil; k-- {
+					thrown = append(thrown, class(body.u2()))
+				}
+				sort.Strings(thrown)
+				extra = append(extra, "throws "+strings.Join(thrown, ","))
+			case "SourceFile":
+				source = utf(body.u2())
+			}
+		}
+		return extra, source
+	}
+
+	var members []member
+	for table := 0; table < 2; table++ {
+		for n := r.u2(); n > 0 && r.err == nil; n-- {
+			m := member{access: r.u2(), name: utf(r.u2()), desc: utf(r.u2())}
+			m.extra, _ = attributes()
+			if m.access&accPrivate == 0 {
+				members = append(members, m)
+			}
+		}
+	}
+
+	classExtra, source := attributes()
+	if r.err != nil {
+		return classInfo{}, r.err
+	}
+	info.Source = source
+
+	uses := map[string]bool{}
+	for _, c := range pool {
+		switch {
+		case c.tag == 7:
+			name := utf(c.index)
+			if strings.HasPrefix(name, "[") {
+				for _, m := range descriptorClass.FindAllStringSubmatch(name, -1) {
+					uses[m[1]] = true
+				}
+			} else if name != "" {
+				uses[name] = true
+			}
+		case c.tag == 1 && strings.Contains(c.text, "L"):
+			for _, m := range descriptorClass.FindAllStringSubmatch(c.text, -1) {
+				uses[m[1]] = true
+			}
+		}
+	}
+	delete(uses, info.Name)
+	for name := range uses {
+		info.Uses = append(info.Uses, name)
+	}
+	sort.Strings(info.Uses)
+
+	lines := []string{
+		fmt.Sprintf("class %x %s extends %s implements %s", access, info.Name, super, strings.Join(interfaces, ",")),
+		strings.Join(classExtra, " "),
+	}
+	var constants []string
+	for _, m := range members {
+		line := fmt.Sprintf("%x %s %s %s", m.access, m.name, m.desc, strings.Join(m.extra, " "))
+		lines = append(lines, line)
+		for _, extra := range m.extra {
+			if strings.HasPrefix(extra, "constant ") {
+				constants = append(constants, line)
+				break
+			}
+		}
+	}
+	sort.Strings(lines[2:])
+	sort.Strings(constants)
+	info.Constants = strings.Join(constants, "\n")
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	info.ABI = hex.EncodeToString(sum[:])
+
+	return info, nil
+}
+
+// anonymous reports whether a class is one javac numbered rather than named:
+// an anonymous or local class, which nothing outside its own file can refer
+// to, so adding one is not a change to what the file offers.
+func anonymous(name string) bool {
+	dollar := strings.LastIndexByte(name, '$')
+	return dollar >= 0 && dollar+1 < len(name) && name[dollar+1] >= '0' && name[dollar+1] <= '9'
+}
@@ -23,6 +23,44 @@ var SourceRoot = filepath.Join("TeamCode", "src", "main", "java")
 type Project struct {
 	Root    string
 	Wrapper string
+
+	// toolchain is held between compiles by Hold, for a process that compiles
+	// over and over. Finding it unpacks the FTC jars out of their AARs, which
+	// is most of a second that a watcher would otherwise pay on every save.
+	toolchain *hotreload.Toolchain
+}
+
+// Hold finds the toolchain once and keeps it for every compile until Release.
+func (p *Project) Hold() error {
+	if p.toolchain != nil {
+		return nil
+	}
+	tc, err := hotreload.FindToolchain()
+	if err != nil {
+		return err
+	}
+	p.toolchain = &tc
+	return nil
+}
+
+// Release lets go of what Hold kept.
+func (p *Project) Release() {
+	if p.toolchain != nil {
+		p.toolchain.Cleanup()
+		p.toolchain = nil
+	}
+}
+
+// tools is the toolchain for one compile, and what to do once it is finished.
+func (p *Project) tools() (hotreload.Toolchain, func(), error) {
+	if p.toolchain != nil {
+		return *p.toolchain, func() {}, nil
+	}
+	tc, err := hotreload.FindToolchain()
+	if err != nil {
+		return tc, nil, err
+	}
+	return tc, tc.Cleanup, nil
 }
 
 // Build is what a compile produced.
@@ -35,6 +73,12 @@ type Build struct {
 	Kept int
 	// Kotlin is how many of the sources were Kotlin.
 	Kotlin int
+	// Recompiled is how many sources this compile actually compiled; the rest
+	// came from the cache.
+	Recompiled int
+	// Cold is whether it compiled everything, and Why says why it had to.
+	Cold bool
+	Why  string
 	// Bridged is how many classes were handed to a library that could not
 	// otherwise see them.
 	Bridged int
@@ -107,18 +151,18 @@ func (p *Project) Sources() (Sourced, error) {
 
 // Compile turns the team's sources into a jar and a dex.
 //
-// Everything is compiled, not only what changed. A reload replaces the whole
-// classloader, so a partial dex would leave the classes it did not contain
-// unresolvable, and the SDK's re-registration abandons everything on the first
-// failure rather than skipping one class.
+// Only what changed is compiled, but everything is packaged. A reload replaces
+// the whole classloader, so a partial dex would leave the classes it did not
+// contain unresolvable, and the SDK's re-registration abandons everything on
+// the first failure rather than skipping one class.
 func Compile(p *Project, cp Classpath, work string, keep, previous []string) (Build, error) {
 	var out Build
 
-	tc, err := hotreload.FindToolchain()
+	tc, done, err := p.tools()
 	if err != nil {
 		return out, err
 	}
-	defer tc.Cleanup()
+	defer done()
 
 	sources, err := p.Sources()
 	if err != nil {
@@ -141,26 +185,17 @@ func Compile(p *Project, cp Classpath, work string, keep, previous []string) (Bu
 		out.Registered = RegisteredNames(configs)
 	}
 
-	classes := filepath.Join(work, "classes")
-	if err := os.MkdirAll(classes, 0o755); err != nil {
+	in, err := inputs(sources, filepath.Join(p.Root, SourceRoot), filepath.Join(work, "generated"))
+	if err != nil {
 		return out, err
 	}
 
-	// Kotlin first, because the Kotlin compiler reads the Java sources for
-	// their signatures without compiling them, while javac cannot read Kotlin
-	// at all and needs the classes it produced. Either half may reference the
-	// other, and this is the order that allows it.
-	if len(sources.Kotlin) > 0 {
-		if err := compileKotlin(tc, cp, sources, classes, work); err != nil {
-			return out, err
-		}
-	}
-
-	if len(sources.Java) > 0 {
-		if err := compileJava(tc, cp, sources.Java, classes, work); err != nil {
-			return out, err
-		}
+	cache := loadCache(p.Root)
+	out.Recompiled, out.Cold, out.Why, err = cache.update(tc, cp, sources, in, compileKey(tc, cp), work)
+	if err != nil {
+		return out, err
 	}
+	classes := cache.classes()
 
 	compiled, err := classFiles(classes)
 	if err != nil {
@@ -188,11 +223,24 @@ func Compile(p *Project, cp Classpath, work string, keep, previous []string) (Bu
 		return out, err
 	}
 
-	// d8 takes the jar rather than the loose classes: one argument instead of
-	// hundreds, and it is the same input the robot will be given.
-	d8 := exec.Command(tc.D8, "--min-api", "24", "--output", dexDir, out.Jar)
-	if result, err := d8.CombinedOutput(); err != nil {
-		return out, fmt.Errorf("dexing %s failed:\n%s", Module, lastLines(string(result), 25))
+	// Merging what each class was dexed to before is the fast way. Dexing the
+	// jar whole is the way that has always worked, so it is what any trouble
+	// with the pieces falls back to, and keeps falling back to until the next
+	// cold compile, rather than paying for a failed attempt on every reload.
+	if err := cache.dex(tc, compiled, work, dexDir); err != nil {
+		if !cache.WholeDex {
+			os.RemoveAll(cache.dexParts())
+			cache.WholeDex = true
+			_ = cache.save()
+		}
+		os.Remove(filepath.Join(dexDir, "classes.dex"))
+
+		// d8 takes the jar rather than the loose classes: one argument instead
+		// of hundreds, and it is the same input the robot will be given.
+		d8 := exec.Command(tc.D8, "--min-api", "24", "--output", dexDir, out.Jar)
+		if result, err := d8.CombinedOutput(); err != nil {
+			return out, fmt.Errorf("dexing %s failed:\n%s", Module, lastLines(string(result), 25))
+		}
 	}
 
 	out.Dex = filepath.Join(dexDir, "classes.dex")
@@ -207,13 +255,19 @@ func Compile(p *Project, cp Classpath, work string, keep, previous []string) (Bu
 //
 // The output directory goes on the classpath ahead of everything else so the
 // Java half can see whatever Kotlin just produced. On a project with no Kotlin
-// it is simply empty, and nothing about this changes.
-func compileJava(tc hotreload.Toolchain, cp Classpath, sources []string, classes, work string) error {
+// it is simply empty, and nothing about this changes. Anything in also comes
+// next, which is how a compile of some files sees the classes of the rest.
+func compileJava(tc hotreload.Toolchain, cp Classpath, sources []string, classes, work string, also ...string) error {
 	// The file list goes in an argument file. A hundred and twenty paths
 	// exceeds what a command line takes on some platforms, and javac has
 	// supported @files for exactly this since forever.
-	list := filepath.Join(work, "sources.txt")
-	if err := os.WriteFile(list, []byte(strings.Join(sources, "\n")), 0o644); err != nil {
+	list, err := os.CreateTemp(work, "sources-*.txt")
+	if err != nil {
+		return err
+	}
+	_, err = list.WriteString(strings.Join(sources, "\n"))
+	list.Close()
+	if err != nil {
 		return err
 	}
 
@@ -225,12 +279,12 @@ func compileJava(tc hotreload.Toolchain, cp Classpath, sources []string, classes
 		"-nowarn",
 		"-encoding", "UTF-8",
 		"-d", classes,
-	}, cp.Args(classes)...)
-	args = append(args, "@"+list)
+	}, cp.Args(append([]string{classes}, also...)...)...)
+	args = append(args, "@"+list.Name())
 
 	javac := exec.Command(tc.Javac, args...)
 	if result, err := javac.CombinedOutput(); err != nil {
-		return fmt.Errorf("compiling %s failed:\n%s", Module, lastLines(string(result), 25))
+		return &CompileError{What: Module, Output: string(result)}
 	}
 
 	return nil
@@ -269,8 +323,7 @@ func compileKotlin(tc hotreload.Toolchain, cp Classpath, sources Sourced, classe
 
 	kotlinc := exec.Command(tc.Java(), args...)
 	if result, err := kotlinc.CombinedOutput(); err != nil {
-		return fmt.Errorf("compiling the Kotlin in %s failed:\n%s",
-			Module, lastLines(string(result), 25))
+		return &CompileError{What: "the Kotlin in " + Module, Output: string(result)}
 	}
 
 	return nil
@@ -87,11 +87,16 @@ type Result struct {
 	Total    time.Duration
 	Steps    []string
 	Warnings []string
+	// Busy is what was done about a running OpMode: one of the Busy constants.
+	Busy string
+	// OpMode is the one that was running, if any was.
+	OpMode string
 }
 
 // Reload compiles the team's code and puts it on the robot, without installing
-// anything.
-func Reload(p *Project, serial string, cp Classpath, keep []string) (*Result, error) {
+// anything. busy says what to do if an OpMode is running when the code is ready
+// to go.
+func Reload(p *Project, serial string, cp Classpath, keep []string, busy Busy) (*Result, error) {
 	out := &Result{}
 	started := time.Now()
 
@@ -115,14 +120,38 @@ func Reload(p *Project, serial string, cp Classpath, keep []string) (*Result, er
 	if build.Bridged > 0 {
 		kept += fmt.Sprintf(", %d @Config classes bridged to FtcDashboard", build.Bridged)
 	}
+	compiled := fmt.Sprintf("compiled %d sources", build.Sources)
+	if !build.Cold {
+		compiled = fmt.Sprintf("recompiled %d of %d sources", build.Recompiled, build.Sources)
+	}
 	out.Steps = append(out.Steps,
-		fmt.Sprintf("compiled %d sources into %d reloadable classes%s in %s",
-			build.Sources, build.Classes, kept, out.Compile.Round(time.Millisecond)))
+		fmt.Sprintf("%s into %d reloadable classes%s in %s",
+			compiled, build.Classes, kept, out.Compile.Round(time.Millisecond)))
 
 	if err := checkOpModes(build.Jar); err != nil {
 		out.Warnings = append(out.Warnings, err.Error())
 	}
 
+	// Checked once the compile is done rather than before it, so an OpMode
+	// that ends while the code compiles is not a reason to refuse, and one
+	// that starts meanwhile is still caught.
+	//
+	// The time that takes is left out of Total, for the same reason the
+	// verification below is: waiting for a match to end is not the reload
+	// being slow.
+	if busy.When != NoCheck {
+		asked := time.Now()
+		outcome, opMode, step, err := clearToReload(serial, busy)
+		started = started.Add(time.Since(asked))
+		out.Busy, out.OpMode = outcome, opMode
+		if step != "" {
+			out.Steps = append(out.Steps, step)
+		}
+		if err != nil {
+			return out, err
+		}
+	}
+
 	marker := time.Now().Format("150405")
 
 	delivery, err := hotreload.Deliver(serial, Name, build.Jar, build.Dex, marker)
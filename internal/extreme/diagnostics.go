@@ -0,0 +1,39 @@
+package extreme
+
+import (
+	"fmt"
+
+	"github.com/andreibanu/pusher/internal/gradle"
+)
+
+// A compile that fails is reported as the compiler's last lines, which is
+// what a person reading a deploy wants: the same text Android Studio would
+// have shown. Something that reports a failure in one line, the watcher
+// between saves being the first, wants the errors themselves, so the output is
+// kept whole and read back into them on request, the same way a Gradle build's
+// is.
+
+// CompileError is the team's code not compiling.
+type CompileError struct {
+	// What is what was being compiled, for the message.
+	What string
+	// Output is everything the compiler said.
+	Output string
+}
+
+func (e *CompileError) Error() string {
+	msg := fmt.Sprintf("compiling %s failed:\n%s", e.What, lastLines(e.Output, 25))
+	for _, h := range gradle.Parse(e.Output).Hints {
+		msg += "\n" + h.String()
+	}
+	return msg
+}
+
+// Diagnostic is one error the compiler reported against a line.
+type Diagnostic = gradle.Diagnostic
+
+// Diagnostics reads the errors out of what the compiler said, in the order it
+// said them.
+func (e *CompileError) Diagnostics() []Diagnostic {
+	return gradle.Parse(e.Output).Diagnostics
+}
@@ -2,10 +2,15 @@ package extreme
 
 import (
 	"archive/zip"
+	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/dash"
 )
 
 // Gradle's own chatter surrounds the answer, so the block markers are what make
@@ -514,6 +519,38 @@ func TestTheSignatureNoticesAVersionCatalog(t *testing.T) {
 	}
 }
 
+// The build files decide the classpath a watch compiles against. A resource
+// needs an install but not a new classpath, and working one out runs Gradle.
+func TestTheBuildSignatureIsOnlyTheBuildFiles(t *testing.T) {
+	root := t.TempDir()
+	res := filepath.Join(root, Module, "src", "main", "res")
+	if err := os.MkdirAll(res, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(GradleFile(root), "dependencies {\n}\n")
+	write(filepath.Join(res, "thing.xml"), "<x/>\n")
+
+	before, err := BuildSignature(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write(filepath.Join(res, "thing.xml"), "<y/>\n")
+	if after, _ := BuildSignature(root); after != before {
+		t.Error("a resource changed the build signature")
+	}
+
+	write(GradleFile(root), "dependencies {\n  implementation 'x'\n}\n")
+	if after, _ := BuildSignature(root); after == before {
+		t.Error("a new dependency did not change the build signature")
+	}
+}
+
 func TestBuildInputsCoverBothGradleDialects(t *testing.T) {
 	for _, name := range []string{
 		"build.gradle", "build.gradle.kts", "settings.gradle.kts",
@@ -1365,3 +1402,187 @@ func TestSummaryStopsNamingAfterAFew(t *testing.T) {
 		t.Errorf("Summary = %q", got)
 	}
 }
+
+// fakeRobot answers Await from a list of statuses, one per read, and notes
+// whether it was told to stop.
+type fakeRobot struct {
+	statuses []dash.Status
+	stopped  bool
+}
+
+func (r *fakeRobot) Await(done func(dash.Status) bool, _ time.Duration, _ func(dash.Packet)) (dash.Status, error) {
+	for len(r.statuses) > 0 {
+		status := r.statuses[0]
+		r.statuses = r.statuses[1:]
+		if done(status) {
+			return status, nil
+		}
+	}
+	return dash.Status{}, errors.New("the robot went away")
+}
+
+func (r *fakeRobot) Stop() error {
+	r.stopped = true
+	return nil
+}
+
+var running = dash.Status{OpMode: "Close Blue", State: "RUNNING"}
+
+func TestAnIdleRobotIsReloadedWithoutAsking(t *testing.T) {
+	robot := &fakeRobot{statuses: []dash.Status{{OpMode: dash.DefaultOpMode, State: "RUNNING"}}}
+	asked := false
+
+	outcome, _, _, err := settle(robot, Busy{Ask: func(string) bool { asked = true; return true }})
+	if err != nil || outcome != BusyIdle {
+		t.Fatalf("outcome = %s, %v", outcome, err)
+	}
+	if asked || robot.stopped {
+		t.Error("an idle robot was asked about or stopped")
+	}
+}
+
+// Nobody to ask is a refusal, never a guess: stopping an autonomous mid-match
+// because a laptop pushed is worse than a reload that did not happen.
+func TestARunningOpModeIsRefusedByDefault(t *testing.T) {
+	robot := &fakeRobot{statuses: []dash.Status{running}}
+
+	outcome, opMode, _, err := settle(robot, Busy{})
+	if !errors.Is(err, ErrOpModeRunning) || outcome != BusyRefused || opMode != "Close Blue" {
+		t.Fatalf("outcome = %s, %s, %v", outcome, opMode, err)
+	}
+	if robot.stopped {
+		t.Error("a refused OpMode was stopped anyway")
+	}
+
+	robot = &fakeRobot{statuses: []dash.Status{running}}
+	if _, _, _, err := settle(robot, Busy{Ask: func(string) bool { return false }}); !errors.Is(err, ErrOpModeRunning) {
+		t.Errorf("declining to stop still reloaded: %v", err)
+	}
+}
+
+func TestAgreeingToStopStopsAndWaits(t *testing.T) {
+	robot := &fakeRobot{statuses: []dash.Status{running, running, {OpMode: "Close Blue", State: "STOPPED"}}}
+
+	outcome, _, step, err := settle(robot, Busy{Ask: func(string) bool { return true }})
+	if err != nil || outcome != BusyStopped || !robot.stopped {
+		t.Fatalf("outcome = %s, stopped %v, %v", outcome, robot.stopped, err)
+	}
+	if !strings.Contains(step, "Close Blue") {
+		t.Errorf("step = %q", step)
+	}
+}
+
+func TestWaitingForIdleLeavesTheOpModeAlone(t *testing.T) {
+	robot := &fakeRobot{statuses: []dash.Status{running, running, running, {}}}
+	var progress []string
+
+	outcome, _, _, err := settle(robot, Busy{When: WaitForIdle, Progress: func(line string) { progress = append(progress, line) }})
+	if err != nil || outcome != BusyWaited {
+		t.Fatalf("outcome = %s, %v", outcome, err)
+	}
+	if robot.stopped {
+		t.Error("waiting stopped the OpMode")
+	}
+	if len(progress) != 1 {
+		t.Errorf("progress = %v", progress)
+	}
+}
+
+// A robot that cannot say what it is doing is reloaded as it always was, with
+// a step saying it was not asked.
+func TestASilentDashboardIsNotARefusal(t *testing.T) {
+	outcome, _, step, err := settle(&fakeRobot{}, Busy{})
+	if err != nil || outcome != BusyUnchecked || step == "" {
+		t.Fatalf("outcome = %s, %q, %v", outcome, step, err)
+	}
+}
+
+func TestCompileErrorsAreReadBackAsLines(t *testing.T) {
+	output := `/robot/TeamCode/src/main/java/org/firstinspires/ftc/teamcode/Drive.java:12: error: cannot find symbol
+        motor.setPowr(1);
+             ^
+  symbol:   method setPowr(int)
+/robot/TeamCode/src/main/java/org/firstinspires/ftc/teamcode/Arm.java:40: error: ';' expected
+e: file:///robot/TeamCode/src/main/java/org/firstinspires/ftc/teamcode/Lift.kt:7:13 Unresolved reference: hieght
+e: /robot/TeamCode/src/main/java/org/firstinspires/ftc/teamcode/Claw.kt: (3, 1): Expecting a top level declaration
+2 errors
+`
+	err := &CompileError{What: Module, Output: output}
+
+	var got []string
+	for _, d := range err.Diagnostics() {
+		got = append(got, d.Relative("/robot").String())
+	}
+
+	base := filepath.Join("TeamCode", "src", "main", "java", "org", "firstinspires", "ftc", "teamcode")
+	want := []string{
+		filepath.Join(base, "Drive.java") + ":12:14: cannot find symbol",
+		filepath.Join(base, "Arm.java") + ":40: ';' expected",
+		filepath.Join(base, "Lift.kt") + ":7:13: Unresolved reference: hieght",
+		filepath.Join(base, "Claw.kt") + ":3:1: Expecting a top level declaration",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if !strings.Contains(err.Error(), "compiling TeamCode failed") {
+		t.Errorf("message = %q", err.Error())
+	}
+}
+
+func TestEditorScratchFilesAreNotSaves(t *testing.T) {
+	for _, name := range []string{"Drive.java~", ".Drive.java.swp", "4913", "Drive.java___jb_tmp___", ".#Drive.java"} {
+		if !ignored(filepath.Join("src", name)) {
+			t.Errorf("%s was taken as a save", name)
+		}
+	}
+	for _, name := range []string{"Drive.java", "Lift.kt", "AndroidManifest.xml", "build.gradle"} {
+		if ignored(filepath.Join("src", name)) {
+			t.Errorf("%s was ignored", name)
+		}
+	}
+}
+
+// A burst of saves, including into a package made while watching, arrives as
+// one batch.
+func TestABurstOfSavesIsOneBatch(t *testing.T) {
+	root := t.TempDir()
+	java := filepath.Join(root, SourceRoot)
+	if err := os.MkdirAll(java, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	saves, err := WatchSaves(&Project{Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer saves.Close()
+	saves.quiet = 100 * time.Millisecond
+
+	write := func(path string) {
+		if err := os.WriteFile(path, []byte("class X {}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(java, "Drive.java"))
+	write(filepath.Join(java, "Drive.java~"))
+	if err := os.Mkdir(filepath.Join(java, "auto"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Join(java, "auto", "Close.java"))
+
+	files, err := saves.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(SourceRoot, "Drive.java"), filepath.Join(SourceRoot, "auto", "Close.java")}
+	sort.Strings(want)
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	saves.Close()
+	if _, err := saves.Next(); !errors.Is(err, ErrStopped) {
+		t.Errorf("a closed watch returned %v", err)
+	}
+}
@@ -0,0 +1,786 @@
+package extreme
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/andreibanu/pusher/internal/hotreload"
+)
+
+// Compiling everything on every reload was right while it was the only way to
+// be sure, and most of it was spent on files nobody had touched. So the
+// classes are kept between reloads, with what each source file produced, what
+// it refers to and what it offers, and a reload compiles what changed.
+//
+// What changed is followed as far as it has to be and no further. A file whose
+// edit changed only method bodies is compiled on its own: nothing compiled
+// against it can tell. A file that changed what it offers, a signature, a
+// constant, a class added or taken away, takes everything that depends on it,
+// directly or through something else, since javac resolved those references
+// against the old version. Deleting a file counts as that.
+//
+// Anything the cache cannot account for is compiled from scratch rather than
+// guessed at: a different classpath or JDK, a class that cannot be traced to
+// the file it came from, and any Kotlin at all among what has to be compiled,
+// because the Kotlin compiler reads the Java alongside it and is not given
+// half a tree.
+//
+// The dex goes the same way. Each class is dexed once, on its own, and a
+// reload merges the pieces, which d8 does in a fraction of the time it takes to
+// dex the jar.
+
+// cacheFormat changes whenever what is cached changes meaning, so an old cache
+// is rebuilt rather than misread.
+const cacheFormat = 2
+
+// compileCache is a project's compiled classes and what is known about them.
+type compileCache struct {
+	dir string
+
+	Format int    `json:"format"`
+	Key    string `json:"key"`
+	// Whole is whether every class is accounted to a source file. A class
+	// that is not could be left stale by a compile that does not know it
+	// needs replacing.
+	Whole bool `json:"whole"`
+	// WholeDex is a d8 that could not dex classes one at a time, so the jar is
+	// dexed whole instead of trying again on every reload.
+	WholeDex bool                     `json:"whole_dex,omitempty"`
+	Sources  map[string]*cachedSource `json:"sources"`
+}
+
+// cachedSource is what one source file produced the last time it compiled.
+type cachedSource struct {
+	Hash   string `json:"hash"`
+	Kotlin bool   `json:"kotlin,omitempty"`
+	// Classes are the class entries it compiled to, a/b/C$D.class.
+	Classes []string `json:"classes"`
+	// Uses are the team's classes it refers to.
+	Uses []string `json:"uses,omitempty"`
+	// ABI identifies what it offers the files that use it.
+	ABI string `json:"abi"`
+	// Constants identifies its compile-time constants alone, empty when it
+	// has none. Their readers are not in anyone's Uses.
+	Constants string `json:"constants,omitempty"`
+}
+
+// input is a source file as this compile finds it.
+type input struct {
+	// key is its package path and file name, a/b/C.java, which is also how a
+	// class file names where it came from.
+	key    string
+	path   string
+	hash   string
+	kotlin bool
+	// generated is the bridge, which is not the team's to count.
+	generated bool
+}
+
+// errStartAgain is an incremental compile that found something it cannot
+// follow, and wants the whole tree compiled instead.
+var errStartAgain = errors.New("compile everything")
+
+// CacheDir is where a project's compile cache lives: in the team module's
+// build directory, which Gradle's clean empties and version control ignores.
+func CacheDir(root string) string {
+	return filepath.Join(root, Module, "build", "pusher-extreme")
+}
+
+func (c *compileCache) classes() string  { return filepath.Join(c.dir, "classes") }
+func (c *compileCache) dexParts() string { return filepath.Join(c.dir, "dex") }
+func (c *compileCache) manifest() string { return filepath.Join(c.dir, "cache.json") }
+
+// loadCache reads a project's cache. A missing or unreadable one is empty,
+// which compiles everything.
+func loadCache(root string) *compileCache {
+	c := &compileCache{dir: CacheDir(root)}
+	if data, err := os.ReadFile(c.manifest()); err == nil {
+		_ = json.Unmarshal(data, c)
+	}
+	if c.Sources == nil || c.Format != cacheFormat {
+		c.Sources = map[string]*cachedSource{}
+		c.Whole = false
+	}
+	return c
+}
+
+// save writes the manifest. It is written before the classes are touched as
+// well as after, so a compile that dies halfway leaves a cache that knows the
+// files it was working on need compiling again.
+func (c *compileCache) save() error {
+	c.Format = cacheFormat
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	temp := c.manifest() + ".tmp"
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, c.manifest())
+}
+
+// forgetCompiles empties a project's cache, so the next compile is a cold one.
+func forgetCompiles(root string) error { return os.RemoveAll(CacheDir(root)) }
+
+// compileKey identifies everything other than the sources that decides what
+// they compile to. The jars are named with their size and time as well as
+// their path, since a local library can be rebuilt in place.
+func compileKey(tc hotreload.Toolchain, cp Classpath) string {
+	sum := sha256.New()
+	fmt.Fprintf(sum, "javac %s\nd8 %s\n", tc.Javac, tc.D8)
+	for _, jar := range append(append([]string{}, cp.Boot...), cp.Compile...) {
+		fmt.Fprintf(sum, "%s", jar)
+		if info, err := os.Stat(jar); err == nil {
+			fmt.Fprintf(sum, " %d %d", info.Size(), info.ModTime().UnixNano())
+		}
+		fmt.Fprintln(sum)
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// inputs hashes the sources, keyed relative to the root each lives under: the
+// module's source root, or generated for the bridge.
+func inputs(sources Sourced, sourceRoot, generated string) (map[string]input, error) {
+	out := map[string]input{}
+
+	add := func(file, root string, kotlin, generated bool) error {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		key := filepath.ToSlash(rel)
+		out[key] = input{key: key, path: file, hash: hex.EncodeToString(sum[:]), kotlin: kotlin, generated: generated}
+		return nil
+	}
+
+	for _, file := range sources.Kotlin {
+		if err := add(file, sourceRoot, true, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range sources.Java {
+		root, ours := sourceRoot, !strings.HasPrefix(file, generated+string(filepath.Separator))
+		if !ours {
+			root = generated
+		}
+		if err := add(file, root, false, !ours); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// plan is what a compile has to do.
+type plan struct {
+	// cold is compiling everything, for the reason in why.
+	cold bool
+	why  string
+	// changed are the sources that are new or edited, and gone the ones that
+	// were deleted.
+	changed []string
+	gone    []string
+}
+
+// plan works out which sources need compiling.
+func (c *compileCache) plan(in map[string]input, key string) plan {
+	switch {
+	case len(c.Sources) == 0:
+		return plan{cold: true, why: "nothing compiled yet"}
+	case c.Key != key:
+		return plan{cold: true, why: "the classpath or the JDK changed"}
+	case !c.Whole:
+		return plan{cold: true, why: "some classes could not be traced to a file"}
+	}
+
+	var p plan
+	for k, source := range in {
+		if cached, ok := c.Sources[k]; !ok || cached.Hash != source.hash {
+			p.changed = append(p.changed, k)
+			if source.kotlin {
+				return plan{cold: true, why: "Kotlin changed"}
+			}
+		}
+	}
+	for k, cached := range c.Sources {
+		if _, ok := in[k]; !ok {
+			p.gone = append(p.gone, k)
+			if cached.Kotlin {
+				return plan{cold: true, why: "Kotlin changed"}
+			}
+		}
+	}
+	sort.Strings(p.changed)
+	sort.Strings(p.gone)
+	return p
+}
+
+// dependents is every source that depends on one of from, directly or through
+// another, leaving out the ones in from.
+func dependents(sources map[string]*cachedSource, from []string) []string {
+	owner := map[string]string{}
+	for k, source := range sources {
+		for _, entry := range source.Classes {
+			owner[strings.TrimSuffix(entry, ".class")] = k
+		}
+	}
+
+	usedBy := map[string][]string{}
+	for k, source := range sources {
+		for _, class := range source.Uses {
+			if o, ok := owner[class]; ok && o != k {
+				usedBy[o] = append(usedBy[o], k)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, k := range from {
+		seen[k] = true
+	}
+	queue := append([]string{}, from...)
+	var out []string
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		for _, user := range usedBy[k] {
+			if !seen[user] {
+				seen[user] = true
+				out = append(out, user)
+				queue = append(queue, user)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// constantReaders is every source whose text names a class in one of from.
+// javac copies a compile-time constant into whoever reads it, and the class
+// file it writes has nothing to say where the value came from, so the text is
+// all there is to go on. A reader with constants of its own may have made one
+// out of what it read, and its readers are followed in turn.
+//
+// Naming a class is not always reading a constant from it, so this compiles
+// more than it has to. Missing one ships the old value with nothing to show
+// for it, and a Constants.java that every OpMode reads is how teams tune.
+func constantReaders(sources map[string]*cachedSource, in map[string]input, from []string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, k := range from {
+		seen[k] = true
+	}
+	queue := append([]string{}, from...)
+	var out []string
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		named := namesOf(sources[k])
+		if named == nil {
+			continue
+		}
+		for reader, source := range sources {
+			file, ok := in[reader]
+			if seen[reader] || !ok {
+				continue
+			}
+			text, err := os.ReadFile(file.path)
+			if err != nil {
+				return nil, err
+			}
+			if !named.Match(text) {
+				continue
+			}
+			seen[reader] = true
+			out = append(out, reader)
+			if source.Constants != "" {
+				queue = append(queue, reader)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// namesOf matches the simple name of any class a source compiled to, as a
+// whole word, or is nil when it compiled to none that can be named.
+func namesOf(source *cachedSource) *regexp.Regexp {
+	if source == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, entry := range source.Classes {
+		for _, part := range strings.Split(path.Base(strings.TrimSuffix(entry, ".class")), "$") {
+			if part != "" && (part[0] < '0' || part[0] > '9') && !seen[part] {
+				seen[part] = true
+				names = append(names, regexp.QuoteMeta(part))
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return regexp.MustCompile(`\b(?:` + strings.Join(names, "|") + `)\b`)
+}
+
+// drop takes sources out of the cache, classes and dex with them.
+func (c *compileCache) drop(keys []string) {
+	for _, k := range keys {
+		source, ok := c.Sources[k]
+		if !ok {
+			continue
+		}
+		for _, entry := range source.Classes {
+			os.Remove(filepath.Join(c.classes(), filepath.FromSlash(entry)))
+			os.Remove(filepath.Join(c.dexParts(), filepath.FromSlash(dexName(entry))))
+		}
+		delete(c.Sources, k)
+	}
+}
+
+// dexName is where a class's own dex is kept.
+func dexName(entry string) string { return strings.TrimSuffix(entry, ".class") + ".dex" }
+
+// update brings the cached classes up to date with the sources, returning how
+// many of the team's sources were compiled, and whether that was all of them
+// and why.
+func (c *compileCache) update(tc hotreload.Toolchain, cp Classpath, sources Sourced, in map[string]input, key, work string) (int, bool, string, error) {
+	p := c.plan(in, key)
+	if !p.cold {
+		n, err := c.incremental(tc, cp, in, p, work)
+		if err == nil {
+			return n, false, "", nil
+		}
+		if !errors.Is(err, errStartAgain) {
+			return 0, false, "", err
+		}
+		p.why = "a change could not be followed"
+	}
+
+	if err := c.cold(tc, cp, sources, in, key, work); err != nil {
+		return 0, true, p.why, err
+	}
+	n := 0
+	for _, source := range in {
+		if !source.generated {
+			n++
+		}
+	}
+	return n, true, p.why, nil
+}
+
+// cold compiles everything into an emptied cache.
+func (c *compileCache) cold(tc hotreload.Toolchain, cp Classpath, sources Sourced, in map[string]input, key, work string) error {
+	if err := os.RemoveAll(c.dir); err != nil {
+		return err
+	}
+	c.Key, c.Whole, c.WholeDex, c.Sources = key, false, false, map[string]*cachedSource{}
+	if err := c.save(); err != nil {
+		return err
+	}
+
+	classes := c.classes()
+	if err := os.MkdirAll(classes, 0o755); err != nil {
+		return err
+	}
+
+	// Kotlin first, because the Kotlin compiler reads the Java sources for
+	// their signatures without compiling them, while javac cannot read Kotlin
+	// at all and needs the classes it produced. Either half may reference the
+	// other, and this is the order that allows it.
+	if len(sources.Kotlin) > 0 {
+		if err := compileKotlin(tc, cp, sources, classes, work); err != nil {
+			return err
+		}
+	}
+	if len(sources.Java) > 0 {
+		if err := compileJava(tc, cp, sources.Java, classes, work); err != nil {
+			return err
+		}
+	}
+
+	entries, err := classFiles(classes)
+	if err != nil {
+		return err
+	}
+	found, whole, err := trace(classes, entries, in)
+	if err != nil {
+		return err
+	}
+	c.Sources, c.Whole = found, whole
+	c.keepUses()
+	return c.save()
+}
+
+// incremental compiles what changed and whatever that reaches.
+func (c *compileCache) incremental(tc hotreload.Toolchain, cp Classpath, in map[string]input, p plan, work string) (int, error) {
+	// The old entries are kept aside: a class that an edit took away is
+	// still named by whatever used it, and that is how its users are found.
+	old := map[string]*cachedSource{}
+	for _, k := range append(append([]string{}, p.changed...), p.gone...) {
+		if cached, ok := c.Sources[k]; ok {
+			old[k] = cached
+		}
+	}
+	c.drop(append(append([]string{}, p.changed...), p.gone...))
+	if err := c.save(); err != nil {
+		return 0, err
+	}
+
+	fresh := filepath.Join(work, "changed")
+	compiled := map[string]*cachedSource{}
+	if len(p.changed) > 0 {
+		found, err := c.compileSome(tc, cp, in, p.changed, fresh, work, nil)
+		if err != nil {
+			return 0, err
+		}
+		compiled = found
+	}
+
+	// What moved is every file that now offers something different, and the
+	// graph it moves through is the untouched files plus both versions of
+	// the ones that changed.
+	graph := map[string]*cachedSource{}
+	for k, source := range c.Sources {
+		graph[k] = source
+	}
+	//
+	// A constant that moved is followed by name instead, since its readers
+	// were given its value and not a reference to it.
+	moved := append([]string{}, p.gone...)
+	var constants []string
+	for _, k := range p.gone {
+		if old[k] != nil && old[k].Constants != "" {
+			constants = append(constants, k)
+		}
+	}
+	for k, was := range old {
+		graph[k] = was
+	}
+	for k, now := range compiled {
+		was, ok := old[k]
+		if ok && was.ABI == now.ABI {
+			continue
+		}
+		moved = append(moved, k)
+		if ok && was.Constants != now.Constants {
+			constants = append(constants, k)
+		}
+		merged := *now
+		if ok {
+			merged.Classes = append(append([]string{}, now.Classes...), was.Classes...)
+		}
+		graph[k] = &merged
+	}
+
+	readers, err := constantReaders(graph, in, constants)
+	if err != nil {
+		return 0, err
+	}
+	users := without(append(dependents(graph, moved), readers...), append(append([]string{}, p.changed...), p.gone...))
+	if len(users) > 0 {
+		for _, k := range users {
+			if c.Sources[k].Kotlin {
+				return 0, errStartAgain
+			}
+		}
+		c.drop(users)
+		if err := c.save(); err != nil {
+			return 0, err
+		}
+
+		again := filepath.Join(work, "dependents")
+		found, err := c.compileSome(tc, cp, in, users, again, work, []string{fresh})
+		if err != nil {
+			return 0, err
+		}
+		if err := merge(again, c.classes()); err != nil {
+			return 0, err
+		}
+		for k, source := range found {
+			compiled[k] = source
+		}
+	}
+
+	if len(p.changed) > 0 {
+		if err := merge(fresh, c.classes()); err != nil {
+			return 0, err
+		}
+	}
+
+	n := 0
+	for k, source := range compiled {
+		c.Sources[k] = source
+		if !in[k].generated {
+			n++
+		}
+	}
+	c.keepUses()
+	return n, c.save()
+}
+
+// compileSome compiles some of the sources into out, against the cache and
+// anything in first, and traces what they produced.
+func (c *compileCache) compileSome(tc hotreload.Toolchain, cp Classpath, in map[string]input, keys []string, out, work string, first []string) (map[string]*cachedSource, error) {
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(keys))
+	subset := map[string]input{}
+	for _, k := range keys {
+		source, ok := in[k]
+		if !ok {
+			return nil, errStartAgain
+		}
+		files = append(files, source.path)
+		subset[k] = source
+	}
+
+	if err := compileJava(tc, cp, files, out, work, append(append([]string{}, first...), c.classes())...); err != nil {
+		return nil, err
+	}
+
+	entries, err := classFiles(out)
+	if err != nil {
+		return nil, err
+	}
+	found, whole, err := trace(out, entries, subset)
+	if err != nil {
+		return nil, err
+	}
+	if !whole {
+		return nil, errStartAgain
+	}
+	return found, nil
+}
+
+// trace reads each compiled class and puts it under the source it came from,
+// reporting whether every class found one.
+func trace(root string, entries []string, in map[string]input) (map[string]*cachedSource, bool, error) {
+	found := map[string]*cachedSource{}
+	abis := map[string][]string{}
+	constants := map[string][]string{}
+	whole := true
+
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(entry)))
+		if err != nil {
+			return nil, false, err
+		}
+		info, err := readClass(data)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", entry, err)
+		}
+
+		// A class names its file without a directory, and lives in its
+		// package's; together they are the file, as long as the file is where
+		// its package says it is.
+		key := info.Source
+		if dir := path.Dir(entry); dir != "." {
+			key = dir + "/" + info.Source
+		}
+		source, ok := in[key]
+		if info.Source == "" || !ok {
+			whole = false
+			continue
+		}
+
+		cached := found[key]
+		if cached == nil {
+			cached = &cachedSource{Hash: source.hash, Kotlin: source.kotlin}
+			found[key] = cached
+		}
+		cached.Classes = append(cached.Classes, entry)
+		cached.Uses = append(cached.Uses, info.Uses...)
+		if !anonymous(info.Name) {
+			abis[key] = append(abis[key], info.Name+" "+info.ABI)
+		}
+		if info.Constants != "" {
+			constants[key] = append(constants[key], info.Name+"\n"+info.Constants)
+		}
+	}
+
+	for key, cached := range found {
+		sort.Strings(cached.Classes)
+		sort.Strings(abis[key])
+		sum := sha256.Sum256([]byte(strings.Join(abis[key], "\n")))
+		cached.ABI = hex.EncodeToString(sum[:])
+		if len(constants[key]) > 0 {
+			sort.Strings(constants[key])
+			sum := sha256.Sum256([]byte(strings.Join(constants[key], "\n")))
+			cached.Constants = hex.EncodeToString(sum[:])
+		}
+	}
+	return found, whole, nil
+}
+
+// keepUses trims what each source uses to the team's own classes, which are
+// the only ones a change here can move, and keeps the manifest small.
+func (c *compileCache) keepUses() {
+	ours := map[string]bool{}
+	for _, source := range c.Sources {
+		for _, entry := range source.Classes {
+			ours[strings.TrimSuffix(entry, ".class")] = true
+		}
+	}
+	for _, source := range c.Sources {
+		var kept []string
+		seen := map[string]bool{}
+		for _, class := range source.Uses {
+			if ours[class] && !seen[class] {
+				seen[class] = true
+				kept = append(kept, class)
+			}
+		}
+		sort.Strings(kept)
+		source.Uses = kept
+	}
+}
+
+// merge moves a compile's classes into the cache.
+func merge(from, to string) error {
+	entries, err := classFiles(from)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		dest := filepath.Join(to, filepath.FromSlash(entry))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(from, filepath.FromSlash(entry)), dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// without is keys less any in drop.
+func without(keys, drop []string) []string {
+	skip := map[string]bool{}
+	for _, k := range drop {
+		skip[k] = true
+	}
+	var out []string
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if !skip[k] && !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// dex produces classes.dex in out from each class's own dex, dexing first the
+// classes that do not have one yet.
+func (c *compileCache) dex(tc hotreload.Toolchain, entries []string, work, out string) error {
+	if c.WholeDex {
+		return errors.New("this d8 dexes whole jars only")
+	}
+
+	var missing []string
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(c.dexParts(), filepath.FromSlash(dexName(entry)))); err != nil {
+			missing = append(missing, entry)
+		}
+	}
+
+	if len(missing) > 0 {
+		jar := filepath.Join(work, "undexed.jar")
+		if err := writeJar(jar, c.classes(), missing); err != nil {
+			return err
+		}
+		parts := filepath.Join(work, "dex-parts")
+		d8 := exec.Command(tc.D8, "--intermediate", "--file-per-class-file",
+			"--min-api", "24", "--output", parts, jar)
+		if result, err := d8.CombinedOutput(); err != nil {
+			return fmt.Errorf("dexing classes one at a time failed:\n%s", lastLines(string(result), 10))
+		}
+		for _, entry := range missing {
+			name := filepath.FromSlash(dexName(entry))
+			dest := filepath.Join(c.dexParts(), name)
+			if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+				return err
+			}
+			if err := os.Rename(filepath.Join(parts, name), dest); err != nil {
+				return fmt.Errorf("d8 did not dex %s on its own: %w", entry, err)
+			}
+		}
+	}
+
+	// d8 reads dex out of an archive as readily as classes, and one archive
+	// is one argument however many classes there are.
+	bundle := filepath.Join(work, "dex-parts.zip")
+	if err := zipDex(bundle, c.dexParts(), entries); err != nil {
+		return err
+	}
+	d8 := exec.Command(tc.D8, "--min-api", "24", "--output", out, bundle)
+	if result, err := d8.CombinedOutput(); err != nil {
+		return fmt.Errorf("merging the dex failed:\n%s", lastLines(string(result), 10))
+	}
+	return nil
+}
+
+// zipDex packs the cached dex for entries into one archive.
+func zipDex(archivePath, root string, entries []string) error {
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	archive := zip.NewWriter(out)
+	for _, entry := range entries {
+		name := dexName(entry)
+		source, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		w, err := archive.Create(name)
+		if err == nil {
+			_, err = io.Copy(w, source)
+		}
+		source.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// touch marks a source as edited without editing it, so the next compile
+// recompiles it as if it had been saved with the same contents. A source the
+// cache does not hold is compiled next time anyway.
+func touch(root, key string) error {
+	c := loadCache(root)
+	source, ok := c.Sources[key]
+	if !ok {
+		return nil
+	}
+	source.Hash = ""
+	return c.save()
+}
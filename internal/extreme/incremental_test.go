@@ -0,0 +1,358 @@
+package extreme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andreibanu/pusher/internal/hotreload"
+)
+
+// classFile builds just enough of a class file for readClass: a name, a super
+// class, some members and the file it came from.
+type classFile struct {
+	name, super, source string
+	fields, methods     []classMember
+}
+
+type classMember struct {
+	access     int
+	name, desc string
+	constant   int32 // a ConstantValue, when nonzero
+}
+
+func (c classFile) bytes() []byte {
+	var pool bytes.Buffer
+	count := 1
+	index := map[string]int{}
+
+	utf := func(s string) int {
+		if i, ok := index["u"+s]; ok {
+			return i
+		}
+		pool.WriteByte(1)
+		binary.Write(&pool, binary.BigEndian, uint16(len(s)))
+		pool.WriteString(s)
+		index["u"+s] = count
+		count++
+		return count - 1
+	}
+	class := func(s string) int {
+		if i, ok := index["c"+s]; ok {
+			return i
+		}
+		name := utf(s)
+		pool.WriteByte(7)
+		binary.Write(&pool, binary.BigEndian, uint16(name))
+		index["c"+s] = count
+		count++
+		return count - 1
+	}
+	integer := func(v int32) int {
+		pool.WriteByte(3)
+		binary.Write(&pool, binary.BigEndian, v)
+		count++
+		return count - 1
+	}
+
+	var body bytes.Buffer
+	u2 := func(v int) { binary.Write(&body, binary.BigEndian, uint16(v)) }
+
+	u2(0x0021)
+	u2(class(c.name))
+	u2(class(c.super))
+	u2(0)
+
+	for _, table := range [][]classMember{c.fields, c.methods} {
+		u2(len(table))
+		for _, m := range table {
+			u2(m.access)
+			u2(utf(m.name))
+			u2(utf(m.desc))
+			if m.constant != 0 {
+				u2(1)
+				u2(utf("ConstantValue"))
+				binary.Write(&body, binary.BigEndian, uint32(2))
+				u2(integer(m.constant))
+			} else {
+				u2(0)
+			}
+		}
+	}
+
+	u2(1)
+	u2(utf("SourceFile"))
+	binary.Write(&body, binary.BigEndian, uint32(2))
+	u2(utf(c.source))
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, uint32(0xCAFEBABE))
+	binary.Write(&out, binary.BigEndian, uint16(0))
+	binary.Write(&out, binary.BigEndian, uint16(52))
+	binary.Write(&out, binary.BigEndian, uint16(count))
+	out.Write(pool.Bytes())
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func drive() classFile {
+	return classFile{
+		name: "org/team/Drive", super: "org/team/Base", source: "Drive.java",
+		fields: []classMember{
+			{access: 0x0019, name: "SPEED", desc: "I", constant: 3},
+			{access: 0x0002, name: "motor", desc: "Lorg/team/Motor;"},
+		},
+		methods: []classMember{
+			{access: 0x0001, name: "go", desc: "(Ljava/util/List<Lorg/team/Wheel;>;)V"},
+		},
+	}
+}
+
+func TestAClassFileSaysWhatItUsesAndOffers(t *testing.T) {
+	info, err := readClass(drive().bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "org/team/Drive" || info.Source != "Drive.java" {
+		t.Errorf("read %s from %s", info.Name, info.Source)
+	}
+	for _, want := range []string{"org/team/Base", "org/team/Motor", "org/team/Wheel", "java/util/List"} {
+		if !strings.Contains(strings.Join(info.Uses, " "), want) {
+			t.Errorf("uses %v, missing %s", info.Uses, want)
+		}
+	}
+}
+
+// Private members are nobody else's business, so changing one changes nothing
+// a dependent compiled against. A constant is copied into its users, so
+// changing one changes them.
+func TestTheABIIsWhatOtherClassesCanSee(t *testing.T) {
+	abi := func(c classFile) string {
+		info, err := readClass(c.bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.ABI
+	}
+	base := abi(drive())
+
+	private := drive()
+	private.fields[1].desc = "Lorg/team/OtherMotor;"
+	if abi(private) != base {
+		t.Error("a private field changed the ABI")
+	}
+
+	constant := drive()
+	constant.fields[0].constant = 4
+	if abi(constant) == base {
+		t.Error("a changed constant did not change the ABI")
+	}
+
+	signature := drive()
+	signature.methods[0].desc = "(I)V"
+	if abi(signature) == base {
+		t.Error("a changed signature did not change the ABI")
+	}
+}
+
+func TestDependentsAreFollowedThroughOtherFiles(t *testing.T) {
+	sources := map[string]*cachedSource{
+		"org/team/Motor.java": {Classes: []string{"org/team/Motor.class"}},
+		"org/team/Drive.java": {Classes: []string{"org/team/Drive.class", "org/team/Drive$1.class"}, Uses: []string{"org/team/Motor"}},
+		"org/team/Auto.java":  {Classes: []string{"org/team/Auto.class"}, Uses: []string{"org/team/Drive"}},
+		"org/team/Arm.java":   {Classes: []string{"org/team/Arm.class"}},
+	}
+
+	got := dependents(sources, []string{"org/team/Motor.java"})
+	if strings.Join(got, ",") != "org/team/Auto.java,org/team/Drive.java" {
+		t.Errorf("dependents = %v", got)
+	}
+	if got := dependents(sources, []string{"org/team/Arm.java"}); len(got) != 0 {
+		t.Errorf("nothing uses Arm, but %v depend on it", got)
+	}
+}
+
+func TestThePlanCompilesFromScratchWhenItCannotFollow(t *testing.T) {
+	cached := func() *compileCache {
+		return &compileCache{Key: "k", Whole: true, Sources: map[string]*cachedSource{
+			"a/Drive.java": {Hash: "1"},
+			"a/Arm.java":   {Hash: "2"},
+			"a/Lift.kt":    {Hash: "3", Kotlin: true},
+		}}
+	}
+	in := map[string]input{
+		"a/Drive.java": {hash: "1"},
+		"a/Arm.java":   {hash: "2"},
+		"a/Lift.kt":    {hash: "3", kotlin: true},
+	}
+
+	if p := cached().plan(in, "k"); p.cold || len(p.changed) != 0 || len(p.gone) != 0 {
+		t.Errorf("nothing changed, but the plan is %+v", p)
+	}
+	if p := cached().plan(in, "other"); !p.cold {
+		t.Error("a new classpath reused the old classes")
+	}
+
+	edited := map[string]input{"a/Drive.java": {hash: "9"}, "a/Lift.kt": {hash: "3", kotlin: true}}
+	p := cached().plan(edited, "k")
+	if p.cold || strings.Join(p.changed, ",") != "a/Drive.java" || strings.Join(p.gone, ",") != "a/Arm.java" {
+		t.Errorf("plan = %+v", p)
+	}
+
+	kotlin := map[string]input{"a/Drive.java": {hash: "1"}, "a/Arm.java": {hash: "2"}, "a/Lift.kt": {hash: "4", kotlin: true}}
+	if p := cached().plan(kotlin, "k"); !p.cold {
+		t.Error("a Kotlin edit was compiled on its own")
+	}
+
+	partial := cached()
+	partial.Whole = false
+	if p := partial.plan(in, "k"); !p.cold {
+		t.Error("a cache with untraced classes was trusted")
+	}
+}
+
+// A class is put under the file its package and SourceFile name, and one that
+// cannot be makes the cache untrustworthy rather than quietly incomplete.
+func TestClassesAreTracedToTheirFiles(t *testing.T) {
+	root := t.TempDir()
+	write := func(entry string, c classFile) {
+		path := filepath.Join(root, filepath.FromSlash(entry))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, c.bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inner := drive()
+	inner.name, inner.fields, inner.methods = "org/team/Drive$1", nil, nil
+	write("org/team/Drive.class", drive())
+	write("org/team/Drive$1.class", inner)
+
+	in := map[string]input{"org/team/Drive.java": {hash: "h"}}
+	found, whole, err := trace(root, []string{"org/team/Drive.class", "org/team/Drive$1.class"}, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !whole || found["org/team/Drive.java"] == nil || len(found["org/team/Drive.java"].Classes) != 2 {
+		t.Fatalf("traced %+v, whole %v", found, whole)
+	}
+
+	// Adding an anonymous class is not a change anyone else can see.
+	alone, _, _ := trace(root, []string{"org/team/Drive.class"}, in)
+	if alone["org/team/Drive.java"].ABI != found["org/team/Drive.java"].ABI {
+		t.Error("an anonymous class changed what the file offers")
+	}
+
+	stray := drive()
+	stray.source = "Elsewhere.java"
+	write("org/team/Stray.class", stray)
+	if _, whole, _ := trace(root, []string{"org/team/Stray.class"}, in); whole {
+		t.Error("a class with no file was taken as traced")
+	}
+}
+
+// javac copies a constant into whoever reads it and leaves no reference
+// behind, so the readers of a changed one are found by what their text names.
+func TestAChangedConstantTakesTheFilesThatNameItsClass(t *testing.T) {
+	root := t.TempDir()
+	in := map[string]input{}
+	source := func(key, text string) {
+		path := filepath.Join(root, filepath.FromSlash(key))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		in[key] = input{key: key, path: path}
+	}
+	source("org/team/Constants.java", "public class Constants { public static final double KP = 0.02; }")
+	source("org/team/Tuning.java", "public class Tuning { public static final double LIFT_KP = Constants.KP * 2; }")
+	source("org/team/Auto.java", "class Auto { double kp = Constants.KP; }")
+	source("org/team/Lift.java", "class Lift { double kp = Tuning.LIFT_KP; }")
+	source("org/team/Arm.java", "class Arm { String label = \"ConstantsOfTheArm\"; }")
+
+	// None of them uses Constants as far as their class files say.
+	sources := map[string]*cachedSource{
+		"org/team/Constants.java": {Classes: []string{"org/team/Constants.class"}, Constants: "c"},
+		"org/team/Tuning.java":    {Classes: []string{"org/team/Tuning.class"}, Constants: "t"},
+		"org/team/Auto.java":      {Classes: []string{"org/team/Auto.class"}},
+		"org/team/Lift.java":      {Classes: []string{"org/team/Lift.class"}},
+		"org/team/Arm.java":       {Classes: []string{"org/team/Arm.class"}},
+	}
+
+	got, err := constantReaders(sources, in, []string{"org/team/Constants.java"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "org/team/Auto.java,org/team/Lift.java,org/team/Tuning.java" {
+		t.Errorf("readers = %v", got)
+	}
+}
+
+func TestAClassFileSaysWhichConstantsItHas(t *testing.T) {
+	info, err := readClass(drive().bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(info.Constants, "SPEED I constant 3") {
+		t.Errorf("constants = %q", info.Constants)
+	}
+
+	none := drive()
+	none.fields = none.fields[1:]
+	if info, _ := readClass(none.bytes()); info.Constants != "" {
+		t.Errorf("a class without constants has %q", info.Constants)
+	}
+}
+
+// The tuning edit itself, through javac: an OpMode reads a value out of
+// Constants.java, the value changes, and the next compile has to carry it.
+func TestAnEditedConstantReachesTheClassesThatReadIt(t *testing.T) {
+	javac, err := exec.LookPath("javac")
+	if err != nil {
+		t.Skip("no javac")
+	}
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	constants := filepath.Join(src, "org", "team", "Constants.java")
+	opmode := filepath.Join(src, "org", "team", "Auto.java")
+	os.MkdirAll(filepath.Dir(constants), 0o755)
+	write := func(path, text string) {
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(constants, "package org.team;\npublic class Constants { public static final int TICKS = 40000; }\n")
+	write(opmode, "package org.team;\nclass Auto { int target() { return Constants.TICKS; } }\n")
+
+	tc := hotreload.Toolchain{Javac: javac}
+	sources := Sourced{Java: []string{constants, opmode}}
+	c := &compileCache{dir: filepath.Join(root, "cache")}
+	c.Sources = map[string]*cachedSource{}
+	compile := func() {
+		t.Helper()
+		in, err := inputs(sources, src, filepath.Join(root, "generated"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := c.update(tc, Classpath{}, sources, in, "k", t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	compile()
+
+	write(constants, "package org.team;\npublic class Constants { public static final int TICKS = 50000; }\n")
+	compile()
+
+	data, err := os.ReadFile(filepath.Join(c.classes(), "org", "team", "Auto.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 50000 does not fit a short, so javac puts it in the pool as an integer.
+	if !bytes.Contains(data, []byte{3, 0, 0, 0xc3, 0x50}) {
+		t.Error("Auto still has the old value of TICKS")
+	}
+}
@@ -33,27 +33,7 @@ const signatureFile = "/data/local/tmp/pusher/extreme-signature"
 // Team sources are deliberately absent: changing them is precisely the case
 // that should reload rather than install.
 func signatureInputs(root string) []string {
-	var paths []string
-
-	// Build files anywhere in the project.
-	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-		if info.IsDir() {
-			// Build outputs are derived, and walking them is slow.
-			switch info.Name() {
-			case "build", ".git", ".gradle", ".idea":
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		if isBuildInput(info.Name()) {
-			paths = append(paths, path)
-		}
-		return nil
-	})
+	paths := buildInputs(root)
 
 	// The SDK module, and the parts of the team module that are packaged:
 	// manifest, resources and assets all end up in the APK.
@@ -79,6 +59,30 @@ func signatureInputs(root string) []string {
 	return paths
 }
 
+// buildInputs are the build files anywhere in the project.
+func buildInputs(root string) []string {
+	var paths []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			// Build outputs are derived, and walking them is slow.
+			switch info.Name() {
+			case "build", ".git", ".gradle", ".idea":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if isBuildInput(info.Name()) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths
+}
+
 // isBuildInput reports whether a file decides what the APK contains.
 //
 // The Kotlin DSL names build files .gradle.kts, which does not end in .gradle.
@@ -107,9 +111,21 @@ func isBuildInput(name string) bool {
 
 // Signature identifies everything the APK is built from except team code.
 func Signature(root string) (string, error) {
+	return sign(root, signatureInputs(root))
+}
+
+// BuildSignature identifies the build files alone, which are what decide the
+// classpath. A resource changing needs an install but compiles against the
+// same jars.
+func BuildSignature(root string) (string, error) {
+	paths := buildInputs(root)
+	sort.Strings(paths)
+	return sign(root, paths)
+}
+
+func sign(root string, paths []string) (string, error) {
 	sum := sha256.New()
 
-	paths := signatureInputs(root)
 	if len(paths) == 0 {
 		return "", fmt.Errorf("nothing to sign in %s", root)
 	}
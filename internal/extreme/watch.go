@@ -0,0 +1,151 @@
+package extreme
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// One save is rarely one event. Editors write a temporary file and rename it
+// over the original, Android Studio saves every open file at once when focus
+// leaves it, and a refactor touches a dozen files in a burst. Reloading on the
+// first event would compile a half-written tree and then do it again a moment
+// later, so events are gathered until the tree has been quiet for a moment and
+// handed back together.
+//
+// The whole of TeamCode/src is watched rather than only the Java. A change to
+// the manifest or a resource is not something a reload can carry, and the
+// signature is what notices that; the watcher only has to say something moved.
+
+// Settle is how long the tree has to be quiet before a burst of saves counts
+// as finished.
+const Settle = 300 * time.Millisecond
+
+// ErrStopped is a watch that was closed.
+var ErrStopped = errors.New("stopped watching")
+
+// Saves watches the team's sources.
+type Saves struct {
+	root  string
+	fs    *fsnotify.Watcher
+	quiet time.Duration
+}
+
+// WatchSaves starts watching everything under the module's src directory.
+func WatchSaves(p *Project) (*Saves, error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Saves{root: p.Root, fs: fs, quiet: Settle}
+	if _, err := s.add(filepath.Join(p.Root, Module, "src")); err != nil {
+		fs.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// add watches dir and every directory under it, returning the files already
+// there. fsnotify does not recurse, so a package created while watching has to
+// be added as it appears, and whatever was written into it before then would
+// otherwise go unnoticed.
+func (s *Saves) add(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return s.fs.Add(path)
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
+
+// Next waits for a burst of saves to finish and returns the files it touched,
+// relative to the project and sorted.
+func (s *Saves) Next() ([]string, error) {
+	pending := map[string]bool{}
+	var settled <-chan time.Time
+
+	touch := func(path string) {
+		if ignored(path) {
+			return
+		}
+		if rel, err := filepath.Rel(s.root, path); err == nil {
+			path = rel
+		}
+		pending[path] = true
+		settled = time.After(s.quiet)
+	}
+
+	for {
+		select {
+		case event, ok := <-s.fs.Events:
+			if !ok {
+				return nil, ErrStopped
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					files, _ := s.add(event.Name)
+					for _, file := range files {
+						touch(file)
+					}
+					continue
+				}
+			}
+			touch(event.Name)
+
+		case err, ok := <-s.fs.Errors:
+			if !ok {
+				return nil, ErrStopped
+			}
+			return nil, err
+
+		case <-settled:
+			files := make([]string, 0, len(pending))
+			for file := range pending {
+				files = append(files, file)
+			}
+			sort.Strings(files)
+			return files, nil
+		}
+	}
+}
+
+// Close stops watching, and makes Next return ErrStopped.
+func (s *Saves) Close() error { return s.fs.Close() }
+
+// ignored reports whether a file is an editor's scratch rather than a save.
+func ignored(path string) bool {
+	name := filepath.Base(path)
+
+	switch {
+	case strings.HasSuffix(name, "~"),
+		strings.HasPrefix(name, ".#"),
+		strings.HasPrefix(name, "#") && strings.HasSuffix(name, "#"),
+		strings.Contains(name, "___jb_tmp___"),
+		strings.Contains(name, "___jb_old___"),
+		name == "4913", // vim checking it can write the directory
+		name == ".DS_Store":
+		return true
+	}
+
+	switch filepath.Ext(name) {
+	case ".swp", ".swx", ".swo", ".tmp":
+		return true
+	}
+	return false
+}
@@ -81,40 +81,25 @@ func ProjectDir(wrapper string) string {
 	return dir
 }
 
-// Build runs assembleDebug, streaming output to the writer.
+// Build runs assembleDebug, streaming output to the writer. A failure is a
+// *BuildError, with the compile errors and hints also listed on the writer.
 func Build(wrapper string, offline bool, outputWriter io.Writer) error {
-	if _, err := os.Stat(wrapper); err != nil {
-		return fmt.Errorf("gradle wrapper not found: %s", wrapper)
-	}
-
-	if runtime.GOOS != "windows" {
-		if err := os.Chmod(wrapper, 0755); err != nil {
-			return fmt.Errorf("failed to make %s executable: %w", wrapperName(), err)
-		}
-	}
-
-	threads := config.GetThreads()
 	args := []string{
 		"assembleDebug",
 		"--parallel",
 		"--build-cache",
-		fmt.Sprintf("-Dorg.gradle.workers.max=%d", threads),
+		// The plain console names every task on a line of its own, which is
+		// what the progress line follows, whether or not there is a terminal.
+		"--console=plain",
+		workersFlag(),
 	}
 	if offline {
 		args = append(args, "--offline")
 	}
 
-	cmd := exec.Command(wrapper, args...)
-
-	wrapperDir := filepath.Dir(wrapper)
-	cmd.Dir = wrapperDir
-
-	if os.Getenv("JAVA_HOME") == "" {
-		if jdk := androidStudioJDK(); jdk != "" {
-			cmd.Env = os.Environ()
-			cmd.Env = append(cmd.Env, "JAVA_HOME="+jdk)
-			cmd.Env = append(cmd.Env, "PATH="+filepath.Join(jdk, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
-		}
+	cmd, err := command(wrapper, args...)
+	if err != nil {
+		return err
 	}
 
 	stdout, err := cmd.StdoutPipe()
@@ -127,28 +112,74 @@ func Build(wrapper string, offline bool, outputWriter io.Writer) error {
 		return fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
+	waitForWarm()
+
+	// Read before the build rather than after, so a file saved while it runs
+	// counts as a change for the next one.
+	root := ProjectDir(wrapper)
+	inputs := Inputs(root)
+
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start gradle: %w", err)
 	}
 
+	out := newConsole(outputWriter)
 	done := make(chan bool)
-	go streamOutput(stdout, outputWriter, done)
-	go streamOutput(stderr, outputWriter, done)
+	go streamOutput(stdout, out, done)
+	go streamOutput(stderr, out, done)
 
 	<-done
 	<-done
 
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("gradle build failed: %w", err)
+	err = cmd.Wait()
+	report := out.finish(root, err != nil)
+	if err != nil {
+		return &BuildError{Report: report, err: err}
 	}
 
+	recordBuilt(root, inputs)
 	return nil
 }
 
-func streamOutput(reader io.Reader, writer io.Writer, done chan bool) {
+// command is the wrapper run in its project with args, on Android Studio's JDK
+// when nothing else is set. Every run goes through here, so a warm-up and the
+// build after it agree on the JDK, which the daemon they share is tied to.
+func command(wrapper string, args ...string) (*exec.Cmd, error) {
+	if _, err := os.Stat(wrapper); err != nil {
+		return nil, fmt.Errorf("gradle wrapper not found: %s", wrapper)
+	}
+
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(wrapper, 0755); err != nil {
+			return nil, fmt.Errorf("failed to make %s executable: %w", wrapperName(), err)
+		}
+	}
+
+	cmd := exec.Command(wrapper, args...)
+	cmd.Dir = filepath.Dir(wrapper)
+
+	if os.Getenv("JAVA_HOME") == "" {
+		if jdk := androidStudioJDK(); jdk != "" {
+			cmd.Env = os.Environ()
+			cmd.Env = append(cmd.Env, "JAVA_HOME="+jdk)
+			cmd.Env = append(cmd.Env, "PATH="+filepath.Join(jdk, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+		}
+	}
+
+	return cmd, nil
+}
+
+func workersFlag() string {
+	return fmt.Sprintf("-Dorg.gradle.workers.max=%d", config.GetThreads())
+}
+
+func streamOutput(reader io.Reader, out *console, done chan bool) {
 	scanner := bufio.NewScanner(reader)
+	// A stack trace from a daemon that ran out of memory can carry lines
+	// longer than the scanner's default.
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	for scanner.Scan() {
-		fmt.Fprintln(writer, scanner.Text())
+		out.line(scanner.Text())
 	}
 	done <- true
 }
@@ -0,0 +1,350 @@
+package gradle
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// A failed build used to mean scrolling back through a few hundred lines of
+// Gradle for the first "error:". Most of those lines are Gradle naming the task
+// it has reached, which is worth one line that keeps up rather than hundreds.
+// The compiler's errors are worth a list at the end, by file and line. And the
+// few failures every team meets sooner or later are worth a sentence on what
+// to do: an SDK that cannot be found, a daemon out of memory, a dependency
+// that was never cached for an offline build.
+//
+// Pusher Extreme runs javac and kotlinc without Gradle around them, and they
+// say the same things in the same words, so it reads their output with this
+// too.
+
+// Diagnostic is one error the compiler reported against a line.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// Relative is the diagnostic with its file relative to root, when it is under
+// it, which is how a person finds it in their editor.
+func (d Diagnostic) Relative(root string) Diagnostic {
+	if rel, err := filepath.Rel(root, d.File); err == nil && !strings.HasPrefix(rel, "..") {
+		d.File = rel
+	}
+	return d
+}
+
+func (d Diagnostic) String() string {
+	if d.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+}
+
+// Hint is a failure recognised by what the output said, with what to do.
+type Hint struct {
+	Problem string
+	Fix     string
+}
+
+func (h Hint) String() string { return h.Problem + " " + h.Fix }
+
+// signatures are the failures worth a hint, each known by any of a few
+// phrases Gradle, the Android plugin or the JVM use for it.
+var signatures = []struct {
+	phrases []string
+	hint    Hint
+}{
+	{
+		[]string{"SDK location not found"},
+		Hint{"The Android SDK cannot be found.",
+			"Open the project in Android Studio once, which writes sdk.dir to local.properties, or set ANDROID_HOME to the SDK."},
+	},
+	{
+		[]string{"Failed to find target with hash string", "Failed to find Build Tools revision"},
+		Hint{"An SDK platform the project needs is not installed.",
+			"Install the version the error names from Android Studio's SDK Manager."},
+	},
+	{
+		[]string{"OutOfMemoryError", "GC overhead limit exceeded", "JVM heap space is exhausted",
+			"Gradle build daemon disappeared unexpectedly"},
+		Hint{"The Gradle daemon ran out of memory.",
+			"Raise -Xmx in org.gradle.jvmargs in gradle.properties, or lower Gradle threads in pusher settings."},
+	},
+	{
+		[]string{"available for offline mode"},
+		Hint{"A dependency is not in Gradle's cache, and the build was offline.",
+			"Run `pusher prepare` on a network with internet, then deploy again."},
+	},
+	{
+		[]string{"UnknownHostException", "Could not GET", "Could not HEAD", "Connect timed out"},
+		Hint{"Gradle could not download a dependency.",
+			"Check this computer has internet; `pusher prepare` caches everything before an event."},
+	},
+	{
+		[]string{"Android Gradle plugin requires Java", "Unsupported class file major version",
+			"invalid source release"},
+		Hint{"Gradle is running on a Java it cannot use.",
+			"Unset JAVA_HOME so pusher uses Android Studio's JDK, or point JAVA_HOME at it."},
+	},
+}
+
+var (
+	// taskLine is Gradle's plain console starting a task, with how it ended
+	// when that was not by running: UP-TO-DATE, FROM-CACHE, FAILED and so on.
+	taskLine = regexp.MustCompile(`^> Task (:\S+)(?: (\S.*))?$`)
+	// failedTask is the summary Gradle ends a failure with.
+	failedTask = regexp.MustCompile(`Execution failed for task '(:[^']+)'`)
+
+	// javacError is javac's header line. It names no column: that is where
+	// the caret falls, two lines down, under the source line it quotes.
+	javacError = regexp.MustCompile(`^(.+\.java):(\d+): error: (.*)$`)
+	// kotlinError is kotlinc from 1.9 on, which names the file as a URL.
+	kotlinError = regexp.MustCompile(`^e: (file://\S+\.kts?):(\d+):(\d+) (.*)$`)
+	// oldKotlinError is kotlinc before that.
+	oldKotlinError = regexp.MustCompile(`^e: (.+\.kts?): \((\d+), (\d+)\): (.*)$`)
+)
+
+// Report is what a build's output said.
+type Report struct {
+	// Tasks is how many tasks Gradle went through, run or not.
+	Tasks int
+	// Failed is the task the build failed in.
+	Failed      string
+	Diagnostics []Diagnostic
+	Hints       []Hint
+}
+
+// Parser reads compiler or Gradle output a line at a time.
+type Parser struct {
+	report Report
+	seen   map[Diagnostic]bool
+	hinted map[string]bool
+	// caret is the javac diagnostic still waiting for its column, counted
+	// from one, and since how many lines have gone by since its header.
+	caret, since int
+}
+
+// Line reads one line, and returns the task it starts, if it starts one.
+func (p *Parser) Line(line string) (task string) {
+	line = strings.TrimRight(line, "\r")
+
+	if m := taskLine.FindStringSubmatch(line); m != nil {
+		p.report.Tasks++
+		if m[2] == "FAILED" {
+			p.report.Failed = m[1]
+		}
+		return m[1]
+	}
+	if m := failedTask.FindStringSubmatch(line); m != nil && p.report.Failed == "" {
+		p.report.Failed = m[1]
+	}
+
+	if p.caret > 0 {
+		p.since++
+		if column := strings.IndexByte(line, '^'); column >= 0 && strings.TrimSpace(line) == "^" {
+			p.report.Diagnostics[p.caret-1].Column = column + 1
+			p.caret = 0
+			return ""
+		}
+		if p.since >= 2 {
+			p.caret = 0
+		}
+	}
+
+	// Gradle repeats a Kotlin error in its summary, so each is kept once.
+	if d, ok := diagnostic(line); ok {
+		if p.seen == nil {
+			p.seen = map[Diagnostic]bool{}
+		}
+		if !p.seen[d] {
+			p.seen[d] = true
+			p.report.Diagnostics = append(p.report.Diagnostics, d)
+			if d.Column == 0 {
+				p.caret, p.since = len(p.report.Diagnostics), 0
+			}
+		}
+		return ""
+	}
+
+	for _, s := range signatures {
+		if p.hinted[s.hint.Problem] {
+			continue
+		}
+		for _, phrase := range s.phrases {
+			if strings.Contains(line, phrase) {
+				if p.hinted == nil {
+					p.hinted = map[string]bool{}
+				}
+				p.hinted[s.hint.Problem] = true
+				p.report.Hints = append(p.report.Hints, s.hint)
+				break
+			}
+		}
+	}
+	return ""
+}
+
+// Report is what has been read so far.
+func (p *Parser) Report() Report { return p.report }
+
+// Parse reads output that is already complete.
+func Parse(output string) Report {
+	var p Parser
+	for _, line := range strings.Split(output, "\n") {
+		p.Line(line)
+	}
+	return p.Report()
+}
+
+func diagnostic(line string) (Diagnostic, bool) {
+	if m := javacError.FindStringSubmatch(line); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		return Diagnostic{File: m[1], Line: n, Message: m[3]}, true
+	}
+
+	m := kotlinError.FindStringSubmatch(line)
+	if m != nil {
+		if u, err := url.Parse(m[1]); err == nil {
+			path := u.Path
+			// file:///C:/Robot names a drive, not a directory called C:.
+			if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+				path = path[1:]
+			}
+			m[1] = filepath.FromSlash(path)
+		}
+	} else {
+		m = oldKotlinError.FindStringSubmatch(line)
+	}
+	if m == nil {
+		return Diagnostic{}, false
+	}
+
+	n, _ := strconv.Atoi(m[2])
+	col, _ := strconv.Atoi(m[3])
+	return Diagnostic{File: m[1], Line: n, Column: col, Message: m[4]}, true
+}
+
+// BuildError is a build that failed, with what its output said about why.
+type BuildError struct {
+	Report Report
+	err    error
+}
+
+func (e *BuildError) Error() string {
+	msg := "gradle build failed"
+	if e.Report.Failed != "" {
+		msg += " in " + e.Report.Failed
+	}
+	switch n := len(e.Report.Diagnostics); n {
+	case 0:
+	case 1:
+		msg += ", 1 compile error"
+	default:
+		msg += fmt.Sprintf(", %d compile errors", n)
+	}
+	return fmt.Sprintf("%s: %v", msg, e.err)
+}
+
+func (e *BuildError) Unwrap() error { return e.err }
+
+// console shows Gradle's output as it arrives. On a terminal the task lines
+// collapse into one that redraws itself, and everything else is shown as
+// Gradle wrote it; anywhere else, a log or a script, every line is kept.
+type console struct {
+	mu     sync.Mutex
+	out    io.Writer
+	live   bool
+	width  int
+	parser Parser
+	drawn  bool
+}
+
+func newConsole(out io.Writer) *console {
+	c := &console{out: out, width: 80}
+	if f, ok := out.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		c.live = true
+		if w, _, err := term.GetSize(int(f.Fd())); err == nil && w > 0 {
+			c.width = w
+		}
+	}
+	return c
+}
+
+func (c *console) line(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	task := c.parser.Line(text)
+	if c.live && task != "" {
+		progress := fmt.Sprintf("> %s (%d tasks)", task, c.parser.report.Tasks)
+		if len(progress) > c.width-1 {
+			progress = progress[:c.width-1]
+		}
+		fmt.Fprintf(c.out, "\r\x1b[K%s", progress)
+		c.drawn = true
+		return
+	}
+
+	c.clear()
+	fmt.Fprintln(c.out, text)
+}
+
+func (c *console) clear() {
+	if c.drawn {
+		fmt.Fprint(c.out, "\r\x1b[K")
+		c.drawn = false
+	}
+}
+
+// finish takes the progress line down and, for a build that failed, lists
+// what went wrong below everything Gradle said.
+func (c *console) finish(root string, failed bool) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clear()
+	report := c.parser.Report()
+	if c.live && !failed && report.Tasks > 0 {
+		fmt.Fprintf(c.out, "%d tasks\n", report.Tasks)
+	}
+	if failed {
+		writeSummary(c.out, report, root)
+	}
+	return report
+}
+
+// maxListed is as many compile errors as the summary lists. The first few are
+// the ones to fix; the rest are often the same mistake seen from elsewhere.
+const maxListed = 20
+
+func writeSummary(w io.Writer, report Report, root string) {
+	if n := len(report.Diagnostics); n > 0 {
+		fmt.Fprintln(w)
+		if n == 1 {
+			fmt.Fprintln(w, "[!] 1 compile error:")
+		} else {
+			fmt.Fprintf(w, "[!] %d compile errors:\n", n)
+		}
+		for i, d := range report.Diagnostics {
+			if i == maxListed {
+				fmt.Fprintf(w, "    and %d more\n", n-maxListed)
+				break
+			}
+			fmt.Fprintf(w, "    %s\n", d.Relative(root))
+		}
+	}
+
+	for _, h := range report.Hints {
+		fmt.Fprintf(w, "\n[!] %s\n    %s\n", h.Problem, h.Fix)
+	}
+}
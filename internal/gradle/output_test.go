@@ -0,0 +1,168 @@
+package gradle
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const failedLog = `> Task :FtcRobotController:preBuild UP-TO-DATE
+> Task :TeamCode:preBuild UP-TO-DATE
+> Task :TeamCode:compileDebugKotlin FAILED
+e: file:///robot/TeamCode/src/main/java/org/firstinspires/ftc/teamcode/Lift.kt:7:13 Unresolved reference: hieght
+> Task :TeamCode:compileDebugJavaWithJavac
+/robot/TeamCode/src/main/java/org/firstinspires/ftc/teamcode/Drive.java:12: error: cannot find symbol
+        motor.setPowr(1);
+             ^
+
+FAILURE: Build failed with an exception.
+
+* What went wrong:
+Execution failed for task ':TeamCode:compileDebugKotlin'.
+> A failure occurred while executing org.jetbrains.kotlin.compilerRunner.GradleCompilerRunnerWithWorkers$GradleKotlinCompilerWorkAction
+   > Compilation error. See log for more details
+e: file:///robot/TeamCode/src/main/java/org/firstinspires/ftc/teamcode/Lift.kt:7:13 Unresolved reference: hieght
+`
+
+func TestAFailedBuildIsReadForItsErrors(t *testing.T) {
+	report := Parse(failedLog)
+
+	if report.Tasks != 4 {
+		t.Errorf("Tasks = %d, want 4", report.Tasks)
+	}
+	if report.Failed != ":TeamCode:compileDebugKotlin" {
+		t.Errorf("Failed = %q", report.Failed)
+	}
+
+	var got []string
+	for _, d := range report.Diagnostics {
+		got = append(got, d.Relative("/robot").String())
+	}
+	base := filepath.Join("TeamCode", "src", "main", "java", "org", "firstinspires", "ftc", "teamcode")
+	want := []string{
+		filepath.Join(base, "Lift.kt") + ":7:13: Unresolved reference: hieght",
+		filepath.Join(base, "Drive.java") + ":12:14: cannot find symbol",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant, each once:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(report.Hints) != 0 {
+		t.Errorf("a compile error was given hints: %v", report.Hints)
+	}
+}
+
+// javac gives its column as a caret under the line it quotes. One indented
+// with tabs keeps them, so the caret lands under the same character.
+func TestJavacsColumnComesFromItsCaret(t *testing.T) {
+	report := Parse("Drive.java:12: error: cannot find symbol\n" +
+		"\t\tmotor.setPowr(1);\n" +
+		"\t\t     ^\n" +
+		"  symbol:   method setPowr(int)\n" +
+		"Arm.java:40: error: ';' expected\n" +
+		"2 errors\n" +
+		"\n" +
+		"    ^\n")
+
+	var got []string
+	for _, d := range report.Diagnostics {
+		got = append(got, d.String())
+	}
+	want := "Drive.java:12:8: cannot find symbol\nArm.java:40: ';' expected"
+	if strings.Join(got, "\n") != want {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), want)
+	}
+}
+
+func TestKnownFailuresGetAHint(t *testing.T) {
+	cases := map[string]string{
+		"SDK location not found. Define a valid SDK location with an ANDROID_HOME environment variable.":    "Android SDK",
+		"Expiring Daemon because JVM heap space is exhausted":                                               "out of memory",
+		"java.lang.OutOfMemoryError: Java heap space":                                                       "out of memory",
+		"   > No cached version of com.acmerobotics.dashboard:dashboard:0.4.15 available for offline mode.": "offline",
+		"   > Could not GET 'https://repo.maven.apache.org/maven2/org/ftc/RobotCore.pom'.":                  "download",
+		"Android Gradle plugin requires Java 17 to run. You are currently using Java 11.":                   "Java",
+	}
+
+	for line, want := range cases {
+		hints := Parse(line + "\n" + line).Hints
+		if len(hints) != 1 {
+			t.Errorf("%q: %d hints, want one however often it is said", line, len(hints))
+			continue
+		}
+		if !strings.Contains(hints[0].Problem, want) {
+			t.Errorf("%q: hint %q does not mention %q", line, hints[0].Problem, want)
+		}
+	}
+
+	if hints := Parse("BUILD SUCCESSFUL in 4s\n42 actionable tasks: 3 executed").Hints; len(hints) != 0 {
+		t.Errorf("a good build was given hints: %v", hints)
+	}
+}
+
+func TestTaskLinesCollapseOnATerminal(t *testing.T) {
+	var out bytes.Buffer
+	c := &console{out: &out, live: true, width: 40}
+	for _, line := range strings.Split(strings.TrimSpace(failedLog), "\n") {
+		c.line(line)
+	}
+	c.finish("/robot", true)
+
+	shown := out.String()
+	if strings.Contains(shown, "> Task") {
+		t.Error("task lines were printed whole on a terminal")
+	}
+	if !strings.Contains(shown, "\r\x1b[K> :TeamCode:compileDebugJavaWithJa") {
+		t.Errorf("no progress line in:\n%q", shown)
+	}
+	if !strings.Contains(shown, "Drive.java:12: error: cannot find symbol\n        motor.setPowr(1);") {
+		t.Error("the compiler's own output was not shown as it was written")
+	}
+	if !strings.Contains(shown, "[!] 2 compile errors:\n    "+filepath.Join("TeamCode", "src")) {
+		t.Errorf("no list of errors at the end:\n%s", shown)
+	}
+
+	// Anywhere but a terminal every line is kept, for the log it ends up in.
+	out.Reset()
+	c = &console{out: &out, width: 40}
+	c.line("> Task :TeamCode:preBuild UP-TO-DATE")
+	if out.String() != "> Task :TeamCode:preBuild UP-TO-DATE\n" {
+		t.Errorf("a log got %q", out.String())
+	}
+}
+
+func TestAFailedBuildSaysWhy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake wrapper is a shell script")
+	}
+
+	root := t.TempDir()
+	wrapper := filepath.Join(root, wrapperName())
+	script := "#!/bin/sh\n" +
+		"echo '> Task :TeamCode:compileDebugJavaWithJavac FAILED'\n" +
+		"echo '" + root + "/Drive.java:3: error: ; expected' >&2\n" +
+		"exit 1\n"
+	if err := os.WriteFile(wrapper, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err := Build(wrapper, true, &out)
+
+	var build *BuildError
+	if !errors.As(err, &build) {
+		t.Fatalf("Build() = %v, want a *BuildError", err)
+	}
+	if build.Report.Failed != ":TeamCode:compileDebugJavaWithJavac" || len(build.Report.Diagnostics) != 1 {
+		t.Errorf("report = %+v", build.Report)
+	}
+	if !strings.Contains(err.Error(), "in :TeamCode:compileDebugJavaWithJavac, 1 compile error") {
+		t.Errorf("message = %q", err.Error())
+	}
+	if !strings.Contains(out.String(), "    Drive.java:3: ; expected") {
+		t.Errorf("the error was not listed against the project:\n%s", out.String())
+	}
+}
@@ -0,0 +1,224 @@
+package gradle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Two costs of a build have nothing to do with the code in it. The first build
+// of a session starts a Gradle daemon and configures the project before any
+// task runs. And a build of a project nobody has touched still has Gradle
+// confirm, task by task, that everything is up to date.
+//
+// So whatever opens first and expects a build to follow, `pusher watch` or the
+// settings screen, starts the daemon ahead of time. And a build is skipped
+// outright when nothing in the project has changed since pusher last built the
+// APK that is there.
+
+var warming struct {
+	sync.Mutex
+	done chan struct{}
+}
+
+// Warm starts the Gradle daemon for the project behind wrapper and has it
+// configure the project once, without waiting for either. A build started in
+// this process meanwhile waits for it, rather than starting a second daemon
+// beside the busy one. The daemon outlives pusher, so a warm-up started by
+// one command serves the next.
+func Warm(wrapper string) {
+	warming.Lock()
+	defer warming.Unlock()
+	if warming.done != nil {
+		return
+	}
+
+	// Offline, because a warm-up that waits on the network is the one thing
+	// worse than none; a project whose plugins are not cached yet still gets
+	// its daemon started before that fails.
+	cmd, err := command(wrapper, "help", "--offline", "--console=plain", "-q", workersFlag())
+	if err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	warming.done = done
+	if err := cmd.Start(); err != nil {
+		close(done)
+		return
+	}
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+}
+
+// waitForWarm holds a build until a warm-up started here has finished.
+func waitForWarm() {
+	warming.Lock()
+	done := warming.done
+	warming.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// StopDaemon stops every Gradle daemon of the wrapper's version, which is how
+// a measurement of the first build of a session starts.
+func StopDaemon(wrapper string) error {
+	cmd, err := command(wrapper, "--stop")
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("gradle --stop failed: %w\n%s", err, out)
+	}
+	return nil
+}
+
+// repositories are where a build downloads from: Google's Maven for the
+// Android plugin and the FTC SDK, and Maven Central for most of the rest.
+var repositories = []string{"dl.google.com:443", "repo.maven.apache.org:443"}
+
+// onlineTimeout is as long as Online waits. A network that takes longer than
+// this to answer would make an online build slower than an offline one.
+const onlineTimeout = 1500 * time.Millisecond
+
+// Online reports whether a build can reach the repositories it downloads from.
+// Being on the robot's network usually means it cannot, but a laptop with a
+// cable in can, and a pit with no internet cannot either way, so it is asked
+// rather than guessed from the network.
+func Online() bool {
+	reached := make(chan bool, len(repositories))
+	for _, addr := range repositories {
+		go func(addr string) {
+			conn, err := net.DialTimeout("tcp", addr, onlineTimeout)
+			if err == nil {
+				conn.Close()
+			}
+			reached <- err == nil
+		}(addr)
+	}
+
+	for range repositories {
+		if <-reached {
+			return true
+		}
+	}
+	return false
+}
+
+// stampPath is where the last build's inputs are recorded. It is in the root
+// project's build directory, so a clean forgets it along with the APK.
+func stampPath(root string) string {
+	return filepath.Join(root, "build", "pusher-inputs.json")
+}
+
+// built is what a build by pusher left behind: the inputs it read and the APK
+// it made from them.
+type built struct {
+	Inputs  string    `json:"inputs"`
+	APK     string    `json:"apk"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Inputs identifies the state of every file a build could read: each path
+// with its size and modification time. Contents are not read, so a file
+// saved without changes counts as changed, which costs a build, never a stale
+// APK.
+func Inputs(root string) string {
+	var lines []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			// Outputs and tool state, which the build writes rather than reads.
+			switch info.Name() {
+			case ".git", ".gradle", ".idea", ".kotlin":
+				return filepath.SkipDir
+			case "build":
+				if outputDir(root, path) {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %d", filepath.ToSlash(rel), info.Size(), info.ModTime().UnixNano()))
+		return nil
+	})
+	sort.Strings(lines)
+
+	sum := sha256.New()
+	for _, line := range lines {
+		fmt.Fprintln(sum, line)
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// outputDir reports whether dir, named build, is where Gradle writes: the
+// project's own or a module's. Anywhere deeper it is something the build
+// reads, a Java package called build being the usual one, and an edit there
+// that went unseen would deploy the APK from before it.
+func outputDir(root, dir string) bool {
+	parent := filepath.Dir(dir)
+	return parent == root || filepath.Dir(parent) == root
+}
+
+// Changed reports whether building the project could produce anything other
+// than the APK already there: whether anything in it changed since pusher
+// last built it, or the APK is missing or not the one that build made.
+func Changed(wrapper string) bool {
+	root := ProjectDir(wrapper)
+
+	blob, err := os.ReadFile(stampPath(root))
+	if err != nil {
+		return true
+	}
+	var last built
+	if json.Unmarshal(blob, &last) != nil {
+		return true
+	}
+
+	info, err := os.Stat(last.APK)
+	if err != nil || info.Size() != last.Size || !info.ModTime().Equal(last.ModTime) {
+		return true
+	}
+
+	return Inputs(root) != last.Inputs
+}
+
+// recordBuilt notes the inputs a successful build read, for Changed.
+func recordBuilt(root, inputs string) {
+	apk, err := FindApk(root)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(apk)
+	if err != nil {
+		return
+	}
+
+	blob, err := json.Marshal(built{Inputs: inputs, APK: apk, Size: info.Size(), ModTime: info.ModTime()})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(stampPath(root)), 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(stampPath(root), blob, 0o644)
+}
@@ -0,0 +1,212 @@
+package gradle
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildingWrapper is a wrapper that builds an APK the way Gradle lays one out,
+// and appends its arguments to a log so a test can see what was run.
+func buildingWrapper(t *testing.T, root, extra string) (wrapper, log string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake wrapper is a shell script")
+	}
+
+	wrapper = filepath.Join(root, wrapperName())
+	log = filepath.Join(t.TempDir(), "runs")
+	apk := filepath.Join(root, "TeamCode", "build", "outputs", "apk", "debug")
+	script := "#!/bin/sh\n" +
+		extra +
+		"echo \"$1\" >> '" + log + "'\n" +
+		"mkdir -p '" + apk + "'\n" +
+		"echo apk > '" + filepath.Join(apk, "TeamCode-debug.apk") + "'\n"
+	if err := os.WriteFile(wrapper, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return wrapper, log
+}
+
+func writeSource(t *testing.T, root, body string) string {
+	t.Helper()
+	path := filepath.Join(root, "TeamCode", "src", "Drive.java")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAnUnchangedProjectIsNotRebuilt(t *testing.T) {
+	root := t.TempDir()
+	wrapper, _ := buildingWrapper(t, root, "")
+	source := writeSource(t, root, "class Drive {}")
+
+	if !Changed(wrapper) {
+		t.Fatal("a project pusher never built counts as unchanged")
+	}
+	if err := Build(wrapper, true, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if Changed(wrapper) {
+		t.Fatal("the project counts as changed straight after a build")
+	}
+
+	// What the build writes is not an input to the next one.
+	if err := os.WriteFile(filepath.Join(root, "TeamCode", "build", "intermediate"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if Changed(wrapper) {
+		t.Error("a build output counts as a change")
+	}
+
+	writeSource(t, root, "class Drive { int power; }")
+	if !Changed(wrapper) {
+		t.Error("an edited source file does not count as a change")
+	}
+
+	if err := Build(wrapper, true, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	if !Changed(wrapper) {
+		t.Error("a deleted source file does not count as a change")
+	}
+}
+
+// Only Gradle's own output directories are left out. A package called build
+// is source, and an edit to it has to rebuild.
+func TestAPackageCalledBuildIsAnInput(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "TeamCode", "src", "main", "java", "org", "team", "build", "Lift.java")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("class Lift {}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	before := Inputs(root)
+	if err := os.WriteFile(path, []byte("class Lift { int height; }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if Inputs(root) == before {
+		t.Error("an edit in a package called build went unseen")
+	}
+
+	before = Inputs(root)
+	for _, output := range []string{filepath.Join(root, "build", "a"), filepath.Join(root, "TeamCode", "build", "b")} {
+		if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(output, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if Inputs(root) != before {
+		t.Error("the project's or a module's build output counts as an input")
+	}
+}
+
+// The record is only as good as the APK it describes: another build, from
+// Android Studio say, replaces the APK without pusher knowing what went in.
+func TestAReplacedAPKIsNotTrusted(t *testing.T) {
+	root := t.TempDir()
+	wrapper, _ := buildingWrapper(t, root, "")
+	writeSource(t, root, "class Drive {}")
+
+	if err := Build(wrapper, true, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	apk, err := FindApk(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(apk, []byte("a different build"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !Changed(wrapper) {
+		t.Error("an APK pusher did not build is taken as up to date")
+	}
+
+	if err := os.Remove(apk); err != nil {
+		t.Fatal(err)
+	}
+	if !Changed(wrapper) {
+		t.Error("a missing APK is taken as up to date")
+	}
+}
+
+// A build started while the daemon is warming would start a second daemon
+// beside the busy one, so it waits.
+func TestABuildWaitsForTheWarmUp(t *testing.T) {
+	root := t.TempDir()
+	wrapper, log := buildingWrapper(t, root, "[ \"$1\" = help ] && sleep 0.3\n")
+	writeSource(t, root, "class Drive {}")
+
+	t.Cleanup(func() {
+		waitForWarm()
+		warming.Lock()
+		warming.done = nil
+		warming.Unlock()
+	})
+
+	Warm(wrapper)
+	Warm(wrapper)
+	if err := Build(wrapper, true, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(blob)); strings.Join(got, " ") != "help assembleDebug" {
+		t.Errorf("ran %q, want one warm-up and then the build", got)
+	}
+}
+
+func TestOfflineWhenNoRepositoryAnswers(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := listener.Addr().String()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shut := closed.Addr().String()
+	closed.Close()
+
+	prev := repositories
+	t.Cleanup(func() {
+		repositories = prev
+		listener.Close()
+	})
+
+	repositories = []string{shut}
+	start := time.Now()
+	if Online() {
+		t.Error("online with nothing to reach")
+	}
+	if time.Since(start) > 2*onlineTimeout {
+		t.Error("deciding took longer than the timeout")
+	}
+
+	repositories = []string{shut, open}
+	if !Online() {
+		t.Error("offline although one repository answered")
+	}
+}
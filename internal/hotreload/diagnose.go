@@ -180,7 +180,7 @@ func (d *Diagnosis) find(format string, args ...any) {
 func Diagnose(serial string) Diagnosis {
 	var d Diagnosis
 
-	d.Package = robotControllerPackage(serial)
+	d.Package = adb.ControllerPackage(serial)
 	if d.Package == "" {
 		d.find("no robot controller app found on this device")
 		return d
@@ -249,23 +249,6 @@ func shellQuote(path string) string {
 	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
 }
 
-// robotControllerPackage finds the installed robot controller.
-func robotControllerPackage(serial string) string {
-	out, err := adb.Shell(serial, "pm", "list", "packages", "2>/dev/null")
-	if err != nil {
-		return ""
-	}
-
-	for _, line := range strings.Split(out, "\n") {
-		line = strings.TrimSpace(strings.TrimRight(line, "\r"))
-		name := strings.TrimPrefix(line, "package:")
-		if strings.Contains(name, "ftcrobotcontroller") {
-			return name
-		}
-	}
-	return ""
-}
-
 // onBotJavaCrash pulls the reason OnBotJava died, if it did.
 //
 // The ActivityManager line only says something crashed. The exception above it
@@ -0,0 +1,203 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Chunking only ever reuses whole chunks of 128 KB and up, so one changed line
+// in a dex file resends a chunk however little of it changed. A patch against
+// the APK the robot already has can do better: it says which stretches of the
+// new APK are copies of the old one and carries only the bytes that are not.
+//
+// bsdiff goes further and encodes near-matches as byte differences, but
+// applying that takes arithmetic over every byte, and the hub's shell has no
+// tool that does it. What it does have is dd, and dd can copy whole blocks from
+// one file to another. So the copies here are runs of whole blocks at block
+// offsets in the old APK, and everything else is sent as it is, which keeps
+// applying a patch to a few dd commands per changed region.
+
+// Block is the unit copied from the old APK, and what literals are aligned to.
+const Block = 512
+
+// minRun is the fewest blocks worth a copy. Each copy is a dd on the hub, and
+// below this its start-up costs more than sending the bytes.
+const minRun = 4
+
+// Op is one step of rebuilding the new APK.
+type Op struct {
+	// FromBase copies from the old APK; otherwise the bytes come from the
+	// patch's literal data.
+	FromBase bool
+
+	// Offset is where the bytes start in the old APK or the literal data, and
+	// is always a multiple of Block.
+	Offset int64
+
+	Size int64
+}
+
+// Patch rebuilds one APK from another.
+type Patch struct {
+	Ops []Op
+
+	// Literal is every byte the old APK did not have, each run starting on a
+	// block boundary so the hub can copy it in whole blocks.
+	Literal []byte
+
+	// Size is the size of the APK the patch rebuilds.
+	Size int64
+}
+
+// Copied is how many bytes of the new APK come from the old one.
+func (p Patch) Copied() int64 {
+	var n int64
+	for _, op := range p.Ops {
+		if op.FromBase {
+			n += op.Size
+		}
+	}
+	return n
+}
+
+// Diff works out the patch that turns base into target.
+func Diff(base, target []byte) Patch {
+	p := Patch{Size: int64(len(target))}
+
+	index, filter := indexBlocks(base)
+
+	lit := 0
+	i := 0
+	var h uint64
+	if len(target) >= Block {
+		h = hashBlock(target[:Block])
+	}
+
+	for i+Block <= len(target) {
+		if filter.has(h) {
+			if at, ok := index[h]; ok {
+				if n := run(base, target, at, i); n >= minRun {
+					p.literal(target[lit:i])
+					p.copy(int64(at), int64(n*Block))
+
+					i += n * Block
+					lit = i
+					if i+Block <= len(target) {
+						h = hashBlock(target[i : i+Block])
+					}
+					continue
+				}
+			}
+		}
+
+		if i+Block < len(target) {
+			h = roll(h, target[i], target[i+Block])
+		}
+		i++
+	}
+	p.literal(target[lit:])
+
+	return p
+}
+
+// Apply rebuilds the target from base, as the hub will.
+func Apply(base []byte, p Patch) ([]byte, error) {
+	out := make([]byte, 0, p.Size)
+	for _, op := range p.Ops {
+		src := p.Literal
+		if op.FromBase {
+			src = base
+		}
+		if op.Offset < 0 || op.Offset+op.Size > int64(len(src)) {
+			return nil, fmt.Errorf("patch reads %d bytes at %d, past the end of its source", op.Size, op.Offset)
+		}
+		out = append(out, src[op.Offset:op.Offset+op.Size]...)
+	}
+	if int64(len(out)) != p.Size {
+		return nil, fmt.Errorf("patch rebuilt %d bytes, want %d", len(out), p.Size)
+	}
+	return out, nil
+}
+
+func (p *Patch) literal(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	if pad := len(p.Literal) % Block; pad != 0 {
+		p.Literal = append(p.Literal, make([]byte, Block-pad)...)
+	}
+	p.Ops = append(p.Ops, Op{Offset: int64(len(p.Literal)), Size: int64(len(b))})
+	p.Literal = append(p.Literal, b...)
+}
+
+func (p *Patch) copy(offset, size int64) {
+	if n := len(p.Ops); n > 0 {
+		last := &p.Ops[n-1]
+		if last.FromBase && last.Offset+last.Size == offset {
+			last.Size += size
+			return
+		}
+	}
+	p.Ops = append(p.Ops, Op{FromBase: true, Offset: offset, Size: size})
+}
+
+// run counts the whole blocks that match from block at in base and from i in
+// target.
+func run(base, target []byte, at, i int) int {
+	n := 0
+	for at+(n+1)*Block <= len(base) && i+(n+1)*Block <= len(target) &&
+		bytes.Equal(base[at+n*Block:at+(n+1)*Block], target[i+n*Block:i+(n+1)*Block]) {
+		n++
+	}
+	return n
+}
+
+// The hash is a polynomial one over a block, so it can be rolled along the
+// target a byte at a time.
+const prime = 0x100000001b3
+
+var outFactor = func() uint64 {
+	f := uint64(1)
+	for i := 0; i < Block-1; i++ {
+		f *= prime
+	}
+	return f
+}()
+
+func hashBlock(b []byte) uint64 {
+	var h uint64
+	for _, c := range b {
+		h = h*prime + uint64(c) + 1
+	}
+	return h
+}
+
+func roll(h uint64, out, in byte) uint64 {
+	h -= (uint64(out) + 1) * outFactor
+	return h*prime + uint64(in) + 1
+}
+
+// bloom is a one-hash filter in front of the index. The target is hashed at
+// every byte, and most of those hashes are of nothing in the base, so a bit
+// test answers most of them before a map lookup has to.
+type bloom []uint64
+
+const bloomBits = 1 << 22
+
+func (b bloom) add(h uint64)      { i := h % bloomBits; b[i/64] |= 1 << (i % 64) }
+func (b bloom) has(h uint64) bool { i := h % bloomBits; return b[i/64]&(1<<(i%64)) != 0 }
+
+// indexBlocks hashes every whole block of base at its block offset. Of blocks
+// that hash alike, the first is kept.
+func indexBlocks(base []byte) (map[uint64]int, bloom) {
+	index := make(map[uint64]int, len(base)/Block)
+	filter := make(bloom, bloomBits/64)
+	for at := 0; at+Block <= len(base); at += Block {
+		h := hashBlock(base[at : at+Block])
+		if _, ok := index[h]; !ok {
+			index[h] = at
+			filter.add(h)
+		}
+	}
+	return index, filter
+}
@@ -0,0 +1,85 @@
+package patch
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func randomBytes(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func roundTrip(t *testing.T, base, target []byte) Patch {
+	t.Helper()
+
+	p := Diff(base, target)
+	got, err := Apply(base, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Fatal("applying the patch did not rebuild the target")
+	}
+	for _, op := range p.Ops {
+		if op.Offset%Block != 0 {
+			t.Fatalf("an op starts at %d, off a block boundary", op.Offset)
+		}
+	}
+	return p
+}
+
+func TestAOneByteChangeSendsAboutOneBlock(t *testing.T) {
+	base := randomBytes(1, 4<<20)
+	target := append([]byte(nil), base...)
+	target[2<<20+100] ^= 0xff
+
+	p := roundTrip(t, base, target)
+
+	if len(p.Literal) > 2*Block {
+		t.Errorf("one changed byte cost %d literal bytes", len(p.Literal))
+	}
+	if len(p.Ops) != 3 {
+		t.Errorf("got %d ops, want a copy, the changed block and a copy", len(p.Ops))
+	}
+}
+
+func TestInsertedBytesShiftNothing(t *testing.T) {
+	base := randomBytes(2, 1<<20)
+	insert := randomBytes(3, 777)
+	target := append(append(append([]byte(nil), base[:300<<10]...), insert...), base[300<<10:]...)
+
+	p := roundTrip(t, base, target)
+
+	if len(p.Literal) > len(insert)+2*Block {
+		t.Errorf("777 inserted bytes cost %d literal bytes", len(p.Literal))
+	}
+}
+
+func TestUnrelatedFilesAreAllLiteral(t *testing.T) {
+	base := randomBytes(4, 256<<10)
+	target := randomBytes(5, 256<<10)
+
+	p := roundTrip(t, base, target)
+
+	if p.Copied() != 0 {
+		t.Errorf("copied %d bytes between unrelated files", p.Copied())
+	}
+}
+
+func TestOddSizesAndEmptyFiles(t *testing.T) {
+	base := randomBytes(6, 10*Block+17)
+	roundTrip(t, base, base)
+	roundTrip(t, base, base[:Block-1])
+	roundTrip(t, nil, base)
+	roundTrip(t, base, nil)
+}
+
+func TestAPatchThatReadsPastItsSourceIsRefused(t *testing.T) {
+	p := Patch{Ops: []Op{{FromBase: true, Offset: 0, Size: 2 * Block}}, Size: 2 * Block}
+	if _, err := Apply(make([]byte, Block), p); err == nil {
+		t.Error("a patch longer than its base was applied")
+	}
+}
@@ -0,0 +1,409 @@
+package robotcfg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// An OpMode finds a device by a string that has to match the configuration
+// letter for letter, and by a class that has to match what the device is. Get
+// either wrong and nothing says so until the OpMode is initialised on the
+// robot. A class generated from the configuration moves both mistakes to the
+// compiler: every enabled device is a field of the interface the SDK hands
+// back for its tag, and a device renamed or removed on the Driver Station is a
+// field that no longer exists.
+//
+// The class is only as good as its last generation, so it records which
+// configuration it came from, and a push regenerates it in memory and refuses
+// to build when the two disagree.
+
+// DefaultPackage is where generated classes go unless told otherwise: beside
+// the team's code, in a package of their own.
+const DefaultPackage = "org.firstinspires.ftc.teamcode.hardware"
+
+// generatedMarker opens every generated file, and is how one is recognised.
+const generatedMarker = "// Generated by pusher from the hardware configuration "
+
+// Codegen is one generated class: which configuration it comes from, and
+// where and in what language it is written.
+type Codegen struct {
+	Config  string
+	Package string
+	Class   string
+	Kotlin  bool
+}
+
+// ClassName is the class a configuration generates by default: "comp bot"
+// becomes CompBotHardware.
+func ClassName(config string) string {
+	name := identifier(config)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "robot" + name
+	}
+	return string(unicode.ToUpper(rune(name[0]))) + name[1:] + "Hardware"
+}
+
+// Path is where the class is written under a source root such as
+// TeamCode/src/main/java, which Gradle compiles Kotlin from as well.
+func (g Codegen) Path(sourceRoot string) string {
+	ext := ".java"
+	if g.Kotlin {
+		ext = ".kt"
+	}
+	return filepath.Join(sourceRoot, filepath.FromSlash(strings.ReplaceAll(g.Package, ".", "/")), g.Class+ext)
+}
+
+// Interfaces are the types the SDK hands back for each tag, read out of the
+// same SDK 11.1.0 jars as the flavors. Motors are DcMotorEx rather than
+// DcMotor, since every motor port on a REV hub is one and it is the interface
+// with velocity control. A tag missing here is typed as HardwareDevice, which
+// still finds it by name; a team that registers its own device knows its class
+// better than pusher does.
+var interfaces = map[string]string{
+	"Servo":                   "com.qualcomm.robotcore.hardware.Servo",
+	"ServoFullRange":          "com.qualcomm.robotcore.hardware.Servo",
+	"ContinuousRotationServo": "com.qualcomm.robotcore.hardware.CRServo",
+	"RevSPARKMini":            "com.qualcomm.robotcore.hardware.DcMotorSimple",
+
+	"AnalogInput":                     "com.qualcomm.robotcore.hardware.AnalogInput",
+	"ModernRoboticsAnalogTouchSensor": "com.qualcomm.robotcore.hardware.TouchSensor",
+	"OpticalDistanceSensor":           "com.qualcomm.robotcore.hardware.OpticalDistanceSensor",
+
+	"DigitalDevice":  "com.qualcomm.robotcore.hardware.DigitalChannel",
+	"Led":            "com.qualcomm.robotcore.hardware.LED",
+	"RevTouchSensor": "com.qualcomm.robotcore.hardware.TouchSensor",
+
+	"AdafruitBNO055IMU":              "com.qualcomm.hardware.bosch.BNO055IMU",
+	"AdafruitColorSensor":            "com.qualcomm.robotcore.hardware.ColorSensor",
+	"AndyMarkColor":                  "com.qualcomm.robotcore.hardware.ColorSensor",
+	"AndyMarkIMU":                    "com.qualcomm.robotcore.hardware.IMU",
+	"AndyMarkTOF":                    "org.firstinspires.ftc.robotcore.external.navigation.DistanceSensor",
+	"ColorSensor":                    "com.qualcomm.robotcore.hardware.ColorSensor",
+	"ControlHubImuBHI260AP":          "com.qualcomm.robotcore.hardware.IMU",
+	"Gyro":                           "com.qualcomm.robotcore.hardware.GyroSensor",
+	"IrSeekerV3":                     "com.qualcomm.robotcore.hardware.IrSeekerSensor",
+	"KauaiLabsNavxMicro":             "com.qualcomm.hardware.kauailabs.NavxMicroNavigationSensor",
+	"LynxColorSensor":                "com.qualcomm.robotcore.hardware.ColorSensor",
+	"LynxEmbeddedIMU":                "com.qualcomm.robotcore.hardware.IMU",
+	"MaxSonarI2CXL":                  "com.qualcomm.hardware.maxbotix.MaxSonarI2CXL",
+	"ModernRoboticsI2cCompassSensor": "com.qualcomm.robotcore.hardware.CompassSensor",
+	"ModernRoboticsI2cRangeSensor":   "com.qualcomm.hardware.modernrobotics.ModernRoboticsI2cRangeSensor",
+	"QWIIC_LED_STICK":                "com.qualcomm.hardware.sparkfun.SparkFunLEDStick",
+	"REV_VL53L0X_RANGE_SENSOR":       "org.firstinspires.ftc.robotcore.external.navigation.DistanceSensor",
+	"RevColorSensorV3":               "com.qualcomm.hardware.rev.RevColorSensorV3",
+	"RevExternalImu":                 "com.qualcomm.robotcore.hardware.IMU",
+	"SparkFunOTOS":                   "com.qualcomm.hardware.sparkfun.SparkFunOTOS",
+	"goBILDAPinpoint":                "com.qualcomm.hardware.gobilda.GoBildaPinpointDriver",
+
+	"Webcam": "org.firstinspires.ftc.robotcore.external.hardware.camera.WebcamName",
+}
+
+const (
+	motorInterface   = "com.qualcomm.robotcore.hardware.DcMotorEx"
+	unknownInterface = "com.qualcomm.robotcore.hardware.HardwareDevice"
+	hardwareMapClass = "com.qualcomm.robotcore.hardware.HardwareMap"
+)
+
+// Interface is the fully qualified type a device of this tag is fetched as.
+func Interface(tag string) string {
+	if FlavorOf(tag) == Motor {
+		return motorInterface
+	}
+	if iface, ok := interfaces[tag]; ok {
+		return iface
+	}
+	return unknownInterface
+}
+
+// field is one device as the generated class has it.
+type field struct {
+	name   string
+	device Device
+	iface  string
+	where  string
+}
+
+func simpleName(qualified string) string {
+	return qualified[strings.LastIndex(qualified, ".")+1:]
+}
+
+// fields lists the enabled devices in document order, which is the order they
+// appear on the Driver Station, each under a name neither language rejects.
+func fields(cfg *Config) []field {
+	var out []field
+	taken := map[string]bool{}
+
+	add := func(d Device, where string) {
+		if !d.Enabled() {
+			return
+		}
+		name := identifier(d.Name)
+		if name == "" || unicode.IsDigit(rune(name[0])) {
+			name = "device" + name
+		}
+		if reserved[name] {
+			name += "Device"
+		}
+		// Two devices whose names differ only in what an identifier cannot
+		// hold; validation already warns when names clash outright.
+		base := name
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%s%d", base, n)
+		}
+		taken[name] = true
+		out = append(out, field{name: name, device: d, iface: Interface(d.Tag), where: where})
+	}
+
+	for _, p := range cfg.Portals {
+		if p.InHardwareMap() {
+			add(p.AsDevice(), "")
+		}
+		for _, d := range p.Devices {
+			add(d, label(p.Tag, p.Name)+", "+position(d))
+		}
+		for _, m := range p.Modules {
+			for _, d := range m.Devices {
+				add(d, label(m.Tag, m.Name)+", "+position(d))
+			}
+		}
+	}
+	return out
+}
+
+// identifier turns a device name into lowerCamelCase, dropping whatever an
+// identifier cannot contain: "left front" and "left-front" are leftFront.
+func identifier(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = b.Len() > 0
+			continue
+		}
+		if b.Len() == 0 {
+			r = unicode.ToLower(r)
+		} else if upper {
+			r = unicode.ToUpper(r)
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// reserved are the words Java or Kotlin will not take as a field name, and
+// the names the class already uses.
+var reserved = map[string]bool{
+	"abstract": true, "assert": true, "boolean": true, "break": true, "byte": true, "case": true,
+	"catch": true, "char": true, "class": true, "const": true, "continue": true, "default": true,
+	"do": true, "double": true, "else": true, "enum": true, "extends": true, "final": true,
+	"finally": true, "float": true, "for": true, "goto": true, "if": true, "implements": true,
+	"import": true, "instanceof": true, "int": true, "interface": true, "long": true, "native": true,
+	"new": true, "package": true, "private": true, "protected": true, "public": true, "return": true,
+	"short": true, "static": true, "strictfp": true, "super": true, "switch": true,
+	"synchronized": true, "this": true, "throw": true, "throws": true, "transient": true,
+	"try": true, "void": true, "volatile": true, "while": true, "true": true, "false": true,
+	"null": true, "var": true, "record": true, "yield": true,
+	"as": true, "fun": true, "in": true, "is": true, "object": true, "typealias": true,
+	"typeof": true, "val": true, "when": true,
+	"init": true, "hardwareMap": true,
+}
+
+// Generate writes the class for a configuration.
+func Generate(cfg *Config, g Codegen) []byte {
+	list := fields(cfg)
+
+	imports := map[string]bool{hardwareMapClass: true}
+	for _, f := range list {
+		imports[f.iface] = true
+	}
+	sorted := make([]string, 0, len(imports))
+	for imp := range imports {
+		sorted = append(sorted, imp)
+	}
+	sort.Strings(sorted)
+
+	semi := ";"
+	if g.Kotlin {
+		semi = ""
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s%q. Do not edit it:\n", generatedMarker, g.Config)
+	fmt.Fprintf(&b, "// change the configuration, then run `pusher hwconfig codegen %s`.\n", quoteArg(g.Config))
+	fmt.Fprintf(&b, "package %s%s\n\n", g.Package, semi)
+	for _, imp := range sorted {
+		fmt.Fprintf(&b, "import %s%s\n", imp, semi)
+	}
+	b.WriteString("\n")
+
+	if g.Kotlin {
+		fmt.Fprintf(&b, "class %s {\n", g.Class)
+	} else {
+		fmt.Fprintf(&b, "public class %s {\n", g.Class)
+	}
+
+	for _, f := range list {
+		doc := f.device.Tag
+		if f.where != "" {
+			doc += " on " + f.where
+		}
+		// A name is free text, and one that ends a comment would end this one.
+		doc = strings.ReplaceAll(quote(f.device.Name, false)+": "+doc, "*/", "*&#47;")
+		fmt.Fprintf(&b, "    /** %s. */\n", doc)
+		if g.Kotlin {
+			fmt.Fprintf(&b, "    lateinit var %s: %s\n", f.name, simpleName(f.iface))
+		} else {
+			fmt.Fprintf(&b, "    public %s %s;\n", simpleName(f.iface), f.name)
+		}
+	}
+	if len(list) > 0 {
+		b.WriteString("\n")
+	}
+
+	if g.Kotlin {
+		b.WriteString("    fun init(hardwareMap: HardwareMap) {\n")
+	} else {
+		b.WriteString("    public void init(HardwareMap hardwareMap) {\n")
+	}
+	for _, f := range list {
+		class := simpleName(f.iface) + ".class"
+		if g.Kotlin {
+			class = simpleName(f.iface) + "::class.java"
+		}
+		fmt.Fprintf(&b, "        %s = hardwareMap.get(%s, %s)%s\n", f.name, class, quote(f.device.Name, g.Kotlin), semi)
+	}
+	b.WriteString("    }\n}\n")
+
+	return b.Bytes()
+}
+
+// quote is a string literal in either language. Kotlin also reads a dollar
+// sign as the start of a template.
+func quote(s string, kotlin bool) string {
+	r := []string{`\`, `\\`, `"`, `\"`}
+	if kotlin {
+		r = append(r, `$`, `\$`)
+	}
+	return `"` + strings.NewReplacer(r...).Replace(s) + `"`
+}
+
+// quoteArg is a configuration name as it would be typed into a shell.
+func quoteArg(name string) string {
+	if strings.ContainsAny(name, " '\"$`\\") {
+		return "'" + strings.ReplaceAll(name, "'", `'\''`) + "'"
+	}
+	return name
+}
+
+// Both take a CR before the line end: git with autocrlf on Windows checks the
+// generated files out that way.
+var (
+	packageLine = regexp.MustCompile(`(?m)^package ([\w.]+);?\r?$`)
+	classLine   = regexp.MustCompile(`(?m)^(?:public )?class (\w+) \{\r?$`)
+)
+
+// Generated reads back what a generated file was generated from, or reports
+// that the file was not generated by pusher.
+func Generated(path string, src []byte) (Codegen, bool) {
+	first, _, _ := bytes.Cut(src, []byte("\n"))
+	rest, ok := bytes.CutPrefix(first, []byte(generatedMarker))
+	if !ok {
+		return Codegen{}, false
+	}
+
+	quoted, err := strconv.QuotedPrefix(string(rest))
+	if err != nil {
+		return Codegen{}, false
+	}
+	var g Codegen
+	g.Config, _ = strconv.Unquote(quoted)
+	pkg := packageLine.FindSubmatch(src)
+	class := classLine.FindSubmatch(src)
+	if pkg == nil || class == nil {
+		return Codegen{}, false
+	}
+	g.Package = string(pkg[1])
+	g.Class = string(class[1])
+	g.Kotlin = filepath.Ext(path) == ".kt"
+	return g, true
+}
+
+// FindGenerated lists the generated files under a source root, by path.
+func FindGenerated(sourceRoot string) (map[string]Codegen, error) {
+	found := map[string]Codegen{}
+	err := filepath.Walk(sourceRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == sourceRoot {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() || (filepath.Ext(path) != ".java" && filepath.Ext(path) != ".kt") {
+			return nil
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if g, ok := Generated(path, src); ok {
+			found[path] = g
+		}
+		return nil
+	})
+	return found, err
+}
+
+// Stale lists, one sentence each, the generated files under a source root
+// that no longer say what their configuration in the store does.
+func Stale(sourceRoot string, store *Store) ([]string, error) {
+	generated, err := FindGenerated(sourceRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(generated))
+	for path := range generated {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var stale []string
+	for _, path := range paths {
+		g := generated[path]
+		rel, err := filepath.Rel(sourceRoot, path)
+		if err != nil {
+			rel = path
+		}
+
+		data, err := store.Read(g.Config)
+		if err != nil {
+			stale = append(stale, fmt.Sprintf("%s was generated from %q, which is not in %s", rel, g.Config, store.Dir))
+			continue
+		}
+		cfg, err := Parse(data)
+		if err != nil {
+			stale = append(stale, fmt.Sprintf("%s was generated from %q, which no longer parses: %v", rel, g.Config, err))
+			continue
+		}
+
+		current, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// Line endings are left to git: a checkout with CRLF says the same.
+		current = bytes.ReplaceAll(current, []byte("\r\n"), []byte("\n"))
+		if !bytes.Equal(current, Generate(cfg, g)) {
+			stale = append(stale, fmt.Sprintf("%s is out of date with %q", rel, g.Config))
+		}
+	}
+	return stale, nil
+}
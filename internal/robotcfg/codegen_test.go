@@ -0,0 +1,204 @@
+package robotcfg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func comp() Codegen {
+	return Codegen{Config: "comp", Package: DefaultPackage, Class: ClassName("comp")}
+}
+
+func TestEveryDeviceIsAFieldOfItsInterface(t *testing.T) {
+	src := string(Generate(parse(t, realConfig), comp()))
+
+	for _, want := range []string{
+		"package org.firstinspires.ftc.teamcode.hardware;",
+		"import com.qualcomm.robotcore.hardware.DcMotorEx;",
+		"import com.qualcomm.robotcore.hardware.HardwareMap;",
+		"public class CompHardware {",
+		"public DcMotorEx transfer;",
+		"public DcMotorEx bl;",
+		"public Servo turretL;",
+		"public AnalogInput turretEncoder;",
+		"public DigitalChannel beamBrakePos2;",
+		"public IMU imu;",
+		"public GoBildaPinpointDriver pinpoint;",
+		// A device pusher has no interface for is still found by name.
+		"public HardwareDevice limelight;",
+		"public void init(HardwareMap hardwareMap) {",
+		`fr = hardwareMap.get(DcMotorEx.class, "fr");`,
+		`limelight = hardwareMap.get(HardwareDevice.class, "limelight");`,
+		`/** "imu": ControlHubImuBHI260AP on Control Hub, I2C bus 0 port 0. */`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("missing %q in:\n%s", want, src)
+		}
+	}
+}
+
+func TestKotlinIsKotlin(t *testing.T) {
+	g := comp()
+	g.Kotlin = true
+	src := string(Generate(parse(t, realConfig), g))
+
+	for _, want := range []string{
+		"package org.firstinspires.ftc.teamcode.hardware\n",
+		"import com.qualcomm.robotcore.hardware.DcMotorEx\n",
+		"class CompHardware {",
+		"lateinit var transfer: DcMotorEx",
+		"fun init(hardwareMap: HardwareMap) {",
+		`transfer = hardwareMap.get(DcMotorEx::class.java, "transfer")`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("missing %q in:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, ";") {
+		t.Errorf("semicolons in Kotlin:\n%s", src)
+	}
+}
+
+func TestNamesBecomeIdentifiers(t *testing.T) {
+	cfg := parse(t, `<Robot type="FirstInspires-FTC">
+    <LynxUsbDevice name="Control Hub Portal" serialNumber="(embedded)" parentModuleAddress="173">
+        <LynxModule name="Control Hub" port="173">
+            <goBILDA5202SeriesMotor name="left front" port="0" />
+            <goBILDA5202SeriesMotor name="left-front" port="1" />
+            <goBILDA5202SeriesMotor name="2nd arm" port="2" />
+            <Servo name="class" port="0" />
+            <Servo name="claw $1 &quot;*/" port="1" />
+            <Servo name="NO$DEVICE$ATTACHED" port="2" />
+        </LynxModule>
+    </LynxUsbDevice>
+</Robot>`)
+
+	src := string(Generate(cfg, comp()))
+	for _, want := range []string{
+		"public DcMotorEx leftFront;",
+		"public DcMotorEx leftFront2;",
+		"public DcMotorEx device2ndArm;",
+		"public Servo classDevice;",
+		`claw1 = hardwareMap.get(Servo.class, "claw $1 \"*/");`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("missing %q in:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "NO$DEVICE") {
+		t.Error("an empty port became a field")
+	}
+	for _, line := range strings.Split(src, "\n") {
+		if strings.Contains(line, "/**") && strings.Count(line, "*/") != 1 {
+			t.Errorf("a name ended its comment early: %s", line)
+		}
+	}
+
+	g := comp()
+	g.Kotlin = true
+	if src := string(Generate(cfg, g)); !strings.Contains(src, `"claw \$1 \"*/"`) {
+		t.Errorf("a dollar sign is left to Kotlin's templates:\n%s", src)
+	}
+
+	for name, want := range map[string]string{
+		"comp": "CompHardware", "comp bot": "CompBotHardware", "2024": "Robot2024Hardware", "": "RobotHardware",
+	} {
+		if got := ClassName(name); got != want {
+			t.Errorf("ClassName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestAGeneratedFileSaysWhereItCameFrom(t *testing.T) {
+	for _, g := range []Codegen{
+		comp(),
+		{Config: `it's "new"`, Package: "org.team.hw", Class: "Robot", Kotlin: true},
+	} {
+		path := g.Path("src")
+		got, ok := Generated(path, Generate(parse(t, realConfig), g))
+		if !ok || got != g {
+			t.Errorf("Generated() = %+v, %v, want %+v", got, ok, g)
+		}
+	}
+
+	if _, ok := Generated("Drive.java", []byte("package org.team;\n\npublic class Drive {\n}\n")); ok {
+		t.Error("a file the team wrote was taken for a generated one")
+	}
+}
+
+// git with autocrlf checks the generated files out with CRLF on Windows, and
+// they are still pusher's and still up to date.
+func TestACRLFCheckoutIsStillGenerated(t *testing.T) {
+	root := t.TempDir()
+	store := NewStore(filepath.Join(root, "configs"))
+	sources := filepath.Join(root, "src")
+	if err := store.Write("comp", []byte(realConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	g := comp()
+	path := g.Path(sources)
+	crlf := bytes.ReplaceAll(Generate(parse(t, realConfig), g), []byte("\n"), []byte("\r\n"))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, crlf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := Generated(path, crlf); !ok || got != g {
+		t.Errorf("Generated() = %+v, %v, want %+v", got, ok, g)
+	}
+	if stale, err := Stale(sources, store); err != nil || len(stale) != 0 {
+		t.Errorf("a CRLF checkout is stale: %v, %v", stale, err)
+	}
+}
+
+func TestStaleClassesAreFound(t *testing.T) {
+	root := t.TempDir()
+	store := NewStore(filepath.Join(root, "configs"))
+	sources := filepath.Join(root, "src")
+
+	if stale, err := Stale(sources, store); err != nil || len(stale) != 0 {
+		t.Fatalf("a project with no sources: %v, %v", stale, err)
+	}
+
+	if err := store.Write("comp", []byte(realConfig)); err != nil {
+		t.Fatal(err)
+	}
+	g := comp()
+	path := g.Path(sources)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, Generate(parse(t, realConfig), g), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "Drive.java"), []byte("class Drive {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if stale, err := Stale(sources, store); err != nil || len(stale) != 0 {
+		t.Fatalf("a class just generated is stale: %v, %v", stale, err)
+	}
+
+	renamed := strings.Replace(realConfig, `name="intake"`, `name="roller"`, 1)
+	if err := store.Write("comp", []byte(renamed)); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := Stale(sources, store)
+	if err != nil || len(stale) != 1 || !strings.Contains(stale[0], "CompHardware.java is out of date") {
+		t.Errorf("a renamed device: %v, %v", stale, err)
+	}
+
+	if err := store.Remove("comp"); err != nil {
+		t.Fatal(err)
+	}
+	stale, err = Stale(sources, store)
+	if err != nil || len(stale) != 1 || !strings.Contains(stale[0], "not in") {
+		t.Errorf("a removed configuration: %v, %v", stale, err)
+	}
+}
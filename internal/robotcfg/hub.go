@@ -244,6 +244,18 @@ func unescapeXML(s string) string {
 	).Replace(s)
 }
 
+// RobotID names a robot the same way however it is reached. adb's serial is
+// the USB serial over a cable and an address and port over Wi-Fi, and the
+// port changes with every wireless debugging pairing; the device's own serial
+// number does not.
+func RobotID(serial string) string {
+	out, err := adb.Shell(serial, "getprop", "ro.serialno")
+	if id := strings.TrimSpace(out); err == nil && id != "" && id != "unknown" {
+		return id
+	}
+	return serial
+}
+
 // LocalDir is where configurations are kept in an FTC project.
 func LocalDir(projectRoot string) string {
 	return filepath.Join(projectRoot, "configs")
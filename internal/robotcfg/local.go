@@ -5,13 +5,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
-const backupDir = ".pusher-backup"
+const (
+	backupDir = ".pusher-backup"
+	baseDir   = ".pusher-base"
+)
 
-// Store is a directory of configuration files in a project.
+// Store is a directory of configuration files in a project. A configuration
+// is kept as the robot controller's XML, or as YAML when there is a .yaml
+// file for it, which then wins over any XML beside it. Either way Read
+// returns the XML and Write takes it, so nothing that reads a configuration
+// needs to know which it is.
 type Store struct {
 	Dir string
 }
@@ -23,9 +32,26 @@ func NewStore(dir string) *Store {
 
 // Path is where a named configuration is kept.
 func (s *Store) Path(name string) string {
+	if s.IsYAML(name) {
+		return s.yamlPath(name)
+	}
+	return s.xmlPath(name)
+}
+
+func (s *Store) xmlPath(name string) string {
 	return filepath.Join(s.Dir, name+Ext)
 }
 
+func (s *Store) yamlPath(name string) string {
+	return filepath.Join(s.Dir, name+YAMLExt)
+}
+
+// IsYAML reports whether a configuration is kept as YAML.
+func (s *Store) IsYAML(name string) bool {
+	_, err := os.Stat(s.yamlPath(name))
+	return err == nil
+}
+
 // Names lists the configurations in the directory, sorted.
 func (s *Store) Names() ([]string, error) {
 	entries, err := os.ReadDir(s.Dir)
@@ -37,39 +63,142 @@ func (s *Store) Names() ([]string, error) {
 	}
 
 	var names []string
+	seen := map[string]bool{}
 	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), Ext) {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		switch {
+		case strings.HasSuffix(name, Ext):
+			name = strings.TrimSuffix(name, Ext)
+		case strings.HasSuffix(name, YAMLExt):
+			name = strings.TrimSuffix(name, YAMLExt)
+		default:
 			continue
 		}
-		names = append(names, strings.TrimSuffix(e.Name(), Ext))
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
 
 	sort.Strings(names)
 	return names, nil
 }
 
-// Read returns one configuration from the directory.
+// Read returns one configuration from the directory, as XML.
 func (s *Store) Read(name string) ([]byte, error) {
-	data, err := os.ReadFile(s.Path(name))
+	path := s.Path(name)
+	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
 		return nil, fmt.Errorf("no configuration called %q in %s", name, s.Dir)
 	}
 	if err != nil {
 		return nil, err
 	}
+
+	if path == s.yamlPath(name) {
+		cfg, err := FromYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return Write(cfg), nil
+	}
 	return data, nil
 }
 
-// Write saves a configuration.
+// Write saves a configuration given as XML, converting it when the
+// configuration is kept as YAML.
 func (s *Store) Write(name string, data []byte) error {
 	if err := CheckName(name); err != nil {
 		return err
 	}
+
+	path := s.Path(name)
+	if path == s.yamlPath(name) {
+		cfg, err := Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s is kept as YAML, and this does not parse: %w", name, err)
+		}
+		data = ToYAML(cfg)
+	}
+
 	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
 		return fmt.Errorf("cannot create %s: %w", s.Dir, err)
 	}
-	if err := os.WriteFile(s.Path(name), data, 0o644); err != nil {
-		return fmt.Errorf("cannot write %s: %w", s.Path(name), err)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return nil
+}
+
+// KeepAsYAML makes the YAML the configuration's source, in place of its XML.
+func (s *Store) KeepAsYAML(name string) error {
+	if s.IsYAML(name) {
+		return nil
+	}
+	data, err := s.Read(name)
+	if err != nil {
+		return err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s does not parse, so it cannot be kept as YAML: %w", s.xmlPath(name), err)
+	}
+
+	// The XML is deleted, so the YAML has to give back every byte of it. What
+	// it cannot hold, a comment for one, leaves the file as it is.
+	y := ToYAML(cfg)
+	back, err := FromYAML(y)
+	if err != nil {
+		return fmt.Errorf("%s cannot be kept as YAML: %w", s.xmlPath(name), err)
+	}
+	if line, differs := firstDifference(data, Write(back)); differs {
+		why := fmt.Sprintf("line %d would not come back from it the same", line)
+		if bytes.Contains(data, []byte("<!--")) {
+			why = "its comments would be lost"
+		}
+		return fmt.Errorf("%s cannot be kept as YAML: %s, so it stays XML", s.xmlPath(name), why)
+	}
+
+	if err := os.WriteFile(s.yamlPath(name), y, 0o644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", s.yamlPath(name), err)
+	}
+	if err := os.Remove(s.xmlPath(name)); err != nil {
+		return fmt.Errorf("cannot delete %s: %w", s.xmlPath(name), err)
+	}
+	return nil
+}
+
+// firstDifference is the first line on which two files differ, counted from
+// one.
+func firstDifference(a, b []byte) (int, bool) {
+	if bytes.Equal(a, b) {
+		return 0, false
+	}
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return bytes.Count(a[:n], []byte("\n")) + 1, true
+}
+
+// KeepAsXML makes the XML the configuration's source again.
+func (s *Store) KeepAsXML(name string) error {
+	if !s.IsYAML(name) {
+		return nil
+	}
+	data, err := s.Read(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(s.xmlPath(name), data, 0o644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", s.xmlPath(name), err)
+	}
+	if err := os.Remove(s.yamlPath(name)); err != nil {
+		return fmt.Errorf("cannot delete %s: %w", s.yamlPath(name), err)
 	}
 	return nil
 }
@@ -113,6 +242,96 @@ func (s *Store) Backup(name string, data []byte) (string, error) {
 	return path, nil
 }
 
+// A base is the configuration as it last was on both a robot and the project,
+// which is what a sync holds each side's changes against. It is per robot,
+// since a project shared by two robots is in step with each at a different
+// point, and it is XML, as it was on the robot.
+
+func (s *Store) basePath(robot, name string) string {
+	return filepath.Join(s.Dir, baseDir, safeFileName(robot), name+Ext)
+}
+
+// Base returns what a configuration was when it last matched on a robot.
+func (s *Store) Base(robot, name string) ([]byte, bool) {
+	data, err := os.ReadFile(s.basePath(robot, name))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// SetBase records that a configuration matches on a robot.
+func (s *Store) SetBase(robot, name string, data []byte) error {
+	path := s.basePath(robot, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ForgetBase drops the record of a configuration on a robot.
+func (s *Store) ForgetBase(robot, name string) error {
+	if err := os.Remove(s.basePath(robot, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// safeFileName makes a robot's id, which may be an address and port, usable
+// as a directory name on every OS.
+func safeFileName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(illegalNameChars, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, id)
+}
+
+var lineMention = regexp.MustCompile(`\bline (\d+)\b`)
+
+// Locate moves issues found in what Read returned to the lines of the file a
+// person edits, which for a configuration kept as YAML is not the XML the
+// issues were found in.
+func (s *Store) Locate(name string, issues Issues) Issues {
+	if !s.IsYAML(name) || len(issues) == 0 {
+		return issues
+	}
+	data, err := os.ReadFile(s.yamlPath(name))
+	if err != nil {
+		return issues
+	}
+	return LocateInYAML(data, issues)
+}
+
+// LocateInYAML moves issues found in the XML a YAML configuration writes to
+// the lines of the YAML. Anything it cannot place is left as it was.
+func LocateInYAML(data []byte, issues Issues) Issues {
+	lines, err := yamlLines(data)
+	if err != nil {
+		return issues
+	}
+
+	out := make(Issues, len(issues))
+	for i, issue := range issues {
+		if line, ok := lines[issue.Line]; ok {
+			issue.Line = line
+		}
+		issue.Msg = lineMention.ReplaceAllStringFunc(issue.Msg, func(mention string) string {
+			n, _ := strconv.Atoi(strings.TrimPrefix(mention, "line "))
+			if line, ok := lines[n]; ok {
+				return "line " + strconv.Itoa(line)
+			}
+			return mention
+		})
+		out[i] = issue
+	}
+	return out
+}
+
 // Same reports whether two configurations are byte for byte identical.
 func Same(a, b []byte) bool {
 	return bytes.Equal(a, b)
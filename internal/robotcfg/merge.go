@@ -0,0 +1,585 @@
+package robotcfg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A configuration is edited in two places: on the Driver Station, by whoever
+// is at the robot, and in the project, by whoever is at a laptop. Copying one
+// over the other loses the other's work. Against the copy both started from,
+// though, each side's changes can be told apart, and most of the time they
+// touch different ports.
+//
+// Things are matched by where they are, not by where they are in the file:
+// a portal by its serial number, a hub by its address, a device by its hub
+// and its port. A device renamed is then a change to one port; a device moved
+// is one port emptied and another filled. Each is taken from whichever side
+// changed it, and is a conflict only when both sides changed it differently.
+
+// Side is one of the two copies being merged.
+type Side int
+
+// The sides. Neither is for a merge that resolves no conflicts.
+const (
+	Neither Side = iota
+	Project
+	Robot
+)
+
+func (s Side) String() string {
+	switch s {
+	case Project:
+		return "project"
+	case Robot:
+		return "robot"
+	}
+	return "neither"
+}
+
+func (s Side) other() Side {
+	if s == Robot {
+		return Project
+	}
+	return Robot
+}
+
+// Conflict is a place both sides changed, differently.
+type Conflict struct {
+	Where   string
+	Project string
+	Robot   string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: the project has %s, the robot has %s", c.Where, c.Project, c.Robot)
+}
+
+// Merge brings together the project's and the robot's copies of a
+// configuration. Without a base, when the two were never known to agree, any
+// difference is a conflict. A conflict takes the side prefer names, and the
+// project's when it names neither; whichever it takes, it is reported.
+//
+// The result starts from the project's copy, so the change in the project is
+// only what came from the robot.
+func Merge(base, project, robot *Config, prefer Side) (*Config, []Conflict) {
+	m := merger{
+		base:    base,
+		project: project,
+		robot:   robot,
+		prefer:  prefer,
+		out:     Clone(project),
+		kept:    map[[2]string]bool{},
+	}
+
+	for _, key := range portalKeys(base, project, robot) {
+		m.portal(key)
+	}
+	for _, key := range moduleKeys(base, project, robot) {
+		m.module(key)
+	}
+	for _, key := range deviceKeys(base, project, robot) {
+		m.device(key)
+	}
+
+	return m.out, m.conflicts
+}
+
+type merger struct {
+	base, project, robot *Config
+	prefer               Side
+	out                  *Config
+	conflicts            []Conflict
+
+	// kept are hubs the robot removed that stay as the project has them,
+	// devices and all, because the project changed what was on them.
+	kept map[[2]string]bool
+}
+
+// choose picks between the two sides' versions of one thing, each given as a
+// signature that is empty when the side does not have it.
+func (m *merger) choose(where, b, p, r string, describe func(Side) string) Side {
+	switch {
+	case p == r:
+		return Project
+	case m.base != nil && p == b:
+		return Robot
+	case m.base != nil && r == b:
+		return Project
+	}
+
+	m.conflicts = append(m.conflicts, Conflict{Where: where, Project: describe(Project), Robot: describe(Robot)})
+	if m.prefer == Robot {
+		return Robot
+	}
+	return Project
+}
+
+func (m *merger) side(s Side) *Config {
+	if s == Robot {
+		return m.robot
+	}
+	return m.project
+}
+
+// portal settles a portal's own attributes, or whether it is there at all.
+func (m *merger) portal(key string) {
+	get := func(cfg *Config) (Portal, bool) {
+		i := findPortal(cfg, key)
+		if i < 0 {
+			return Portal{}, false
+		}
+		return cfg.Portals[i], true
+	}
+	sig := func(cfg *Config) string {
+		p, ok := get(cfg)
+		if !ok {
+			return ""
+		}
+		return signature(p.Tag, portalAttrs(p))
+	}
+
+	bp, _ := get(m.base)
+	pp, _ := get(m.project)
+	rp, _ := get(m.robot)
+	where := firstNonEmpty(pp.Name, rp.Name, bp.Name, key)
+
+	chosen := m.choose(where, sig(m.base), sig(m.project), sig(m.robot), func(s Side) string {
+		p, ok := get(m.side(s))
+		if !ok {
+			return "removed it"
+		}
+		return describeAttrs(p.Tag, portalAttrs(p))
+	})
+	if chosen == Project {
+		return
+	}
+
+	want, there := get(m.robot)
+	i := findPortal(m.out, key)
+	switch {
+	case !there && i >= 0:
+		m.out.Portals = append(m.out.Portals[:i], m.out.Portals[i+1:]...)
+	case there && i < 0:
+		want.Attrs = append([]Attr(nil), want.Attrs...)
+		want.Devices, want.Modules = nil, nil
+		m.out.Portals = append(m.out.Portals, want)
+	case there:
+		have := &m.out.Portals[i]
+		have.Tag, have.Name, have.Serial = want.Tag, want.Name, want.Serial
+		have.ParentAddress, have.HasParent = want.ParentAddress, want.HasParent
+		have.Attrs = append([]Attr(nil), want.Attrs...)
+		have.SelfClosing = want.SelfClosing
+	}
+}
+
+// module settles a hub's own attributes, or whether it is there at all.
+func (m *merger) module(key [2]string) {
+	get := func(cfg *Config) (Module, bool) {
+		pi, mi := findModule(cfg, key)
+		if mi < 0 {
+			return Module{}, false
+		}
+		return cfg.Portals[pi].Modules[mi], true
+	}
+	sig := func(cfg *Config) string {
+		mod, ok := get(cfg)
+		if !ok {
+			return ""
+		}
+		return signature(mod.Tag, moduleAttrs(mod))
+	}
+
+	bm, _ := get(m.base)
+	pm, _ := get(m.project)
+	rm, _ := get(m.robot)
+	where := firstNonEmpty(pm.Name, rm.Name, bm.Name, "hub at "+key[1])
+
+	chosen := m.choose(where, sig(m.base), sig(m.project), sig(m.robot), func(s Side) string {
+		mod, ok := get(m.side(s))
+		if !ok {
+			return "removed it"
+		}
+		return describeAttrs(mod.Tag, moduleAttrs(mod))
+	})
+	if chosen == Project {
+		return
+	}
+
+	want, there := get(m.robot)
+	pi, mi := findModule(m.out, key)
+
+	// The hub's own attributes say nothing of its devices, so the robot
+	// removing a hub the project left alone is only a clean removal when the
+	// project left what is on it alone too. Otherwise the project's devices
+	// would go with the hub and nothing would say so.
+	if !there && mi >= 0 && m.base != nil && m.devicesChanged(key) {
+		m.conflicts = append(m.conflicts, Conflict{Where: where,
+			Project: "changed the devices on it", Robot: "removed it"})
+		if m.prefer != Robot {
+			m.kept[key] = true
+			return
+		}
+	}
+
+	switch {
+	case !there && mi >= 0:
+		list := &m.out.Portals[pi].Modules
+		*list = append((*list)[:mi], (*list)[mi+1:]...)
+	case there && mi < 0:
+		pi := findPortal(m.out, key[0])
+		if pi < 0 {
+			m.conflicts = append(m.conflicts, Conflict{Where: where,
+				Project: "removed the portal it is on", Robot: "added it"})
+			return
+		}
+		want.Attrs = append([]Attr(nil), want.Attrs...)
+		want.Devices = nil
+		m.out.Portals[pi].Modules = append(m.out.Portals[pi].Modules, want)
+	case there:
+		have := &m.out.Portals[pi].Modules[mi]
+		have.Tag, have.Name = want.Tag, want.Name
+		have.Address, have.HasAddress = want.Address, want.HasAddress
+		have.Attrs = append([]Attr(nil), want.Attrs...)
+		have.SelfClosing = want.SelfClosing
+	}
+}
+
+// device settles what is on one port.
+func (m *merger) device(key deviceKey) {
+	get := func(cfg *Config) (Device, bool) {
+		s, ok := findDevice(cfg, key)
+		if !ok {
+			return Device{}, false
+		}
+		return cfg.DeviceAt(s)
+	}
+	sig := func(cfg *Config) string {
+		d, ok := get(cfg)
+		if !ok {
+			return ""
+		}
+		return signature(d.Tag, deviceAttrs(d))
+	}
+
+	if m.kept[[2]string{key.portal, key.module}] {
+		return
+	}
+
+	bd, _ := get(m.base)
+	pd, _ := get(m.project)
+	rd, _ := get(m.robot)
+	where := key.where(firstNonEmpty(pd.Tag, rd.Tag, bd.Tag), m.containerName(key))
+
+	describe := func(s Side) string {
+		d, ok := get(m.side(s))
+		if !ok {
+			return "nothing"
+		}
+		other, _ := get(m.side(s.other()))
+		if d.Name == other.Name && d.Tag == other.Tag {
+			return describeAttrs(d.Tag, deviceAttrs(d))
+		}
+		return fmt.Sprintf("%q (%s)", d.Name, d.Tag)
+	}
+	if m.choose(where, sig(m.base), sig(m.project), sig(m.robot), describe) == Project {
+		return
+	}
+
+	want, there := get(m.robot)
+	slot, have := findDevice(m.out, key)
+	switch {
+	case !there && have:
+		_ = m.out.RemoveDevice(slot)
+	case there && have:
+		list, _ := m.out.devicesAt(slot)
+		want.Attrs = append([]Attr(nil), want.Attrs...)
+		(*list)[slot.Device] = want
+	case there:
+		list, ok := m.out.containerOf(key)
+		if !ok {
+			m.conflicts = append(m.conflicts, Conflict{Where: where,
+				Project: "removed the hub it is on", Robot: fmt.Sprintf("%q (%s) on it", want.Name, want.Tag)})
+			return
+		}
+		want.Attrs = append([]Attr(nil), want.Attrs...)
+		*list = insertDevice(*list, want)
+	}
+}
+
+// devicesChanged reports whether the project's devices on the hub at key are
+// not the base's: one added, removed or changed.
+func (m *merger) devicesChanged(key [2]string) bool {
+	on := func(cfg *Config) map[deviceKey]string {
+		out := map[deviceKey]string{}
+		pi, mi := findModule(cfg, key)
+		if mi < 0 {
+			return out
+		}
+		p := cfg.Portals[pi]
+		for _, d := range p.Modules[mi].Devices {
+			out[keyOf(p, &p.Modules[mi], d)] = signature(d.Tag, deviceAttrs(d))
+		}
+		return out
+	}
+
+	base, project := on(m.base), on(m.project)
+	if len(base) != len(project) {
+		return true
+	}
+	for k, sig := range project {
+		if base[k] != sig {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *merger) containerName(key deviceKey) string {
+	for _, cfg := range []*Config{m.project, m.robot, m.base} {
+		if cfg == nil {
+			continue
+		}
+		if key.module == "" {
+			if i := findPortal(cfg, key.portal); i >= 0 {
+				return label(cfg.Portals[i].Tag, cfg.Portals[i].Name)
+			}
+			continue
+		}
+		if pi, mi := findModule(cfg, [2]string{key.portal, key.module}); mi >= 0 {
+			return cfg.Portals[pi].Modules[mi].Name
+		}
+	}
+	return key.portal
+}
+
+// insertDevice puts a device among a hub's devices where the Driver Station
+// would have written it, without reordering the rest.
+func insertDevice(devices []Device, d Device) []Device {
+	at := len(devices)
+	for i, other := range devices {
+		if FlavorOf(other.Tag) > FlavorOf(d.Tag) ||
+			(FlavorOf(other.Tag) == FlavorOf(d.Tag) && (other.Bus > d.Bus || (other.Bus == d.Bus && other.Port > d.Port))) {
+			at = i
+			break
+		}
+	}
+	devices = append(devices, Device{})
+	copy(devices[at+1:], devices[at:])
+	devices[at] = d
+	return devices
+}
+
+func signature(tag string, attrs []Attr) string {
+	var b strings.Builder
+	b.WriteString(tag)
+	writeAttrs(&b, attrs)
+	return b.String()
+}
+
+func describeAttrs(tag string, attrs []Attr) string {
+	var b strings.Builder
+	b.WriteString("<" + tag)
+	writeAttrs(&b, attrs)
+	b.WriteString(">")
+	return b.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// portalKey is what a portal is known by: its serial number, which the
+// Driver Station's scan found, or its type and name when it has none.
+func portalKey(p Portal) string {
+	if p.Serial != "" {
+		return "serial " + p.Serial
+	}
+	return p.Tag + " " + p.Name
+}
+
+func moduleKey(p Portal, m Module) [2]string {
+	if m.HasAddress {
+		return [2]string{portalKey(p), strconv.Itoa(m.Address)}
+	}
+	return [2]string{portalKey(p), m.Tag + " " + m.Name}
+}
+
+// deviceKey is a port: the portal and hub it is on, the kind of port, and
+// its bus and number. A device with no port is known by its name.
+type deviceKey struct {
+	portal, module string
+	flavor         Flavor
+	bus, port      int
+	name           string
+}
+
+func keyOf(p Portal, m *Module, d Device) deviceKey {
+	k := deviceKey{portal: portalKey(p)}
+	if m != nil {
+		k.module = moduleKey(p, *m)[1]
+	}
+	if !d.HasPort {
+		k.name = d.Tag + " " + d.Name
+		return k
+	}
+	k.flavor, k.port = FlavorOf(d.Tag), d.Port
+	if d.HasBus {
+		k.bus = d.Bus
+	}
+	return k
+}
+
+func (k deviceKey) where(tag, container string) string {
+	if k.name != "" {
+		return fmt.Sprintf("%s, %s", container, strings.TrimPrefix(k.name, tag+" "))
+	}
+	return fmt.Sprintf("%s, %s", container, position(Device{Tag: tag, Port: k.port, HasPort: true, Bus: k.bus, HasBus: k.flavor == I2C}))
+}
+
+func findPortal(cfg *Config, key string) int {
+	if cfg == nil {
+		return -1
+	}
+	for i, p := range cfg.Portals {
+		if portalKey(p) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func findModule(cfg *Config, key [2]string) (int, int) {
+	pi := findPortal(cfg, key[0])
+	if pi < 0 {
+		return -1, -1
+	}
+	p := cfg.Portals[pi]
+	for mi, m := range p.Modules {
+		if moduleKey(p, m) == key {
+			return pi, mi
+		}
+	}
+	return pi, -1
+}
+
+func findDevice(cfg *Config, key deviceKey) (Slot, bool) {
+	if cfg == nil {
+		return Slot{}, false
+	}
+	for pi, p := range cfg.Portals {
+		if portalKey(p) != key.portal {
+			continue
+		}
+		for di, d := range p.Devices {
+			if keyOf(p, nil, d) == key {
+				return Slot{pi, -1, di}, true
+			}
+		}
+		for mi := range p.Modules {
+			for di, d := range p.Modules[mi].Devices {
+				if keyOf(p, &p.Modules[mi], d) == key {
+					return Slot{pi, mi, di}, true
+				}
+			}
+		}
+	}
+	return Slot{}, false
+}
+
+// containerOf is the list a device with that key belongs in.
+func (c *Config) containerOf(key deviceKey) (*[]Device, bool) {
+	if key.module == "" {
+		pi := findPortal(c, key.portal)
+		if pi < 0 {
+			return nil, false
+		}
+		return &c.Portals[pi].Devices, true
+	}
+	for pi, p := range c.Portals {
+		if portalKey(p) != key.portal {
+			continue
+		}
+		for mi, m := range p.Modules {
+			if moduleKey(p, m)[1] == key.module {
+				return &c.Portals[pi].Modules[mi].Devices, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// The keys of everything in any of the three, in the order the project has
+// them, then the robot, then the base.
+
+func portalKeys(configs ...*Config) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, cfg := range orderForKeys(configs) {
+		for _, p := range cfg.Portals {
+			if k := portalKey(p); !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+func moduleKeys(configs ...*Config) [][2]string {
+	var keys [][2]string
+	seen := map[[2]string]bool{}
+	for _, cfg := range orderForKeys(configs) {
+		for _, p := range cfg.Portals {
+			for _, m := range p.Modules {
+				if k := moduleKey(p, m); !seen[k] {
+					seen[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+	}
+	return keys
+}
+
+func deviceKeys(configs ...*Config) []deviceKey {
+	var keys []deviceKey
+	seen := map[deviceKey]bool{}
+	add := func(k deviceKey) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	for _, cfg := range orderForKeys(configs) {
+		for _, p := range cfg.Portals {
+			for _, d := range p.Devices {
+				add(keyOf(p, nil, d))
+			}
+			for mi := range p.Modules {
+				for _, d := range p.Modules[mi].Devices {
+					add(keyOf(p, &p.Modules[mi], d))
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// orderForKeys is the configurations given as base, project, robot, put in
+// project, robot, base order and without the missing base.
+func orderForKeys(configs []*Config) []*Config {
+	var out []*Config
+	for _, cfg := range []*Config{configs[1], configs[2], configs[0]} {
+		if cfg != nil {
+			out = append(out, cfg)
+		}
+	}
+	return out
+}
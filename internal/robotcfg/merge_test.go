@@ -0,0 +1,205 @@
+package robotcfg
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func edited(t *testing.T, pairs ...string) *Config {
+	t.Helper()
+	xml := realConfig
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if !strings.Contains(xml, pairs[i]) {
+			t.Fatalf("%q is not in the configuration", pairs[i])
+		}
+		xml = strings.Replace(xml, pairs[i], pairs[i+1], 1)
+	}
+	return parse(t, xml)
+}
+
+func TestChangesOnDifferentPortsAreBothKept(t *testing.T) {
+	base := parse(t, realConfig)
+	// The drive team renamed a motor on the Driver Station...
+	robot := edited(t, `name="intake" port="1"`, `name="roller" port="1"`)
+	// ...while a programmer added a servo and took one away.
+	project := edited(t,
+		`            <Servo name="led" port="0" />`+"\n",
+		`            <Servo name="led" port="0" />`+"\n"+`            <Servo name="claw" port="1" />`+"\n",
+		`            <Servo name="capac" port="1" />`+"\n", "")
+
+	out, conflicts := Merge(base, project, robot, Neither)
+	if len(conflicts) != 0 {
+		t.Fatalf("conflicts: %v", conflicts)
+	}
+
+	want := strings.Replace(string(Write(project)), `name="intake"`, `name="roller"`, 1)
+	if got := string(Write(out)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTheSameChangeOnBothSidesIsNotAConflict(t *testing.T) {
+	base := parse(t, realConfig)
+	robot := edited(t, `name="fr"`, `name="frontRight"`)
+	project := edited(t, `name="fr"`, `name="frontRight"`)
+
+	out, conflicts := Merge(base, project, robot, Neither)
+	if len(conflicts) != 0 || string(Write(out)) != string(Write(project)) {
+		t.Errorf("conflicts %v, or the result is not the change both made", conflicts)
+	}
+}
+
+func TestAPortChangedDifferentlyIsAConflict(t *testing.T) {
+	base := parse(t, realConfig)
+	robot := edited(t, `name="fr"`, `name="rightFront"`)
+	project := edited(t, `name="fr"`, `name="frontRight"`, `name="intake"`, `name="roller"`)
+
+	out, conflicts := Merge(base, project, robot, Neither)
+	if len(conflicts) != 1 {
+		t.Fatalf("got %v", conflicts)
+	}
+	if got := conflicts[0].String(); got != `Control Hub, motor port 0: the project has "frontRight" (goBILDA5202SeriesMotor), the robot has "rightFront" (goBILDA5202SeriesMotor)` {
+		t.Errorf("got %s", got)
+	}
+	if !strings.Contains(string(Write(out)), `name="frontRight"`) || !strings.Contains(string(Write(out)), `name="roller"`) {
+		t.Error("without a preference, a conflict should keep the project's side and the rest should merge")
+	}
+
+	out, conflicts = Merge(base, project, robot, Robot)
+	written := string(Write(out))
+	if len(conflicts) != 1 || !strings.Contains(written, `name="rightFront"`) || !strings.Contains(written, `name="roller"`) {
+		t.Errorf("preferring the robot: %v\n%s", conflicts, written)
+	}
+}
+
+func TestAHubAddedOnTheRobotComesWithItsDevices(t *testing.T) {
+	base := parse(t, realConfig)
+	robot := edited(t, "    </LynxUsbDevice>\n",
+		`        <LynxModule name="Expansion Hub 3" port="3">
+            <Motor name="lift" port="0" />
+        </LynxModule>
+    </LynxUsbDevice>
+`)
+	project := edited(t, `name="bl" port="2"`, `name="backLeft" port="2"`)
+
+	out, conflicts := Merge(base, project, robot, Neither)
+	if len(conflicts) != 0 {
+		t.Fatalf("conflicts: %v", conflicts)
+	}
+	written := string(Write(out))
+	for _, want := range []string{`<LynxModule name="Expansion Hub 3" port="3">`, `<Motor name="lift" port="0" />`, `name="backLeft"`} {
+		if !strings.Contains(written, want) {
+			t.Errorf("missing %s in:\n%s", want, written)
+		}
+	}
+}
+
+func TestADeviceRemovedOnOneSideIsRemoved(t *testing.T) {
+	base := parse(t, realConfig)
+	robot := edited(t, `            <DigitalDevice name="beamBrakePos2" port="0" />`+"\n", "")
+
+	out, conflicts := Merge(base, parse(t, realConfig), robot, Neither)
+	if len(conflicts) != 0 || string(Write(out)) != string(Write(robot)) {
+		t.Errorf("conflicts %v, or the device is still there:\n%s", conflicts, Write(out))
+	}
+
+	// Removed on one side and renamed on the other is a conflict.
+	project := edited(t, `name="beamBrakePos2"`, `name="beam"`)
+	if _, conflicts := Merge(base, project, robot, Neither); len(conflicts) != 1 ||
+		!strings.Contains(conflicts[0].String(), "the robot has nothing") {
+		t.Errorf("got %v", conflicts)
+	}
+}
+
+// The hub's removal and the servo's addition are on different things, but
+// taking both would drop the servo with the hub and say nothing.
+func TestAHubRemovedUnderAnAddedDeviceIsAConflict(t *testing.T) {
+	base := parse(t, realConfig)
+	hub := `        <LynxModule name="Expansion Hub 2" port="2">
+            <goBILDA5202SeriesMotor name="transfer" port="0" />
+            <goBILDA5202SeriesMotor name="intake" port="1" />
+            <Servo name="capac" port="1" />
+        </LynxModule>
+`
+	robot := edited(t, hub, "")
+	project := edited(t, `            <Servo name="capac" port="1" />`+"\n",
+		`            <Servo name="capac" port="1" />`+"\n"+`            <Servo name="claw" port="2" />`+"\n")
+
+	out, conflicts := Merge(base, project, robot, Neither)
+	if len(conflicts) != 1 || conflicts[0].String() !=
+		"Expansion Hub 2: the project has changed the devices on it, the robot has removed it" {
+		t.Fatalf("got %v", conflicts)
+	}
+	if got, want := string(Write(out)), string(Write(project)); got != want {
+		t.Errorf("without a preference the project's hub should stay whole:\n%s", got)
+	}
+
+	out, conflicts = Merge(base, project, robot, Robot)
+	if len(conflicts) != 1 || string(Write(out)) != string(Write(robot)) {
+		t.Errorf("preferring the robot: %v\n%s", conflicts, Write(out))
+	}
+
+	// Left alone in the project, the hub goes quietly.
+	out, conflicts = Merge(base, parse(t, realConfig), robot, Neither)
+	if len(conflicts) != 0 || string(Write(out)) != string(Write(robot)) {
+		t.Errorf("an untouched hub: %v\n%s", conflicts, Write(out))
+	}
+}
+
+// Without a base there is no telling which side changed, so every difference
+// is a conflict and nothing is merged silently.
+func TestWithoutABaseEveryDifferenceIsAConflict(t *testing.T) {
+	robot := edited(t, `name="fr"`, `name="rightFront"`)
+	project := edited(t, `name="intake"`, `name="roller"`)
+
+	_, conflicts := Merge(nil, project, robot, Neither)
+	if len(conflicts) != 2 {
+		t.Errorf("got %v", conflicts)
+	}
+}
+
+func TestAnAddedDeviceLandsInPortOrder(t *testing.T) {
+	devices := []Device{
+		{Tag: "Motor", Name: "a", Port: 0, HasPort: true},
+		{Tag: "Motor", Name: "c", Port: 2, HasPort: true},
+		{Tag: "Servo", Name: "s", Port: 0, HasPort: true},
+	}
+	got := insertDevice(devices, Device{Tag: "Motor", Name: "b", Port: 1, HasPort: true})
+
+	var names []string
+	for _, d := range got {
+		names = append(names, d.Name)
+	}
+	if strings.Join(names, " ") != "a b c s" {
+		t.Errorf("got %v", names)
+	}
+}
+
+func TestABaseIsKeptPerRobot(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "configs"))
+
+	if _, ok := s.Base("192.168.43.1:5555", "comp"); ok {
+		t.Fatal("a base before any was recorded")
+	}
+	if err := s.SetBase("192.168.43.1:5555", "comp", []byte(realConfig)); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := s.Base("192.168.43.1:5555", "comp"); !ok || string(got) != realConfig {
+		t.Error("the base did not come back")
+	}
+	if _, ok := s.Base("practice-bot", "comp"); ok {
+		t.Error("one robot's base answered for another")
+	}
+
+	if names, err := s.Names(); err != nil || len(names) != 0 {
+		t.Errorf("a base showed up as a configuration: %v, %v", names, err)
+	}
+
+	if err := s.ForgetBase("192.168.43.1:5555", "comp"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Base("192.168.43.1:5555", "comp"); ok {
+		t.Error("the base is still there")
+	}
+}
@@ -84,6 +84,10 @@ type Config struct {
 
 	Indent string
 
+	// Newline is the line ending, "\r\n" for a file checked out on Windows
+	// and "\n" or empty for every other.
+	Newline string
+
 	Trailer string
 }
 
@@ -183,6 +187,7 @@ func Parse(data []byte) (*Config, error) {
 
 	cfg.Declaration = declarationOf(data)
 	cfg.Indent = indentOf(data)
+	cfg.Newline = newlineOf(data)
 	cfg.Trailer = trailerOf(data)
 
 	return cfg, nil
@@ -222,6 +227,13 @@ func indentOf(data []byte) string {
 	return "    "
 }
 
+func newlineOf(data []byte) string {
+	if i := bytes.IndexByte(data, '\n'); i > 0 && data[i-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
 func trailerOf(data []byte) string {
 	close := bytes.LastIndex(data, []byte("</"+RootTag+">"))
 	if close < 0 {
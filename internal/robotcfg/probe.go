@@ -0,0 +1,90 @@
+package robotcfg
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andreibanu/pusher/internal/adb"
+)
+
+// Nothing but the robot controller app can ask the RS-485 chain which hubs
+// are on it, and it does not answer adb. It does log what it finds each time
+// it looks, when it starts and when the Driver Station scans, into the same
+// log files on /sdcard that survive a reboot. So the hubs attached are the
+// ones its latest discovery found.
+
+// controllerLogs are the robot controller's logs, oldest first, so the last
+// discovery read is the latest.
+var controllerLogs = []string{
+	"/sdcard/robotControllerLog.txt.3",
+	"/sdcard/robotControllerLog.txt.2",
+	"/sdcard/robotControllerLog.txt.1",
+	"/sdcard/robotControllerLog.txt",
+}
+
+var (
+	discoveryStart = regexp.MustCompile(`(?i)lynx discovery beginning`)
+	discoveredHub  = regexp.MustCompile(`(?i)discovered lynx module.*?addr(?:ess)?[=: ]+(\d+)`)
+)
+
+// ProbeAddresses asks the robot which Expansion Hubs answered the robot
+// controller's latest discovery, by address. The Control Hub is not among
+// them; it is always there.
+func ProbeAddresses(serial string) ([]int, error) {
+	args := append([]string{"grep", "-h", "-i", "-E", "'lynx discovery beginning|discovered lynx module'"},
+		controllerLogs...)
+	out, err := adb.Shell(serial, append(args, "2>/dev/null")...)
+	if err != nil && strings.TrimSpace(out) == "" {
+		return nil, fmt.Errorf("cannot read the robot controller's log: %w", err)
+	}
+
+	return parseDiscovery(out)
+}
+
+// parseDiscovery reads the hubs the latest discovery in a log found.
+//
+// Every discovery finds at least the Control Hub's own module, so one that
+// lists nothing at all is a log pusher cannot read, most likely one an SDK
+// release reworded. Read as "no Expansion Hubs", it would lay out a robot
+// with hubs missing and say nothing.
+func parseDiscovery(log string) ([]int, error) {
+	var found map[int]bool
+	modules := 0
+	for _, line := range strings.Split(log, "\n") {
+		if discoveryStart.MatchString(line) {
+			found, modules = map[int]bool{}, 0
+			continue
+		}
+		m := discoveredHub.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if found == nil {
+			found = map[int]bool{}
+		}
+		modules++
+		if address, err := strconv.Atoi(m[1]); err == nil && address != ControlHubAddress {
+			found[address] = true
+		}
+	}
+
+	switch {
+	case found == nil:
+		return nil, fmt.Errorf("the robot controller's log has no hub discovery in it\n\n" +
+			"Restart the robot controller app, or scan on the Driver Station, then try again")
+	case modules == 0:
+		return nil, fmt.Errorf("log format not recognised: the latest hub discovery in the robot " +
+			"controller's log names no modules, not even the Control Hub\n\n" +
+			"Leave out --probe and list the hubs in a --from template instead")
+	}
+
+	addresses := make([]int, 0, len(found))
+	for a := range found {
+		addresses = append(addresses, a)
+	}
+	sort.Ints(addresses)
+	return addresses, nil
+}
@@ -0,0 +1,342 @@
+package robotcfg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/andreibanu/pusher/internal/javasrc"
+)
+
+// A name the configuration does not have is found when the OpMode that asks
+// for it is initialised, which at an event is on the field. So is a motor
+// asked for as a Servo. Both are visible in the source long before that: the
+// name is a string literal next to the class it is wanted as, and the
+// configuration says what is at that name.
+//
+// Only literal names are read. A name built at runtime, or kept in a constant,
+// is skipped rather than guessed at; so is anything in a generated class,
+// which Stale already holds to its configuration.
+
+// Lookup is one place the source fetches a device from the hardware map.
+type Lookup struct {
+	// File is relative to the source root.
+	File string
+	Line int
+	Name string
+	// Class is the simple name of the class asked for, or empty when the
+	// lookup does not say.
+	Class string
+	// Optional is tryGet, which expects that the device may be missing.
+	Optional bool
+}
+
+var (
+	// typedLookup is get or tryGet with a class, in Java or Kotlin. The
+	// receiver is not checked, since a HardwareMap is as often a parameter
+	// called hwMap as it is the OpMode's own hardwareMap.
+	typedLookup = regexp.MustCompile(`\.\s*(get|tryGet)\s*\(\s*([\w.]+?)\s*(?:\.class|::class\.java|::class)\s*,\s*"`)
+	// mappingLookup is the older form through one of the type mappings.
+	mappingLookup = regexp.MustCompile(`\.\s*(dcMotor|servo|crservo|analogInput|digitalChannel|touchSensor|colorSensor|led|opticalDistanceSensor|gyroSensor|irSeekerSensor|compassSensor|voltageSensor)\s*\.\s*get\s*\(\s*"`)
+	// untypedLookup asks only by name, which anything answers; here the
+	// receiver has to be the hardware map, or every map in the code matches.
+	untypedLookup = regexp.MustCompile(`\bhardwareMap\s*\.\s*get\s*\(\s*"`)
+)
+
+var mappings = map[string]string{
+	"dcMotor":               "DcMotor",
+	"servo":                 "Servo",
+	"crservo":               "CRServo",
+	"analogInput":           "AnalogInput",
+	"digitalChannel":        "DigitalChannel",
+	"touchSensor":           "TouchSensor",
+	"colorSensor":           "ColorSensor",
+	"led":                   "LED",
+	"opticalDistanceSensor": "OpticalDistanceSensor",
+	"gyroSensor":            "GyroSensor",
+	"irSeekerSensor":        "IrSeekerSensor",
+	"compassSensor":         "CompassSensor",
+	"voltageSensor":         "VoltageSensor",
+}
+
+// FindLookups reads every Java and Kotlin file under a source root for
+// hardware map lookups.
+func FindLookups(sourceRoot string) ([]Lookup, error) {
+	var lookups []Lookup
+	err := filepath.Walk(sourceRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == sourceRoot {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() || (filepath.Ext(path) != ".java" && filepath.Ext(path) != ".kt") {
+			return nil
+		}
+
+		blob, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if _, generated := Generated(path, blob); generated {
+			return nil
+		}
+
+		rel, err := filepath.Rel(sourceRoot, path)
+		if err != nil {
+			rel = path
+		}
+		lookups = append(lookups, findLookups(filepath.ToSlash(rel), string(blob))...)
+		return nil
+	})
+	return lookups, err
+}
+
+// findLookups reads one file. The patterns run over the masked source, so a
+// lookup in a comment is not one, and Mask keeps every byte where it was, so
+// the name is read from the raw source at the same offsets.
+func findLookups(file, raw string) []Lookup {
+	code := javasrc.Mask(raw)
+	kotlin := strings.HasSuffix(file, ".kt")
+
+	var lookups []Lookup
+	add := func(end int, class string, optional bool) {
+		// end is just past the opening quote of the name.
+		closing := strings.IndexByte(code[end:], '"')
+		if closing < 0 {
+			return
+		}
+		literal := raw[end-1 : end+closing+1]
+		if kotlin && templated(literal) {
+			return
+		}
+		name, err := strconv.Unquote(literal)
+		if err != nil {
+			return
+		}
+		// A name followed by anything but the end of the call is being
+		// built, "arm" + side, and is not the name looked up.
+		if rest := strings.TrimLeft(code[end+closing+1:], " \t\r\n"); !strings.HasPrefix(rest, ")") {
+			return
+		}
+		lookups = append(lookups, Lookup{
+			File:     file,
+			Line:     strings.Count(raw[:end], "\n") + 1,
+			Name:     name,
+			Class:    class,
+			Optional: optional,
+		})
+	}
+
+	for _, m := range typedLookup.FindAllStringSubmatchIndex(code, -1) {
+		class := code[m[4]:m[5]]
+		add(m[1], class[strings.LastIndex(class, ".")+1:], code[m[2]:m[3]] == "tryGet")
+	}
+	for _, m := range mappingLookup.FindAllStringSubmatchIndex(code, -1) {
+		add(m[1], mappings[code[m[2]:m[3]]], false)
+	}
+	for _, m := range untypedLookup.FindAllStringIndex(code, -1) {
+		add(m[1], "", false)
+	}
+
+	sort.Slice(lookups, func(a, b int) bool { return lookups[a].Line < lookups[b].Line })
+	return lookups
+}
+
+// templated reports whether a Kotlin string literal is a template, "${side}Motor"
+// or "$side", which is a name built at runtime however literal it looks. A
+// dollar sign escaped with a backslash is only a dollar sign.
+func templated(literal string) bool {
+	for i := 0; i+1 < len(literal); i++ {
+		switch c := literal[i]; {
+		case c == '\\':
+			i++
+		case c == '$':
+			next := literal[i+1]
+			if next == '{' || next == '_' || unicode.IsLetter(rune(next)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// classFlavors are the ports a device fetched as each class can be on. A class
+// not listed, a team's own driver or a camera, is not checked.
+var classFlavors = map[string][]Flavor{
+	"DcMotor":       {Motor},
+	"DcMotorEx":     {Motor},
+	"DcMotorImplEx": {Motor},
+	// A SPARK Mini is a motor controller on a servo port, and a CRServo is
+	// a DcMotorSimple too.
+	"DcMotorSimple": {Motor, Servo},
+
+	"Servo":         {Servo},
+	"ServoImplEx":   {Servo},
+	"CRServo":       {Servo},
+	"CRServoImplEx": {Servo},
+
+	"AnalogInput":    {Analog},
+	"DigitalChannel": {Digital},
+	"LED":            {Digital},
+	"TouchSensor":    {Digital, Analog},
+
+	"IMU":                       {I2C},
+	"BNO055IMU":                 {I2C},
+	"ColorSensor":               {I2C},
+	"NormalizedColorSensor":     {I2C},
+	"RevColorSensorV3":          {I2C},
+	"DistanceSensor":            {I2C},
+	"Rev2mDistanceSensor":       {I2C},
+	"GoBildaPinpointDriver":     {I2C},
+	"SparkFunOTOS":              {I2C},
+	"NavxMicroNavigationSensor": {I2C},
+}
+
+// Mismatch is a lookup the configurations do not answer the way the source
+// expects.
+type Mismatch struct {
+	Lookup
+	Msg string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s:%d: %s", m.File, m.Line, m.Msg)
+}
+
+// CheckLookups holds lookups against configurations. Given one, the robot's
+// active one, that is exact. Given several, when which will be active is not
+// known, a name is missing when none of them has it, and asked for as the
+// wrong class when every one that has it disagrees.
+func CheckLookups(lookups []Lookup, configs map[string]*Config) []Mismatch {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	which := strings.Join(names, ", ")
+	if len(names) > 1 {
+		which = "any of " + which
+	}
+
+	var mismatches []Mismatch
+	for _, l := range lookups {
+		var found []Device
+		known := false
+		for _, name := range names {
+			d, ok := lookupIn(configs[name], l.Name)
+			if ok {
+				known = true
+				found = append(found, d)
+			}
+		}
+
+		if !known {
+			if l.Optional {
+				continue
+			}
+			msg := fmt.Sprintf("%q is not in %s", l.Name, which)
+			if near := nearest(l.Name, configs); near != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", near)
+			}
+			mismatches = append(mismatches, Mismatch{l, msg})
+			continue
+		}
+
+		wanted, checked := classFlavors[l.Class]
+		if !checked {
+			continue
+		}
+		var conflict *Device
+		for i, d := range found {
+			f := FlavorOf(d.Tag)
+			if f == Unclassified || fits(f, wanted) {
+				conflict = nil
+				break
+			}
+			if conflict == nil {
+				conflict = &found[i]
+			}
+		}
+		if conflict != nil {
+			mismatches = append(mismatches, Mismatch{l, fmt.Sprintf("%q is asked for as a %s, but it is a %s (%s)",
+				l.Name, l.Class, FlavorOf(conflict.Tag), conflict.Tag)})
+		}
+	}
+	return mismatches
+}
+
+func fits(f Flavor, wanted []Flavor) bool {
+	for _, w := range wanted {
+		if f == w {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupIn finds what a configuration has at a name. The hubs themselves are
+// in the hardware map under their own names, as LynxModule and VoltageSensor,
+// so those count too; they come back without a tag to check.
+func lookupIn(cfg *Config, name string) (Device, bool) {
+	for _, d := range cfg.Named() {
+		if d.Enabled() && d.Name == name {
+			return d, true
+		}
+	}
+	for _, p := range cfg.Portals {
+		if p.Name == name {
+			return Device{Name: name}, true
+		}
+		for _, m := range p.Modules {
+			if m.Name == name {
+				return Device{Name: name}, true
+			}
+		}
+	}
+	return Device{}, false
+}
+
+// nearest is the configured name a typo most likely meant: the closest by
+// edits, if it is close enough to be a typo rather than another device.
+func nearest(name string, configs map[string]*Config) string {
+	best, bestDistance := "", 3
+	if len(name) <= 3 {
+		bestDistance = 2
+	}
+	for _, cfg := range configs {
+		for _, candidate := range cfg.Names() {
+			d := distance(strings.ToLower(name), strings.ToLower(candidate))
+			if d < bestDistance || (d == bestDistance && best != "" && candidate < best) {
+				best, bestDistance = candidate, d
+			}
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between two names.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,168 @@
+package robotcfg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const teamCode = `package org.firstinspires.ftc.teamcode;
+
+public class Robot {
+    // hardwareMap.get(DcMotorEx.class, "commented") is not a lookup
+    public void init(HardwareMap hwMap) {
+        fr = hwMap.get(DcMotorEx.class, "fr");
+        intake = hwMap.get(com.qualcomm.robotcore.hardware.DcMotor.class,
+                "intake");
+        lefFront = hardwareMap.dcMotor.get("lefFront");
+        wrong = hardwareMap.get(Servo.class, "transfer");
+        arm = hardwareMap.get(DcMotorEx.class, "arm" + side);
+        hub = hardwareMap.get(LynxModule.class, "Control Hub");
+        cam = hardwareMap.tryGet(WebcamName.class, "Webcam 1");
+        claw = hardwareMap.get("claw");
+        String s = "hardwareMap.get(\"quoted\")";
+    }
+}
+`
+
+func TestLookupsAreFoundInTheSource(t *testing.T) {
+	got := findLookups("Robot.java", teamCode)
+
+	want := []Lookup{
+		{File: "Robot.java", Line: 6, Name: "fr", Class: "DcMotorEx"},
+		{File: "Robot.java", Line: 8, Name: "intake", Class: "DcMotor"},
+		{File: "Robot.java", Line: 9, Name: "lefFront", Class: "DcMotor"},
+		{File: "Robot.java", Line: 10, Name: "transfer", Class: "Servo"},
+		{File: "Robot.java", Line: 12, Name: "Control Hub", Class: "LynxModule"},
+		{File: "Robot.java", Line: 13, Name: "Webcam 1", Class: "WebcamName", Optional: true},
+		{File: "Robot.java", Line: 14, Name: "claw"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("found %d lookups, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("lookup %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestKotlinLookups(t *testing.T) {
+	got := findLookups("Robot.kt", `val fr = hardwareMap.get(DcMotorEx::class.java, "fr")`+"\n")
+	if len(got) != 1 || got[0].Name != "fr" || got[0].Class != "DcMotorEx" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// A Kotlin template builds its name at runtime, the same as "arm" + side does
+// in Java, and is skipped rather than reported as a name nobody configured.
+func TestKotlinTemplatesAreNotNames(t *testing.T) {
+	src := `val front = hardwareMap.get(DcMotorEx::class.java, "${side}Motor")
+val back = hardwareMap.get(DcMotorEx::class.java, "$side")
+val price = hardwareMap.get(Servo::class.java, "\$5")
+val plain = hardwareMap.get(Servo::class.java, "claw$")
+`
+	got := findLookups("Robot.kt", src)
+	if len(got) != 1 || got[0].Name != "claw$" {
+		t.Errorf("got %+v", got)
+	}
+
+	// In Java a dollar sign is only a dollar sign.
+	if got := findLookups("Robot.java", `hardwareMap.get(DcMotorEx.class, "${side}Motor");`); len(got) != 1 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestLookupsAreHeldAgainstTheConfiguration(t *testing.T) {
+	configs := map[string]*Config{"comp": parse(t, realConfig)}
+
+	var messages []string
+	for _, m := range CheckLookups(findLookups("Robot.java", teamCode), configs) {
+		messages = append(messages, m.String())
+	}
+
+	want := []string{
+		`Robot.java:9: "lefFront" is not in comp`,
+		`Robot.java:10: "transfer" is asked for as a Servo, but it is a motor`,
+		`Robot.java:14: "claw" is not in comp`,
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("got %d mismatches, want %d:\n%s", len(messages), len(want), strings.Join(messages, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(messages[i], want[i]) {
+			t.Errorf("got %q, want it to start %q", messages[i], want[i])
+		}
+	}
+}
+
+func TestATypoSuggestsTheName(t *testing.T) {
+	cfg := parse(t, `<Robot type="FirstInspires-FTC">
+    <LynxUsbDevice name="Control Hub Portal" serialNumber="(embedded)" parentModuleAddress="173">
+        <LynxModule name="Control Hub" port="173">
+            <goBILDA5202SeriesMotor name="leftFront" port="0" />
+            <goBILDA5202SeriesMotor name="rightFront" port="1" />
+        </LynxModule>
+    </LynxUsbDevice>
+</Robot>`)
+	configs := map[string]*Config{"comp": cfg}
+
+	lookups := []Lookup{
+		{File: "A.java", Line: 1, Name: "lefFront", Class: "DcMotorEx"},
+		{File: "A.java", Line: 2, Name: "intake", Class: "DcMotorEx"},
+	}
+	got := CheckLookups(lookups, configs)
+	if len(got) != 2 {
+		t.Fatalf("got %v", got)
+	}
+	if !strings.Contains(got[0].Msg, `did you mean "leftFront"?`) {
+		t.Errorf("no suggestion for a typo: %s", got[0].Msg)
+	}
+	if strings.Contains(got[1].Msg, "did you mean") {
+		t.Errorf("a suggestion for a name nothing is close to: %s", got[1].Msg)
+	}
+}
+
+// Without knowing which configuration is active, only what is wrong in all of
+// them is reported.
+func TestSeveralConfigurationsMustAllDisagree(t *testing.T) {
+	practice := strings.Replace(realConfig, `<goBILDA5202SeriesMotor name="transfer" port="0" />`,
+		`<Servo name="transfer" port="0" />`, 1)
+	practice = strings.Replace(practice, `name="bl"`, `name="lefFront"`, 1)
+
+	configs := map[string]*Config{"comp": parse(t, realConfig), "practice": parse(t, practice)}
+	got := CheckLookups(findLookups("Robot.java", teamCode), configs)
+
+	if len(got) != 1 || !strings.Contains(got[0].Msg, `"claw" is not in any of comp, practice`) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestGeneratedClassesAreNotScanned(t *testing.T) {
+	root := t.TempDir()
+	g := comp()
+	path := g.Path(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, Generate(parse(t, realConfig), g), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "Robot.java"), []byte(teamCode), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lookups, err := FindLookups(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range lookups {
+		if !strings.HasSuffix(l.File, "hardware/Robot.java") {
+			t.Errorf("a lookup from %s", l.File)
+		}
+	}
+	if len(lookups) != 7 {
+		t.Errorf("found %d lookups, want 7", len(lookups))
+	}
+}
@@ -0,0 +1,238 @@
+package robotcfg
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Setting up a fresh Control Hub on the Driver Station is a device at a time,
+// port by port, on a phone. A template says the same thing in a few lines
+// that can be kept in the repository and reused for the next hub:
+//
+//	hubs:
+//	  - name: Control Hub
+//	    motors:
+//	      0: leftFront
+//	      1: {name: rightFront, type: goBILDA5202SeriesMotor}
+//	    servos: {0: claw}
+//	    i2c:
+//	      0: {0: {name: imu, type: ControlHubImuBHI260AP}}
+//	  - address: 2
+//	    motors: {0: lift}
+//
+// The first hub is the Control Hub unless given another address; the rest
+// are Expansion Hubs on its RS-485 chain. A device given only a name is the
+// generic type for its ports, which the robot controller accepts for any
+// device plugged in there.
+
+// Template is a compact description of a configuration.
+type Template struct {
+	Hubs []HubTemplate `yaml:"hubs"`
+}
+
+// HubTemplate is one hub and what is plugged into it, by port.
+type HubTemplate struct {
+	Name    string                         `yaml:"name,omitempty"`
+	Address int                            `yaml:"address,omitempty"`
+	Motors  map[int]DeviceTemplate         `yaml:"motors,omitempty"`
+	Servos  map[int]DeviceTemplate         `yaml:"servos,omitempty"`
+	Analog  map[int]DeviceTemplate         `yaml:"analog,omitempty"`
+	Digital map[int]DeviceTemplate         `yaml:"digital,omitempty"`
+	PWM     map[int]DeviceTemplate         `yaml:"pwm,omitempty"`
+	I2C     map[int]map[int]DeviceTemplate `yaml:"i2c,omitempty"`
+}
+
+// DeviceTemplate is a device by name, and by type when the generic one for
+// its ports will not do.
+type DeviceTemplate struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type,omitempty"`
+}
+
+// UnmarshalYAML takes a bare name as well as a name and a type.
+func (d *DeviceTemplate) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		d.Name = node.Value
+		return nil
+	}
+	type plain DeviceTemplate
+	return node.Decode((*plain)(d))
+}
+
+// genericTags are what a device given only a name is, for each kind of port.
+var genericTags = map[Flavor]string{
+	Motor:   "Motor",
+	Servo:   "Servo",
+	Analog:  "AnalogInput",
+	Digital: "DigitalDevice",
+	PWM:     "PulseWidthDevice",
+}
+
+// FromTemplate builds the configuration a template describes. It is checked
+// for what a template can get wrong, a device type on the wrong kind of port
+// or a port the hub does not have; Validate still has the last word.
+func FromTemplate(data []byte) (*Config, error) {
+	var t Template
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&t); err != nil {
+		return nil, fmt.Errorf("cannot read the template: %w", err)
+	}
+	if len(t.Hubs) == 0 {
+		return nil, fmt.Errorf("the template has no hubs")
+	}
+
+	cfg := New()
+	portal := &cfg.Portals[0]
+	portal.Modules = nil
+
+	next := 1
+	taken := map[int]bool{}
+	for i, hub := range t.Hubs {
+		address := hub.Address
+		switch {
+		case address == 0 && i == 0:
+			address = ControlHubAddress
+		case address == 0:
+			for taken[next] {
+				next++
+			}
+			address = next
+		}
+		if taken[address] {
+			return nil, fmt.Errorf("two hubs at address %d", address)
+		}
+		taken[address] = true
+
+		name := hub.Name
+		if name == "" {
+			name = fmt.Sprintf("Expansion Hub %d", address)
+			if address == ControlHubAddress {
+				name = "Control Hub"
+			}
+		}
+
+		m := Module{Tag: "LynxModule", Name: name, Address: address, HasAddress: true}
+		for _, group := range []struct {
+			flavor  Flavor
+			devices map[int]DeviceTemplate
+		}{
+			{Motor, hub.Motors}, {Servo, hub.Servos}, {Analog, hub.Analog},
+			{Digital, hub.Digital}, {PWM, hub.PWM},
+		} {
+			for _, port := range sortedPorts(group.devices) {
+				d, err := templateDevice(group.devices[port], group.flavor)
+				if err != nil {
+					return nil, fmt.Errorf("%s, %s port %d: %w", name, group.flavor, port, err)
+				}
+				if port < 0 || port >= group.flavor.Ports() {
+					return nil, fmt.Errorf("%s, %s port %d: a hub has %s ports 0 to %d",
+						name, group.flavor, port, group.flavor, group.flavor.Ports()-1)
+				}
+				d.Port, d.HasPort = port, true
+				m.Devices = append(m.Devices, d)
+			}
+		}
+		for _, bus := range sortedPorts(hub.I2C) {
+			if bus < 0 || bus >= Buses {
+				return nil, fmt.Errorf("%s, I2C bus %d: a hub has buses 0 to %d", name, bus, Buses-1)
+			}
+			for _, port := range sortedPorts(hub.I2C[bus]) {
+				d, err := templateDevice(hub.I2C[bus][port], I2C)
+				if err != nil {
+					return nil, fmt.Errorf("%s, I2C bus %d port %d: %w", name, bus, port, err)
+				}
+				d.Port, d.HasPort = port, true
+				d.Bus, d.HasBus = bus, true
+				m.Devices = append(m.Devices, d)
+			}
+		}
+
+		portal.Modules = append(portal.Modules, m)
+	}
+
+	// The portal is the Control Hub's; an Expansion Hub on a phone's USB
+	// would be a portal of its own, with its serial number, which only
+	// the Driver Station's scan can fill in.
+	if !taken[ControlHubAddress] {
+		return nil, fmt.Errorf("the template has no Control Hub: give the first hub no address, or address %d",
+			ControlHubAddress)
+	}
+
+	return cfg, nil
+}
+
+func templateDevice(t DeviceTemplate, f Flavor) (Device, error) {
+	name := strings.TrimSpace(t.Name)
+	if name == "" {
+		return Device{}, fmt.Errorf("a device needs a name")
+	}
+
+	tag := t.Type
+	if tag == "" {
+		tag = genericTags[f]
+		if tag == "" {
+			return Device{}, fmt.Errorf("%q needs a type: there is no generic %s device", name, f)
+		}
+	}
+	// A type this table does not know is a team's own driver, and is let
+	// through as Validate lets it through.
+	if known := FlavorOf(tag); known != Unclassified && known != f {
+		return Device{}, fmt.Errorf("%q is a %s, which is a %s, not a %s", name, tag, known, f)
+	}
+
+	return Device{Tag: tag, Name: name}, nil
+}
+
+func sortedPorts[V any](m map[int]V) []int {
+	ports := make([]int, 0, len(m))
+	for port := range m {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// ExpectHubs makes a configuration's hubs the ones at addresses, the way the
+// robot reported them: a hub the configuration lacks is added as an empty
+// Expansion Hub. It returns the hubs the configuration has that were not
+// reported, which are kept, since a hub unplugged today is on the robot
+// tomorrow.
+func (c *Config) ExpectHubs(addresses []int) []Module {
+	if len(c.Portals) == 0 {
+		return nil
+	}
+	portal := &c.Portals[0]
+
+	reported := map[int]bool{ControlHubAddress: true}
+	for _, a := range addresses {
+		reported[a] = true
+	}
+
+	var missing []Module
+	have := map[int]bool{}
+	for _, m := range portal.Modules {
+		have[m.Address] = true
+		if !reported[m.Address] {
+			missing = append(missing, m)
+		}
+	}
+
+	for _, a := range addresses {
+		if have[a] {
+			continue
+		}
+		have[a] = true
+		portal.Modules = append(portal.Modules, Module{
+			Tag:        "LynxModule",
+			Name:       fmt.Sprintf("Expansion Hub %d", a),
+			Address:    a,
+			HasAddress: true,
+		})
+	}
+	return missing
+}
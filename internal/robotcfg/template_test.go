@@ -0,0 +1,148 @@
+package robotcfg
+
+import (
+	"strings"
+	"testing"
+)
+
+const robotTemplate = `hubs:
+  - motors:
+      0: leftFront
+      1: {name: rightFront, type: goBILDA5202SeriesMotor}
+    servos: {0: claw}
+    i2c:
+      0: {0: {name: imu, type: ControlHubImuBHI260AP}}
+  - address: 2
+    motors: {0: lift}
+  - digital: {3: {name: limit, type: RevTouchSensor}}
+`
+
+func TestATemplateBecomesAConfiguration(t *testing.T) {
+	cfg, err := FromTemplate([]byte(robotTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// What is written has to be what the robot controller reads, so it goes
+	// back through the parser and the checks rather than being trusted.
+	written := Write(cfg)
+	back, err := Parse(written)
+	if err != nil {
+		t.Fatalf("the configuration written does not parse: %v\n%s", err, written)
+	}
+	if issues := Validate(back); len(issues) > 0 {
+		t.Errorf("the configuration written has issues: %v\n%s", issues, written)
+	}
+
+	for _, want := range []string{
+		`<LynxUsbDevice name="Control Hub Portal" serialNumber="(embedded)" parentModuleAddress="173">`,
+		`<LynxModule name="Control Hub" port="173">`,
+		`<Motor name="leftFront" port="0" />`,
+		`<goBILDA5202SeriesMotor name="rightFront" port="1" />`,
+		`<Servo name="claw" port="0" />`,
+		`<ControlHubImuBHI260AP name="imu" port="0" bus="0" />`,
+		`<LynxModule name="Expansion Hub 2" port="2">`,
+		`<Motor name="lift" port="0" />`,
+		// The third hub had no address and 1 was free.
+		`<LynxModule name="Expansion Hub 1" port="1">`,
+		`<RevTouchSensor name="limit" port="3" />`,
+	} {
+		if !strings.Contains(string(written), want) {
+			t.Errorf("missing %s in:\n%s", want, written)
+		}
+	}
+}
+
+func TestTemplateMistakesAreCaught(t *testing.T) {
+	for _, tc := range []struct {
+		template, want string
+	}{
+		{"hubs: []", "no hubs"},
+		{"hubs:\n  - motors: {4: arm}", "motor port 4"},
+		{"hubs:\n  - servos: {0: {name: arm, type: goBILDA5202SeriesMotor}}", "which is a motor, not a servo"},
+		{"hubs:\n  - i2c: {0: {0: imu}}", "needs a type"},
+		{"hubs:\n  - i2c: {4: {0: {name: imu, type: LynxEmbeddedIMU}}}", "I2C bus 4"},
+		{"hubs:\n  - motors: {0: {type: Motor}}", "needs a name"},
+		{"hubs:\n  - {}\n  - address: 173", "two hubs at address 173"},
+		{"hubs:\n  - address: 2", "no Control Hub"},
+		{"hubs:\n  - motor: {0: arm}", "field motor not found"},
+	} {
+		_, err := FromTemplate([]byte(tc.template))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: got %v, want an error about %q", tc.template, err, tc.want)
+		}
+	}
+}
+
+// A team's own device type is theirs to know about.
+func TestAnUnknownTypeIsLetThrough(t *testing.T) {
+	cfg, err := FromTemplate([]byte("hubs:\n  - i2c: {1: {0: {name: lidar, type: TeamLidar}}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cfg.Devices()[0]; d.Tag != "TeamLidar" || d.Bus != 1 || !d.HasBus {
+		t.Errorf("got %+v", d)
+	}
+}
+
+// These lines are in the form discoveryStart and discoveredHub match. They are
+// not copied from a robot: no captured robotControllerLog was to hand when
+// this was written, and one from a real hub should replace them. Until then
+// the format check below is what keeps wording these patterns miss from
+// reading as a robot with no Expansion Hubs.
+func TestTheLatestDiscoveryIsTheOneRead(t *testing.T) {
+	log := `10-14 09:00:01.000 V/LynxUsb: lynx discovery beginning...
+10-14 09:00:01.200 V/LynxUsb: discovered lynx module: addr=173 parent=true
+10-14 09:00:01.300 V/LynxUsb: discovered lynx module: addr=2 parent=false
+10-14 09:00:01.400 V/LynxUsb: discovered lynx module: addr=3 parent=false
+10-15 16:30:00.000 V/LynxUsb: lynx discovery beginning...
+10-15 16:30:00.200 V/LynxUsb: discovered lynx module: addr=173 parent=true
+10-15 16:30:00.300 V/LynxUsb: discovered lynx module: addr=2 parent=false
+`
+	got, err := parseDiscovery(log)
+	if err != nil || len(got) != 1 || got[0] != 2 {
+		t.Errorf("got %v, %v, want [2]: the hub at 3 was unplugged since", got, err)
+	}
+
+	if _, err := parseDiscovery("10-15 16:30:00.000 I/RobotCore: nothing here\n"); err == nil {
+		t.Error("a log with no discovery reported one")
+	}
+	if got, err := parseDiscovery("V/LynxUsb: lynx discovery beginning...\n" +
+		"V/LynxUsb: discovered lynx module: addr=173 parent=true\n"); err != nil || len(got) != 0 {
+		t.Errorf("a discovery that found only the Control Hub: %v, %v", got, err)
+	}
+}
+
+// A discovery always finds the Control Hub's own module. One that names no
+// module at all is wording pusher does not know, not a robot without hubs.
+func TestADiscoveryThatNamesNoModuleIsNotRead(t *testing.T) {
+	log := "10-14 09:00:01.000 V/LynxUsb: lynx discovery beginning...\n" +
+		"10-14 09:00:01.200 V/LynxUsb: found module #2 (parent=false)\n"
+
+	if _, err := parseDiscovery(log); err == nil || !strings.Contains(err.Error(), "log format not recognised") {
+		t.Errorf("got %v, want the format named as the problem", err)
+	}
+}
+
+func TestProbedHubsAreLaidOut(t *testing.T) {
+	cfg, err := FromTemplate([]byte("hubs:\n  - {}\n  - address: 3\n    motors: {0: lift}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing := cfg.ExpectHubs([]int{2})
+	if len(missing) != 1 || missing[0].Address != 3 {
+		t.Errorf("missing = %+v, want the hub at 3", missing)
+	}
+
+	var addresses []int
+	for _, m := range cfg.Portals[0].Modules {
+		addresses = append(addresses, m.Address)
+	}
+	if len(addresses) != 3 || addresses[2] != 2 {
+		t.Errorf("hubs at %v, want 173, 3 and the probed 2", addresses)
+	}
+	if issues := Validate(cfg); issues.Errors() {
+		t.Errorf("issues: %v", issues)
+	}
+}
@@ -31,13 +31,19 @@ func Write(cfg *Config) []byte {
 
 	b.WriteString("</" + RootTag + ">")
 
+	// Lines are written ending in \n and changed over at the end. The
+	// trailer is as it was read, and already has its own.
+	out := b.String()
+	if cfg.Newline != "" && cfg.Newline != "\n" {
+		out = strings.ReplaceAll(out, "\n", cfg.Newline)
+	}
+
 	trailer := cfg.Trailer
 	if trailer == "" {
 		trailer = "\n"
 	}
-	b.WriteString(trailer)
 
-	return []byte(b.String())
+	return []byte(out + trailer)
 }
 
 func rootAttrs(cfg *Config) []Attr {
@@ -109,7 +115,19 @@ func escapeAttr(value string) string {
 	).Replace(value)
 }
 
+// The attributes each element's fields stand for, in the order they are added
+// to an element that did not already have them.
+var (
+	deviceOrder = []string{"name", "port", "bus"}
+	moduleOrder = []string{"name", "port"}
+	portalOrder = []string{"name", "serialNumber", "parentModuleAddress"}
+)
+
 func deviceAttrs(d Device) []Attr {
+	return merge(d.Attrs, deviceValues(d), deviceOrder)
+}
+
+func deviceValues(d Device) map[string]string {
 	known := map[string]string{}
 	if d.Name != "" || has(d.Attrs, "name") {
 		known["name"] = d.Name
@@ -120,20 +138,26 @@ func deviceAttrs(d Device) []Attr {
 	if d.HasBus {
 		known["bus"] = strconv.Itoa(d.Bus)
 	}
-
-	return merge(d.Attrs, known, []string{"name", "port", "bus"})
+	return known
 }
 
 func moduleAttrs(m Module) []Attr {
+	return merge(m.Attrs, moduleValues(m), moduleOrder)
+}
+
+func moduleValues(m Module) map[string]string {
 	known := map[string]string{"name": m.Name}
 	if m.HasAddress {
 		known["port"] = strconv.Itoa(m.Address)
 	}
-
-	return merge(m.Attrs, known, []string{"name", "port"})
+	return known
 }
 
 func portalAttrs(p Portal) []Attr {
+	return merge(p.Attrs, portalValues(p), portalOrder)
+}
+
+func portalValues(p Portal) map[string]string {
 	known := map[string]string{"name": p.Name}
 	if p.Serial != "" || has(p.Attrs, "serialNumber") {
 		known["serialNumber"] = p.Serial
@@ -141,8 +165,7 @@ func portalAttrs(p Portal) []Attr {
 	if p.HasParent {
 		known["parentModuleAddress"] = strconv.Itoa(p.ParentAddress)
 	}
-
-	return merge(p.Attrs, known, []string{"name", "serialNumber", "parentModuleAddress"})
+	return known
 }
 
 func merge(original []Attr, values map[string]string, order []string) []Attr {
@@ -0,0 +1,500 @@
+package robotcfg
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The XML is the Driver Station's, and it is poor to review: a rename is a
+// line of angle brackets, and a device moved to another hub is a line gone
+// here and a line come there. The same configuration as YAML reads as what
+// is plugged in where:
+//
+//	portals:
+//	  - type: LynxUsbDevice
+//	    name: Control Hub Portal
+//	    serial: (embedded)
+//	    parent: 173
+//	    modules:
+//	      - type: LynxModule
+//	        name: Control Hub
+//	        address: 173
+//	        devices:
+//	          - {port: 0, name: fr, type: goBILDA5202SeriesMotor}
+//	          - {bus: 0, port: 0, name: imu, type: ControlHubImuBHI260AP}
+//
+// Unlike a template, it is not a shorthand. It holds everything Write needs
+// to give back the file it came from byte for byte: attributes pusher does
+// not model, in attrs, the order they were written in, when it is not the
+// usual one, and how the file itself was laid out, under xml, when it is not
+// the way the Driver Station lays it out. Those are rare, so what a person
+// edits is the short form.
+
+// YAMLExt is the extension of a configuration kept as YAML.
+const YAMLExt = ".yaml"
+
+const yamlHeader = `A hardware configuration, kept as YAML by pusher. It is turned back into
+the Driver Station's XML, byte for byte, whenever it is read; comments in
+it are not kept when pusher rewrites it.`
+
+type yamlConfig struct {
+	Portals []yamlPortal `yaml:"portals"`
+	XML     *yamlLayout  `yaml:"xml,omitempty"`
+}
+
+// yamlLayout is how the file is laid out, where it is not how New lays it
+// out.
+type yamlLayout struct {
+	Declaration *string  `yaml:"declaration,omitempty"`
+	Indent      *string  `yaml:"indent,omitempty"`
+	Newline     *string  `yaml:"newline,omitempty"`
+	Trailer     *string  `yaml:"trailer,omitempty"`
+	Root        attrList `yaml:"root,omitempty"`
+}
+
+type yamlPortal struct {
+	Type        string       `yaml:"type"`
+	Name        string       `yaml:"name"`
+	Serial      *string      `yaml:"serial,omitempty"`
+	Parent      *int         `yaml:"parent,omitempty"`
+	Attrs       attrList     `yaml:"attrs,omitempty"`
+	Order       attrList     `yaml:"order,omitempty"`
+	SelfClosing bool         `yaml:"selfClosing,omitempty"`
+	Devices     []yamlDevice `yaml:"devices,omitempty"`
+	Modules     []yamlModule `yaml:"modules,omitempty"`
+}
+
+type yamlModule struct {
+	Type        string       `yaml:"type"`
+	Name        string       `yaml:"name"`
+	Address     *int         `yaml:"address,omitempty"`
+	Attrs       attrList     `yaml:"attrs,omitempty"`
+	Order       attrList     `yaml:"order,omitempty"`
+	SelfClosing bool         `yaml:"selfClosing,omitempty"`
+	Devices     []yamlDevice `yaml:"devices,omitempty"`
+}
+
+type yamlDevice struct {
+	Bus   *int     `yaml:"bus,omitempty"`
+	Port  *int     `yaml:"port,omitempty"`
+	Name  *string  `yaml:"name,omitempty"`
+	Type  string   `yaml:"type"`
+	Attrs attrList `yaml:"attrs,omitempty"`
+	Order attrList `yaml:"order,omitempty"`
+}
+
+// MarshalYAML puts a device on one line, so a diff of a device is a line.
+func (d yamlDevice) MarshalYAML() (interface{}, error) {
+	type plain yamlDevice
+	return flow(plain(d))
+}
+
+// attrList is attributes as name=value, in order. A list rather than a map,
+// since the SDK's Ethernet writer gives an element the same attribute twice.
+type attrList []string
+
+// MarshalYAML keeps a list of attributes on one line.
+func (l attrList) MarshalYAML() (interface{}, error) {
+	return flow([]string(l))
+}
+
+func flow(v interface{}) (*yaml.Node, error) {
+	var n yaml.Node
+	if err := n.Encode(v); err != nil {
+		return nil, err
+	}
+	n.Style = yaml.FlowStyle
+	return &n, nil
+}
+
+// ToYAML writes a configuration as YAML.
+func ToYAML(cfg *Config) []byte {
+	out := yamlConfig{XML: layoutOf(cfg)}
+
+	for _, p := range cfg.Portals {
+		yp := yamlPortal{
+			Type:        p.Tag,
+			Name:        p.Name,
+			SelfClosing: p.SelfClosing && len(p.Devices) == 0 && len(p.Modules) == 0,
+		}
+		values := portalValues(p)
+		if serial, ok := values["serialNumber"]; ok {
+			yp.Serial = &serial
+		}
+		if p.HasParent {
+			yp.Parent = intPointer(p.ParentAddress)
+		}
+		yp.Attrs, yp.Order = unmodelled(portalAttrs(p), values, portalOrder)
+
+		for _, d := range p.Devices {
+			yp.Devices = append(yp.Devices, yamlDeviceOf(d))
+		}
+		for _, m := range p.Modules {
+			ym := yamlModule{
+				Type:        m.Tag,
+				Name:        m.Name,
+				SelfClosing: m.SelfClosing && len(m.Devices) == 0,
+			}
+			if m.HasAddress {
+				ym.Address = intPointer(m.Address)
+			}
+			ym.Attrs, ym.Order = unmodelled(moduleAttrs(m), moduleValues(m), moduleOrder)
+			for _, d := range m.Devices {
+				ym.Devices = append(ym.Devices, yamlDeviceOf(d))
+			}
+			yp.Modules = append(yp.Modules, ym)
+		}
+
+		out.Portals = append(out.Portals, yp)
+	}
+
+	var doc yaml.Node
+	if err := doc.Encode(out); err != nil {
+		// Every value is a string, an int or a list of them.
+		panic(err)
+	}
+	doc.HeadComment = yamlHeader
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		panic(err)
+	}
+	_ = enc.Close()
+	return b.Bytes()
+}
+
+func yamlDeviceOf(d Device) yamlDevice {
+	yd := yamlDevice{Type: d.Tag}
+	values := deviceValues(d)
+	if name, ok := values["name"]; ok {
+		yd.Name = &name
+	}
+	if d.HasPort {
+		yd.Port = intPointer(d.Port)
+	}
+	if d.HasBus {
+		yd.Bus = intPointer(d.Bus)
+	}
+	yd.Attrs, yd.Order = unmodelled(deviceAttrs(d), values, deviceOrder)
+	return yd
+}
+
+func intPointer(v int) *int {
+	return &v
+}
+
+// unmodelled splits what Write gives an element into the attributes its
+// fields do not stand for, in order, and the order of all of them when it is
+// not the fields' attributes first, in the usual order, then the rest.
+func unmodelled(written []Attr, values map[string]string, standard []string) (extras, order attrList) {
+	claimed := map[string]bool{}
+	var names []string
+	for _, a := range written {
+		names = append(names, a.Name)
+		if _, modelled := values[a.Name]; modelled && !claimed[a.Name] {
+			claimed[a.Name] = true
+			continue
+		}
+		extras = append(extras, a.Name+"="+a.Value)
+	}
+
+	var usual []string
+	for _, name := range standard {
+		if claimed[name] {
+			usual = append(usual, name)
+		}
+	}
+	for _, e := range extras {
+		name, _, _ := strings.Cut(e, "=")
+		usual = append(usual, name)
+	}
+	if strings.Join(usual, " ") != strings.Join(names, " ") {
+		order = names
+	}
+	return extras, order
+}
+
+func layoutOf(cfg *Config) *yamlLayout {
+	usual := New()
+	var l yamlLayout
+	set := false
+
+	if cfg.Declaration != usual.Declaration {
+		l.Declaration, set = &cfg.Declaration, true
+	}
+	if indent := orDefault(cfg.Indent, "    "); indent != usual.Indent {
+		l.Indent, set = &indent, true
+	}
+	if newline := orDefault(cfg.Newline, "\n"); newline != "\n" {
+		l.Newline, set = &newline, true
+	}
+	if trailer := orDefault(cfg.Trailer, "\n"); trailer != usual.Trailer {
+		l.Trailer, set = &trailer, true
+	}
+	if root := rootAttrs(cfg); len(root) != 1 || root[0] != usual.RootAttrs[0] {
+		for _, a := range root {
+			l.Root = append(l.Root, a.Name+"="+a.Value)
+		}
+		set = true
+	}
+
+	if !set {
+		return nil
+	}
+	return &l
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// FromYAML reads a configuration ToYAML wrote, or a person edited. What it
+// returns writes the XML the YAML came from.
+func FromYAML(data []byte) (*Config, error) {
+	var in yamlConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&in); err != nil {
+		return nil, fmt.Errorf("cannot read the configuration: %w", err)
+	}
+
+	cfg := New()
+	cfg.Portals = nil
+	if l := in.XML; l != nil {
+		if l.Declaration != nil {
+			cfg.Declaration = *l.Declaration
+		}
+		if l.Indent != nil {
+			cfg.Indent = *l.Indent
+		}
+		if l.Newline != nil {
+			cfg.Newline = *l.Newline
+		}
+		if l.Trailer != nil {
+			cfg.Trailer = *l.Trailer
+		}
+		if l.Root != nil {
+			root, err := parseAttrList(l.Root)
+			if err != nil {
+				return nil, fmt.Errorf("xml, root: %w", err)
+			}
+			cfg.RootAttrs = root
+		}
+	}
+
+	for i, yp := range in.Portals {
+		where := fmt.Sprintf("portal %d (%s)", i+1, yp.Name)
+		if yp.Type == "" {
+			return nil, fmt.Errorf("%s has no type", where)
+		}
+
+		p := Portal{Tag: yp.Type, Name: yp.Name, SelfClosing: yp.SelfClosing}
+		if yp.Serial != nil {
+			p.Serial = *yp.Serial
+		}
+		if yp.Parent != nil {
+			p.ParentAddress, p.HasParent = *yp.Parent, true
+		}
+		values := portalValues(p)
+		// As with a device's name, a serial number given as empty is still
+		// written.
+		if yp.Serial != nil {
+			values["serialNumber"] = p.Serial
+		}
+		attrs, err := rebuildAttrs(values, portalOrder, yp.Attrs, yp.Order)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", where, err)
+		}
+		p.Attrs = attrs
+
+		if p.Devices, err = devicesFromYAML(yp.Devices, where); err != nil {
+			return nil, err
+		}
+
+		for j, ym := range yp.Modules {
+			where := fmt.Sprintf("%s, module %d (%s)", where, j+1, ym.Name)
+			if ym.Type == "" {
+				return nil, fmt.Errorf("%s has no type", where)
+			}
+
+			m := Module{Tag: ym.Type, Name: ym.Name, SelfClosing: ym.SelfClosing}
+			if ym.Address != nil {
+				m.Address, m.HasAddress = *ym.Address, true
+			}
+			if m.Attrs, err = rebuildAttrs(moduleValues(m), moduleOrder, ym.Attrs, ym.Order); err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+			if m.Devices, err = devicesFromYAML(ym.Devices, where); err != nil {
+				return nil, err
+			}
+			p.Modules = append(p.Modules, m)
+		}
+
+		cfg.Portals = append(cfg.Portals, p)
+	}
+
+	cfg.Raw = Write(cfg)
+	return cfg, nil
+}
+
+func devicesFromYAML(in []yamlDevice, where string) ([]Device, error) {
+	var devices []Device
+	for k, yd := range in {
+		if yd.Type == "" {
+			return nil, fmt.Errorf("%s, device %d has no type", where, k+1)
+		}
+
+		d := Device{Tag: yd.Type}
+		if yd.Name != nil {
+			d.Name = *yd.Name
+		}
+		if yd.Port != nil {
+			d.Port, d.HasPort = *yd.Port, true
+		}
+		if yd.Bus != nil {
+			d.Bus, d.HasBus = *yd.Bus, true
+		}
+
+		values := deviceValues(d)
+		// A name given as empty is an attribute all the same, as the
+		// Driver Station writes name="" on a port it left empty.
+		if yd.Name != nil {
+			values["name"] = d.Name
+		}
+		attrs, err := rebuildAttrs(values, deviceOrder, yd.Attrs, yd.Order)
+		if err != nil {
+			return nil, fmt.Errorf("%s, device %d: %w", where, k+1, err)
+		}
+		d.Attrs = attrs
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// rebuildAttrs puts an element's attributes back in the order they were
+// written: the fields' first, then the rest, unless order says otherwise.
+func rebuildAttrs(values map[string]string, standard []string, extras, order attrList) ([]Attr, error) {
+	rest, err := parseAttrList(extras)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := map[string]bool{}
+	var out []Attr
+	take := func(name string) bool {
+		if value, modelled := values[name]; modelled && !claimed[name] {
+			claimed[name] = true
+			out = append(out, Attr{Name: name, Value: value})
+			return true
+		}
+		for i, a := range rest {
+			if a.Name == name {
+				out = append(out, a)
+				rest = append(rest[:i], rest[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range order {
+		if !take(name) {
+			return nil, fmt.Errorf("order names %s, which the element does not have", name)
+		}
+	}
+	for _, name := range standard {
+		take(name)
+	}
+	return append(out, rest...), nil
+}
+
+func parseAttrList(list attrList) ([]Attr, error) {
+	out := make([]Attr, 0, len(list))
+	for _, entry := range list {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" || strings.ContainsAny(name, " \t\"'<>&") {
+			return nil, fmt.Errorf("attribute %s is not name=value", strconv.Quote(entry))
+		}
+		out = append(out, Attr{Name: name, Value: value})
+	}
+	return out, nil
+}
+
+// yamlLines maps each line of the XML a YAML configuration writes that holds
+// an element to the line of the YAML that element came from. Write puts one
+// element on a line and ToYAML one to a list item, in the same order, so the
+// two walks pair up.
+func yamlLines(data []byte) (map[int]int, error) {
+	cfg, err := FromYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	written, err := Parse(Write(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	var yamlAt []int
+	items := func(n *yaml.Node, key string) []*yaml.Node {
+		if n == nil || n.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == key {
+				return n.Content[i+1].Content
+			}
+		}
+		return nil
+	}
+	for _, p := range items(doc.Content[0], "portals") {
+		yamlAt = append(yamlAt, p.Line)
+		for _, d := range items(p, "devices") {
+			yamlAt = append(yamlAt, d.Line)
+		}
+		for _, m := range items(p, "modules") {
+			yamlAt = append(yamlAt, m.Line)
+			for _, d := range items(m, "devices") {
+				yamlAt = append(yamlAt, d.Line)
+			}
+		}
+	}
+
+	var xmlAt []int
+	for _, p := range written.Portals {
+		xmlAt = append(xmlAt, p.Line)
+		for _, d := range p.Devices {
+			xmlAt = append(xmlAt, d.Line)
+		}
+		for _, m := range p.Modules {
+			xmlAt = append(xmlAt, m.Line)
+			for _, d := range m.Devices {
+				xmlAt = append(xmlAt, d.Line)
+			}
+		}
+	}
+
+	if len(xmlAt) != len(yamlAt) {
+		return nil, fmt.Errorf("the YAML and its XML do not line up")
+	}
+	lines := make(map[int]int, len(xmlAt))
+	for i, line := range xmlAt {
+		lines[line] = yamlAt[i]
+	}
+	return lines, nil
+}
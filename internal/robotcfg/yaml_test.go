@@ -0,0 +1,267 @@
+package robotcfg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// What the Driver Station writes, and what else has turned up in real files,
+// has to come back out of the YAML exactly as it went in.
+func TestYAMLGivesBackTheSameXML(t *testing.T) {
+	cases := []struct{ name, xml string }{
+		{"a configuration the Driver Station wrote", realConfig},
+		{"two-space indentation and no declaration", `<Robot type="FirstInspires-FTC">
+  <LynxUsbDevice name="portal" serialNumber="(embedded)" parentModuleAddress="173">
+    <LynxModule name="Control Hub" port="173" />
+  </LynxUsbDevice>
+</Robot>`},
+		{"attributes in an unusual order", `<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>
+<Robot type="FirstInspires-FTC" version="2">
+    <LynxUsbDevice serialNumber="DQ2F3X" name="Expansion Hub Portal 1" parentModuleAddress="2">
+        <LynxModule port="2" name="Expansion Hub 2" extra="yes">
+            <Motor port="0" name="arm" />
+            <Servo name="" port="1" />
+            <Motor name="NO$DEVICE$ATTACHED" port="2" note="a &quot;quoted&quot; &amp; escaped value" />
+            <DigitalDevice name="limit" port="x" />
+        </LynxModule>
+        <LynxModule name="Expansion Hub 3" port="3">
+        </LynxModule>
+    </LynxUsbDevice>
+    <Webcam name="Webcam 1" serialNumber="" />
+</Robot>
+`},
+	}
+
+	data, err := os.ReadFile("testdata/real.xml")
+	if err == nil {
+		cases = append(cases, struct{ name, xml string }{"testdata/real.xml", string(data)})
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := Write(parse(t, tc.xml))
+
+			y := ToYAML(parse(t, tc.xml))
+			cfg, err := FromYAML(y)
+			if err != nil {
+				t.Fatalf("FromYAML: %v\n%s", err, y)
+			}
+			if got := Write(cfg); string(got) != string(want) {
+				t.Errorf("the XML changed on the way through YAML.\n--- want ---\n%s\n--- got ---\n%s\n--- yaml ---\n%s", want, got, y)
+			}
+
+			// And writing the YAML again must not churn it.
+			if again := ToYAML(cfg); string(again) != string(y) {
+				t.Errorf("the YAML changed on a second pass.\n--- first ---\n%s\n--- second ---\n%s", y, again)
+			}
+		})
+	}
+}
+
+// A Windows checkout with core.autocrlf turns every line ending into CRLF, and
+// those have to come back too, not only the last one.
+func TestYAMLKeepsWindowsLineEndings(t *testing.T) {
+	crlf := strings.ReplaceAll(realConfig, "\n", "\r\n")
+
+	y := ToYAML(parse(t, crlf))
+	if !strings.Contains(string(y), "newline: \"\\r\\n\"") {
+		t.Errorf("the line ending is not recorded:\n%s", y)
+	}
+	cfg, err := FromYAML(y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(Write(cfg)); got != crlf {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestTheUsualFileIsTheShortForm(t *testing.T) {
+	y := string(ToYAML(parse(t, realConfig)))
+
+	for _, want := range []string{
+		"  - type: LynxUsbDevice\n    name: Control Hub Portal\n    serial: (embedded)\n    parent: 173\n",
+		"          - {port: 0, name: transfer, type: goBILDA5202SeriesMotor}\n",
+		"          - {bus: 2, port: 0, name: pinpoint, type: goBILDAPinpoint}\n",
+		// The Ethernet writer's second name is kept, and nothing else needs
+		// to be.
+		"    attrs: [name=limelight, port=-1, ipAddress=172.29.0.1]\n",
+	} {
+		if !strings.Contains(y, want) {
+			t.Errorf("missing %q in:\n%s", want, y)
+		}
+	}
+	for _, unwanted := range []string{"order:", "xml:", "declaration"} {
+		if strings.Contains(y, unwanted) {
+			t.Errorf("%q in a file the Driver Station wrote:\n%s", unwanted, y)
+		}
+	}
+}
+
+func TestAnEditToTheYAMLIsAOneLineChange(t *testing.T) {
+	y := strings.Replace(string(ToYAML(parse(t, realConfig))), "name: intake,", "name: roller,", 1)
+	cfg, err := FromYAML([]byte(y))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Split(string(Write(cfg)), "\n")
+	want := strings.Split(realConfig, "\n")
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(got), len(want))
+	}
+	changed := 0
+	for i := range want {
+		if got[i] != want[i] {
+			changed++
+			if got[i] != `            <goBILDA5202SeriesMotor name="roller" port="1" />` {
+				t.Errorf("line %d: %s", i+1, got[i])
+			}
+		}
+	}
+	if changed != 1 {
+		t.Errorf("%d lines changed", changed)
+	}
+}
+
+func TestYAMLMistakesAreCaught(t *testing.T) {
+	for _, tc := range []struct {
+		yaml, want string
+	}{
+		{"portals:\n  - name: x", "portal 1 (x) has no type"},
+		{"portals:\n  - type: Webcam\n    name: cam\n    attrs: [autoOpen]", `"autoOpen" is not name=value`},
+		{"portals:\n  - type: LynxUsbDevice\n    name: p\n    modules:\n      - type: LynxModule\n        name: h\n        devices:\n          - {prot: 0, name: x, type: Motor}",
+			"field prot not found"},
+		{"portals:\n  - type: LynxUsbDevice\n    name: p\n    modules:\n      - type: LynxModule\n        name: h\n        devices:\n          - {port: 0, name: x}",
+			"portal 1 (p), module 1 (h), device 1 has no type"},
+		{"portals:\n  - type: Webcam\n    name: cam\n    order: [name, autoOpen]", "order names autoOpen"},
+	} {
+		_, err := FromYAML([]byte(tc.yaml))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: got %v, want an error about %q", tc.yaml, err, tc.want)
+		}
+	}
+}
+
+func TestAStoreCanKeepAConfigurationAsYAML(t *testing.T) {
+	s := NewStore(t.TempDir())
+	if err := s.Write("comp", []byte(realConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.KeepAsYAML("comp"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsYAML("comp") || filepath.Ext(s.Path("comp")) != YAMLExt {
+		t.Fatalf("comp is at %s", s.Path("comp"))
+	}
+	if _, err := os.Stat(filepath.Join(s.Dir, "comp"+Ext)); !os.IsNotExist(err) {
+		t.Error("the XML was left beside the YAML")
+	}
+
+	back, err := s.Read("comp")
+	if err != nil || string(back) != realConfig {
+		t.Fatalf("reading YAML back gave a different file: %v\n%s", err, back)
+	}
+
+	renamed := strings.Replace(realConfig, `name="intake"`, `name="roller"`, 1)
+	if err := s.Write("comp", []byte(renamed)); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := os.ReadFile(s.Path("comp"))
+	if err != nil || !strings.Contains(string(blob), "name: roller,") {
+		t.Errorf("writing did not go to the YAML: %v\n%s", err, blob)
+	}
+
+	names, err := s.Names()
+	if err != nil || len(names) != 1 || names[0] != "comp" {
+		t.Errorf("names = %v, %v", names, err)
+	}
+
+	if err := s.KeepAsXML("comp"); err != nil {
+		t.Fatal(err)
+	}
+	if back, err := os.ReadFile(filepath.Join(s.Dir, "comp"+Ext)); err != nil || string(back) != renamed {
+		t.Errorf("the XML written back is not the one last saved: %v", err)
+	}
+	if s.IsYAML("comp") {
+		t.Error("the YAML was left beside the XML")
+	}
+}
+
+// Deleting the XML is only safe when the YAML gives all of it back. A comment
+// is something it cannot, and the XML stays.
+func TestAStoreKeepsAsYAMLOnlyWhatComesBackTheSame(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	crlf := strings.ReplaceAll(realConfig, "\n", "\r\n")
+	if err := s.Write("windows", []byte(crlf)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.KeepAsYAML("windows"); err != nil {
+		t.Fatal(err)
+	}
+	if back, err := s.Read("windows"); err != nil || string(back) != crlf {
+		t.Errorf("the CRLF file did not come back: %v", err)
+	}
+
+	commented := strings.Replace(realConfig, "    <LynxUsbDevice", "    <!-- the drive base -->\n    <LynxUsbDevice", 1)
+	if err := s.Write("commented", []byte(commented)); err != nil {
+		t.Fatal(err)
+	}
+	err := s.KeepAsYAML("commented")
+	if err == nil || !strings.Contains(err.Error(), "comments") {
+		t.Errorf("got %v, want the comments named", err)
+	}
+	if s.IsYAML("commented") {
+		t.Error("the configuration was kept as YAML anyway")
+	}
+	if back, err := os.ReadFile(filepath.Join(s.Dir, "commented"+Ext)); err != nil || string(back) != commented {
+		t.Errorf("the XML did not survive: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.Dir, "commented"+YAMLExt)); !os.IsNotExist(err) {
+		t.Error("a YAML was left beside the XML")
+	}
+}
+
+func TestAStoreReportsBrokenYAMLWithItsPath(t *testing.T) {
+	s := NewStore(t.TempDir())
+	if err := os.WriteFile(filepath.Join(s.Dir, "comp"+YAMLExt), []byte("portals: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read("comp"); err == nil || !strings.Contains(err.Error(), "comp.yaml") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestIssuesPointAtTheYAML(t *testing.T) {
+	y := []byte(`portals:
+  - type: LynxUsbDevice
+    name: Control Hub Portal
+    serial: (embedded)
+    parent: 173
+    modules:
+      - type: LynxModule
+        name: Control Hub
+        address: 173
+        devices:
+          - {port: 0, name: arm, type: Motor}
+
+          - {port: 1, name: arm, type: Motor}
+`)
+	cfg, err := FromYAML(y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues := Validate(parse(t, string(Write(cfg))))
+	if len(issues) != 1 {
+		t.Fatalf("got %v", issues)
+	}
+
+	got := LocateInYAML(y, issues)[0]
+	if got.Line != 13 || !strings.Contains(got.Msg, "also on line 11") {
+		t.Errorf("got %s, want it on line 13 and naming line 11", got)
+	}
+}
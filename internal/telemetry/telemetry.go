@@ -208,8 +208,12 @@ func send(id, version string) error {
 	return nil
 }
 
-// noticeOut is stdout everywhere but the tests, which have nothing to say.
-var noticeOut io.Writer = os.Stdout
+// noticeOut is stderr everywhere but the tests, which have nothing to say.
+//
+// Not stdout: the notice is printed before the command has read its flags, so
+// it cannot know whether stdout is somebody's terminal or a script reading
+// `--output json`, and it must never land in the second.
+var noticeOut io.Writer = os.Stderr
 
 func printNotice() {
 	fmt.Fprintln(noticeOut, "[*] pusher counts how many devices use it: a random ID it just made up,")
@@ -12,6 +12,8 @@ import (
 
 var deployItems = []string{
 	"Send only changed parts",
+	"Compress changed parts",
+	"Patch the installed APK",
 	"Skip install when unchanged",
 	"Stream the install",
 	"Store native libraries uncompressed",
@@ -23,6 +25,12 @@ var deployHelp = []string{
 	"Sends only the parts of the APK that changed since the last deploy.\n" +
 		"Big win over Wi-Fi, little to nothing over USB.",
 
+	"Compress the changed parts on the way, when the robot can unpack them.\n" +
+		"Less over Wi-Fi, a little work for the hub. Needs changed parts on.",
+
+	"Send only the bytes that differ from the APK the robot already has.\n" +
+		"Smallest transfer there is. Uses the APKs kept for `pusher rollback`.",
+
 	"If the robot already holds exactly this build, do nothing at all.\n" +
 		"Free. Only skips when the package has not been touched since.",
 
@@ -43,6 +51,8 @@ func (m *SettingsModel) deployLabel() string {
 	on := 0
 	for _, enabled := range []bool{
 		config.GetDeltaTransfer(),
+		config.GetCompressChunks(),
+		config.GetPatchTransfer(),
 		config.GetSkipUnchanged(),
 		config.GetStreamInstall(),
 		config.GetStoreLibs(),
@@ -53,7 +63,7 @@ func (m *SettingsModel) deployLabel() string {
 		}
 	}
 
-	return fmt.Sprintf("%d of 5 on", on)
+	return fmt.Sprintf("%d of 7 on", on)
 }
 
 func (m *SettingsModel) updateDeploy(key tea.KeyMsg) (tea.Model, tea.Cmd) {
@@ -79,14 +89,18 @@ func (m *SettingsModel) updateDeploy(key tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case 0:
 			m.setStatus(config.SetDeltaTransfer(!config.GetDeltaTransfer()), "Delta transfer updated")
 		case 1:
-			m.setStatus(config.SetSkipUnchanged(!config.GetSkipUnchanged()), "Skip-when-unchanged updated")
+			m.setStatus(config.SetCompressChunks(!config.GetCompressChunks()), "Chunk compression updated")
 		case 2:
-			m.setStatus(config.SetStreamInstall(!config.GetStreamInstall()), "Streaming install updated")
+			m.setStatus(config.SetPatchTransfer(!config.GetPatchTransfer()), "Patch transfer updated")
 		case 3:
-			m.toggleStoreLibs()
+			m.setStatus(config.SetSkipUnchanged(!config.GetSkipUnchanged()), "Skip-when-unchanged updated")
 		case 4:
-			m.setStatus(config.SetSplitInstall(!config.GetSplitInstall()), "Split install updated")
+			m.setStatus(config.SetStreamInstall(!config.GetStreamInstall()), "Streaming install updated")
 		case 5:
+			m.toggleStoreLibs()
+		case 6:
+			m.setStatus(config.SetSplitInstall(!config.GetSplitInstall()), "Split install updated")
+		case 7:
 			m.goTo(screenMain, 8)
 		}
 	}
@@ -135,6 +149,8 @@ func (m *SettingsModel) viewDeploy() string {
 
 	values := []string{
 		onOff(config.GetDeltaTransfer()),
+		onOff(config.GetCompressChunks()),
+		onOff(config.GetPatchTransfer()),
 		onOff(config.GetSkipUnchanged()),
 		onOff(config.GetStreamInstall()),
 		m.storeLibsLabel(),
@@ -312,6 +312,16 @@ func (m *devModel) run(deploy, reload bool) tea.Cmd {
 	serial, apk, splits, project := m.serial, m.apk, m.splits, m.project
 
 	work := func() tea.Msg {
+		// First, so that if the project changed since its last build, the
+		// APK inspected and deployed below is the one built now.
+		var build bench.Build
+		if deploy {
+			if wrapper, err := gradle.DetectWrapper(); err == nil {
+				post("timing builds")
+				build = bench.MeasureBuild(wrapper)
+			}
+		}
+
 		info, err := bench.Inspect(apk)
 		if err != nil {
 			return devDoneMsg{err: err}
@@ -336,13 +346,14 @@ func (m *devModel) run(deploy, reload bool) tea.Cmd {
 
 		settings := map[string]bool{
 			"delta":     config.GetDeltaTransfer(),
+			"compress":  config.GetCompressChunks(),
 			"skip":      config.GetSkipUnchanged(),
 			"stream":    config.GetStreamInstall(),
 			"storeLibs": config.GetStoreLibs(),
 			"split":     config.GetSplitInstall(),
 		}
 
-		body := bench.Report(info, runs, floor, settings)
+		body := bench.Report(info, build, runs, floor, settings)
 
 		saved, err := bench.SaveReport(project, body)
 		if err != nil {
@@ -685,8 +696,8 @@ const extremeRuns = 5
 
 func extremeSummary(r extreme.BenchResult) string {
 	var b strings.Builder
-	for _, phase := range []extreme.Phase{r.Classpath, r.Compile, r.Deliver, r.Total} {
-		fmt.Fprintf(&b, "  %-22s %s\n", phase.Name, phase.Best().Round(time.Millisecond))
+	for _, phase := range []extreme.Phase{r.Classpath, r.Cold, r.Warm, r.Deliver, r.ColdTotal, r.WarmTotal} {
+		fmt.Fprintf(&b, "  %-28s %s\n", phase.Name, phase.Best().Round(time.Millisecond))
 	}
 	return b.String()
 }
@@ -419,6 +419,17 @@ func (m *SettingsModel) updateProfiles(key tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.setStatus(config.SetDefaultProfile(name), fmt.Sprintf("%q is now the default robot", name))
 			m.refreshProfiles()
 		}
+
+	case "f":
+		if len(m.profiles) > 0 {
+			name := m.profiles[m.cursor]
+			in, err := config.ToggleFleet(name)
+			message := fmt.Sprintf("%q left the fleet", name)
+			if in {
+				message = fmt.Sprintf("%q joined the fleet: every push deploys to it in turn", name)
+			}
+			m.setStatus(err, message)
+		}
 	}
 
 	return m, nil
@@ -852,7 +863,7 @@ func (m *SettingsModel) defaultProfileLabel() string {
 		return "none"
 	}
 	if profile, ok := m.cfg.Profiles[m.cfg.DefaultProfile]; ok {
-		return fmt.Sprintf("%s (%s)", m.cfg.DefaultProfile, profile.SSID)
+		return fmt.Sprintf("%s (%s)", m.cfg.DefaultProfile, profileNetwork(profile))
 	}
 	return m.cfg.DefaultProfile
 }
@@ -868,7 +879,7 @@ func (m *SettingsModel) viewProfiles() string {
 	head := b.String()
 
 	return m.fill(head,
-		"\n"+helpStyle.Render("  "+fit("enter set default · a add · d delete · esc back", textWidth(m.width)))+"\n",
+		"\n"+helpStyle.Render("  "+fit("enter set default · f fleet · a add · d delete · esc back", textWidth(m.width)))+"\n",
 		len(m.profiles), func(i int) string {
 			name := m.profiles[i]
 
@@ -880,11 +891,16 @@ func (m *SettingsModel) viewProfiles() string {
 			if m.cfg != nil && name == m.cfg.DefaultProfile {
 				marker = "*"
 			}
+			if m.cfg != nil && inFleet(m.cfg.Fleet, name) {
+				marker += "+"
+			} else {
+				marker += " "
+			}
 
 			ssid := ""
 			if m.cfg != nil {
 				if profile, ok := m.cfg.Profiles[name]; ok {
-					ssid = profile.SSID
+					ssid = profileNetwork(profile)
 				}
 			}
 
@@ -892,6 +908,27 @@ func (m *SettingsModel) viewProfiles() string {
 		})
 }
 
+// profileNetwork is what a profile is reached by: its Wi-Fi, or for a robot
+// paired over wireless debugging on the computer's own network, its address.
+func profileNetwork(profile *config.Profile) string {
+	if profile == nil {
+		return ""
+	}
+	if profile.SSID == "" && profile.Paired() {
+		return profile.Addr
+	}
+	return profile.SSID
+}
+
+func inFleet(fleet []string, name string) bool {
+	for _, member := range fleet {
+		if member == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *SettingsModel) viewAddProfile() string {
 	labels := map[addStep]string{
 		stepName:     "Profile name",
@@ -947,7 +984,7 @@ func (m *SettingsModel) viewHomeNetwork() string {
 
 func (m *SettingsModel) viewThreads() string {
 	var b strings.Builder
-	b.WriteString(helpStyle.Render("  Gradle worker threads") + "\n\n")
+	b.WriteString(helpStyle.Render("  "+fit("Gradle worker threads, and changed parts sent at once", textWidth(m.width))) + "\n\n")
 	b.WriteString(fmt.Sprintf("  Threads: %s\n", valueStyle.Render(m.input+"▌")))
 	b.WriteString("\n" + helpStyle.Render("  "+fit("enter save · esc cancel", textWidth(m.width))) + "\n")
 	return b.String()
@@ -0,0 +1,305 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andreibanu/pusher/internal/dash"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The view the dashboard's browser page gives, without a browser: each line
+// of telemetry in place, updating as packets arrive, with the recent history of
+// every number drawn beside it. Keys stay in the order they first appeared,
+// because an OpMode sends them in a map and sorting them would make lines jump
+// whenever one is added.
+
+// sparkLength is how many samples a sparkline holds.
+const sparkLength = 40
+
+// sparkBars are the heights a sample can be drawn at, lowest first.
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// logLines is how many addLine lines are shown.
+const logLines = 5
+
+type telemetryModel struct {
+	stream *dash.Stream
+	record *dash.Recorder
+	robot  string
+	path   string
+
+	keys    []string
+	values  map[string]string
+	history map[string][]float64
+	// latest holds the keys the last packet had. A key the OpMode stopped
+	// sending is shown dimmed rather than removed, so its line does not vanish
+	// between states.
+	latest map[string]bool
+	log    []string
+
+	status  *dash.Status
+	packets int
+
+	recordErr error
+	err       error
+
+	width  int
+	height int
+	quit   bool
+}
+
+// telemetryMsg carries what the stream read back to the view.
+type telemetryMsg struct {
+	update dash.Update
+	err    error
+}
+
+// RunTelemetry shows a stream until the person quits or the robot goes away.
+// record may be nil; when it is not, every packet is written to it as it
+// arrives.
+func RunTelemetry(stream *dash.Stream, record *dash.Recorder, robot, path string) error {
+	m := newTelemetryModel(stream, record, robot, path)
+
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	stream.Close()
+
+	if err != nil {
+		return err
+	}
+	if m.recordErr != nil {
+		return fmt.Errorf("the recording stopped: %w", m.recordErr)
+	}
+	return m.err
+}
+
+func newTelemetryModel(stream *dash.Stream, record *dash.Recorder, robot, path string) *telemetryModel {
+	return &telemetryModel{
+		stream:  stream,
+		record:  record,
+		robot:   robot,
+		path:    path,
+		values:  map[string]string{},
+		history: map[string][]float64{},
+		latest:  map[string]bool{},
+		width:   defaultWidth,
+		height:  defaultHeight,
+	}
+}
+
+func (m *telemetryModel) next() tea.Msg {
+	update, err := m.stream.Next()
+	return telemetryMsg{update: update, err: err}
+}
+
+// Init satisfies tea.Model.
+func (m *telemetryModel) Init() tea.Cmd { return m.next }
+
+// Update satisfies tea.Model.
+func (m *telemetryModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+
+	case telemetryMsg:
+		if msg.err != nil {
+			if !m.quit {
+				m.err = fmt.Errorf("lost the dashboard: %w", msg.err)
+			}
+			m.quit = true
+			return m, tea.Quit
+		}
+		m.apply(msg.update)
+		return m, m.next
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.quit = true
+			return m, tea.Quit
+		case "c":
+			m.history = map[string][]float64{}
+		}
+	}
+
+	return m, nil
+}
+
+// apply takes in one update.
+func (m *telemetryModel) apply(update dash.Update) {
+	if update.Status != nil {
+		m.status = update.Status
+	}
+
+	for _, p := range update.Packets {
+		m.packets++
+
+		if m.record != nil && m.recordErr == nil {
+			m.recordErr = m.record.Write(p)
+		}
+
+		m.latest = map[string]bool{}
+		for _, key := range orderedKeys(p.Data, m.values) {
+			value := p.Data[key]
+			if _, seen := m.values[key]; !seen {
+				m.keys = append(m.keys, key)
+			}
+			m.values[key] = value
+			m.latest[key] = true
+
+			// ParseFloat takes "NaN" and "Inf", which an OpMode dividing by
+			// zero does send, and neither has a height on the sparkline.
+			number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err == nil && !math.IsNaN(number) && !math.IsInf(number, 0) {
+				samples := append(m.history[key], number)
+				if len(samples) > sparkLength {
+					samples = samples[len(samples)-sparkLength:]
+				}
+				m.history[key] = samples
+			}
+		}
+
+		m.log = append(m.log, p.Log...)
+		if len(m.log) > logLines {
+			m.log = m.log[len(m.log)-logLines:]
+		}
+	}
+}
+
+// orderedKeys is a packet's keys, new ones sorted so that keys arriving
+// together are placed the same way every run.
+func orderedKeys(data map[string]string, seen map[string]string) []string {
+	var known, fresh []string
+	for key := range data {
+		if _, ok := seen[key]; ok {
+			known = append(known, key)
+		} else {
+			fresh = append(fresh, key)
+		}
+	}
+	sort.Strings(fresh)
+	return append(known, fresh...)
+}
+
+// View satisfies tea.Model.
+func (m *telemetryModel) View() string {
+	var b strings.Builder
+
+	title := "Telemetry"
+	if m.robot != "" {
+		title += " · " + m.robot
+	}
+	b.WriteString(titleStyle.Render(fit(title, textWidth(m.width))))
+	b.WriteString("\n\n")
+
+	b.WriteString("  " + m.viewStatus() + "\n")
+	if m.path != "" {
+		line := fmt.Sprintf("Recording to %s, %d packets", m.path, m.packets)
+		if m.recordErr != nil {
+			b.WriteString("  " + errStyle.Render(fit("Recording stopped: "+m.recordErr.Error(), textWidth(m.width))) + "\n")
+		} else {
+			b.WriteString("  " + helpStyle.Render(fit(line, textWidth(m.width))) + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if len(m.keys) == 0 {
+		b.WriteString("  " + unsetStyle.Render("No telemetry yet. It arrives while an OpMode runs.") + "\n")
+	}
+
+	keyWidth := 0
+	for _, key := range m.keys {
+		if len(key) > keyWidth {
+			keyWidth = len(key)
+		}
+	}
+	if keyWidth > 28 {
+		keyWidth = 28
+	}
+
+	const valueWidth = 14
+	sparkRoom := textWidth(m.width) - keyWidth - valueWidth - 4
+	if sparkRoom > sparkLength {
+		sparkRoom = sparkLength
+	}
+
+	for _, key := range m.keys {
+		value := fit(m.values[key], valueWidth)
+		row := fit(fmt.Sprintf("%-*s  %-*s", keyWidth, fit(key, keyWidth), valueWidth, value), textWidth(m.width))
+
+		if sparkRoom > 0 && len(m.history[key]) > 1 {
+			row += "  " + scrollStyle.Render(sparkline(m.history[key], sparkRoom))
+		}
+
+		if !m.latest[key] {
+			row = unsetStyle.Render(row)
+		}
+		b.WriteString("  " + row + "\n")
+	}
+
+	if len(m.log) > 0 {
+		b.WriteString("\n")
+		for _, line := range m.log {
+			b.WriteString("  " + helpStyle.Render(fit(line, textWidth(m.width))) + "\n")
+		}
+	}
+
+	b.WriteString("\n" + helpStyle.Render("  c clear history · q quit") + "\n")
+
+	return clamp(b.String(), m.width, m.height)
+}
+
+func (m *telemetryModel) viewStatus() string {
+	switch {
+	case m.status == nil:
+		return unsetStyle.Render("Waiting for the robot…")
+	case m.status.Error != "":
+		return errStyle.Render(fit("! "+m.status.Error, textWidth(m.width)))
+	case m.status.OpMode == "" || m.status.OpMode == "$Stop$Robot$":
+		return unsetStyle.Render("No OpMode running")
+	}
+
+	line := m.status.OpMode + "  " + strings.ToLower(m.status.State)
+	if m.status.Warning != "" {
+		line += "  " + errStyle.Render(m.status.Warning)
+	}
+	return okStyle.Render(fit(line, textWidth(m.width)))
+}
+
+// sparkline draws samples scaled between their own smallest and largest, using
+// at most width of the most recent.
+func sparkline(samples []float64, width int) string {
+	if width < 1 || len(samples) == 0 {
+		return ""
+	}
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+
+	low, high := samples[0], samples[0]
+	for _, sample := range samples {
+		if sample < low {
+			low = sample
+		}
+		if sample > high {
+			high = sample
+		}
+	}
+
+	// Clamped all the same: a range wide enough to overflow, high minus low
+	// being +Inf, would otherwise make a level that indexes nothing.
+	top := len(sparkBars) - 1
+	out := make([]rune, len(samples))
+	for i, sample := range samples {
+		level := 0
+		if high > low {
+			level = int((sample - low) / (high - low) * float64(top))
+		}
+		out[i] = sparkBars[max(0, min(level, top))]
+	}
+	return string(out)
+}
@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreibanu/pusher/internal/dash"
+)
+
+func TestASparklineSpansItsOwnRange(t *testing.T) {
+	if got := sparkline([]float64{0, 1, 2, 3, 4, 5, 6, 7}, 10); got != "▁▂▃▄▅▆▇█" {
+		t.Errorf("rising = %q", got)
+	}
+	if got := sparkline([]float64{5, 5, 5}, 10); got != "▁▁▁" {
+		t.Errorf("flat = %q", got)
+	}
+	if got := sparkline([]float64{0, 0, 0, 7}, 2); got != "▁█" {
+		t.Errorf("narrow = %q, want only the most recent samples", got)
+	}
+}
+
+// A division by zero in an OpMode sends NaN or Inf. Kept, either would stretch
+// the range to nothing or index past the last bar.
+func TestNaNAndInfinityAreNotDrawn(t *testing.T) {
+	m := newTelemetryModel(nil, nil, "robot", "")
+	for _, value := range []string{"1", "NaN", "+Inf", "-Inf", "2"} {
+		m.apply(dash.Update{Packets: []dash.Packet{{Data: map[string]string{"n": value}}}})
+	}
+
+	if got := m.history["n"]; len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("history = %v, want only the finite samples", got)
+	}
+	if m.values["n"] != "2" {
+		t.Errorf("value = %q, want the text shown as it came", m.values["n"])
+	}
+}
+
+func TestASparklineOverTheWholeFloatRangeStaysInBounds(t *testing.T) {
+	got := sparkline([]float64{-math.MaxFloat64, 0, math.MaxFloat64}, 10)
+	if len([]rune(got)) != 3 {
+		t.Errorf("extremes = %q", got)
+	}
+}
+
+// Lines keep the place they first appeared in, so nothing on screen jumps when
+// an OpMode adds a key partway through.
+func TestTelemetryKeepsItsLinesInPlace(t *testing.T) {
+	m := newTelemetryModel(nil, nil, "robot", "")
+	at := time.Now()
+
+	m.apply(dash.Update{Packets: []dash.Packet{{Time: at, Data: map[string]string{"b": "1", "a": "x"}}}})
+	m.apply(dash.Update{Packets: []dash.Packet{{Time: at, Data: map[string]string{"0": "2", "b": "2"}}}})
+
+	if got := strings.Join(m.keys, ","); got != "a,b,0" {
+		t.Errorf("keys = %s", got)
+	}
+	if m.latest["a"] {
+		t.Error("a key the last packet did not have is still shown as current")
+	}
+	if len(m.history["b"]) != 2 || len(m.history["a"]) != 0 {
+		t.Errorf("history = %v, want only numbers kept", m.history)
+	}
+}
+
+func TestHistoryIsCapped(t *testing.T) {
+	m := newTelemetryModel(nil, nil, "", "")
+	for i := 0; i < sparkLength*2; i++ {
+		m.apply(dash.Update{Packets: []dash.Packet{{Data: map[string]string{"n": "1"}}}})
+	}
+	if len(m.history["n"]) != sparkLength {
+		t.Errorf("%d samples kept, want %d", len(m.history["n"]), sparkLength)
+	}
+}